@@ -0,0 +1,289 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: transcation/v1/transcation.proto
+
+package transcationv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// TranscationServiceClient is the client API for TranscationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TranscationServiceClient interface {
+	// CreateAccount create account with document number.
+	CreateAccount(ctx context.Context, in *CreateAccountRequest, opts ...grpc.CallOption) (*CreateAccountResponse, error)
+	// GetAccount get account details via account id.
+	GetAccount(ctx context.Context, in *GetAccountRequest, opts ...grpc.CallOption) (*GetAccountResponse, error)
+	// CreateTranscation add new transcation for given account id.
+	CreateTranscation(ctx context.Context, in *CreateTranscationRequest, opts ...grpc.CallOption) (*CreateTranscationResponse, error)
+	// ListTranscations list the transcations of an account, oldest first.
+	ListTranscations(ctx context.Context, in *ListTranscationsRequest, opts ...grpc.CallOption) (*ListTranscationsResponse, error)
+	// StreamTranscations streams transcations posted to an account after the
+	// stream was opened, or after since when it is set.
+	StreamTranscations(ctx context.Context, in *StreamTranscationsRequest, opts ...grpc.CallOption) (TranscationService_StreamTranscationsClient, error)
+}
+
+type transcationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTranscationServiceClient(cc grpc.ClientConnInterface) TranscationServiceClient {
+	return &transcationServiceClient{cc}
+}
+
+func (c *transcationServiceClient) CreateAccount(ctx context.Context, in *CreateAccountRequest, opts ...grpc.CallOption) (*CreateAccountResponse, error) {
+	out := new(CreateAccountResponse)
+	err := c.cc.Invoke(ctx, "/transcation.v1.TranscationService/CreateAccount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transcationServiceClient) GetAccount(ctx context.Context, in *GetAccountRequest, opts ...grpc.CallOption) (*GetAccountResponse, error) {
+	out := new(GetAccountResponse)
+	err := c.cc.Invoke(ctx, "/transcation.v1.TranscationService/GetAccount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transcationServiceClient) CreateTranscation(ctx context.Context, in *CreateTranscationRequest, opts ...grpc.CallOption) (*CreateTranscationResponse, error) {
+	out := new(CreateTranscationResponse)
+	err := c.cc.Invoke(ctx, "/transcation.v1.TranscationService/CreateTranscation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transcationServiceClient) ListTranscations(ctx context.Context, in *ListTranscationsRequest, opts ...grpc.CallOption) (*ListTranscationsResponse, error) {
+	out := new(ListTranscationsResponse)
+	err := c.cc.Invoke(ctx, "/transcation.v1.TranscationService/ListTranscations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transcationServiceClient) StreamTranscations(ctx context.Context, in *StreamTranscationsRequest, opts ...grpc.CallOption) (TranscationService_StreamTranscationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TranscationService_ServiceDesc.Streams[0], "/transcation.v1.TranscationService/StreamTranscations", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &transcationServiceStreamTranscationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TranscationService_StreamTranscationsClient interface {
+	Recv() (*StreamTranscationsResponse, error)
+	grpc.ClientStream
+}
+
+type transcationServiceStreamTranscationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *transcationServiceStreamTranscationsClient) Recv() (*StreamTranscationsResponse, error) {
+	m := new(StreamTranscationsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TranscationServiceServer is the server API for TranscationService service.
+// All implementations must embed UnimplementedTranscationServiceServer
+// for forward compatibility
+type TranscationServiceServer interface {
+	// CreateAccount create account with document number.
+	CreateAccount(context.Context, *CreateAccountRequest) (*CreateAccountResponse, error)
+	// GetAccount get account details via account id.
+	GetAccount(context.Context, *GetAccountRequest) (*GetAccountResponse, error)
+	// CreateTranscation add new transcation for given account id.
+	CreateTranscation(context.Context, *CreateTranscationRequest) (*CreateTranscationResponse, error)
+	// ListTranscations list the transcations of an account, oldest first.
+	ListTranscations(context.Context, *ListTranscationsRequest) (*ListTranscationsResponse, error)
+	// StreamTranscations streams transcations posted to an account after the
+	// stream was opened, or after since when it is set.
+	StreamTranscations(*StreamTranscationsRequest, TranscationService_StreamTranscationsServer) error
+	mustEmbedUnimplementedTranscationServiceServer()
+}
+
+// UnimplementedTranscationServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedTranscationServiceServer struct {
+}
+
+func (UnimplementedTranscationServiceServer) CreateAccount(context.Context, *CreateAccountRequest) (*CreateAccountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAccount not implemented")
+}
+func (UnimplementedTranscationServiceServer) GetAccount(context.Context, *GetAccountRequest) (*GetAccountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccount not implemented")
+}
+func (UnimplementedTranscationServiceServer) CreateTranscation(context.Context, *CreateTranscationRequest) (*CreateTranscationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTranscation not implemented")
+}
+func (UnimplementedTranscationServiceServer) ListTranscations(context.Context, *ListTranscationsRequest) (*ListTranscationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTranscations not implemented")
+}
+func (UnimplementedTranscationServiceServer) StreamTranscations(*StreamTranscationsRequest, TranscationService_StreamTranscationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTranscations not implemented")
+}
+func (UnimplementedTranscationServiceServer) mustEmbedUnimplementedTranscationServiceServer() {}
+
+// UnsafeTranscationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TranscationServiceServer will
+// result in compilation errors.
+type UnsafeTranscationServiceServer interface {
+	mustEmbedUnimplementedTranscationServiceServer()
+}
+
+func RegisterTranscationServiceServer(s grpc.ServiceRegistrar, srv TranscationServiceServer) {
+	s.RegisterService(&TranscationService_ServiceDesc, srv)
+}
+
+func _TranscationService_CreateAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranscationServiceServer).CreateAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/transcation.v1.TranscationService/CreateAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranscationServiceServer).CreateAccount(ctx, req.(*CreateAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranscationService_GetAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranscationServiceServer).GetAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/transcation.v1.TranscationService/GetAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranscationServiceServer).GetAccount(ctx, req.(*GetAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranscationService_CreateTranscation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTranscationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranscationServiceServer).CreateTranscation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/transcation.v1.TranscationService/CreateTranscation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranscationServiceServer).CreateTranscation(ctx, req.(*CreateTranscationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranscationService_ListTranscations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTranscationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranscationServiceServer).ListTranscations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/transcation.v1.TranscationService/ListTranscations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranscationServiceServer).ListTranscations(ctx, req.(*ListTranscationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranscationService_StreamTranscations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTranscationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TranscationServiceServer).StreamTranscations(m, &transcationServiceStreamTranscationsServer{stream})
+}
+
+type TranscationService_StreamTranscationsServer interface {
+	Send(*StreamTranscationsResponse) error
+	grpc.ServerStream
+}
+
+type transcationServiceStreamTranscationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *transcationServiceStreamTranscationsServer) Send(m *StreamTranscationsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// TranscationService_ServiceDesc is the grpc.ServiceDesc for TranscationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TranscationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "transcation.v1.TranscationService",
+	HandlerType: (*TranscationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateAccount",
+			Handler:    _TranscationService_CreateAccount_Handler,
+		},
+		{
+			MethodName: "GetAccount",
+			Handler:    _TranscationService_GetAccount_Handler,
+		},
+		{
+			MethodName: "CreateTranscation",
+			Handler:    _TranscationService_CreateTranscation_Handler,
+		},
+		{
+			MethodName: "ListTranscations",
+			Handler:    _TranscationService_ListTranscations_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTranscations",
+			Handler:       _TranscationService_StreamTranscations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "transcation/v1/transcation.proto",
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/fraud"
+	"github.com/madhurikadam/app-transcation/internal/metrics"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/internal/publisher/kafka"
+	"github.com/madhurikadam/app-transcation/internal/service"
+	"github.com/madhurikadam/app-transcation/pkg/health"
+	log "github.com/sirupsen/logrus"
+)
+
+// app holds the repository and the services every command shares
+type app struct {
+	repo         repository
+	transcations service.TranscationService
+	webhooks     service.WebhookService
+	apiKeys      service.APIKeyService
+	// metrics holds the metrics of the services and of the storage
+	metrics *prometheus.Registry
+	// health holds the readiness checks of the storage and of the outbox
+	health *health.Checker
+
+	close func()
+}
+
+// newApp connects to the configured storage and builds the services. Callers
+// are authorized when authorize is set, operator commands aren't: they act
+// with the rights of whoever may reach the database.
+func newApp(ctx context.Context, authorize bool) (*app, error) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	checker := health.New(cfg.ReadinessTimeout)
+
+	repo, closeRepo, err := initRepo(ctx, registry, checker)
+	if err != nil {
+		return nil, err
+	}
+
+	// the outbox is shared by every replica, a lagging relay degrades them
+	// rather than taking them all out at once
+	if cfg.OutboxMaxLag > 0 {
+		checker.AddDegrading("outbox", service.CheckOutboxLag(repo, cfg.OutboxMaxLag))
+	}
+
+	transcationMetrics, err := metrics.NewTranscations(registry)
+	if err != nil {
+		closeRepo()
+		return nil, err
+	}
+
+	if err := registry.Register(metrics.NewOutbox(repo)); err != nil {
+		closeRepo()
+		return nil, err
+	}
+
+	svcOpts := make([]service.Option, 0)
+	if authorize {
+		// denials go to the audit log of the storages keeping one
+		var recorder policy.Recorder = policy.LogRecorder{}
+		if audited, ok := repo.(policy.Recorder); ok {
+			recorder = policy.Recorders{recorder, audited}
+		}
+
+		svcOpts = append(svcOpts, service.WithAuthorizer(policy.New(recorder)))
+	}
+
+	transcationOpts := append([]service.Option{
+		service.WithVelocityLimits(domain.VelocityLimits{
+			MaxDebitsPerMinute:   cfg.VelocityMaxDebitsPerMinute,
+			MaxDebitAmountPerDay: cfg.VelocityMaxDebitAmountPerDay,
+		}),
+		service.WithReviewConfig(service.ReviewConfig{
+			SLA:             cfg.ReviewSLA,
+			TimeoutDecision: cfg.ReviewTimeoutDecision,
+			Interval:        cfg.ReviewPollInterval,
+		}),
+		service.WithMetrics(transcationMetrics),
+	}, svcOpts...)
+	if cfg.FraudRulesFile != "" {
+		rules, err := fraud.LoadRules(cfg.FraudRulesFile)
+		if err != nil {
+			closeRepo()
+			return nil, err
+		}
+
+		log.WithField("rules", len(rules)).Info("evaluating transcations against fraud rules")
+		transcationOpts = append(transcationOpts, service.WithFraudChecker(fraud.New(rules, repo, repo)))
+	}
+
+	return &app{
+		repo:         repo,
+		transcations: service.New(repo, transcationOpts...),
+		webhooks: service.NewWebhookService(repo, nil, service.WebhookConfig{
+			MaxAttempts: cfg.WebhookMaxAttempts,
+			Backoff:     cfg.WebhookBackoff,
+			MaxBackoff:  cfg.WebhookMaxBackoff,
+			Timeout:     cfg.WebhookTimeout,
+			Interval:    cfg.WebhookPollInterval,
+			BatchSize:   cfg.WebhookBatchSize,
+
+			AllowPrivateAddresses: cfg.WebhookAllowPrivateAddresses,
+		}, svcOpts...),
+		apiKeys: service.NewAPIKeyService(repo, svcOpts...),
+		metrics: registry,
+		health:  checker,
+		close:   closeRepo,
+	}, nil
+}
+
+// startJobs runs the background jobs in g until ctx is done: the outbox relay,
+// the webhook deliveries and the review timeouts. The returned func releases
+// the publishers once g is done.
+func (a *app) startJobs(ctx context.Context, g *errgroup.Group) func() {
+	closePublishers := func() {}
+
+	publishers := []service.Publisher{&a.webhooks}
+	if len(cfg.KafkaBrokers) > 0 {
+		log.WithField("brokers", cfg.KafkaBrokers).Info("publishing events to kafka")
+
+		writer := kafka.NewWriter(cfg.KafkaBrokers)
+		closePublishers = func() {
+			if err := writer.Close(); err != nil {
+				log.WithError(err).Error("failed to close the kafka writer")
+			}
+		}
+
+		kafkaPublisher := kafka.NewPublisher(writer, cfg.KafkaTopicPrefix)
+		publishers = append(publishers, &kafkaPublisher)
+	}
+
+	relay := service.NewRelay(a.repo, cfg.OutboxPollInterval, cfg.OutboxBatchSize, publishers...)
+
+	g.Go(func() error {
+		return relay.Run(ctx)
+	})
+
+	g.Go(func() error {
+		return a.webhooks.Run(ctx)
+	})
+
+	g.Go(func() error {
+		return a.transcations.RunReviewTimeouts(ctx)
+	})
+
+	return closePublishers
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/madhurikadam/app-transcation/internal/audit"
+	"github.com/madhurikadam/app-transcation/internal/database/postgres"
+	postgresPkg "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+	log "github.com/sirupsen/logrus"
+)
+
+// verifyAuditLog checks the hash chain of the audit log, it prints the report
+// and fails when the chain is broken
+func verifyAuditLog(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("verify-audit", flag.ContinueOnError)
+	output := outputFlag(flags)
+
+	rest, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) > 0 {
+		return errors.New("usage: verify-audit")
+	}
+
+	pgxPool, err := postgresPkg.Wait(ctx, cfg.Config, nil)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection to postgresPkg: %w", err)
+	}
+	defer pgxPool.Close()
+
+	repo := postgres.NewRepo(pgxPool, postgres.SetupPSQL())
+
+	report, err := audit.Verify(ctx, &repo, 0)
+	if err != nil {
+		return err
+	}
+
+	err = render(*output, report, func(w io.Writer) {
+		fmt.Fprintf(w, "%d entries up to seq %d, last hash %s\n", report.Entries, report.LastSeq, report.LastHash)
+		if report.OK() {
+			return
+		}
+
+		fmt.Fprintln(w, "\nSEQ\tREASON")
+		for _, b := range report.Breaks {
+			fmt.Fprintf(w, "%d\t%s\n", b.Seq, b.Reason)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if !report.OK() {
+		return fmt.Errorf("audit log hash chain is broken at %d entries", len(report.Breaks))
+	}
+
+	log.WithField("entries", report.Entries).Info("audit log hash chain holds")
+
+	return nil
+}
@@ -4,11 +4,98 @@ package configuration, keep  environment, configuration parameter here in this p
 
 package configuration
 
-import "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+import (
+	"time"
+
+	"github.com/madhurikadam/app-transcation/pkg/database/postgres"
+)
 
 type Config struct {
 	postgres.Config
 
 	HTTPPort       int    `envconfig:"HTTP_PORT" default:"8080"`
+	GRPCPort       int    `envconfig:"GRPC_PORT" default:"9090"`
 	AllowedOrigins string `envconfig:"ALLOWED_ORIGINS" default:"*"`
+
+	// LogFormat is json or text, LogLevel the least severe level logged
+	LogFormat string `envconfig:"LOG_FORMAT" default:"json"`
+	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
+
+	// Storage is where the data is kept, postgres or memory. The memory
+	// storage loses everything on exit, it is meant for local development.
+	Storage string `envconfig:"STORAGE" default:"postgres"`
+
+	// AutoMigrate applies the pending migrations on start, turn it off when
+	// they are applied with the migrate command
+	AutoMigrate bool `envconfig:"AUTO_MIGRATE" default:"true"`
+
+	// HTTPWriteTimeout bounds the non streaming http routes, streaming routes
+	// stay open until the client leaves or the server shuts down
+	HTTPWriteTimeout time.Duration `envconfig:"HTTP_WRITE_TIMEOUT" default:"5s"`
+
+	// ShutdownDrainDelay is how long readiness fails before the servers shut
+	// down, time for the load balancer to stop sending requests
+	ShutdownDrainDelay time.Duration `envconfig:"SHUTDOWN_DRAIN_DELAY" default:"5s"`
+	// ReadinessTimeout bounds the checks of a readiness probe, and
+	// OutboxMaxLag is how long an event may wait for the relay before the
+	// service is reported degraded, 0 leaves the outbox out of readiness
+	ReadinessTimeout time.Duration `envconfig:"READINESS_TIMEOUT" default:"2s"`
+	OutboxMaxLag     time.Duration `envconfig:"OUTBOX_MAX_LAG" default:"1m"`
+
+	// AuthDisabled leaves the apis open, meant for local development only
+	AuthDisabled bool `envconfig:"AUTH_DISABLED" default:"false"`
+	// AuthJWKSFile or AuthJWKSURL provide the keys access tokens are signed with
+	AuthJWKSFile    string        `envconfig:"AUTH_JWKS_FILE"`
+	AuthJWKSURL     string        `envconfig:"AUTH_JWKS_URL"`
+	AuthJWKSRefresh time.Duration `envconfig:"AUTH_JWKS_REFRESH" default:"1h"`
+	AuthIssuer      string        `envconfig:"AUTH_ISSUER"`
+	AuthAudience    string        `envconfig:"AUTH_AUDIENCE"`
+	AuthClockSkew   time.Duration `envconfig:"AUTH_CLOCK_SKEW" default:"30s"`
+
+	// RateLimitPerSecond and RateLimitBurst size the token bucket of every
+	// http client, a zero rate disables rate limiting
+	RateLimitPerSecond float64 `envconfig:"RATE_LIMIT_PER_SECOND" default:"10"`
+	RateLimitBurst     int     `envconfig:"RATE_LIMIT_BURST" default:"20"`
+	// RateLimitAddrPerSecond and RateLimitAddrBurst size the token bucket of
+	// every client address, checked before authentication so that failing
+	// credentials are limited too, a zero rate disables it
+	RateLimitAddrPerSecond float64 `envconfig:"RATE_LIMIT_ADDR_PER_SECOND" default:"50"`
+	RateLimitAddrBurst     int     `envconfig:"RATE_LIMIT_ADDR_BURST" default:"100"`
+
+	// VelocityMaxDebitsPerMinute and VelocityMaxDebitAmountPerDay bound how
+	// fast an account spends, zero disables a limit
+	VelocityMaxDebitsPerMinute   int     `envconfig:"VELOCITY_MAX_DEBITS_PER_MINUTE" default:"0"`
+	VelocityMaxDebitAmountPerDay float64 `envconfig:"VELOCITY_MAX_DEBIT_AMOUNT_PER_DAY" default:"0"`
+
+	// FraudRulesFile holds the fraud rules transcations are evaluated against,
+	// transcations are not checked when it is empty
+	FraudRulesFile string `envconfig:"FRAUD_RULES_FILE"`
+
+	// transcations flagged for review are decided with ReviewTimeoutDecision,
+	// approved or rejected, when no operator did within ReviewSLA
+	ReviewSLA             time.Duration `envconfig:"REVIEW_SLA" default:"24h"`
+	ReviewTimeoutDecision string        `envconfig:"REVIEW_TIMEOUT_DECISION" default:"rejected"`
+	ReviewPollInterval    time.Duration `envconfig:"REVIEW_POLL_INTERVAL" default:"1m"`
+
+	OutboxPollInterval time.Duration `envconfig:"OUTBOX_POLL_INTERVAL" default:"1s"`
+	OutboxBatchSize    int           `envconfig:"OUTBOX_BATCH_SIZE" default:"100"`
+
+	WebhookPollInterval time.Duration `envconfig:"WEBHOOK_POLL_INTERVAL" default:"1s"`
+	WebhookBatchSize    int           `envconfig:"WEBHOOK_BATCH_SIZE" default:"50"`
+	WebhookTimeout      time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"5s"`
+	WebhookMaxAttempts  int           `envconfig:"WEBHOOK_MAX_ATTEMPTS" default:"8"`
+	WebhookBackoff      time.Duration `envconfig:"WEBHOOK_BACKOFF" default:"10s"`
+	WebhookMaxBackoff   time.Duration `envconfig:"WEBHOOK_MAX_BACKOFF" default:"1h"`
+	// WebhookAllowPrivateAddresses lets webhook endpoints be on loopback,
+	// private and link-local addresses, meant for local development only
+	WebhookAllowPrivateAddresses bool `envconfig:"WEBHOOK_ALLOW_PRIVATE_ADDRESSES" default:"false"`
+
+	// KafkaBrokers enables publishing events to Kafka when set
+	KafkaBrokers     []string `envconfig:"KAFKA_BROKERS"`
+	KafkaTopicPrefix string   `envconfig:"KAFKA_TOPIC_PREFIX" default:"app-transcation"`
+
+	// TracingExporter sends spans to stdout or to the OTLP collector of the
+	// OTEL_EXPORTER_OTLP_* variables, none disables tracing
+	TracingExporter    string  `envconfig:"TRACING_EXPORTER" default:"none"`
+	TracingSampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
 }
@@ -0,0 +1,164 @@
+// Command loadtest drives the http api of a running service with transcations
+// and account reads and reports latencies, errors by status and the
+// invariants that broke. It exits with 1 when any did.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/madhurikadam/app-transcation/internal/loadtest"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func main() {
+	cfg, output, err := parseFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.WithFields(log.Fields{
+		"url":         cfg.BaseURL,
+		"accounts":    cfg.Accounts,
+		"concurrency": cfg.Concurrency,
+		"rate":        cfg.Rate,
+		"mix":         cfg.Mix.String(),
+		"skew":        cfg.Skew,
+	}).Info("starting load test")
+
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost: cfg.Concurrency,
+		},
+	}
+
+	report, err := loadtest.Run(ctx, cfg, httpClient)
+	if err != nil {
+		log.WithError(err).Fatal("load test failed")
+	}
+
+	if err := write(os.Stdout, output, report); err != nil {
+		log.WithError(err).Fatal("failed to write report")
+	}
+
+	if len(report.Violations) > 0 {
+		os.Exit(1)
+	}
+}
+
+func parseFlags(args []string) (loadtest.Config, string, error) {
+	var cfg loadtest.Config
+
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	flags.StringVar(&cfg.BaseURL, "url", "http://localhost:8080", "base url of the http api")
+	flags.StringVar(&cfg.Authorization, "authorization", os.Getenv("LOADTEST_AUTHORIZATION"), "Authorization header of the requests, LOADTEST_AUTHORIZATION by default")
+	flags.IntVar(&cfg.Accounts, "accounts", 100, "number of accounts the load is spread over")
+	flags.IntVar(&cfg.Concurrency, "concurrency", 10, "number of requests in flight")
+	flags.Float64Var(&cfg.Rate, "rate", 0, "requests per second, 0 sends them as fast as the concurrency allows")
+	flags.DurationVar(&cfg.Duration, "duration", 30*time.Second, "how long the load lasts, 0 runs until --requests are sent")
+	flags.IntVar(&cfg.Requests, "requests", 0, "number of requests to send, 0 runs for --duration")
+	mix := flags.String("mix", "1=45,2=10,3=10,4=15,read=20", "weights of the operation types posted and of account reads")
+	flags.Float64Var(&cfg.Skew, "skew", 0, "zipf exponent of the accounts picked, above 1 sends most of the load to a few hot accounts, 0 spreads it evenly")
+	flags.Float64Var(&cfg.WithdrawalLimit, "withdrawal-limit", 100000, "withdrawal limit the accounts start with")
+	flags.Float64Var(&cfg.CreditLimit, "credit-limit", 100000, "credit limit the accounts start with")
+	flags.Float64Var(&cfg.MinAmount, "min-amount", 1, "smallest amount of a transcation")
+	flags.Float64Var(&cfg.MaxAmount, "max-amount", 500, "largest amount of a transcation")
+	flags.Int64Var(&cfg.Seed, "seed", time.Now().UnixNano(), "seed of the document numbers, operations and amounts")
+	output := flags.String("output", outputTable, "format of the report, table or json")
+
+	if err := flags.Parse(args); err != nil {
+		return cfg, "", err
+	}
+
+	if flags.NArg() > 0 {
+		return cfg, "", fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	if *output != outputTable && *output != outputJSON {
+		return cfg, "", fmt.Errorf("invalid output %q, expected %s or %s", *output, outputTable, outputJSON)
+	}
+
+	var err error
+	if cfg.Mix, err = loadtest.ParseMix(*mix); err != nil {
+		return cfg, "", err
+	}
+
+	return cfg, *output, nil
+}
+
+func write(w io.Writer, output string, report *loadtest.Report) error {
+	if output == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(report)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "duration\t%s\n", report.Duration.Round(time.Millisecond))
+	fmt.Fprintf(tw, "requests\t%d\n", report.Requests)
+	fmt.Fprintf(tw, "throughput\t%.1f/s\n\n", report.Throughput)
+
+	ops := make([]string, 0, len(report.Operations))
+	for op := range report.Operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	fmt.Fprintln(tw, "OPERATION\tREQUESTS\tP50\tP90\tP99\tMAX\tSTATUSES")
+	for _, op := range ops {
+		o := report.Operations[op]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", op, o.Requests,
+			o.Latency.P50.Round(time.Microsecond), o.Latency.P90.Round(time.Microsecond),
+			o.Latency.P99.Round(time.Microsecond), o.Latency.Max.Round(time.Microsecond), statuses(o.ByStatus))
+	}
+
+	fmt.Fprintf(tw, "\nviolations\t%d\n", len(report.Violations))
+	for _, v := range report.Violations {
+		fmt.Fprintf(tw, "  %s\t%s\n", v.AccountID, v.Message)
+	}
+
+	return tw.Flush()
+}
+
+// statuses lists the counts by status in order, 0 are the requests without a
+// response
+func statuses(byStatus map[int]int) string {
+	codes := make([]int, 0, len(byStatus))
+	for code := range byStatus {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	s := ""
+	for i, code := range codes {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%d:%d", code, byStatus[code])
+	}
+
+	return s
+}
@@ -5,105 +5,350 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/kelseyhightower/envconfig"
-	"golang.org/x/sync/errgroup"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
+	transcationv1 "github.com/madhurikadam/app-transcation/api/transcation/v1"
 	"github.com/madhurikadam/app-transcation/cmd/configuration"
+	"github.com/madhurikadam/app-transcation/internal/database/memory"
 	"github.com/madhurikadam/app-transcation/internal/database/postgres"
+	"github.com/madhurikadam/app-transcation/internal/fraud"
+	grpcGW "github.com/madhurikadam/app-transcation/internal/gateway/grpc"
 	httpGW "github.com/madhurikadam/app-transcation/internal/gateway/http"
 	"github.com/madhurikadam/app-transcation/internal/service"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
 	postgresPkg "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+	grpcPkg "github.com/madhurikadam/app-transcation/pkg/grpc"
+	"github.com/madhurikadam/app-transcation/pkg/health"
 	httpPkg "github.com/madhurikadam/app-transcation/pkg/http"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+	"github.com/madhurikadam/app-transcation/pkg/requestid"
+	"github.com/madhurikadam/app-transcation/pkg/tracing"
 	log "github.com/sirupsen/logrus"
 )
 
 const ServiceName = "app-transcation"
 
+// storages the data may be kept in
+const (
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
+)
+
 var cfg configuration.Config
 
+// commands of the binary, serve runs when none is given
+var commands = []struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}{
+	{name: "serve", usage: "run the http and grpc apis and the background jobs", run: serve},
+	{name: "worker", usage: "run the background jobs only", run: worker},
+	{name: "migrate", usage: "manage the database migrations", run: runMigrate},
+	{name: "seed", usage: "generate account histories for load tests and demos", run: runSeed},
+	{name: "reconcile", usage: "compare the tables with the event history, --rebuild fixes them", run: runReconcile},
+	{name: "verify-audit", usage: "check the hash chain of the audit log", run: verifyAuditLog},
+	{name: "account", usage: "create or show an account", run: runAccount},
+	{name: "tx", usage: "post or list transcations", run: runTx},
+}
+
 func main() {
 
 	// Parse the config from the environment, exiting on error
 	if err := envconfig.Process("", &cfg); err != nil {
-		log.Error("failed to parse config from environment", err)
-		panic(err)
+		log.WithError(err).Fatal("failed to parse config from environment")
+	}
+
+	if err := logging.Setup(cfg.LogFormat, cfg.LogLevel); err != nil {
+		log.WithError(err).Fatal("failed to set up the log")
 	}
 
-	log.Info(fmt.Sprintf("starting %s service", ServiceName))
-	ctx := context.Background()
+	// commands stop gracefully on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	pgxPool, err := initPostgres(ctx)
-	if err != nil {
-		panic(err)
+	if err := runCommand(ctx, os.Args[1:]); err != nil {
+		log.WithError(err).Fatal("command failed")
 	}
-	defer pgxPool.Close()
+}
 
-	repo := postgres.NewRepo(pgxPool, postgres.SetupPSQL())
+// runCommand runs the command given on the command line, the service when
+// there is none
+func runCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return serve(ctx, nil)
+	}
 
-	transcationSvc := service.New(&repo)
+	// projection is the former name of reconcile
+	if args[0] == "projection" {
+		if len(args) != 2 || (args[1] != "compare" && args[1] != "rebuild") {
+			return errors.New("usage: projection compare|rebuild")
+		}
 
-	errGroup, ctx := errgroup.WithContext(ctx)
+		return runProjection(ctx, args[1], outputJSON)
+	}
 
-	httpSvr, err := initHttpServer(&transcationSvc)
-	if err != nil {
-		panic(err)
+	for _, command := range commands {
+		if command.name == args[0] {
+			return command.run(ctx, args[1:])
+		}
 	}
 
-	errGroup.Go(func() error {
-		<-ctx.Done()
-		tCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-		defer cancel()
+	if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+		return fmt.Errorf("unknown command %q, run help to list the commands", args[0])
+	}
 
-		log.Info("attempting http server shutdown")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "usage: %s <command> [arguments]\n\n", ServiceName)
+	for _, command := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", command.name, command.usage)
+	}
 
-		return httpSvr.Shutdown(tCtx)()
-	})
+	return w.Flush()
+}
+
+// repository is everything the service keeps, it is implemented by every
+// storage
+type repository interface {
+	service.Repo
+	service.APIKeyRepo
+	service.WebhookRepo
+	service.OutboxRepo
+	fraud.Facts
+	fraud.Recorder
 
-	errGroup.Go(func() error {
-		err := httpSvr.ListenAndServe()
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			return err
+	ListenAccountEvents(ctx context.Context, fn func(accountID string)) error
+}
+
+// initRepo returns the repository of the configured storage and the func
+// releasing it, the metrics of the storage are registered with registerer and
+// its readiness checks added to checker
+func initRepo(ctx context.Context, registerer prometheus.Registerer, checker *health.Checker) (repository, func(), error) {
+	switch cfg.Storage {
+	case storageMemory:
+		log.Warn("keeping data in memory, it is lost when the service exits")
+		return memory.NewRepo(), func() {}, nil
+	case storagePostgres:
+		pgxPool, err := initPostgres(ctx)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		return nil
-	})
+		if err := registerer.Register(postgresPkg.NewCollector(pgxPool)); err != nil {
+			pgxPool.Close()
+			return nil, nil, err
+		}
 
-	if err := errGroup.Wait(); err != nil {
-		panic(err)
-	}
+		expectedVersion, err := postgresPkg.LatestVersion(postgres.Migrations, "migrations")
+		if err != nil {
+			pgxPool.Close()
+			return nil, nil, err
+		}
+
+		checker.Add("postgres", pgxPool.Ping)
+		checker.Add("migrations", postgresPkg.CheckMigrationVersion(pgxPool, expectedVersion))
 
+		repo := postgres.NewRepo(pgxPool, postgres.SetupPSQL())
+
+		return &repo, pgxPool.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage %q, the storages are %s and %s", cfg.Storage, storagePostgres, storageMemory)
+	}
 }
 
 func initPostgres(ctx context.Context) (*pgxpool.Pool, error) {
-	log.WithField("config", cfg).Info("connecting to postgres")
+	log.WithFields(log.Fields{
+		"host":     cfg.Host,
+		"port":     cfg.Port,
+		"database": cfg.Database,
+	}).Info("connecting to postgres")
 
 	pgxPool, err := postgresPkg.Wait(ctx, cfg.Config, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to establish connection to postgresPkg: %w", err)
 	}
 
+	if !cfg.AutoMigrate {
+		log.Info("connected, migrations are left to the migrate command")
+		return pgxPool, nil
+	}
+
 	log.Info("connected, running migrations...")
 	if err = postgresPkg.Migrate(ctx, postgres.Migrations, "migrations", cfg.PostgresDSN()); err != nil {
+		pgxPool.Close()
 		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
 
 	return pgxPool, err
 }
 
-func initHttpServer(transcationSvc *service.TranscationService) (*httpPkg.Server, error) {
+// initTracing sets up the exporter of the spans, the returned func flushes
+// the spans left
+func initTracing(ctx context.Context) (func(), error) {
+	shutdown, err := tracing.Init(ctx, tracing.Config{
+		Exporter:    cfg.TracingExporter,
+		ServiceName: ServiceName,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TracingExporter != tracing.ExporterNone {
+		log.WithField("exporter", cfg.TracingExporter).Info("exporting traces")
+	}
+
+	return func() {
+		tCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdown(tCtx); err != nil {
+			log.WithError(err).Error("failed to flush the spans")
+		}
+	}, nil
+}
+
+// initAuthenticators returns the authenticators of the apis, none when auth is
+// disabled
+func initAuthenticators(apiKeySvc *service.APIKeyService) ([]auth.Authenticator, error) {
+	if cfg.AuthDisabled {
+		log.Warn("authentication is disabled, the apis are open to anyone")
+		return nil, nil
+	}
+
+	jwtCfg := auth.JWTConfig{
+		Issuer:    cfg.AuthIssuer,
+		Audience:  cfg.AuthAudience,
+		ClockSkew: cfg.AuthClockSkew,
+	}
+	if err := jwtCfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%w, AUTH_ISSUER and AUTH_AUDIENCE are required unless AUTH_DISABLED is true", err)
+	}
+
+	var keys auth.KeySet
+	switch {
+	case cfg.AuthJWKSFile != "":
+		jwks, err := auth.LoadJWKSFile(cfg.AuthJWKSFile)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = jwks
+	case cfg.AuthJWKSURL != "":
+		keys = auth.NewRemoteJWKS(cfg.AuthJWKSURL, nil, cfg.AuthJWKSRefresh)
+	default:
+		return nil, errors.New("AUTH_JWKS_FILE or AUTH_JWKS_URL must be set unless AUTH_DISABLED is true")
+	}
+
+	return []auth.Authenticator{
+		auth.NewJWTAuthenticator(keys, jwtCfg),
+		apiKeySvc,
+	}, nil
+}
+
+func initHttpServer(transcationSvc *service.TranscationService, webhookSvc *service.WebhookService, apiKeySvc *service.APIKeyService, authenticators []auth.Authenticator, registry *prometheus.Registry, checker *health.Checker) (*httpPkg.Server, error) {
 	gw := httpGW.NewGateway(transcationSvc)
+	webhookGW := httpGW.NewWebhookGateway(webhookSvc)
+	apiKeyGW := httpGW.NewAPIKeyGateway(apiKeySvc)
+	reviewGW := httpGW.NewReviewGateway(transcationSvc)
 	router := mux.NewRouter()
 
-	router.HandleFunc("/accounts", gw.CreateAccount).Methods(http.MethodPost)
-	router.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}", gw.GetAccount).Methods(http.MethodGet)
+	// the server write timeout would cut streams, it is applied per route instead
+	server := httpPkg.New(fmt.Sprintf(":%d", cfg.HTTPPort), router, httpPkg.WithWriteTimeout(0))
+
+	metrics, err := httpPkg.NewMetrics(registry)
+	if err != nil {
+		return nil, err
+	}
+
+	router.Use(requestid.Middleware, metrics.Middleware)
 
-	router.HandleFunc("/transcations", gw.CreateTranscation).Methods(http.MethodPost)
+	// metrics are scraped without credentials, like the probes of the
+	// orchestrator
+	router.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{})).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", checker.Live).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", checker.Ready).Methods(http.MethodGet)
 
-	server := httpPkg.New(fmt.Sprintf(":%d", cfg.HTTPPort), router)
+	// the metrics scrapes and probes aren't traced nor logged, the access log
+	// goes within the span so that its lines carry the trace
+	streams := router.NewRoute().Subrouter()
+	streams.Use(httpPkg.Tracing, httpPkg.AccessLog)
+	api := router.NewRoute().Subrouter()
+	api.Use(httpPkg.Tracing, httpPkg.AccessLog, httpPkg.Timeout(cfg.HTTPWriteTimeout))
+
+	// addresses are limited before authentication, so that checking the
+	// credentials of a flood of requests is bounded too, the limiter is shared
+	// by the subrouters
+	if cfg.RateLimitAddrPerSecond > 0 {
+		byAddress := httpPkg.RateLimit(httpPkg.NewRateLimiter(cfg.RateLimitAddrPerSecond, cfg.RateLimitAddrBurst), httpPkg.ClientAddress)
+		streams.Use(byAddress)
+		api.Use(byAddress)
+	}
+
+	if len(authenticators) > 0 {
+		streams.Use(auth.Middleware(authenticators...))
+		api.Use(auth.Middleware(authenticators...))
+	}
+
+	if cfg.RateLimitPerSecond > 0 {
+		api.Use(httpPkg.RateLimit(httpPkg.NewRateLimiter(cfg.RateLimitPerSecond, cfg.RateLimitBurst), httpPkg.ClientIdentity))
+	}
+
+	streams.Handle("/accounts/{id:[-0-9a-zA-Z]+}/events", server.Streaming(http.HandlerFunc(gw.StreamAccountEvents))).Methods(http.MethodGet)
+
+	api.HandleFunc("/accounts", gw.CreateAccount).Methods(http.MethodPost)
+	api.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}", gw.GetAccount).Methods(http.MethodGet)
+	api.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}/limits", gw.UpdateAccountLimits).Methods(http.MethodPatch)
+	api.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}/transcations", gw.ListTranscations).Methods(http.MethodGet)
+
+	api.HandleFunc("/transcations", gw.CreateTranscation).Methods(http.MethodPost)
+
+	api.HandleFunc("/reviews", reviewGW.ListReviews).Methods(http.MethodGet)
+	api.HandleFunc("/reviews/{id:[-0-9a-zA-Z]+}", reviewGW.GetReview).Methods(http.MethodGet)
+	api.HandleFunc("/reviews/{id:[-0-9a-zA-Z]+}/approve", reviewGW.ApproveReview).Methods(http.MethodPost)
+	api.HandleFunc("/reviews/{id:[-0-9a-zA-Z]+}/reject", reviewGW.RejectReview).Methods(http.MethodPost)
+
+	api.HandleFunc("/webhooks", webhookGW.CreateSubscription).Methods(http.MethodPost)
+	api.HandleFunc("/webhooks", webhookGW.ListSubscriptions).Methods(http.MethodGet)
+	api.HandleFunc("/webhooks/{id:[-0-9a-zA-Z]+}", webhookGW.GetSubscription).Methods(http.MethodGet)
+	api.HandleFunc("/webhooks/{id:[-0-9a-zA-Z]+}", webhookGW.DeleteSubscription).Methods(http.MethodDelete)
+	api.HandleFunc("/webhooks/{id:[-0-9a-zA-Z]+}/deliveries", webhookGW.ListDeliveries).Methods(http.MethodGet)
+	api.HandleFunc("/webhooks/{id:[-0-9a-zA-Z]+}/deliveries/{delivery_id:[-0-9a-zA-Z]+}/redeliver", webhookGW.Redeliver).Methods(http.MethodPost)
+
+	api.HandleFunc("/api-keys", apiKeyGW.IssueKey).Methods(http.MethodPost)
+	api.HandleFunc("/api-keys", apiKeyGW.ListKeys).Methods(http.MethodGet)
+	api.HandleFunc("/api-keys/{id:[-0-9a-zA-Z]+}", apiKeyGW.GetKey).Methods(http.MethodGet)
+	api.HandleFunc("/api-keys/{id:[-0-9a-zA-Z]+}", apiKeyGW.RevokeKey).Methods(http.MethodDelete)
+	api.HandleFunc("/api-keys/{id:[-0-9a-zA-Z]+}/rotate", apiKeyGW.RotateKey).Methods(http.MethodPost)
 
 	return server, nil
 }
+
+func initGrpcServer(transcationSvc *service.TranscationService, authenticators []auth.Authenticator) *grpcPkg.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(requestid.UnaryServerInterceptor(), logging.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), logging.StreamServerInterceptor()),
+	}
+	if len(authenticators) > 0 {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(auth.UnaryServerInterceptor(authenticators...)),
+			grpc.ChainStreamInterceptor(auth.StreamServerInterceptor(authenticators...)),
+		)
+	}
+
+	server := grpcPkg.New(fmt.Sprintf(":%d", cfg.GRPCPort), opts...)
+	transcationv1.RegisterTranscationServiceServer(server, grpcGW.NewGateway(transcationSvc))
+
+	return server
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/madhurikadam/app-transcation/internal/database/postgres"
+	postgresPkg "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+)
+
+const migrateUsage = "usage: migrate [--dry-run] up|down N|goto V|status [--output json|table]|force V"
+
+// runMigrate manages the migrations of the database, with --dry-run the sql
+// of the migrations that would run is printed instead
+func runMigrate(_ context.Context, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the sql of the migrations instead of running them")
+	output := outputFlag(flags)
+
+	args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := postgresPkg.NewMigrator(postgres.Migrations, "migrations", cfg.PostgresDSN())
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(m, *output)
+	case "up":
+		if *dryRun {
+			return printMigrationPlan(m.PlanUp())
+		}
+
+		return m.Up()
+	case "down":
+		n, err := migrateArg(args)
+		if err != nil {
+			return err
+		}
+
+		if *dryRun {
+			return printMigrationPlan(m.PlanDown(n))
+		}
+
+		return m.Down(n)
+	case "goto":
+		v, err := migrateArg(args)
+		if err != nil {
+			return err
+		}
+
+		if v < 0 {
+			return fmt.Errorf("invalid version %d", v)
+		}
+
+		if *dryRun {
+			return printMigrationPlan(m.PlanGoto(uint(v)))
+		}
+
+		return m.Goto(uint(v))
+	case "force":
+		v, err := migrateArg(args)
+		if err != nil {
+			return err
+		}
+
+		if *dryRun {
+			fmt.Printf("-- would force version %d\n", v)
+			return nil
+		}
+
+		return m.Force(v)
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", args[0], migrateUsage)
+	}
+}
+
+func migrateArg(args []string) (int, error) {
+	if len(args) != 2 {
+		return 0, errors.New(migrateUsage)
+	}
+
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s argument %q: %w", args[0], args[1], err)
+	}
+
+	return n, nil
+}
+
+func printMigrationStatus(m *postgresPkg.Migrator, format string) error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	return render(format, status, func(w io.Writer) {
+		fmt.Fprintf(w, "version %d, dirty %t\n\n", status.Version, status.Dirty)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, migration := range status.Migrations {
+			fmt.Fprintf(w, "%d\t%s\t%t\n", migration.Version, migration.Name, migration.Applied)
+		}
+	})
+}
+
+func printMigrationPlan(steps []postgresPkg.MigrationStep, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if len(steps) == 0 {
+		fmt.Println("-- no change")
+		return nil
+	}
+
+	for _, step := range steps {
+		fmt.Printf("-- %d %s (%s)\n%s\n", step.Version, step.Name, step.Direction, step.SQL)
+	}
+
+	return nil
+}
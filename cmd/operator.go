@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+const (
+	accountUsage = "usage: account create --document-number N | account show ID"
+	txUsage      = "usage: tx post --account ID --operation-type T --amount A | tx list ACCOUNT_ID [--page-size N] [--page-token T]"
+)
+
+// runAccount creates and shows accounts through the service, like the api
+// does for its clients
+func runAccount(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(accountUsage)
+	}
+
+	flags := flag.NewFlagSet("account "+args[0], flag.ContinueOnError)
+	output := outputFlag(flags)
+
+	switch args[0] {
+	case "create":
+		documentNumber := flags.String("document-number", "", "document number of the account holder")
+		if _, err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+
+		return withApp(ctx, func(a *app) error {
+			account, err := a.transcations.CreateAccount(ctx, *documentNumber)
+			if err != nil {
+				return err
+			}
+
+			return renderAccount(*output, account)
+		})
+	case "show":
+		ids, err := parseFlags(flags, args[1:])
+		if err != nil {
+			return err
+		}
+
+		if len(ids) != 1 {
+			return errors.New(accountUsage)
+		}
+
+		return withApp(ctx, func(a *app) error {
+			account, err := a.transcations.GetAccount(ctx, ids[0])
+			if err != nil {
+				return err
+			}
+
+			return renderAccount(*output, account)
+		})
+	default:
+		return fmt.Errorf("unknown account command %q, %s", args[0], accountUsage)
+	}
+}
+
+// runTx posts and lists the transcations of an account through the service
+func runTx(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(txUsage)
+	}
+
+	flags := flag.NewFlagSet("tx "+args[0], flag.ContinueOnError)
+	output := outputFlag(flags)
+
+	switch args[0] {
+	case "post":
+		accountID := flags.String("account", "", "id of the account")
+		operationType := flags.Int("operation-type", 0, "1 purchase, 2 installments, 3 withdrawal, 4 credit voucher")
+		amount := flags.Float64("amount", 0, "amount of the transcation")
+		if _, err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+
+		return withApp(ctx, func(a *app) error {
+			transcation, err := a.transcations.CreateTranscation(ctx, domain.Transcation{
+				AccountID:       *accountID,
+				OperationTypeID: *operationType,
+				Amount:          *amount,
+			})
+			if err != nil {
+				return err
+			}
+
+			return renderTranscations(*output, transcation, []domain.Transcation{*transcation}, "")
+		})
+	case "list":
+		pageSize := flags.Int("page-size", 0, "number of transcations per page, the default of the api when zero")
+		pageToken := flags.String("page-token", "", "token of the page, the first page when empty")
+		ids, err := parseFlags(flags, args[1:])
+		if err != nil {
+			return err
+		}
+
+		if len(ids) != 1 {
+			return errors.New(txUsage)
+		}
+
+		return withApp(ctx, func(a *app) error {
+			page, err := a.transcations.ListTranscations(ctx, ids[0], *pageSize, *pageToken)
+			if err != nil {
+				return err
+			}
+
+			return renderTranscations(*output, page, page.Transcations, page.NextPageToken)
+		})
+	default:
+		return fmt.Errorf("unknown tx command %q, %s", args[0], txUsage)
+	}
+}
+
+// withApp runs fn with the services of an operator
+func withApp(ctx context.Context, fn func(a *app) error) error {
+	a, err := newApp(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	return fn(a)
+}
+
+func renderAccount(format string, account *domain.Account) error {
+	return render(format, account, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tDOCUMENT NUMBER\tWITHDRAWAL LIMIT\tCREDIT LIMIT\tCREATED AT")
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%.2f\t%s\n",
+			account.ID, account.DocumentNumber, account.WithdrawalLimit, account.CreaditLimit, account.CreatedAt.Format(time.RFC3339))
+	})
+}
+
+// renderTranscations prints v as json, or the transcations as a table
+// followed by the token of the next page when there is one
+func renderTranscations(format string, v interface{}, transcations []domain.Transcation, nextPageToken string) error {
+	return render(format, v, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tOPERATION TYPE\tAMOUNT\tBALANCE\tSTATUS\tEVENT AT")
+		for _, t := range transcations {
+			fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\t%s\t%s\n",
+				t.ID, t.OperationTypeID, t.Amount, t.Balance, t.Status, t.EventAt.Format(time.RFC3339))
+		}
+
+		if nextPageToken != "" {
+			fmt.Fprintf(w, "\nnext page token: %s\n", nextPageToken)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// formats of the --output flag
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// outputFlag adds the --output flag to the flags of a command
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("output", outputTable, "output format, json or table")
+}
+
+// parseFlags parses the flags given before, between or after the positional
+// arguments and returns the latter
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if output := flags.Lookup("output"); output != nil {
+		if format := output.Value.String(); format != outputTable && format != outputJSON {
+			return nil, fmt.Errorf("unknown output %q, use json or table", format)
+		}
+	}
+
+	return positional, nil
+}
+
+// render prints v to stdout as indented json or, for tables, as the rows
+// written by table
+func render(format string, v interface{}, table func(w io.Writer)) error {
+	if format == outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	table(w)
+
+	return w.Flush()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/madhurikadam/app-transcation/internal/database/postgres"
+	"github.com/madhurikadam/app-transcation/internal/projection"
+	postgresPkg "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+	"github.com/madhurikadam/app-transcation/pkg/requestid"
+	log "github.com/sirupsen/logrus"
+)
+
+type projectionReport struct {
+	Events        int                       `json:"events"`
+	LastSeq       int64                     `json:"last_seq"`
+	Accounts      int                       `json:"accounts"`
+	Discrepancies []projection.Discrepancy  `json:"discrepancies"`
+	Rebuild       *projection.RebuildResult `json:"rebuild,omitempty"`
+}
+
+// runReconcile compares the tables with the event history, with --rebuild the
+// projected limits and balances are also written where they differ
+func runReconcile(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	rebuild := flags.Bool("rebuild", false, "write the projected limits and balances where they differ")
+	output := outputFlag(flags)
+
+	rest, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) > 0 {
+		return errors.New("usage: reconcile [--rebuild]")
+	}
+
+	mode := "compare"
+	if *rebuild {
+		mode = "rebuild"
+	}
+
+	return runProjection(ctx, mode, *output)
+}
+
+// runProjection folds the event history and compares the tables with it, the
+// rebuild mode also writes the projected limits and balances where they
+// differ. Compare fails when there is any discrepancy, rebuild when some are
+// left.
+func runProjection(ctx context.Context, mode string, format string) error {
+	if mode != "compare" && mode != "rebuild" {
+		return fmt.Errorf("unknown projection mode %q, use compare or rebuild", mode)
+	}
+
+	pgxPool, err := postgresPkg.Wait(ctx, cfg.Config, nil)
+	if err != nil {
+		return fmt.Errorf("failed to establish connection to postgresPkg: %w", err)
+	}
+	defer pgxPool.Close()
+
+	repo := postgres.NewRepo(pgxPool, postgres.SetupPSQL())
+
+	p, err := projection.Build(ctx, &repo, 0)
+	if err != nil {
+		return err
+	}
+
+	discrepancies, err := projection.Compare(ctx, p, &repo, 0)
+	if err != nil {
+		return err
+	}
+
+	report := projectionReport{
+		Events:        p.Events,
+		LastSeq:       p.LastSeq,
+		Accounts:      len(p.Accounts),
+		Discrepancies: discrepancies,
+	}
+
+	if mode == "rebuild" {
+		// the audit log tells the writes of a rebuild by their request id
+		ctx = requestid.WithID(ctx, "projection-rebuild-"+uuid.NewString())
+
+		result, err := projection.Rebuild(ctx, p, discrepancies, &repo)
+		if err != nil {
+			return err
+		}
+		report.Rebuild = &result
+
+		if report.Discrepancies, err = projection.Compare(ctx, p, &repo, 0); err != nil {
+			return err
+		}
+	}
+
+	err = render(format, report, func(w io.Writer) {
+		fmt.Fprintf(w, "%d events up to seq %d, %d accounts\n", report.Events, report.LastSeq, report.Accounts)
+		if report.Rebuild != nil {
+			fmt.Fprintf(w, "%d accounts rebuilt, %d stale\n", len(report.Rebuild.Rebuilt), len(report.Rebuild.Stale))
+		}
+
+		if len(report.Discrepancies) == 0 {
+			return
+		}
+
+		fmt.Fprintln(w, "\nKIND\tACCOUNT\tTRANSCATION\tPROJECTED\tCURRENT")
+		for _, d := range report.Discrepancies {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Kind, d.AccountID, d.TranscationID, formatAmount(d.Projected), formatAmount(d.Current))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(report.Discrepancies) > 0 {
+		return fmt.Errorf("%d discrepancies between the tables and the event history", len(report.Discrepancies))
+	}
+
+	log.WithField("events", p.Events).Info("tables match the event history")
+
+	return nil
+}
+
+func formatAmount(amount *float64) string {
+	if amount == nil {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.2f", *amount)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/database/memory"
+	"github.com/madhurikadam/app-transcation/internal/database/postgres"
+	"github.com/madhurikadam/app-transcation/internal/seed"
+	log "github.com/sirupsen/logrus"
+)
+
+// runSeed generates account histories and copies them to postgres in
+// batches, so that load and demo environments don't start empty
+func runSeed(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	accounts := flags.Int("accounts", 10, "number of accounts to generate")
+	months := flags.Int("months", 12, "number of months of history of every account")
+	perMonth := flags.Int("per-month", 30, "average number of transcations of an account in a month")
+	seedValue := flags.Int64("seed", 1, "seed of the generator, the same seed and end give the same data")
+	end := flags.String("end", time.Now().UTC().Format("2006-01-02"), "day the histories end at")
+	batchSize := flags.Int("batch-size", 500, "number of accounts copied at once")
+	output := outputFlag(flags)
+
+	rest, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) > 0 || *batchSize <= 0 {
+		return errors.New("usage: seed [--accounts N] [--months M] [--per-month T] [--seed S] [--end YYYY-MM-DD] [--batch-size B]")
+	}
+
+	endAt, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("invalid end %q: %w", *end, err)
+	}
+
+	copyBatch := func(batch []memory.Snapshot) error { return nil }
+	if cfg.Storage == storageMemory {
+		log.Warn("the memory storage doesn't outlive the command, the data is generated and dropped")
+	} else {
+		pgxPool, err := initPostgres(ctx)
+		if err != nil {
+			return err
+		}
+		defer pgxPool.Close()
+
+		repo := postgres.NewRepo(pgxPool, postgres.SetupPSQL())
+		copyBatch = func(batch []memory.Snapshot) error {
+			return copyHistories(ctx, &repo, batch)
+		}
+	}
+
+	started := time.Now()
+	batch := make([]memory.Snapshot, 0, *batchSize)
+	stats, err := seed.Run(ctx, seed.Config{
+		Accounts:             *accounts,
+		Months:               *months,
+		TranscationsPerMonth: *perMonth,
+		Seed:                 *seedValue,
+		End:                  endAt,
+	}, func(s memory.Snapshot) error {
+		batch = append(batch, s)
+		if len(batch) < *batchSize {
+			return nil
+		}
+
+		err := copyBatch(batch)
+		batch = batch[:0]
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := copyBatch(batch); err != nil {
+		return err
+	}
+
+	log.WithField("duration", time.Since(started)).Info("seeded")
+
+	return render(*output, stats, func(w io.Writer) {
+		fmt.Fprintf(w, "accounts\t%d\n", stats.Accounts)
+		fmt.Fprintf(w, "transcations\t%d\n", stats.Transcations)
+		fmt.Fprintf(w, "declined\t%d\n", stats.Declined)
+		fmt.Fprintf(w, "events\t%d\n", stats.Events)
+
+		ops := make([]int, 0, len(stats.ByOperation))
+		for op := range stats.ByOperation {
+			ops = append(ops, op)
+		}
+		sort.Ints(ops)
+
+		for _, op := range ops {
+			fmt.Fprintf(w, "operation type %d\t%d\n", op, stats.ByOperation[op])
+		}
+	})
+}
+
+// copyHistories copies the histories of a batch of accounts at once
+func copyHistories(ctx context.Context, repo *postgres.Repo, batch []memory.Snapshot) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var all memory.Snapshot
+	for _, s := range batch {
+		all.Accounts = append(all.Accounts, s.Accounts...)
+		all.Transcations = append(all.Transcations, s.Transcations...)
+		all.Events = append(all.Events, s.Events...)
+	}
+
+	return repo.CopyHistories(ctx, all.Accounts, all.Transcations, all.Events)
+}
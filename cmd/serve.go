@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// serve runs the http and grpc apis along with the background jobs
+func serve(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: serve")
+	}
+
+	log.Info(fmt.Sprintf("starting %s service", ServiceName))
+
+	closeTracing, err := initTracing(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeTracing()
+
+	a, err := newApp(ctx, !cfg.AuthDisabled)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	authenticators, err := initAuthenticators(&a.apiKeys)
+	if err != nil {
+		return err
+	}
+
+	httpSvr, err := initHttpServer(&a.transcations, &a.webhooks, &a.apiKeys, authenticators, a.metrics, a.health)
+	if err != nil {
+		return err
+	}
+
+	grpcSvr := initGrpcServer(&a.transcations, authenticators)
+
+	errGroup, gCtx := errgroup.WithContext(ctx)
+
+	closeJobs := a.startJobs(gCtx, errGroup)
+	defer closeJobs()
+
+	// the servers outlive the jobs: on a signal readiness fails first and the
+	// servers keep serving until the load balancer stopped sending requests
+	serversCtx, stopServers := context.WithCancel(context.Background())
+	defer stopServers()
+
+	errGroup.Go(func() error {
+		<-gCtx.Done()
+		defer stopServers()
+
+		a.health.Drain()
+		if ctx.Err() == nil {
+			// a server or a job failed, there is nothing to drain for
+			return nil
+		}
+
+		log.WithField("delay", cfg.ShutdownDrainDelay).Info("draining before shutting down the servers")
+
+		time.Sleep(cfg.ShutdownDrainDelay)
+
+		return nil
+	})
+
+	errGroup.Go(grpcSvr.Shutdown(serversCtx))
+	errGroup.Go(grpcSvr.ListenAndServe)
+
+	// the account event streams are served until the servers stop
+	errGroup.Go(func() error {
+		return a.repo.ListenAccountEvents(serversCtx, a.transcations.NotifyAccountEvent)
+	})
+
+	errGroup.Go(httpSvr.Shutdown(serversCtx))
+
+	errGroup.Go(func() error {
+		err := httpSvr.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		return nil
+	})
+
+	return errGroup.Wait()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// worker runs the background jobs only, so that they can be scaled apart
+// from the apis. The jobs take turns with the ones of other replicas.
+func worker(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: worker")
+	}
+
+	log.Info(fmt.Sprintf("starting %s worker", ServiceName))
+
+	closeTracing, err := initTracing(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeTracing()
+
+	a, err := newApp(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer a.close()
+
+	errGroup, ctx := errgroup.WithContext(ctx)
+
+	closeJobs := a.startJobs(ctx, errGroup)
+	defer closeJobs()
+
+	return errGroup.Wait()
+}
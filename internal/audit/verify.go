@@ -0,0 +1,107 @@
+// Package audit checks the hash chain of the audit log
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+const defaultBatchSize = 1000
+
+// Source reads the audit log in order
+type Source interface {
+	ListAuditEntries(ctx context.Context, afterSeq int64, limit int) ([]domain.AuditEntry, error)
+}
+
+// Report is the outcome of a verification. The chain can't tell whether its
+// latest entries were removed, LastSeq and LastHash are meant to be kept
+// elsewhere and compared with the next report.
+type Report struct {
+	Entries  int                 `json:"entries"`
+	LastSeq  int64               `json:"last_seq"`
+	LastHash string              `json:"last_hash"`
+	Breaks   []domain.AuditBreak `json:"breaks"`
+}
+
+// OK reports whether the chain holds
+func (r Report) OK() bool {
+	return len(r.Breaks) == 0
+}
+
+// Verify walks the whole audit log and reports the entries whose hash doesn't
+// match their content, which were changed, and the entries that don't chain to
+// the latest entry of their entity before them, which follow removed or
+// reordered entries
+func Verify(ctx context.Context, source Source, batchSize int) (Report, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	report := Report{Breaks: make([]domain.AuditBreak, 0)}
+
+	// tips holds the hash of the latest entry of every entity by seq, and
+	// streams the seq of the latest entry of every entity
+	tips := make(map[int64]string)
+	streams := make(map[string]int64)
+	for {
+		entries, err := source.ListAuditEntries(ctx, report.LastSeq, batchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to list audit entries: %w", err)
+		}
+
+		for _, entry := range entries {
+			if reason := check(entry, tips); reason != "" {
+				report.Breaks = append(report.Breaks, domain.AuditBreak{
+					Seq:    entry.Seq,
+					ID:     entry.ID,
+					Reason: reason,
+				})
+			}
+
+			// chaining to the stored hash flags a changed entry once rather
+			// than every entry after it
+			if seq, ok := streams[entry.Stream()]; ok {
+				delete(tips, seq)
+			}
+			streams[entry.Stream()] = entry.Seq
+			tips[entry.Seq] = entry.Hash
+
+			report.Entries++
+			report.LastSeq = entry.Seq
+			report.LastHash = entry.Hash
+		}
+
+		if len(entries) < batchSize {
+			return report, nil
+		}
+	}
+}
+
+func check(entry domain.AuditEntry, tips map[int64]string) string {
+	var prevHash string
+	if entry.PrevSeq != 0 {
+		hash, ok := tips[entry.PrevSeq]
+		if !ok {
+			return "prev_seq isn't the latest entry of an entity, entries were removed or reordered"
+		}
+
+		prevHash = hash
+	}
+
+	if entry.PrevHash != prevHash {
+		return "prev_hash doesn't match the entry before, entries were removed or reordered"
+	}
+
+	hash, err := entry.ComputeHash()
+	if err != nil {
+		return err.Error()
+	}
+
+	if hash != entry.Hash {
+		return "hash doesn't match the entry, it was changed"
+	}
+
+	return ""
+}
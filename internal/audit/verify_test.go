@@ -0,0 +1,240 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+type entries []domain.AuditEntry
+
+func (e entries) ListAuditEntries(_ context.Context, afterSeq int64, limit int) ([]domain.AuditEntry, error) {
+	list := make([]domain.AuditEntry, 0, limit)
+	for _, entry := range e {
+		if entry.Seq > afterSeq && len(list) < limit {
+			list = append(list, entry)
+		}
+	}
+
+	return list, nil
+}
+
+// chain returns n entries chained per account, accounts are taken in turn.
+// seq 2 is missing like after a rollback.
+func chain(t *testing.T, n int, accounts ...string) entries {
+	t.Helper()
+
+	if len(accounts) == 0 {
+		accounts = []string{"account-1"}
+	}
+
+	at := time.Date(2022, 10, 3, 12, 0, 0, 0, time.UTC)
+	list := make(entries, 0, n)
+	tips := make(map[string]domain.AuditEntry)
+	for i := 0; i < n; i++ {
+		account := accounts[i%len(accounts)]
+		entry := domain.AuditEntry{
+			Seq:        int64(i + 1),
+			ID:         fmt.Sprintf("entry-%d", i),
+			Actor:      "ops",
+			RequestID:  "req-1",
+			Action:     domain.AuditAccountLimitsChanged,
+			EntityType: "accounts",
+			EntityID:   account,
+			Before:     json.RawMessage(fmt.Sprintf(`{"account_id": %q, "credit_limit": %d}`, account, i)),
+			After:      json.RawMessage(fmt.Sprintf(`{"credit_limit":%d,"account_id":%q}`, i+1, account)),
+			CreatedAt:  at.Add(time.Duration(i) * time.Second),
+		}
+		if i > 0 {
+			entry.Seq++
+		}
+
+		if tip, ok := tips[account]; ok {
+			entry.PrevSeq = tip.Seq
+			entry.PrevHash = tip.Hash
+		}
+
+		hash, err := entry.ComputeHash()
+		require.NoError(t, err)
+
+		entry.Hash = hash
+		tips[account] = entry
+		list = append(list, entry)
+	}
+
+	return list
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		tamper func(entries) entries
+		breaks []int64
+	}{
+		{
+			name:   "intact",
+			tamper: func(e entries) entries { return e },
+		},
+		{
+			name: "changed snapshot",
+			tamper: func(e entries) entries {
+				e[3].After = json.RawMessage(`{"account_id":"account-1","credit_limit":1000}`)
+				return e
+			},
+			breaks: []int64{5},
+		},
+		{
+			name: "changed snapshot with its hash",
+			tamper: func(e entries) entries {
+				e[3].Actor = "intruder"
+				e[3].Hash, _ = e[3].ComputeHash()
+				return e
+			},
+			breaks: []int64{6},
+		},
+		{
+			name: "removed entry",
+			tamper: func(e entries) entries {
+				return append(e[:2], e[3:]...)
+			},
+			breaks: []int64{5},
+		},
+		{
+			name: "snapshot reformatted by the database",
+			tamper: func(e entries) entries {
+				e[1].Before = json.RawMessage(`{"credit_limit": 1, "account_id": "account-1"}`)
+				return e
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			list := tt.tamper(chain(t, 10))
+
+			// a batch smaller than the log makes verify page through it
+			report, err := Verify(context.Background(), list, 3)
+			require.NoError(t, err)
+			require.Equal(t, len(list), report.Entries)
+			require.Equal(t, list[len(list)-1].Seq, report.LastSeq)
+			require.Equal(t, len(tt.breaks) == 0, report.OK())
+
+			seqs := make([]int64, 0, len(report.Breaks))
+			for _, b := range report.Breaks {
+				seqs = append(seqs, b.Seq)
+			}
+			require.ElementsMatch(t, tt.breaks, seqs)
+		})
+	}
+}
+
+func TestVerifyEntities(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		tamper func(entries) entries
+		breaks []int64
+	}{
+		{
+			name:   "intact",
+			tamper: func(e entries) entries { return e },
+		},
+		{
+			name: "removed entry of an account",
+			tamper: func(e entries) entries {
+				// entry-4 of account-2 is chained to by entry-7, seq 9
+				return append(e[:4], e[5:]...)
+			},
+			breaks: []int64{9},
+		},
+		{
+			name: "entry chained to another account",
+			tamper: func(e entries) entries {
+				e[6].PrevSeq = e[5].Seq
+				return e
+			},
+			breaks: []int64{8},
+		},
+		{
+			name: "entries chained to the same entry",
+			tamper: func(e entries) entries {
+				// entry-6 already chains to entry-3 of account-1
+				e[9].PrevSeq = e[3].Seq
+				e[9].PrevHash = e[3].Hash
+				e[9].Hash, _ = e[9].ComputeHash()
+				return e
+			},
+			breaks: []int64{11},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			list := tt.tamper(chain(t, 10, "account-1", "account-2", "account-3"))
+
+			report, err := Verify(context.Background(), list, 4)
+			require.NoError(t, err)
+			require.Equal(t, len(list), report.Entries)
+
+			seqs := make([]int64, 0, len(report.Breaks))
+			for _, b := range report.Breaks {
+				seqs = append(seqs, b.Seq)
+			}
+			require.ElementsMatch(t, tt.breaks, seqs)
+		})
+	}
+}
+
+// entries written before the log was chained per entity chain to the entry
+// before them in the log, the entries written after to the latest entry of
+// their entity
+func TestVerifyLegacyEntries(t *testing.T) {
+	t.Parallel()
+
+	legacy := chain(t, 4)
+	accounts := []string{"account-1", "account-2", "account-1", "account-2"}
+	for i := range legacy {
+		legacy[i].EntityID = accounts[i]
+		if i > 0 {
+			legacy[i].PrevSeq = legacy[i-1].Seq
+			legacy[i].PrevHash = legacy[i-1].Hash
+		}
+
+		legacy[i].Hash, _ = legacy[i].ComputeHash()
+	}
+
+	next := domain.AuditEntry{
+		Seq:        10,
+		PrevSeq:    legacy[2].Seq,
+		PrevHash:   legacy[2].Hash,
+		ID:         "entry-10",
+		Actor:      "ops",
+		Action:     domain.AuditAccountLimitsChanged,
+		EntityType: "accounts",
+		EntityID:   "account-1",
+		CreatedAt:  legacy[3].CreatedAt.Add(time.Second),
+	}
+	next.Hash, _ = next.ComputeHash()
+
+	report, err := Verify(context.Background(), append(legacy, next), 0)
+	require.NoError(t, err)
+	require.True(t, report.OK(), report.Breaks)
+	require.Equal(t, 5, report.Entries)
+}
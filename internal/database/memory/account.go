@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// CreateAccount writes the account and its account.created event
+func (r *Repo) CreateAccount(ctx context.Context, account domain.Account) error {
+	return r.write(ctx, func(s *state) error {
+		if _, ok := s.accounts[account.ID]; ok {
+			return errDuplicateKey
+		}
+
+		s.accounts[account.ID] = account
+
+		return s.createEvent(domain.EventAccountCreated, account.ID, domain.AccountLimits{
+			AccountID:       account.ID,
+			WithdrawalLimit: account.WithdrawalLimit,
+			CreditLimit:     account.CreaditLimit,
+		}, account.CreatedAt)
+	})
+}
+
+func (r *Repo) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
+	var account domain.Account
+	err := r.read(ctx, func(s *state) error {
+		found, ok := s.accounts[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		account = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// UpdateAccountLimits sets the limits of the account given in req and writes
+// an account.limits_changed event
+func (r *Repo) UpdateAccountLimits(ctx context.Context, accountID string, req domain.AccountLimitsReq, at time.Time) (*domain.AccountLimits, error) {
+	var limits *domain.AccountLimits
+	err := r.write(ctx, func(s *state) error {
+		account, ok := s.accounts[accountID]
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		if req.WithdrawalLimit != nil {
+			account.WithdrawalLimit = *req.WithdrawalLimit
+		}
+
+		if req.CreditLimit != nil {
+			account.CreaditLimit = *req.CreditLimit
+		}
+
+		account.UpdatedAt = &at
+		s.accounts[accountID] = account
+
+		limits = accountLimits(account)
+
+		return s.createEvent(domain.EventAccountLimitsChanged, accountID, limits, at)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// updateDebitLimit consumes the withdrawal limit of the account by the debit
+// amount, which is negative, and returns the new limits. It fails with
+// ErrExceedWithdrawalLimit when the limit left is too low.
+func (s *state) updateDebitLimit(accountID string, amount float64) (*domain.AccountLimits, error) {
+	account, ok := s.accounts[accountID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	if account.WithdrawalLimit+amount < 0 {
+		return nil, domain.ErrExceedWithdrawalLimit
+	}
+
+	account.WithdrawalLimit += amount
+	s.accounts[accountID] = account
+
+	return accountLimits(account), nil
+}
+
+// updateCreditLimit consumes the credit limit of the account by the credit
+// amount and returns the new limits. It fails with ErrExceedCreditLimit when
+// the limit left is too low.
+func (s *state) updateCreditLimit(accountID string, amount float64) (*domain.AccountLimits, error) {
+	account, ok := s.accounts[accountID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	if account.CreaditLimit-amount < 0 {
+		return nil, domain.ErrExceedCreditLimit
+	}
+
+	account.CreaditLimit -= amount
+	s.accounts[accountID] = account
+
+	return accountLimits(account), nil
+}
+
+func accountLimits(account domain.Account) *domain.AccountLimits {
+	return &domain.AccountLimits{
+		AccountID:       account.ID,
+		WithdrawalLimit: account.WithdrawalLimit,
+		CreditLimit:     account.CreaditLimit,
+	}
+}
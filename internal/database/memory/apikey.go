@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+func (r *Repo) CreateAPIKey(ctx context.Context, key domain.APIKey) error {
+	return r.write(ctx, func(s *state) error {
+		return s.createAPIKey(key)
+	})
+}
+
+// RotateAPIKey creates the key replacing key.RotatedFrom and shortens the
+// validity of the replaced key to oldExpiresAt, it fails with ErrNotFound when
+// the replaced key is unknown or revoked
+func (r *Repo) RotateAPIKey(ctx context.Context, key domain.APIKey, oldExpiresAt time.Time) error {
+	if key.RotatedFrom == nil {
+		return domain.ErrNotFound
+	}
+
+	return r.write(ctx, func(s *state) error {
+		old, ok := s.apiKeys[*key.RotatedFrom]
+		if !ok || old.RevokedAt != nil {
+			return domain.ErrNotFound
+		}
+
+		if old.ExpiresAt == nil || oldExpiresAt.Before(*old.ExpiresAt) {
+			old.ExpiresAt = &oldExpiresAt
+		}
+		s.apiKeys[old.ID] = old
+
+		return s.createAPIKey(key)
+	})
+}
+
+func (s *state) createAPIKey(key domain.APIKey) error {
+	if _, ok := s.apiKeys[key.ID]; ok {
+		return errDuplicateKey
+	}
+
+	if key.RotatedFrom != nil {
+		if _, ok := s.apiKeys[*key.RotatedFrom]; !ok {
+			return domain.ErrNotFound
+		}
+	}
+
+	s.apiKeys[key.ID] = cloneAPIKey(key)
+
+	return nil
+}
+
+func (r *Repo) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.read(ctx, func(s *state) error {
+		found, ok := s.apiKeys[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		key = cloneAPIKey(found)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *Repo) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	keys := make([]domain.APIKey, 0)
+	err := r.read(ctx, func(s *state) error {
+		for _, key := range s.apiKeys {
+			keys = append(keys, cloneAPIKey(key))
+		}
+
+		return nil
+	})
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, err
+}
+
+// RevokeAPIKey revokes the key at the given time, revoking a revoked key fails
+// with ErrNotFound
+func (r *Repo) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return r.write(ctx, func(s *state) error {
+		key, ok := s.apiKeys[id]
+		if !ok || key.RevokedAt != nil {
+			return domain.ErrNotFound
+		}
+
+		key.RevokedAt = &at
+		s.apiKeys[id] = key
+
+		return nil
+	})
+}
+
+// TouchAPIKey records the use of the key, last_used_at is written at most once
+// per interval
+func (r *Repo) TouchAPIKey(ctx context.Context, id string, at time.Time, interval time.Duration) error {
+	return r.write(ctx, func(s *state) error {
+		key, ok := s.apiKeys[id]
+		if !ok {
+			return nil
+		}
+
+		if key.LastUsedAt == nil || key.LastUsedAt.Before(at.Add(-interval)) {
+			key.LastUsedAt = &at
+			s.apiKeys[id] = key
+		}
+
+		return nil
+	})
+}
+
+func cloneAPIKey(key domain.APIKey) domain.APIKey {
+	key.Roles = append([]string(nil), key.Roles...)
+	key.Scopes = append([]string(nil), key.Scopes...)
+	key.Hash = append([]byte(nil), key.Hash...)
+
+	return key
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// RecordFraudDecision keeps a decision of the fraud rules
+func (r *Repo) RecordFraudDecision(ctx context.Context, decision domain.FraudDecision) error {
+	return r.write(ctx, func(s *state) error {
+		if _, ok := s.accounts[decision.AccountID]; !ok {
+			return domain.ErrNotFound
+		}
+
+		if _, ok := s.decisions[decision.ID]; ok {
+			return errDuplicateKey
+		}
+
+		decision.Reasons = cloneReasons(decision.Reasons)
+		s.decisions[decision.ID] = decision
+
+		return nil
+	})
+}
+
+// TranscationStats counts the transcations of the account with one of the
+// operation types made after since and adds up their absolute amounts,
+// rejected transcations are left out
+func (r *Repo) TranscationStats(ctx context.Context, accountID string, operationTypes []int, since time.Time) (domain.TranscationStats, error) {
+	var stats domain.TranscationStats
+	err := r.read(ctx, func(s *state) error {
+		for _, t := range s.transcations {
+			if t.AccountID != accountID || t.Status == domain.TranscationRejected || !t.EventAt.After(since) {
+				continue
+			}
+
+			if len(operationTypes) > 0 && !containsInt(operationTypes, t.OperationTypeID) {
+				continue
+			}
+
+			stats.Count++
+			stats.Amount += math.Abs(t.Amount)
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// CountFraudDecisions counts the decisions with the outcome taken for the
+// account after since
+func (r *Repo) CountFraudDecisions(ctx context.Context, accountID string, outcome domain.FraudOutcome, since time.Time) (int, error) {
+	var count int
+	err := r.read(ctx, func(s *state) error {
+		for _, decision := range s.decisions {
+			if decision.AccountID == accountID && decision.Outcome == outcome && decision.CreatedAt.After(since) {
+				count++
+			}
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+func cloneReasons(reasons []domain.FraudReason) []domain.FraudReason {
+	return append([]domain.FraudReason(nil), reasons...)
+}
+
+func containsInt(list []int, v int) bool {
+	for _, val := range list {
+		if val == v {
+			return true
+		}
+	}
+
+	return false
+}
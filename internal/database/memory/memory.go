@@ -0,0 +1,301 @@
+// Package memory keeps the data of the service in memory, it implements the
+// repositories of the postgres package for tests and local development. Every
+// write copies the whole state, it is not meant for large data sets.
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// listenBuffer is how many notifications a listener may fall behind, later
+// ones are dropped like the ones of a postgres listener reconnecting
+const listenBuffer = 256
+
+var errDuplicateKey = errors.New("duplicate key")
+
+// Repo is an in memory repository with the semantics of postgres.Repo. Writes
+// are serialized, a unit of work holds the repo until it ends.
+type Repo struct {
+	mu    sync.RWMutex
+	state *state
+
+	// outboxMu serializes the relays, events are handed out once
+	outboxMu sync.Mutex
+
+	listenersMu sync.Mutex
+	listeners   map[chan string]struct{}
+}
+
+// state holds every table of the repo, it is copied by the transactions and
+// replaces the committed one when they succeed
+type state struct {
+	accounts      map[string]domain.Account
+	transcations  map[string]domain.Transcation
+	reviews       map[string]review
+	decisions     map[string]domain.FraudDecision
+	apiKeys       map[string]domain.APIKey
+	subscriptions map[string]domain.WebhookSubscription
+	deliveries    map[string]domain.WebhookDelivery
+
+	// events are ordered by sequence number and only ever appended to, the
+	// copies share them. The relay publishes them in order, published is the
+	// sequence number of the last one it published.
+	events    []domain.Event
+	seq       int64
+	published int64
+
+	// notify are the accounts events were written for, listeners are told
+	// once the transaction commits
+	notify []string
+
+	// newID draws the ids of the events
+	newID func() string
+}
+
+// Option configures a Repo
+type Option func(*Repo)
+
+// WithIDs sets how the ids of the events are drawn, it is meant for
+// generating reproducible histories
+func WithIDs(newID func() string) Option {
+	return func(r *Repo) {
+		r.state.newID = newID
+	}
+}
+
+type txKey struct{}
+
+// tx is the unit of work of a context
+type tx struct {
+	repo  *Repo
+	state *state
+}
+
+func NewRepo(opts ...Option) *Repo {
+	r := &Repo{
+		state: &state{
+			accounts:      make(map[string]domain.Account),
+			transcations:  make(map[string]domain.Transcation),
+			reviews:       make(map[string]review),
+			decisions:     make(map[string]domain.FraudDecision),
+			apiKeys:       make(map[string]domain.APIKey),
+			subscriptions: make(map[string]domain.WebhookSubscription),
+			deliveries:    make(map[string]domain.WebhookDelivery),
+			events:        make([]domain.Event, 0),
+			newID:         uuid.NewString,
+		},
+		listeners: make(map[chan string]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// WithinTx runs fn with a context whose repo calls share one transaction,
+// committed when fn succeeds. Other callers wait until it ends.
+func (r *Repo) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.txState(ctx) != nil {
+		return fn(ctx)
+	}
+
+	return r.commit(func(s *state) error {
+		return fn(context.WithValue(ctx, txKey{}, &tx{repo: r, state: s}))
+	})
+}
+
+// txState returns the state of the unit of work of ctx, nil outside of one
+func (r *Repo) txState(ctx context.Context) *state {
+	t, ok := ctx.Value(txKey{}).(*tx)
+	if !ok || t.repo != r {
+		return nil
+	}
+
+	return t.state
+}
+
+// read runs fn on the state seen by ctx
+func (r *Repo) read(ctx context.Context, fn func(s *state) error) error {
+	if s := r.txState(ctx); s != nil {
+		return fn(s)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return fn(r.state)
+}
+
+// write runs fn in the unit of work of ctx, or in a transaction of its own
+func (r *Repo) write(ctx context.Context, fn func(s *state) error) error {
+	if s := r.txState(ctx); s != nil {
+		return fn(s)
+	}
+
+	return r.commit(fn)
+}
+
+// commit runs fn on a copy of the state and keeps the copy when fn succeeds
+func (r *Repo) commit(fn func(s *state) error) error {
+	notify, err := r.apply(fn)
+	if err != nil {
+		return err
+	}
+
+	r.notifyListeners(notify)
+
+	return nil
+}
+
+func (r *Repo) apply(fn func(s *state) error) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	working := r.state.clone()
+	if err := fn(working); err != nil {
+		return nil, err
+	}
+
+	notify := working.notify
+	working.notify = nil
+	r.state = working
+
+	return notify, nil
+}
+
+func (s *state) clone() *state {
+	c := &state{
+		accounts:      make(map[string]domain.Account, len(s.accounts)),
+		transcations:  make(map[string]domain.Transcation, len(s.transcations)),
+		reviews:       make(map[string]review, len(s.reviews)),
+		decisions:     make(map[string]domain.FraudDecision, len(s.decisions)),
+		apiKeys:       make(map[string]domain.APIKey, len(s.apiKeys)),
+		subscriptions: make(map[string]domain.WebhookSubscription, len(s.subscriptions)),
+		deliveries:    make(map[string]domain.WebhookDelivery, len(s.deliveries)),
+		// writes are serialized, the copy is the only one appending to the
+		// events past the committed ones
+		events:    s.events,
+		seq:       s.seq,
+		published: s.published,
+		newID:     s.newID,
+	}
+
+	for id, account := range s.accounts {
+		c.accounts[id] = account
+	}
+
+	for id, transcation := range s.transcations {
+		c.transcations[id] = transcation
+	}
+
+	for id, review := range s.reviews {
+		c.reviews[id] = review
+	}
+
+	for id, decision := range s.decisions {
+		c.decisions[id] = decision
+	}
+
+	for id, key := range s.apiKeys {
+		c.apiKeys[id] = key
+	}
+
+	for id, sub := range s.subscriptions {
+		c.subscriptions[id] = sub
+	}
+
+	for id, delivery := range s.deliveries {
+		c.deliveries[id] = delivery
+	}
+
+	return c
+}
+
+// ListenAccountEvents calls fn with the account id of every event written
+// until ctx is cancelled
+func (r *Repo) ListenAccountEvents(ctx context.Context, fn func(accountID string)) error {
+	ch := make(chan string, listenBuffer)
+
+	r.listenersMu.Lock()
+	r.listeners[ch] = struct{}{}
+	r.listenersMu.Unlock()
+
+	defer func() {
+		r.listenersMu.Lock()
+		delete(r.listeners, ch)
+		r.listenersMu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case accountID := <-ch:
+			fn(accountID)
+		}
+	}
+}
+
+func (r *Repo) notifyListeners(accountIDs []string) {
+	if len(accountIDs) == 0 {
+		return
+	}
+
+	r.listenersMu.Lock()
+	defer r.listenersMu.Unlock()
+
+	for ch := range r.listeners {
+		for _, accountID := range accountIDs {
+			select {
+			case ch <- accountID:
+			default:
+			}
+		}
+	}
+}
+
+// Snapshot is the committed accounts, transcations and events of a repo
+type Snapshot struct {
+	Accounts     []domain.Account
+	Transcations []domain.Transcation
+	Events       []domain.Event
+}
+
+// Snapshot returns the accounts ordered by creation, the transcations by
+// event time and the events by sequence number. Reviews, fraud decisions, API
+// keys and webhooks are left out.
+func (r *Repo) Snapshot() Snapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := Snapshot{
+		Accounts:     make([]domain.Account, 0, len(r.state.accounts)),
+		Transcations: r.state.listTranscations(func(domain.Transcation) bool { return true }),
+		Events:       make([]domain.Event, 0, len(r.state.events)),
+	}
+
+	for _, account := range r.state.accounts {
+		snapshot.Accounts = append(snapshot.Accounts, account)
+	}
+
+	sort.Slice(snapshot.Accounts, func(i, j int) bool {
+		if !snapshot.Accounts[i].CreatedAt.Equal(snapshot.Accounts[j].CreatedAt) {
+			return snapshot.Accounts[i].CreatedAt.Before(snapshot.Accounts[j].CreatedAt)
+		}
+
+		return snapshot.Accounts[i].ID < snapshot.Accounts[j].ID
+	})
+
+	snapshot.Events = append(snapshot.Events, r.state.events...)
+
+	return snapshot
+}
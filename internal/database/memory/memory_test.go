@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/madhurikadam/app-transcation/internal/database/memory"
+	"github.com/madhurikadam/app-transcation/internal/database/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repo {
+		return memory.NewRepo()
+	})
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// createEvent writes an event to the outbox, it is published by the relay only
+// once the transaction commits
+func (s *state) createEvent(eventType, accountID string, payload interface{}, at time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	s.seq++
+	s.events = append(s.events, domain.Event{
+		ID:        s.newID(),
+		Seq:       s.seq,
+		AccountID: accountID,
+		Type:      eventType,
+		Payload:   data,
+		CreatedAt: at,
+	})
+	s.notify = append(s.notify, accountID)
+
+	return nil
+}
+
+// ProcessOutbox hands up to limit unpublished events to fn and marks them as
+// published once fn succeeds. The repo isn't held while fn runs, publishers
+// may write to it.
+func (r *Repo) ProcessOutbox(ctx context.Context, limit int, fn func([]domain.Event) error) (int, error) {
+	r.outboxMu.Lock()
+	defer r.outboxMu.Unlock()
+
+	events := make([]domain.Event, 0, limit)
+	err := r.read(ctx, func(s *state) error {
+		for _, event := range s.events {
+			if len(events) == limit {
+				break
+			}
+
+			if event.Seq > s.published {
+				events = append(events, event)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := fn(events); err != nil {
+		return 0, err
+	}
+
+	err = r.write(ctx, func(s *state) error {
+		s.published = events[len(events)-1].Seq
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
+
+// GetOldestUnpublishedEventTime returns when the oldest event not published
+// yet was written, the zero time when there is none
+func (r *Repo) GetOldestUnpublishedEventTime(ctx context.Context) (time.Time, error) {
+	var oldest time.Time
+	err := r.read(ctx, func(s *state) error {
+		for _, event := range s.events {
+			if event.Seq > s.published {
+				oldest = event.CreatedAt
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return oldest, err
+}
+
+// ListAccountEvents returns up to limit events of the account with a sequence
+// number greater than afterSeq, in sequence order
+func (r *Repo) ListAccountEvents(ctx context.Context, accountID string, afterSeq int64, limit int) ([]domain.Event, error) {
+	events := make([]domain.Event, 0)
+	err := r.read(ctx, func(s *state) error {
+		for _, event := range s.events {
+			if len(events) == limit {
+				break
+			}
+
+			if event.AccountID == accountID && event.Seq > afterSeq {
+				events = append(events, event)
+			}
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+// GetLastEventSeq returns the sequence number of the latest event of the
+// account, 0 when it has none
+func (r *Repo) GetLastEventSeq(ctx context.Context, accountID string) (int64, error) {
+	var seq int64
+	err := r.read(ctx, func(s *state) error {
+		for _, event := range s.events {
+			if event.AccountID == accountID {
+				seq = event.Seq
+			}
+		}
+
+		return nil
+	})
+
+	return seq, err
+}
@@ -0,0 +1,271 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// review is a row of the reviews, its transcation and fraud reasons are kept
+// with the transcation and the decision
+type review struct {
+	ID            string
+	TranscationID string
+	DecisionID    string
+	Status        string
+	Reviewer      string
+	Reason        string
+	DueAt         time.Time
+	DecidedAt     *time.Time
+	CreatedAt     time.Time
+}
+
+// HoldTranscation writes a transcation pending review along with its review
+// and reserves the limit it uses until the review is decided. Debits are
+// checked against the velocity limits like posted ones.
+func (r *Repo) HoldTranscation(ctx context.Context, review domain.Review, velocity domain.VelocityLimits) error {
+	return r.write(ctx, func(s *state) error {
+		transcation := review.Transcation
+		if transcation.IsDebit() && velocity.Enabled() {
+			if err := s.checkVelocity(transcation, velocity); err != nil {
+				return err
+			}
+		}
+
+		if err := s.createTranscation(transcation); err != nil {
+			return err
+		}
+
+		limits, err := s.reserveLimit(transcation, transcation.Amount)
+		if err != nil {
+			return err
+		}
+
+		if err := s.createReview(review); err != nil {
+			return err
+		}
+
+		return s.createEvent(domain.EventAccountLimitsChanged, limits.AccountID, limits, review.CreatedAt)
+	})
+}
+
+// ApproveReview posts the transcation of a pending review, credits discharge
+// the debits of dbTxList. It fails with ErrReviewDecided when the review was
+// decided in the meantime.
+func (r *Repo) ApproveReview(ctx context.Context, review domain.Review, dbTxList []domain.DebitTx) error {
+	return r.write(ctx, func(s *state) error {
+		if err := s.decideReview(review); err != nil {
+			return err
+		}
+
+		transcation := review.Transcation
+		discharges, err := s.dischargeDebits(transcation, dbTxList)
+		if err != nil {
+			return err
+		}
+
+		if err := s.updateTranscationStatus(transcation); err != nil {
+			return err
+		}
+
+		// the limit was already used when the transcation was held
+		return s.createTranscationEvents(transcation, discharges, nil, *review.DecidedAt)
+	})
+}
+
+// RejectReview rejects the transcation of a pending review and releases the
+// limit it reserved. It fails with ErrReviewDecided when the review was
+// decided in the meantime.
+func (r *Repo) RejectReview(ctx context.Context, review domain.Review) error {
+	return r.write(ctx, func(s *state) error {
+		if err := s.decideReview(review); err != nil {
+			return err
+		}
+
+		transcation := review.Transcation
+		if err := s.updateTranscationStatus(transcation); err != nil {
+			return err
+		}
+
+		limits, err := s.reserveLimit(transcation, -transcation.Amount)
+		if err != nil {
+			return err
+		}
+
+		return s.createEvent(domain.EventAccountLimitsChanged, limits.AccountID, limits, *review.DecidedAt)
+	})
+}
+
+func (r *Repo) GetReview(ctx context.Context, id string) (*domain.Review, error) {
+	var found domain.Review
+	err := r.read(ctx, func(s *state) error {
+		row, ok := s.reviews[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		found, ok = s.review(row)
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &found, nil
+}
+
+// ListReviews returns up to limit reviews with the status ordered by due time
+func (r *Repo) ListReviews(ctx context.Context, status string, limit int) ([]domain.Review, error) {
+	var reviews []domain.Review
+	err := r.read(ctx, func(s *state) error {
+		reviews = s.listReviews(func(row review) bool {
+			return row.Status == status
+		}, limit)
+
+		return nil
+	})
+
+	return reviews, err
+}
+
+// ListDueReviews returns up to limit pending reviews due at the given time
+func (r *Repo) ListDueReviews(ctx context.Context, at time.Time, limit int) ([]domain.Review, error) {
+	var reviews []domain.Review
+	err := r.read(ctx, func(s *state) error {
+		reviews = s.listReviews(func(row review) bool {
+			return row.Status == domain.ReviewPending && !row.DueAt.After(at)
+		}, limit)
+
+		return nil
+	})
+
+	return reviews, err
+}
+
+func (s *state) createReview(r domain.Review) error {
+	if _, ok := s.reviews[r.ID]; ok {
+		return errDuplicateKey
+	}
+
+	if _, ok := s.decisions[r.DecisionID]; !ok {
+		return domain.ErrNotFound
+	}
+
+	for _, row := range s.reviews {
+		if row.TranscationID == r.Transcation.ID {
+			return errDuplicateKey
+		}
+	}
+
+	s.reviews[r.ID] = review{
+		ID:            r.ID,
+		TranscationID: r.Transcation.ID,
+		DecisionID:    r.DecisionID,
+		Status:        r.Status,
+		DueAt:         r.DueAt,
+		CreatedAt:     r.CreatedAt,
+	}
+
+	return nil
+}
+
+// decideReview records the decision of a review that is still pending
+func (s *state) decideReview(r domain.Review) error {
+	row, ok := s.reviews[r.ID]
+	if !ok || row.Status != domain.ReviewPending {
+		return domain.ErrReviewDecided
+	}
+
+	row.Status = r.Status
+	row.Reviewer = r.Reviewer
+	row.Reason = r.Reason
+	row.DecidedAt = r.DecidedAt
+	s.reviews[r.ID] = row
+
+	return nil
+}
+
+func (s *state) updateTranscationStatus(transcation domain.Transcation) error {
+	stored, ok := s.transcations[transcation.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	stored.Status = transcation.Status
+	stored.Balance = transcation.Balance
+	s.transcations[transcation.ID] = stored
+
+	return nil
+}
+
+// reserveLimit uses amount of the limit matching the operation type of the
+// transcation, the opposite amount releases it
+func (s *state) reserveLimit(transcation domain.Transcation, amount float64) (*domain.AccountLimits, error) {
+	if transcation.IsDebit() {
+		return s.updateDebitLimit(transcation.AccountID, amount)
+	}
+
+	return s.updateCreditLimit(transcation.AccountID, amount)
+}
+
+// review joins the row with its transcation and fraud decision, it reports
+// false when one of them is missing
+func (s *state) review(row review) (domain.Review, bool) {
+	transcation, ok := s.transcations[row.TranscationID]
+	if !ok {
+		return domain.Review{}, false
+	}
+
+	decision, ok := s.decisions[row.DecisionID]
+	if !ok {
+		return domain.Review{}, false
+	}
+
+	return domain.Review{
+		ID:          row.ID,
+		Transcation: transcation,
+		DecisionID:  row.DecisionID,
+		Reasons:     cloneReasons(decision.Reasons),
+		Status:      row.Status,
+		Reviewer:    row.Reviewer,
+		Reason:      row.Reason,
+		DueAt:       row.DueAt,
+		DecidedAt:   row.DecidedAt,
+		CreatedAt:   row.CreatedAt,
+	}, true
+}
+
+// listReviews returns up to limit reviews matching the filter ordered by due
+// time
+func (s *state) listReviews(filter func(review) bool, limit int) []domain.Review {
+	reviews := make([]domain.Review, 0)
+	for _, row := range s.reviews {
+		if !filter(row) {
+			continue
+		}
+
+		if found, ok := s.review(row); ok {
+			reviews = append(reviews, found)
+		}
+	}
+
+	sort.Slice(reviews, func(i, j int) bool {
+		if !reviews[i].DueAt.Equal(reviews[j].DueAt) {
+			return reviews[i].DueAt.Before(reviews[j].DueAt)
+		}
+
+		return reviews[i].ID < reviews[j].ID
+	})
+
+	if len(reviews) > limit {
+		reviews = reviews[:limit]
+	}
+
+	return reviews
+}
@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+func (r *Repo) CreateCreditTranscation(ctx context.Context, transcation domain.Transcation, dbTxList []domain.DebitTx) error {
+	return r.write(ctx, func(s *state) error {
+		discharges, err := s.dischargeDebits(transcation, dbTxList)
+		if err != nil {
+			return err
+		}
+
+		if err := s.createTranscation(transcation); err != nil {
+			return err
+		}
+
+		limits, err := s.updateCreditLimit(transcation.AccountID, transcation.Amount)
+		if err != nil {
+			return err
+		}
+
+		return s.createTranscationEvents(transcation, discharges, limits, transcation.EventAt)
+	})
+}
+
+// CreateDebitTranscation posts a debit, it fails with a domain.VelocityError
+// when the debit exceeds the velocity limits of the account
+func (r *Repo) CreateDebitTranscation(ctx context.Context, transcation domain.Transcation, velocity domain.VelocityLimits) error {
+	return r.write(ctx, func(s *state) error {
+		if velocity.Enabled() {
+			if err := s.checkVelocity(transcation, velocity); err != nil {
+				return err
+			}
+		}
+
+		if err := s.createTranscation(transcation); err != nil {
+			return err
+		}
+
+		limits, err := s.updateDebitLimit(transcation.AccountID, transcation.Amount)
+		if err != nil {
+			return err
+		}
+
+		return s.createTranscationEvents(transcation, nil, limits, transcation.EventAt)
+	})
+}
+
+func (s *state) createTranscation(transcation domain.Transcation) error {
+	if _, ok := s.accounts[transcation.AccountID]; !ok {
+		return domain.ErrNotFound
+	}
+
+	if _, ok := s.transcations[transcation.ID]; ok {
+		return errDuplicateKey
+	}
+
+	s.transcations[transcation.ID] = transcation
+
+	return nil
+}
+
+// dischargeDebits sets the balances of the debits paid off by the credit and
+// returns the discharges of the balances that changed
+func (s *state) dischargeDebits(credit domain.Transcation, dbTxList []domain.DebitTx) ([]domain.Discharge, error) {
+	discharges := make([]domain.Discharge, 0, len(dbTxList))
+	for _, update := range dbTxList {
+		changed, err := s.updateBalance(update.ID, update.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		if changed {
+			discharges = append(discharges, domain.Discharge{
+				TranscationID: update.ID,
+				AccountID:     credit.AccountID,
+				Balance:       update.Amount,
+				DischargedBy:  credit.ID,
+			})
+		}
+	}
+
+	return discharges, nil
+}
+
+// updateBalance sets the balance of a debit, it reports whether the balance
+// actually changed
+func (s *state) updateBalance(id string, amount float64) (bool, error) {
+	transcation, ok := s.transcations[id]
+	if !ok {
+		return false, domain.ErrNotFound
+	}
+
+	if transcation.Balance == amount {
+		return false, nil
+	}
+
+	transcation.Balance = amount
+	s.transcations[id] = transcation
+
+	return true, nil
+}
+
+// createTranscationEvents writes the events of a posted transcation to the
+// outbox: the transcation itself, every debit it discharged and the new limits
+// of the account when they changed, at is when the transcation got posted
+func (s *state) createTranscationEvents(transcation domain.Transcation, discharges []domain.Discharge, limits *domain.AccountLimits, at time.Time) error {
+	if err := s.createEvent(domain.EventTranscationCreated, transcation.AccountID, transcation, at); err != nil {
+		return err
+	}
+
+	for _, discharge := range discharges {
+		if err := s.createEvent(domain.EventTranscationDischarged, transcation.AccountID, discharge, at); err != nil {
+			return err
+		}
+	}
+
+	if limits != nil {
+		return s.createEvent(domain.EventAccountLimitsChanged, transcation.AccountID, limits, at)
+	}
+
+	return nil
+}
+
+// ListTranscations returns up to limit transcations of the account ordered by
+// event time, starting after the cursor when one is given
+func (r *Repo) ListTranscations(ctx context.Context, accountID string, after *domain.TranscationCursor, limit int) ([]domain.Transcation, error) {
+	var transcations []domain.Transcation
+	err := r.read(ctx, func(s *state) error {
+		transcations = s.listTranscations(func(t domain.Transcation) bool {
+			if t.AccountID != accountID {
+				return false
+			}
+
+			if after == nil {
+				return true
+			}
+
+			if after.ID == "" || !t.EventAt.Equal(after.EventAt) {
+				return t.EventAt.After(after.EventAt)
+			}
+
+			return t.ID > after.ID
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transcations) > limit {
+		transcations = transcations[:limit]
+	}
+
+	return transcations, nil
+}
+
+// ListDebitTx returns the posted debits of the account that are not paid off
+// yet, oldest first so that credits discharge them in order
+func (r *Repo) ListDebitTx(ctx context.Context, accountID string) ([]domain.Transcation, error) {
+	var transcations []domain.Transcation
+	err := r.read(ctx, func(s *state) error {
+		transcations = s.listTranscations(func(t domain.Transcation) bool {
+			return t.AccountID == accountID &&
+				t.IsDebit() &&
+				t.Status == domain.TranscationPosted &&
+				t.Balance < 0
+		})
+
+		return nil
+	})
+
+	return transcations, err
+}
+
+// listTranscations returns the transcations matching the filter ordered by
+// event time and id
+func (s *state) listTranscations(filter func(domain.Transcation) bool) []domain.Transcation {
+	transcations := make([]domain.Transcation, 0)
+	for _, t := range s.transcations {
+		if filter(t) {
+			transcations = append(transcations, t)
+		}
+	}
+
+	sort.Slice(transcations, func(i, j int) bool {
+		if !transcations[i].EventAt.Equal(transcations[j].EventAt) {
+			return transcations[i].EventAt.Before(transcations[j].EventAt)
+		}
+
+		return transcations[i].ID < transcations[j].ID
+	})
+
+	return transcations
+}
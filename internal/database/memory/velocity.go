@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// checkVelocity fails with a domain.VelocityError when the debit would exceed
+// the velocity limits of its account
+func (s *state) checkVelocity(transcation domain.Transcation, limits domain.VelocityLimits) error {
+	if _, ok := s.accounts[transcation.AccountID]; !ok {
+		return domain.ErrNotFound
+	}
+
+	debits := s.velocityDebits(transcation.AccountID, transcation.EventAt.Add(-limits.Window()))
+
+	// debit amounts are negative
+	return limits.Check(debits, -transcation.Amount, transcation.EventAt)
+}
+
+// velocityDebits returns the debits of the account made after since, oldest
+// first, including the ones held for review
+func (s *state) velocityDebits(accountID string, since time.Time) []domain.VelocityDebit {
+	debits := make([]domain.VelocityDebit, 0)
+	for _, t := range s.transcations {
+		if t.AccountID != accountID || !t.IsDebit() || t.Status == domain.TranscationRejected || !t.EventAt.After(since) {
+			continue
+		}
+
+		debits = append(debits, domain.VelocityDebit{EventAt: t.EventAt, Amount: -t.Amount})
+	}
+
+	sort.Slice(debits, func(i, j int) bool {
+		return debits[i].EventAt.Before(debits[j].EventAt)
+	})
+
+	return debits
+}
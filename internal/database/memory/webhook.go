@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+func (r *Repo) CreateWebhookSubscription(ctx context.Context, sub domain.WebhookSubscription) error {
+	return r.write(ctx, func(s *state) error {
+		if _, ok := s.subscriptions[sub.ID]; ok {
+			return errDuplicateKey
+		}
+
+		sub.EventTypes = append([]string(nil), sub.EventTypes...)
+		s.subscriptions[sub.ID] = sub
+
+		return nil
+	})
+}
+
+func (r *Repo) GetWebhookSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	var sub domain.WebhookSubscription
+	err := r.read(ctx, func(s *state) error {
+		found, ok := s.subscriptions[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		sub = found
+		sub.EventTypes = append([]string(nil), found.EventTypes...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+func (r *Repo) ListWebhookSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	subs := make([]domain.WebhookSubscription, 0)
+	err := r.read(ctx, func(s *state) error {
+		for _, sub := range s.subscriptions {
+			sub.EventTypes = append([]string(nil), sub.EventTypes...)
+			subs = append(subs, sub)
+		}
+
+		return nil
+	})
+
+	sort.Slice(subs, func(i, j int) bool {
+		if !subs[i].CreatedAt.Equal(subs[j].CreatedAt) {
+			return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+		}
+
+		return subs[i].ID < subs[j].ID
+	})
+
+	return subs, err
+}
+
+// DeleteWebhookSubscription deletes the subscription along with its
+// deliveries
+func (r *Repo) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	return r.write(ctx, func(s *state) error {
+		if _, ok := s.subscriptions[id]; !ok {
+			return domain.ErrNotFound
+		}
+
+		delete(s.subscriptions, id)
+		for deliveryID, d := range s.deliveries {
+			if d.SubscriptionID == id {
+				delete(s.deliveries, deliveryID)
+			}
+		}
+
+		return nil
+	})
+}
+
+// CreateWebhookDeliveries enqueues deliveries, an event already enqueued for a
+// subscription is ignored so that relaying the same event twice is harmless
+func (r *Repo) CreateWebhookDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return r.write(ctx, func(s *state) error {
+		enqueued := make(map[[2]string]bool, len(s.deliveries))
+		for _, d := range s.deliveries {
+			enqueued[[2]string{d.SubscriptionID, d.EventID}] = true
+		}
+
+		for _, d := range deliveries {
+			if _, ok := s.subscriptions[d.SubscriptionID]; !ok {
+				return domain.ErrNotFound
+			}
+
+			key := [2]string{d.SubscriptionID, d.EventID}
+			if enqueued[key] {
+				continue
+			}
+
+			if _, ok := s.deliveries[d.ID]; ok {
+				return errDuplicateKey
+			}
+
+			d.Payload = append([]byte(nil), d.Payload...)
+			d.URL = ""
+			d.Secret = ""
+			s.deliveries[d.ID] = d
+			enqueued[key] = true
+		}
+
+		return nil
+	})
+}
+
+func (r *Repo) GetWebhookDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	var d domain.WebhookDelivery
+	err := r.read(ctx, func(s *state) error {
+		found, ok := s.deliveries[id]
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		d = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// ListWebhookDeliveries returns the deliveries of the subscription, newest
+// first
+func (r *Repo) ListWebhookDeliveries(ctx context.Context, subscriptionID string) ([]domain.WebhookDelivery, error) {
+	deliveries := make([]domain.WebhookDelivery, 0)
+	err := r.read(ctx, func(s *state) error {
+		for _, d := range s.deliveries {
+			if d.SubscriptionID == subscriptionID {
+				deliveries = append(deliveries, d)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+
+		return deliveries[i].ID > deliveries[j].ID
+	})
+
+	return deliveries, err
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due
+// at now, together with the url and secret of their subscription. Claimed
+// deliveries are leased by pushing next_attempt_at forward.
+func (r *Repo) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	deliveries := make([]domain.WebhookDelivery, 0)
+	err := r.write(ctx, func(s *state) error {
+		for _, d := range s.deliveries {
+			if d.Status == domain.DeliveryStatusPending && !d.NextAttemptAt.After(now) {
+				deliveries = append(deliveries, d)
+			}
+		}
+
+		sort.Slice(deliveries, func(i, j int) bool {
+			if !deliveries[i].NextAttemptAt.Equal(deliveries[j].NextAttemptAt) {
+				return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+			}
+
+			return deliveries[i].ID < deliveries[j].ID
+		})
+
+		if len(deliveries) > limit {
+			deliveries = deliveries[:limit]
+		}
+
+		for i, d := range deliveries {
+			leased := d
+			leased.NextAttemptAt = now.Add(lease)
+			s.deliveries[d.ID] = leased
+
+			sub := s.subscriptions[d.SubscriptionID]
+			deliveries[i].URL = sub.URL
+			deliveries[i].Secret = sub.Secret
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *Repo) UpdateWebhookDelivery(ctx context.Context, d domain.WebhookDelivery) error {
+	return r.write(ctx, func(s *state) error {
+		stored, ok := s.deliveries[d.ID]
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		stored.Status = d.Status
+		stored.Attempts = d.Attempts
+		stored.NextAttemptAt = d.NextAttemptAt
+		stored.LastStatusCode = d.LastStatusCode
+		stored.LastError = d.LastError
+		stored.UpdatedAt = d.UpdatedAt
+		s.deliveries[d.ID] = stored
+
+		return nil
+	})
+}
@@ -2,9 +2,12 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/madhurikadam/app-transcation/internal/domain"
@@ -15,6 +18,16 @@ type Repo struct {
 	pgx  *pgxpool.Pool
 }
 
+// txKey carries the transaction of a unit of work in the context
+type txKey struct{}
+
+// querier runs the queries of the repo
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 func NewRepo(pgx *pgxpool.Pool, psql squirrel.StatementBuilderType) Repo {
 	return Repo{
 		pgx:  pgx,
@@ -22,37 +35,94 @@ func NewRepo(pgx *pgxpool.Pool, psql squirrel.StatementBuilderType) Repo {
 	}
 }
 
-func (r *Repo) CreateAccount(ctx context.Context, account domain.Account) error {
+// WithinTx runs fn with a context whose repo calls share one transaction,
+// committed when fn succeeds. Reads of an account lock it until then, so that
+// the units of work of an account run one after the other. fn is run again
+// when the transaction fails with a serialization failure or a deadlock.
+func (r *Repo) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	// a unit of work nested in another one joins it
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
 
-	stmt := r.psql.
-		Insert(TableAccounts).
-		Columns(
-			ID,
-			DocumentNumber,
-			CreditLimit,
-			WithdrewalLimit,
-			CreatedAt,
-			UpdatedAt,
-		).
-		Values(
-			account.ID,
-			account.DocumentNumber,
-			account.CreaditLimit,
-			account.WithdrawalLimit,
-			account.CreatedAt,
-			account.UpdatedAt,
-		)
+	pending := &pendingDenials{}
+	err := r.withTx(ctx, func(tx pgx.Tx) error {
+		// a retried attempt denies again, only the last one is recorded
+		pending.denials = nil
 
-	query, params, err := stmt.ToSql()
-	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
-	}
+		return fn(context.WithValue(context.WithValue(ctx, txKey{}, tx), denialsKey{}, pending))
+	})
+
+	r.recordDenials(ctx, pending.denials)
+
+	return err
+}
+
+// txFromContext returns the transaction of the unit of work of ctx, nil
+// outside of one
+func txFromContext(ctx context.Context) pgx.Tx {
+	tx, _ := ctx.Value(txKey{}).(pgx.Tx)
+	return tx
+}
 
-	if _, err := r.pgx.Exec(ctx, query, params...); err != nil {
-		return err
+// db is the transaction of the unit of work of ctx or the pool
+func (r *Repo) db(ctx context.Context) querier {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
 	}
 
-	return nil
+	return r.pgx
+}
+
+// CreateAccount writes the account and its account.created event, the event
+// history of an account starts with its initial limits
+func (r *Repo) CreateAccount(ctx context.Context, account domain.Account) error {
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		stmt := r.psql.
+			Insert(TableAccounts).
+			Columns(
+				ID,
+				DocumentNumber,
+				CreditLimit,
+				WithdrewalLimit,
+				OwnerID,
+				CreatedAt,
+				UpdatedAt,
+			).
+			Values(
+				account.ID,
+				account.DocumentNumber,
+				account.CreaditLimit,
+				account.WithdrawalLimit,
+				account.OwnerID,
+				account.CreatedAt,
+				account.UpdatedAt,
+			)
+
+		query, params, err := stmt.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, query, params...); err != nil {
+			return err
+		}
+
+		if err := r.audit(ctx, domain.AuditAccountCreated, TableAccounts, account.ID, nil, account, tx); err != nil {
+			return err
+		}
+
+		event, err := newEvent(domain.EventAccountCreated, account.ID, domain.AccountLimits{
+			AccountID:       account.ID,
+			WithdrawalLimit: account.WithdrawalLimit,
+			CreditLimit:     account.CreaditLimit,
+		}, account.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		return r.createEvent(ctx, event, tx)
+	})
 }
 
 func (r Repo) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
@@ -62,12 +132,17 @@ func (r Repo) GetAccount(ctx context.Context, id string) (*domain.Account, error
 			DocumentNumber,
 			CreditLimit,
 			WithdrewalLimit,
+			OwnerID,
 			CreatedAt,
 			UpdatedAt,
 		).
 		From(TableAccounts).
 		Where(squirrel.Eq{ID: id})
 
+	if txFromContext(ctx) != nil {
+		stmt = stmt.Suffix("FOR NO KEY UPDATE")
+	}
+
 	query, params, err := stmt.ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build query: %w", err)
@@ -75,75 +150,197 @@ func (r Repo) GetAccount(ctx context.Context, id string) (*domain.Account, error
 
 	var account domain.Account
 
-	err = r.pgx.
+	err = r.db(ctx).
 		QueryRow(ctx, query, params...).
 		Scan(
 			&account.ID,
 			&account.DocumentNumber,
 			&account.CreaditLimit,
 			&account.WithdrawalLimit,
+			&account.OwnerID,
 			&account.CreatedAt,
 			&account.UpdatedAt,
 		)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+
 		return nil, err
 	}
 
 	return &account, nil
 }
 
-func (r *Repo) updateDebitLimit(ctx context.Context, accountID string, amount float64, tx pgx.Tx) error {
+// UpdateAccountLimits sets the limits of the account given in req and writes
+// an account.limits_changed event
+func (r *Repo) UpdateAccountLimits(ctx context.Context, accountID string, req domain.AccountLimitsReq, at time.Time) (*domain.AccountLimits, error) {
 	stmt := r.psql.
 		Update(TableAccounts).
-		Set(WithdrewalLimit,
-			squirrel.Expr("withdrawal_limit + ?", -amount),
-		).Where(squirrel.Eq{ID: accountID})
+		Set(UpdatedAt, at).
+		Where(squirrel.Eq{ID: accountID}).
+		Suffix(returningLimits)
 
-	query, params, err := stmt.ToSql()
-	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+	if req.WithdrawalLimit != nil {
+		stmt = stmt.Set(WithdrewalLimit, *req.WithdrawalLimit)
+	}
+
+	if req.CreditLimit != nil {
+		stmt = stmt.Set(CreditLimit, *req.CreditLimit)
 	}
 
-	if _, err := tx.Exec(ctx, query, params...); err != nil {
-		return err
+	var limits *domain.AccountLimits
+	err := r.withTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		limits, err = r.updateLimits(ctx, accountID, stmt, domain.ErrNotFound, tx)
+		if err != nil {
+			return err
+		}
+
+		event, err := newEvent(domain.EventAccountLimitsChanged, accountID, limits, at)
+		if err != nil {
+			return err
+		}
+
+		return r.createEvent(ctx, event, tx)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return limits, nil
 }
 
-func (r *Repo) updateCreditLimit(ctx context.Context, accountID string, amount float64, tx pgx.Tx) error {
+// updateDebitLimit consumes the withdrawal limit of the account by the debit
+// amount, which is negative, and returns the new limits. It fails with
+// ErrExceedWithdrawalLimit when the limit left is too low.
+func (r *Repo) updateDebitLimit(ctx context.Context, accountID string, amount float64, tx pgx.Tx) (*domain.AccountLimits, error) {
+	stmt := r.psql.
+		Update(TableAccounts).
+		Set(WithdrewalLimit,
+			squirrel.Expr("withdrawal_limit + ?", amount),
+		).Where(squirrel.Eq{ID: accountID}).
+		Where(squirrel.Expr("withdrawal_limit + ? >= 0", amount)).
+		Suffix(returningLimits)
+
+	return r.updateLimits(ctx, accountID, stmt, domain.ErrExceedWithdrawalLimit, tx)
+}
+
+// updateCreditLimit consumes the credit limit of the account by the credit
+// amount and returns the new limits. It fails with ErrExceedCreditLimit when
+// the limit left is too low.
+func (r *Repo) updateCreditLimit(ctx context.Context, accountID string, amount float64, tx pgx.Tx) (*domain.AccountLimits, error) {
 	stmt := r.psql.
 		Update(TableAccounts).
 		Set(CreditLimit,
 			squirrel.Expr("credit_limit - ?", amount),
-		).Where(squirrel.Eq{ID: accountID})
+		).Where(squirrel.Eq{ID: accountID}).
+		Where(squirrel.Expr("credit_limit - ? >= 0", amount)).
+		Suffix(returningLimits)
+
+	return r.updateLimits(ctx, accountID, stmt, domain.ErrExceedCreditLimit, tx)
+}
+
+const returningLimits = "RETURNING id, withdrawal_limit, credit_limit"
+
+// updateLimits runs stmt updating the limits of the account and records the
+// change in the audit log. The account is locked first so that conditions of
+// stmt hold until tx ends, notUpdated is returned when they don't match.
+func (r *Repo) updateLimits(ctx context.Context, accountID string, stmt squirrel.UpdateBuilder, notUpdated error, tx pgx.Tx) (*domain.AccountLimits, error) {
+	before, err := r.lockLimits(ctx, accountID, tx)
+	if err != nil {
+		return nil, err
+	}
 
 	query, params, err := stmt.ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var limits domain.AccountLimits
+	if err := tx.QueryRow(ctx, query, params...).Scan(
+		&limits.AccountID,
+		&limits.WithdrawalLimit,
+		&limits.CreditLimit,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notUpdated
+		}
+
+		return nil, err
 	}
 
-	if _, err := tx.Exec(ctx, query, params...); err != nil {
-		return err
+	if err := r.audit(ctx, domain.AuditAccountLimitsChanged, TableAccounts, accountID, before, limits, tx); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &limits, nil
 }
 
-func (r *Repo) updateBalance(ctx context.Context, id string, amount float64, tx pgx.Tx) error {
+// lockLimits returns the limits of the account, locking it until tx ends. The
+// lock doesn't conflict with the key share lock taken by inserting rows
+// referencing the account, writers can't deadlock on it.
+func (r *Repo) lockLimits(ctx context.Context, accountID string, tx pgx.Tx) (*domain.AccountLimits, error) {
+	stmt := r.psql.
+		Select(ID, WithdrewalLimit, CreditLimit).
+		From(TableAccounts).
+		Where(squirrel.Eq{ID: accountID}).
+		Suffix("FOR NO KEY UPDATE")
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var limits domain.AccountLimits
+	if err := tx.QueryRow(ctx, query, params...).Scan(
+		&limits.AccountID,
+		&limits.WithdrawalLimit,
+		&limits.CreditLimit,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &limits, nil
+}
+
+// updateBalance sets the balance of a debit, it reports whether the balance
+// actually changed
+func (r *Repo) updateBalance(ctx context.Context, id string, amount float64, tx pgx.Tx) (bool, error) {
+	before, err := r.lockTranscationState(ctx, id, tx)
+	if err != nil {
+		return false, err
+	}
+
 	stmt := r.psql.
 		Update(TableTranscations).
-		Set(Balance, amount).Where(squirrel.Eq{ID: id})
+		Set(Balance, amount).
+		Where(squirrel.Eq{ID: id}).
+		Where(squirrel.Expr("balance IS DISTINCT FROM ?", amount))
 
 	query, params, err := stmt.ToSql()
 	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+		return false, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, query, params...)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
 	}
 
-	if _, err := tx.Exec(ctx, query, params...); err != nil {
-		return err
+	after := transcationState{Status: before.Status, Balance: &amount}
+	if err := r.audit(ctx, domain.AuditTranscationBalanceChanged, TableTranscations, id, before, after, tx); err != nil {
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
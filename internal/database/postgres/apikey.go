@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// apiKeyState is the audit snapshot of the validity of a key
+type apiKeyState struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+var apiKeyColumns = []string{
+	ID,
+	Name,
+	Subject,
+	Roles,
+	Scopes,
+	RateLimit,
+	Hash,
+	RotatedFrom,
+	ExpiresAt,
+	RevokedAt,
+	LastUsedAt,
+	CreatedAt,
+}
+
+func (r *Repo) CreateAPIKey(ctx context.Context, key domain.APIKey) error {
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		return r.createAPIKey(ctx, key, tx)
+	})
+}
+
+// RotateAPIKey creates the key replacing key.RotatedFrom and shortens the
+// validity of the replaced key to oldExpiresAt, it fails with ErrNotFound when
+// the replaced key is unknown or revoked
+func (r *Repo) RotateAPIKey(ctx context.Context, key domain.APIKey, oldExpiresAt time.Time) error {
+	if key.RotatedFrom == nil {
+		return domain.ErrNotFound
+	}
+
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		stmt := r.psql.
+			Update(TableAPIKeys).
+			Set(ExpiresAt, squirrel.Expr("LEAST(COALESCE(expires_at, ?), ?)", oldExpiresAt, oldExpiresAt)).
+			Where(squirrel.Eq{ID: *key.RotatedFrom}).
+			Where(squirrel.Eq{RevokedAt: nil}).
+			Suffix("RETURNING expires_at, (SELECT expires_at FROM api_keys old WHERE old.id = api_keys.id)")
+
+		query, params, err := stmt.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+
+		var before, after apiKeyState
+		if err := tx.QueryRow(ctx, query, params...).Scan(&after.ExpiresAt, &before.ExpiresAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrNotFound
+			}
+
+			return err
+		}
+
+		if err := r.audit(ctx, domain.AuditAPIKeyExpiryChanged, TableAPIKeys, *key.RotatedFrom, before, after, tx); err != nil {
+			return err
+		}
+
+		return r.createAPIKey(ctx, key, tx)
+	})
+}
+
+func (r *Repo) createAPIKey(ctx context.Context, key domain.APIKey, tx pgx.Tx) error {
+	stmt := r.psql.
+		Insert(TableAPIKeys).
+		Columns(
+			ID,
+			Name,
+			Subject,
+			Roles,
+			Scopes,
+			RateLimit,
+			Hash,
+			RotatedFrom,
+			ExpiresAt,
+			CreatedAt,
+		).
+		Values(
+			key.ID,
+			key.Name,
+			key.Subject,
+			key.Roles,
+			key.Scopes,
+			key.RateLimit,
+			key.Hash,
+			key.RotatedFrom,
+			key.ExpiresAt,
+			key.CreatedAt,
+		)
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, query, params...); err != nil {
+		return err
+	}
+
+	return r.audit(ctx, domain.AuditAPIKeyCreated, TableAPIKeys, key.ID, nil, key, tx)
+}
+
+func (r *Repo) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	query, params, err := r.psql.
+		Select(apiKeyColumns...).
+		From(TableAPIKeys).
+		Where(squirrel.Eq{ID: id}).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := scanAPIKeys(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	return &keys[0], nil
+}
+
+func (r *Repo) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	query, params, err := r.psql.
+		Select(apiKeyColumns...).
+		From(TableAPIKeys).
+		OrderBy(CreatedAt).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAPIKeys(rows)
+}
+
+// RevokeAPIKey revokes the key at the given time, revoking a revoked key fails
+// with ErrNotFound
+func (r *Repo) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	stmt := r.psql.
+		Update(TableAPIKeys).
+		Set(RevokedAt, at).
+		Where(squirrel.Eq{ID: id}).
+		Where(squirrel.Eq{RevokedAt: nil}).
+		Suffix("RETURNING expires_at, revoked_at")
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		var after apiKeyState
+		if err := tx.QueryRow(ctx, query, params...).Scan(&after.ExpiresAt, &after.RevokedAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrNotFound
+			}
+
+			return err
+		}
+
+		before := apiKeyState{ExpiresAt: after.ExpiresAt}
+		return r.audit(ctx, domain.AuditAPIKeyRevoked, TableAPIKeys, id, before, after, tx)
+	})
+}
+
+// TouchAPIKey records the use of the key, last_used_at is written at most once
+// per interval to keep busy keys from turning every request into a write. Uses
+// are not audited.
+func (r *Repo) TouchAPIKey(ctx context.Context, id string, at time.Time, interval time.Duration) error {
+	stmt := r.psql.
+		Update(TableAPIKeys).
+		Set(LastUsedAt, at).
+		Where(squirrel.Eq{ID: id}).
+		Where(squirrel.Or{
+			squirrel.Eq{LastUsedAt: nil},
+			squirrel.Lt{LastUsedAt: at.Add(-interval)},
+		})
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := r.db(ctx).Exec(ctx, query, params...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func scanAPIKeys(rows pgx.Rows) ([]domain.APIKey, error) {
+	defer rows.Close()
+
+	keys := make([]domain.APIKey, 0)
+	for rows.Next() {
+		var key domain.APIKey
+		if err := rows.Scan(
+			&key.ID,
+			&key.Name,
+			&key.Subject,
+			&key.Roles,
+			&key.Scopes,
+			&key.RateLimit,
+			&key.Hash,
+			&key.RotatedFrom,
+			&key.ExpiresAt,
+			&key.RevokedAt,
+			&key.LastUsedAt,
+			&key.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
@@ -0,0 +1,375 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+	postgresPkg "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+	"github.com/madhurikadam/app-transcation/pkg/requestid"
+)
+
+// auditLockKey namespaces the advisory locks serializing the writers of an
+// entity in the audit log, its hash chain needs the entries appended one
+// after the other. Writers of other entities append side by side.
+const auditLockKey = 0x61756469
+
+var auditColumns = []string{
+	Seq,
+	PrevSeq,
+	ID,
+	Actor,
+	RequestID,
+	Action,
+	EntityType,
+	EntityID,
+	Before,
+	After,
+	CreatedAt,
+	PrevHash,
+	Hash,
+}
+
+// auditedTx is a transaction of the repo, it buffers the audit entries of the
+// writes and appends them to the audit log when committing. The audit locks
+// are taken last so that writers can't deadlock on them while locking rows.
+type auditedTx struct {
+	pgx.Tx
+
+	repo    *Repo
+	entries []domain.AuditEntry
+}
+
+// auditedBeginner begins the transactions of the repo
+type auditedBeginner struct {
+	repo *Repo
+}
+
+func (b auditedBeginner) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := b.repo.pgx.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditedTx{Tx: tx, repo: b.repo}, nil
+}
+
+// withTx runs fn in a transaction whose writes can be audited, it is rolled
+// back when fn fails and run again on serialization failures and deadlocks.
+// Within a unit of work fn runs in its transaction.
+func (r *Repo) withTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	// the unit of work of ctx commits the writes
+	if tx := txFromContext(ctx); tx != nil {
+		return fn(tx)
+	}
+
+	return postgresPkg.WithTx(ctx, auditedBeginner{repo: r}, postgresPkg.TxOptions{}, fn)
+}
+
+func (t *auditedTx) Commit(ctx context.Context) error {
+	if err := t.repo.appendAudit(ctx, t.entries, t.Tx); err != nil {
+		return err
+	}
+
+	return t.Tx.Commit(ctx)
+}
+
+// audit records a write of tx in the audit log, the actor and the request id
+// come from ctx. before and after are the snapshots of the entity, nil when it
+// didn't exist.
+func (r *Repo) audit(ctx context.Context, action, entityType, entityID string, before, after interface{}, tx pgx.Tx) error {
+	atx, ok := tx.(*auditedTx)
+	if !ok {
+		return fmt.Errorf("audited writes need a transaction begun by the repo")
+	}
+
+	entry := domain.AuditEntry{
+		ID:         uuid.NewString(),
+		Actor:      domain.AuditActorSystem,
+		RequestID:  requestid.FromContext(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		// timestamp columns keep microseconds, the hash must match what is read
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		entry.Actor = principal.Subject
+	}
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return err
+	}
+
+	if entry.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	atx.entries = append(atx.entries, entry)
+
+	return nil
+}
+
+// auditTip is the latest entry of an entity in the audit log
+type auditTip struct {
+	seq  int64
+	hash string
+}
+
+// appendAudit chains the entries to the ones of their entity and writes them.
+// The entities are locked in order so that writers can't deadlock on them.
+func (r *Repo) appendAudit(ctx context.Context, entries []domain.AuditEntry, tx pgx.Tx) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	streams := make([]string, 0, len(entries))
+	tips := make(map[string]*auditTip, len(entries))
+	for _, entry := range entries {
+		if _, ok := tips[entry.Stream()]; ok {
+			continue
+		}
+
+		streams = append(streams, entry.Stream())
+		tips[entry.Stream()] = nil
+	}
+	sort.Strings(streams)
+
+	for _, stream := range streams {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", auditLockKey, stream); err != nil {
+			return fmt.Errorf("failed to lock audit log: %w", err)
+		}
+	}
+
+	for _, entry := range entries {
+		tip := tips[entry.Stream()]
+		if tip == nil {
+			last, err := r.lastAuditEntry(ctx, entry.EntityType, entry.EntityID, tx)
+			if err != nil {
+				return err
+			}
+
+			tip = last
+		}
+
+		entry.PrevSeq = tip.seq
+		entry.PrevHash = tip.hash
+
+		hash, err := entry.ComputeHash()
+		if err != nil {
+			return err
+		}
+		entry.Hash = hash
+
+		seq, err := r.insertAuditEntry(ctx, entry, tx)
+		if err != nil {
+			return err
+		}
+
+		tips[entry.Stream()] = &auditTip{seq: seq, hash: hash}
+	}
+
+	return nil
+}
+
+func (r *Repo) insertAuditEntry(ctx context.Context, entry domain.AuditEntry, tx pgx.Tx) (int64, error) {
+	var prevSeq *int64
+	if entry.PrevSeq != 0 {
+		prevSeq = &entry.PrevSeq
+	}
+
+	query, params, err := r.psql.
+		Insert(TableAuditLog).
+		Columns(auditColumns[1:]...).
+		Values(
+			prevSeq,
+			entry.ID,
+			entry.Actor,
+			entry.RequestID,
+			entry.Action,
+			entry.EntityType,
+			entry.EntityID,
+			nullableJSON(entry.Before),
+			nullableJSON(entry.After),
+			entry.CreatedAt,
+			entry.PrevHash,
+			entry.Hash,
+		).
+		Suffix("RETURNING " + Seq).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var seq int64
+	if err := tx.QueryRow(ctx, query, params...).Scan(&seq); err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+// lastAuditEntry returns the latest entry of the entity, an empty tip when it
+// has none
+func (r *Repo) lastAuditEntry(ctx context.Context, entityType, entityID string, tx pgx.Tx) (*auditTip, error) {
+	query, params, err := r.psql.
+		Select(Seq, Hash).
+		From(TableAuditLog).
+		Where(squirrel.Eq{EntityType: entityType, EntityID: entityID}).
+		OrderBy(Seq + " DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var tip auditTip
+	if err := tx.QueryRow(ctx, query, params...).Scan(&tip.seq, &tip.hash); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &auditTip{}, nil
+		}
+
+		return nil, err
+	}
+
+	return &tip, nil
+}
+
+// pendingDenials are the denials of a unit of work, they are written once it
+// ends
+type pendingDenials struct {
+	denials []policy.Denial
+}
+
+// denialsKey carries the pending denials of a unit of work in the context
+type denialsKey struct{}
+
+// RecordDenial writes the denied action to the audit log. Within a unit of
+// work it is written once the unit of work ends, committed or rolled back:
+// the unit of work holds a connection and the locks of its reads, writing on
+// a second connection meanwhile could drain the pool.
+func (r *Repo) RecordDenial(ctx context.Context, denial policy.Denial) {
+	if pending, ok := ctx.Value(denialsKey{}).(*pendingDenials); ok {
+		pending.denials = append(pending.denials, denial)
+		return
+	}
+
+	r.recordDenials(ctx, []policy.Denial{denial})
+}
+
+// recordDenials writes the denials to the audit log in a transaction of their
+// own, failures are logged
+func (r *Repo) recordDenials(ctx context.Context, denials []policy.Denial) {
+	if len(denials) == 0 {
+		return
+	}
+
+	err := r.withTx(ctx, func(tx pgx.Tx) error {
+		for _, denial := range denials {
+			subject := denial.Subject
+			if subject == "" {
+				subject = domain.AuditAnonymous
+			}
+
+			if err := r.audit(ctx, domain.AuditAccessDenied, domain.AuditEntityAccess, subject, nil, denial, tx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("denials", len(denials)).Error("failed to record access denials")
+	}
+}
+
+// ListAuditEntries returns up to limit entries of the audit log written after
+// the entry afterSeq in order
+func (r *Repo) ListAuditEntries(ctx context.Context, afterSeq int64, limit int) ([]domain.AuditEntry, error) {
+	stmt := r.psql.
+		Select(auditColumns...).
+		From(TableAuditLog).
+		Where(squirrel.Gt{Seq: afterSeq}).
+		OrderBy(Seq).
+		Limit(uint64(limit))
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]domain.AuditEntry, 0)
+	for rows.Next() {
+		var (
+			entry         domain.AuditEntry
+			prevSeq       *int64
+			before, after []byte
+		)
+		if err := rows.Scan(
+			&entry.Seq,
+			&prevSeq,
+			&entry.ID,
+			&entry.Actor,
+			&entry.RequestID,
+			&entry.Action,
+			&entry.EntityType,
+			&entry.EntityID,
+			&before,
+			&after,
+			&entry.CreatedAt,
+			&entry.PrevHash,
+			&entry.Hash,
+		); err != nil {
+			return nil, err
+		}
+
+		if prevSeq != nil {
+			entry.PrevSeq = *prevSeq
+		}
+		entry.Before = before
+		entry.After = after
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit snapshot: %w", err)
+	}
+
+	return data, nil
+}
+
+// nullableJSON stores a missing snapshot as NULL
+func nullableJSON(data json.RawMessage) interface{} {
+	if data == nil {
+		return nil
+	}
+
+	return []byte(data)
+}
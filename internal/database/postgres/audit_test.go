@@ -0,0 +1,214 @@
+package postgres_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/audit"
+	"github.com/madhurikadam/app-transcation/internal/database/postgres"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/internal/service"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+)
+
+// auditEntries returns the entries of the entity in the audit log
+func auditEntries(t *testing.T, repo *postgres.Repo, entityType, entityID string) []domain.AuditEntry {
+	t.Helper()
+
+	all, err := repo.ListAuditEntries(context.Background(), 0, 1000000)
+	require.NoError(t, err)
+
+	entries := make([]domain.AuditEntry, 0)
+	for _, entry := range all {
+		if entry.EntityType == entityType && entry.EntityID == entityID {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func TestAuditChainsPerEntity(t *testing.T) {
+	repo := testRepo(t)
+	svc := service.New(repo)
+	ctx := context.Background()
+
+	accounts := make([]*domain.Account, 0, 3)
+	for i := 0; i < 3; i++ {
+		account, err := svc.CreateAccount(ctx, uuid.NewString())
+		require.NoError(t, err)
+
+		accounts = append(accounts, account)
+	}
+
+	// the writers of every account append to the log side by side
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < cap(errs); i++ {
+		limit := float64(1000 + i)
+		account := accounts[i%len(accounts)]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := svc.UpdateAccountLimits(ctx, account.ID, domain.AccountLimitsReq{CreditLimit: &limit})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	for _, account := range accounts {
+		entries := auditEntries(t, repo, postgres.TableAccounts, account.ID)
+		require.Len(t, entries, 11)
+
+		require.Zero(t, entries[0].PrevSeq)
+		for i := 1; i < len(entries); i++ {
+			require.Equal(t, entries[i-1].Seq, entries[i].PrevSeq)
+			require.Equal(t, entries[i-1].Hash, entries[i].PrevHash)
+		}
+	}
+
+	report, err := audit.Verify(ctx, repo, 0)
+	require.NoError(t, err)
+	require.True(t, report.OK(), report.Breaks)
+}
+
+func TestAuditWebhookDeliveries(t *testing.T) {
+	repo := testRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	sub := domain.WebhookSubscription{
+		ID:         uuid.NewString(),
+		URL:        "https://example.com/hooks",
+		EventTypes: []string{domain.EventAccountCreated},
+		Secret:     "whsec_test",
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	require.NoError(t, repo.CreateWebhookSubscription(ctx, sub))
+
+	delivery := domain.WebhookDelivery{
+		ID:             uuid.NewString(),
+		SubscriptionID: sub.ID,
+		EventID:        uuid.NewString(),
+		EventType:      domain.EventAccountCreated,
+		Payload:        json.RawMessage(`{}`),
+		Status:         domain.DeliveryStatusPending,
+		NextAttemptAt:  now.Add(-time.Minute),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	require.NoError(t, repo.CreateWebhookDeliveries(ctx, []domain.WebhookDelivery{delivery}))
+
+	claimed, err := repo.ClaimWebhookDeliveries(ctx, now, time.Minute, 1000)
+	require.NoError(t, err)
+	require.NotEmpty(t, claimed)
+
+	delivery.Status = domain.DeliveryStatusDelivered
+	delivery.Attempts = 1
+	require.NoError(t, repo.UpdateWebhookDelivery(ctx, delivery))
+
+	entries := auditEntries(t, repo, postgres.TableWebhookDeliveries, delivery.ID)
+	require.Len(t, entries, 2)
+	require.Equal(t, domain.AuditWebhookDeliveryClaimed, entries[0].Action)
+	require.Equal(t, domain.AuditWebhookDeliveryUpdated, entries[1].Action)
+	require.JSONEq(t, `"delivered"`, string(mustField(t, entries[1].After, "status")))
+
+	delivery.ID = uuid.NewString()
+	require.ErrorIs(t, repo.UpdateWebhookDelivery(ctx, delivery), domain.ErrNotFound)
+}
+
+func TestAuditDenials(t *testing.T) {
+	repo := testRepo(t)
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: uuid.NewString(), Roles: []string{policy.RoleAuditor}})
+	authorizer := policy.New(repo)
+
+	// the denial is kept when the unit of work it happened in rolls back
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		return authorizer.Authorize(ctx, policy.ActionUpdateAccountLimits, policy.Resource{AccountID: "account-1"})
+	})
+	require.True(t, errors.Is(err, policy.ErrForbidden))
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	entries := auditEntries(t, repo, domain.AuditEntityAccess, principal.Subject)
+	require.Len(t, entries, 1)
+	require.Equal(t, domain.AuditAccessDenied, entries[0].Action)
+	require.Equal(t, principal.Subject, entries[0].Actor)
+	require.JSONEq(t, `"account:update_limits"`, string(mustField(t, entries[0].After, "action")))
+}
+
+func TestAuditConcurrentDenials(t *testing.T) {
+	repo := testRepo(t)
+	ctx := context.Background()
+
+	owner := service.New(repo)
+	account, err := owner.CreateAccount(ctx, uuid.NewString())
+	require.NoError(t, err)
+
+	// denials within a unit of work must not need a second connection, two
+	// callers holding the only two of the pool would wait on each other
+	cfg, err := pgxpool.ParseConfig(testDSN(t))
+	require.NoError(t, err)
+	cfg.MaxConns = 2
+
+	pool, err := pgxpool.ConnectConfig(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	small := postgres.NewRepo(pool, postgres.SetupPSQL())
+	svc := service.New(&small, service.WithAuthorizer(policy.New(&small)))
+
+	principal := &auth.Principal{Subject: uuid.NewString(), Roles: []string{policy.RoleCustomer}}
+	ctx, cancel := context.WithTimeout(auth.WithPrincipal(ctx, principal), 30*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := svc.CreateTranscation(ctx, domain.Transcation{AccountID: account.ID, OperationTypeID: 1, Amount: 10})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.ErrorIs(t, err, policy.ErrForbidden)
+	}
+
+	entries := auditEntries(t, repo, domain.AuditEntityAccess, principal.Subject)
+	require.Len(t, entries, cap(errs))
+	for _, entry := range entries {
+		require.Equal(t, domain.AuditAccessDenied, entry.Action)
+	}
+}
+
+func mustField(t *testing.T, snapshot json.RawMessage, field string) json.RawMessage {
+	t.Helper()
+
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(snapshot, &fields))
+
+	return fields[field]
+}
@@ -0,0 +1,13 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/madhurikadam/app-transcation/internal/database/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repo {
+		return testRepo(t)
+	})
+}
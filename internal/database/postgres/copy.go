@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// CopyHistories bulk loads accounts along with their transcations and events
+// in one transaction, it is how large data sets are seeded. The rows aren't
+// audited and the events are marked as published, subscribers never missed
+// them.
+func (r *Repo) CopyHistories(ctx context.Context, accounts []domain.Account, transcations []domain.Transcation, events []domain.Event) error {
+	accountRows := make([][]interface{}, 0, len(accounts))
+	for _, account := range accounts {
+		updatedAt := account.CreatedAt
+		if account.UpdatedAt != nil {
+			updatedAt = *account.UpdatedAt
+		}
+
+		accountRows = append(accountRows, []interface{}{
+			account.ID,
+			account.DocumentNumber,
+			account.CreaditLimit,
+			account.WithdrawalLimit,
+			account.OwnerID,
+			account.CreatedAt,
+			updatedAt,
+		})
+	}
+
+	transcationRows := make([][]interface{}, 0, len(transcations))
+	for _, t := range transcations {
+		transcationRows = append(transcationRows, []interface{}{
+			t.ID,
+			t.AccountID,
+			t.OperationTypeID,
+			t.Amount,
+			t.EventAt,
+			t.Balance,
+			t.Status,
+		})
+	}
+
+	// events keep their order, the sequence of the table numbers them
+	eventRows := make([][]interface{}, 0, len(events))
+	for _, event := range events {
+		eventRows = append(eventRows, []interface{}{
+			event.ID,
+			event.AccountID,
+			event.Type,
+			[]byte(event.Payload),
+			event.CreatedAt,
+			event.CreatedAt,
+		})
+	}
+
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		copies := []struct {
+			table   string
+			columns []string
+			rows    [][]interface{}
+		}{
+			{TableAccounts, []string{ID, DocumentNumber, CreditLimit, WithdrewalLimit, OwnerID, CreatedAt, UpdatedAt}, accountRows},
+			{TableTranscations, []string{ID, AccountID, OperationTypeID, Amount, EventAt, Balance, Status}, transcationRows},
+			{TableOutboxEvents, []string{ID, AccountID, EventType, Payload, CreatedAt, PublishedAt}, eventRows},
+		}
+
+		for _, c := range copies {
+			if len(c.rows) == 0 {
+				continue
+			}
+
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{c.table}, c.columns, pgx.CopyFromRows(c.rows)); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", c.table, err)
+			}
+		}
+
+		return nil
+	})
+}
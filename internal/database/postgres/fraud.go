@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// RecordFraudDecision keeps a decision of the fraud rules
+func (r *Repo) RecordFraudDecision(ctx context.Context, decision domain.FraudDecision) error {
+	reasons, err := json.Marshal(decision.Reasons)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fraud reasons: %w", err)
+	}
+
+	stmt := r.psql.
+		Insert(TableFraudDecisions).
+		Columns(
+			ID,
+			TranscationID,
+			AccountID,
+			OperationTypeID,
+			Amount,
+			Outcome,
+			Reasons,
+			CreatedAt,
+		).
+		Values(
+			decision.ID,
+			decision.TranscationID,
+			decision.AccountID,
+			decision.OperationTypeID,
+			decision.Amount,
+			string(decision.Outcome),
+			reasons,
+			decision.CreatedAt,
+		)
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query, params...); err != nil {
+			return err
+		}
+
+		return r.audit(ctx, domain.AuditFraudDecisionRecorded, TableFraudDecisions, decision.ID, nil, decision, tx)
+	})
+}
+
+// TranscationStats counts the transcations of the account with one of the
+// operation types made after since and adds up their absolute amounts,
+// rejected transcations are left out
+func (r *Repo) TranscationStats(ctx context.Context, accountID string, operationTypes []int, since time.Time) (domain.TranscationStats, error) {
+	stmt := r.psql.
+		Select(
+			"COUNT(*)",
+			"COALESCE(SUM(ABS("+Amount+")), 0)",
+		).
+		From(TableTranscations).
+		Where(squirrel.Eq{AccountID: accountID}).
+		Where(squirrel.NotEq{Status: domain.TranscationRejected}).
+		Where(squirrel.Gt{EventAt: since})
+
+	if len(operationTypes) > 0 {
+		stmt = stmt.Where(squirrel.Eq{OperationTypeID: operationTypes})
+	}
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return domain.TranscationStats{}, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var stats domain.TranscationStats
+	if err := r.db(ctx).QueryRow(ctx, query, params...).Scan(&stats.Count, &stats.Amount); err != nil {
+		return domain.TranscationStats{}, err
+	}
+
+	return stats, nil
+}
+
+// CountFraudDecisions counts the decisions with the outcome taken for the
+// account after since
+func (r *Repo) CountFraudDecisions(ctx context.Context, accountID string, outcome domain.FraudOutcome, since time.Time) (int, error) {
+	stmt := r.psql.
+		Select("COUNT(*)").
+		From(TableFraudDecisions).
+		Where(squirrel.Eq{AccountID: accountID}).
+		Where(squirrel.Eq{Outcome: string(outcome)}).
+		Where(squirrel.Gt{CreatedAt: since})
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var count int
+	if err := r.db(ctx).QueryRow(ctx, query, params...).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,115 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/database/postgres"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/service"
+	postgresPkg "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+)
+
+// testDSNEnv names the database the tests of the package run against, they
+// are skipped when it is not set, unless they run on CI
+const testDSNEnv = "POSTGRES_TEST_DSN"
+
+// testDSN returns the database the tests run against, on CI the tests fail
+// rather than being skipped without one
+func testDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s must be set on CI", testDSNEnv)
+		}
+
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	return dsn
+}
+
+func testRepo(t *testing.T) *postgres.Repo {
+	t.Helper()
+
+	dsn := testDSN(t)
+
+	ctx := context.Background()
+	require.NoError(t, postgresPkg.Migrate(ctx, postgres.Migrations, "migrations", dsn))
+
+	pool, err := pgxpool.Connect(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	repo := postgres.NewRepo(pool, postgres.SetupPSQL())
+
+	return &repo
+}
+
+func TestConcurrentDebitsDontOverdraw(t *testing.T) {
+	repo := testRepo(t)
+	svc := service.New(repo)
+	ctx := context.Background()
+
+	account, err := svc.CreateAccount(ctx, "12345678900")
+	require.NoError(t, err)
+
+	_, err = svc.UpdateAccountLimits(ctx, account.ID, domain.AccountLimitsReq{WithdrawalLimit: floatPtr(100)})
+	require.NoError(t, err)
+
+	// every purchase passes the check of the service against the account it
+	// read, only the limit left when the repo locks the account counts
+	const purchases = 25
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		posted     int
+		exceeded   int
+		unexpected []error
+	)
+	for i := 0; i < purchases; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := svc.CreateTranscation(ctx, domain.Transcation{
+				AccountID:       account.ID,
+				OperationTypeID: 1,
+				Amount:          10,
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				posted++
+			case errors.Is(err, domain.ErrExceedWithdrawalLimit):
+				exceeded++
+			default:
+				unexpected = append(unexpected, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Empty(t, unexpected)
+	require.Equal(t, 10, posted)
+	require.Equal(t, purchases-10, exceeded)
+
+	got, err := svc.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, 0.0, got.WithdrawalLimit)
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,89 @@
+package postgres_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// testBinEnv names the directory of the postgres binaries, initdb and pg_ctl,
+// the tests start a throwaway server with when POSTGRES_TEST_DSN isn't set
+const testBinEnv = "POSTGRES_TEST_BIN"
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	binDir := os.Getenv(testBinEnv)
+	if os.Getenv(testDSNEnv) != "" || binDir == "" {
+		return m.Run()
+	}
+
+	dsn, stop, err := startPostgres(binDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start postgres from %s: %v\n", binDir, err)
+		return 1
+	}
+	defer stop()
+
+	if err := os.Setenv(testDSNEnv, dsn); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set %s: %v\n", testDSNEnv, err)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// startPostgres initializes a cluster in a temporary directory and starts a
+// server on a free local port, stop shuts it down and removes the cluster
+func startPostgres(binDir string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "postgres-test")
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := filepath.Join(dir, "data")
+	stop := func() {
+		if _, err := os.Stat(filepath.Join(data, "postmaster.pid")); err == nil {
+			_ = exec.Command(filepath.Join(binDir, "pg_ctl"), "stop", "-D", data, "-m", "immediate", "-w").Run()
+		}
+
+		_ = os.RemoveAll(dir)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+
+	initdb := exec.Command(filepath.Join(binDir, "initdb"), "-D", data, "-U", "postgres", "-A", "trust", "--no-sync")
+	if out, err := initdb.CombinedOutput(); err != nil {
+		stop()
+		return "", nil, fmt.Errorf("initdb: %w: %s", err, out)
+	}
+
+	// fsync is off, the cluster doesn't outlive the tests
+	opts := fmt.Sprintf("-p %d -h 127.0.0.1 -k %s -F", port, dir)
+	start := exec.Command(filepath.Join(binDir, "pg_ctl"), "start", "-D", data, "-o", opts, "-l", filepath.Join(dir, "postgres.log"), "-w")
+	if out, err := start.CombinedOutput(); err != nil {
+		stop()
+		return "", nil, fmt.Errorf("pg_ctl start: %w: %s", err, out)
+	}
+
+	return fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port), stop, nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1 @@
+ALTER TABLE transcations DROP COLUMN IF EXISTS balance;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS outbox_events (
+    seq bigserial PRIMARY KEY,
+    id uuid NOT NULL UNIQUE,
+    account_id uuid NOT NULL,
+    event_type TEXT NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamp NOT NULL,
+    published_at timestamp
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_unpublished_idx ON outbox_events (seq) WHERE published_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id uuid PRIMARY KEY,
+    url TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    secret TEXT NOT NULL,
+    active boolean NOT NULL DEFAULT true,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id uuid PRIMARY KEY,
+    subscription_id uuid NOT NULL,
+    event_id uuid NOT NULL,
+    event_type TEXT NOT NULL,
+    payload jsonb NOT NULL,
+    status TEXT NOT NULL,
+    attempts int NOT NULL DEFAULT 0,
+    next_attempt_at timestamp NOT NULL,
+    last_status_code int,
+    last_error TEXT,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    UNIQUE (subscription_id, event_id),
+    FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, created_at);
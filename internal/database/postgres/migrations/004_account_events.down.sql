@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS outbox_events_notify ON outbox_events;
+DROP FUNCTION IF EXISTS notify_account_event();
+DROP INDEX IF EXISTS outbox_events_account_idx;
+DROP INDEX IF EXISTS transcations_account_event_at_idx;
@@ -0,0 +1,12 @@
+CREATE INDEX IF NOT EXISTS transcations_account_event_at_idx ON transcations (account_id, event_at, id);
+CREATE INDEX IF NOT EXISTS outbox_events_account_idx ON outbox_events (account_id, seq);
+
+CREATE OR REPLACE FUNCTION notify_account_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('account_events', NEW.account_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_events_notify ON outbox_events;
+CREATE TRIGGER outbox_events_notify AFTER INSERT ON outbox_events FOR EACH ROW EXECUTE FUNCTION notify_account_event();
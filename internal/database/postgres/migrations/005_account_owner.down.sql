@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS accounts_owner_idx;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,5 @@
+-- owner_id is the subject of the principal the account belongs to, accounts
+-- created before authentication have no owner
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS owner_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS accounts_owner_idx ON accounts (owner_id);
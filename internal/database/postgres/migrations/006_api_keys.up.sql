@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id uuid PRIMARY KEY,
+    name TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    roles TEXT[] NOT NULL,
+    scopes TEXT[] NOT NULL,
+    rate_limit int NOT NULL DEFAULT 0,
+    hash bytea NOT NULL,
+    rotated_from uuid NULL REFERENCES api_keys(id),
+    expires_at timestamp NULL,
+    revoked_at timestamp NULL,
+    last_used_at timestamp NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_subject_idx ON api_keys (subject);
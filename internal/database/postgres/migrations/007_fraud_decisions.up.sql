@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS fraud_decisions (
+    id uuid PRIMARY KEY,
+    transcation_id uuid NOT NULL,
+    account_id uuid NOT NULL REFERENCES accounts(id),
+    operation_type_id int NOT NULL,
+    amount float8 NOT NULL,
+    outcome TEXT NOT NULL,
+    reasons jsonb NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS fraud_decisions_account_idx ON fraud_decisions (account_id, outcome, created_at);
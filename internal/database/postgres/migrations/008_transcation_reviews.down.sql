@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transcation_reviews;
+
+ALTER TABLE transcations DROP COLUMN IF EXISTS status;
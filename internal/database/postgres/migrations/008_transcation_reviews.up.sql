@@ -0,0 +1,15 @@
+ALTER TABLE transcations ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'posted';
+
+CREATE TABLE IF NOT EXISTS transcation_reviews (
+    id uuid PRIMARY KEY,
+    transcation_id uuid NOT NULL UNIQUE REFERENCES transcations(id),
+    decision_id uuid NOT NULL REFERENCES fraud_decisions(id),
+    status TEXT NOT NULL,
+    reviewer TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    due_at timestamp NOT NULL,
+    decided_at timestamp NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS transcation_reviews_pending_idx ON transcation_reviews (due_at) WHERE status = 'pending';
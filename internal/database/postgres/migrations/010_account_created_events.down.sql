@@ -0,0 +1,5 @@
+-- only the events the up backfilled are removed, the account.created events
+-- written when accounts were created are kept
+DELETE FROM outbox_events WHERE backfilled;
+
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS backfilled;
@@ -0,0 +1,12 @@
+-- accounts created before account.created existed start their history with
+-- their current limits when they have no event yet, these events are marked
+-- as published since subscribers never missed them. backfilled tells them
+-- apart from the events written when accounts are created.
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS backfilled BOOLEAN NOT NULL DEFAULT false;
+
+INSERT INTO outbox_events (id, account_id, event_type, payload, created_at, published_at, backfilled)
+SELECT gen_random_uuid(), a.id, 'account.created',
+       jsonb_build_object('account_id', a.id, 'withdrawal_limit', a.withdrawal_limit, 'credit_limit', a.credit_limit),
+       a.created_at, now(), true
+FROM accounts a
+WHERE NOT EXISTS (SELECT 1 FROM outbox_events e WHERE e.account_id = a.id);
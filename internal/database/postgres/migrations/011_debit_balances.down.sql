@@ -0,0 +1,4 @@
+-- back to the balances of before debits were discharged: a debit holds its
+-- positive amount and a credit its amount, whatever was discharged since
+UPDATE transcations SET balance = -amount WHERE operation_type_id IN (1, 2, 3);
+UPDATE transcations SET balance = amount WHERE operation_type_id = 4;
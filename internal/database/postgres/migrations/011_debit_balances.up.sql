@@ -0,0 +1,4 @@
+-- debits used to be stored with a positive balance and were never discharged,
+-- from now on the balance of a debit is the negative amount still owed and
+-- credits discharge it. Databases that ran this as part of 004 are left as is.
+UPDATE transcations SET balance = amount WHERE balance IS NULL OR (operation_type_id IN (1, 2, 3) AND balance = -amount);
@@ -0,0 +1,4 @@
+-- the entries written since the up chain to the latest entry of their entity,
+-- the verification of the previous version reports them as breaks of the
+-- chain of the whole log
+ALTER TABLE audit_log DROP COLUMN IF EXISTS prev_seq;
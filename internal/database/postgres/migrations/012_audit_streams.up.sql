@@ -0,0 +1,13 @@
+-- entries chain to the latest entry of their entity from now on, so that the
+-- writers of different entities don't wait for each other, prev_seq is the
+-- entry an entry chains to. The entries written before chained to the entry
+-- before them in the log.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS prev_seq BIGINT NULL;
+
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only;
+
+UPDATE audit_log SET prev_seq = chained.prev_seq
+FROM (SELECT seq, LAG(seq) OVER (ORDER BY seq) AS prev_seq FROM audit_log) chained
+WHERE audit_log.seq = chained.seq AND audit_log.prev_seq IS NULL;
+
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_append_only;
@@ -0,0 +1,277 @@
+package postgres_test
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/database/postgres"
+	postgresPkg "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+)
+
+func TestEveryUpMigrationHasADown(t *testing.T) {
+	files, err := fs.Glob(postgres.Migrations, "migrations/*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		down := strings.TrimSuffix(file, ".up.sql") + ".down.sql"
+		data, err := fs.ReadFile(postgres.Migrations, down)
+		require.NoError(t, err, "%s has no down migration", file)
+		require.True(t, hasStatement(string(data)), "%s reverts nothing", down)
+	}
+}
+
+// hasStatement reports whether the sql holds more than comments
+func hasStatement(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestLatestVersion(t *testing.T) {
+	files, err := fs.Glob(postgres.Migrations, "migrations/*.up.sql")
+	require.NoError(t, err)
+
+	latest, err := postgresPkg.LatestVersion(postgres.Migrations, "migrations")
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("migrations/%03d_", latest), files[len(files)-1][:len("migrations/000_")])
+}
+
+// TestMigrationsRoundTrip applies the migrations one by one on a database of
+// its own, reverts them one by one, checking that every down brings the
+// schema back to what it was before its up, and applies them again
+func TestMigrationsRoundTrip(t *testing.T) {
+	dsn := testDSN(t)
+
+	ctx := context.Background()
+	database := createDatabase(t, dsn)
+	m, err := postgresPkg.NewMigrator(postgres.Migrations, "migrations", database)
+	require.NoError(t, err)
+	defer m.Close()
+
+	pool, err := pgxpool.Connect(ctx, database)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.NotEmpty(t, status.Migrations)
+
+	// schemas[i] is the schema once the first i migrations are applied
+	schemas := []string{schemaOf(t, pool)}
+	for _, migration := range status.Migrations {
+		require.NoError(t, m.Goto(migration.Version), "migration %d applies", migration.Version)
+		schemas = append(schemas, schemaOf(t, pool))
+	}
+
+	status, err = m.Status()
+	require.NoError(t, err)
+	require.False(t, status.Dirty)
+	for _, migration := range status.Migrations {
+		require.True(t, migration.Applied, "migration %d is applied", migration.Version)
+	}
+
+	for i := len(status.Migrations) - 1; i >= 0; i-- {
+		steps, err := m.PlanDown(1)
+		require.NoError(t, err)
+		require.Len(t, steps, 1)
+		require.Equal(t, status.Migrations[i].Version, steps[0].Version)
+
+		require.NoError(t, m.Down(1), "migration %d reverts", status.Migrations[i].Version)
+
+		version, dirty, err := m.Version()
+		require.NoError(t, err)
+		require.False(t, dirty)
+		if i > 0 {
+			require.Equal(t, int(status.Migrations[i-1].Version), version)
+		} else {
+			require.Equal(t, postgresPkg.NilVersion, version)
+		}
+
+		require.Equal(t, schemas[i], schemaOf(t, pool), "migration %d reverts its schema changes", status.Migrations[i].Version)
+	}
+
+	steps, err := m.PlanUp()
+	require.NoError(t, err)
+	require.Len(t, steps, len(status.Migrations))
+
+	require.NoError(t, m.Up(), "migrations apply again once reverted")
+	require.Equal(t, schemas[len(schemas)-1], schemaOf(t, pool))
+
+	latest, err := postgresPkg.LatestVersion(postgres.Migrations, "migrations")
+	require.NoError(t, err)
+
+	check := postgresPkg.CheckMigrationVersion(pool, latest)
+	require.NoError(t, check(ctx))
+
+	require.NoError(t, m.Down(1))
+	require.EqualError(t, check(ctx), fmt.Sprintf("database at migration %d, expected %d", latest-1, latest))
+}
+
+// schemaOf describes the tables, columns, constraints, indexes, triggers and
+// functions of the database, the table of the migrations left out
+func schemaOf(t *testing.T, pool *pgxpool.Pool) string {
+	t.Helper()
+
+	rows, err := pool.Query(context.Background(), `
+		SELECT 'column ' || table_name || '.' || column_name || ' ' || data_type || ' ' || is_nullable || ' ' || COALESCE(column_default, '')
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name <> 'schema_migrations'
+		UNION ALL
+		SELECT 'constraint ' || conrelid::regclass::text || ' ' || pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE connamespace = 'public'::regnamespace AND conrelid::regclass::text <> 'schema_migrations'
+		UNION ALL
+		SELECT 'index ' || indexdef
+		FROM pg_indexes
+		WHERE schemaname = 'public' AND tablename <> 'schema_migrations'
+		UNION ALL
+		SELECT 'trigger ' || event_object_table || '.' || trigger_name || ' ' || event_manipulation
+		FROM information_schema.triggers
+		WHERE trigger_schema = 'public'
+		UNION ALL
+		SELECT 'function ' || routine_name
+		FROM information_schema.routines
+		WHERE routine_schema = 'public'
+		ORDER BY 1`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		require.NoError(t, rows.Scan(&line))
+
+		lines = append(lines, line)
+	}
+	require.NoError(t, rows.Err())
+
+	return strings.Join(lines, "\n")
+}
+
+// TestDebitBalancesMigration flips the balances of the debits written before
+// they were discharged and restores them on the way down
+func TestDebitBalancesMigration(t *testing.T) {
+	dsn := testDSN(t)
+
+	ctx := context.Background()
+	database := createDatabase(t, dsn)
+	m, err := postgresPkg.NewMigrator(postgres.Migrations, "migrations", database)
+	require.NoError(t, err)
+	defer m.Close()
+
+	require.NoError(t, m.Goto(10))
+
+	pool, err := pgxpool.Connect(ctx, database)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	accountID, debitID, creditID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	_, err = pool.Exec(ctx, "INSERT INTO accounts (id, document_number, created_at, updated_at) VALUES ($1, '12345678900', now(), now())", accountID)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `INSERT INTO transcations (id, account_id, operation_type_id, amount, balance, event_at) VALUES
+		($1, $3, 2, -20, 20, now()),
+		($2, $3, 4, 30, NULL, now())`, debitID, creditID, accountID)
+	require.NoError(t, err)
+
+	balance := func(id string) float64 {
+		var b float64
+		require.NoError(t, pool.QueryRow(ctx, "SELECT balance FROM transcations WHERE id = $1", id).Scan(&b))
+		return b
+	}
+
+	require.NoError(t, m.Goto(11))
+	require.Equal(t, float64(-20), balance(debitID), "the debit owes its amount")
+	require.Equal(t, float64(30), balance(creditID))
+
+	// a credit discharges part of the debit once it is migrated
+	_, err = pool.Exec(ctx, "UPDATE transcations SET balance = -5 WHERE id = $1", debitID)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Down(1))
+	require.Equal(t, float64(20), balance(debitID), "the debit holds its positive amount again")
+	require.Equal(t, float64(30), balance(creditID))
+}
+
+// TestAccountCreatedEventsMigration backfills the events of the accounts
+// without history and only removes those on the way down
+func TestAccountCreatedEventsMigration(t *testing.T) {
+	dsn := testDSN(t)
+
+	ctx := context.Background()
+	database := createDatabase(t, dsn)
+	m, err := postgresPkg.NewMigrator(postgres.Migrations, "migrations", database)
+	require.NoError(t, err)
+	defer m.Close()
+
+	require.NoError(t, m.Goto(9))
+
+	pool, err := pgxpool.Connect(ctx, database)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	legacyID := uuid.NewString()
+	_, err = pool.Exec(ctx, "INSERT INTO accounts (id, document_number, created_at, updated_at) VALUES ($1, '12345678900', now(), now())", legacyID)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Goto(10))
+
+	// an account created once the events exist
+	createdID := uuid.NewString()
+	_, err = pool.Exec(ctx, "INSERT INTO accounts (id, document_number, created_at, updated_at) VALUES ($1, '98765432100', now(), now())", createdID)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `INSERT INTO outbox_events (id, account_id, event_type, payload, created_at)
+		VALUES ($1, $2, 'account.created', '{}', now())`, uuid.NewString(), createdID)
+	require.NoError(t, err)
+
+	events := func(accountID string) int {
+		var n int
+		require.NoError(t, pool.QueryRow(ctx, "SELECT COUNT(*) FROM outbox_events WHERE account_id = $1 AND event_type = 'account.created'", accountID).Scan(&n))
+		return n
+	}
+
+	require.Equal(t, 1, events(legacyID), "the account without history gets its event")
+
+	require.NoError(t, m.Goto(9))
+	require.Equal(t, 0, events(legacyID), "the backfilled event is removed")
+	require.Equal(t, 1, events(createdID), "the event written when the account was created is kept")
+}
+
+// createDatabase creates an empty database next to the one of dsn, it is
+// dropped once the test ends
+func createDatabase(t *testing.T, dsn string) string {
+	t.Helper()
+
+	ctx := context.Background()
+	pool, err := pgxpool.Connect(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	name := "migrations_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	_, err = pool.Exec(ctx, fmt.Sprintf("CREATE DATABASE %s", name))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_, err := pool.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", name))
+		require.NoError(t, err)
+	})
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	u.Path = "/" + name
+
+	return u.String()
+}
@@ -0,0 +1,258 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	postgresPkg "github.com/madhurikadam/app-transcation/pkg/database/postgres"
+)
+
+func newEvent(eventType, accountID string, payload interface{}, at time.Time) (domain.Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return domain.Event{}, fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	return domain.Event{
+		ID:        uuid.NewString(),
+		AccountID: accountID,
+		Type:      eventType,
+		Payload:   data,
+		CreatedAt: at,
+	}, nil
+}
+
+// createEvent writes the event to the outbox as part of tx, it is published
+// by the relay only once tx commits
+func (r *Repo) createEvent(ctx context.Context, event domain.Event, tx pgx.Tx) error {
+	stmt := r.psql.
+		Insert(TableOutboxEvents).
+		Columns(
+			ID,
+			AccountID,
+			EventType,
+			Payload,
+			CreatedAt,
+		).
+		Values(
+			event.ID,
+			event.AccountID,
+			event.Type,
+			[]byte(event.Payload),
+			event.CreatedAt,
+		)
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, query, params...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// eventState is the part of an outbox event publishing changes, its snapshot
+// in the audit log
+type eventState struct {
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// ProcessOutbox locks up to limit unpublished events, hands them to fn and
+// marks them as published once fn succeeds. Locked rows are skipped so that
+// several relays can work on the outbox at the same time. A transaction run
+// again after a serialization failure hands the events to fn again, they are
+// published at least once.
+func (r *Repo) ProcessOutbox(ctx context.Context, limit int, fn func([]domain.Event) error) (int, error) {
+	var processed int
+	err := r.withTx(ctx, func(tx pgx.Tx) error {
+		processed = 0
+
+		stmt := r.psql.
+			Select(
+				Seq,
+				ID,
+				AccountID,
+				EventType,
+				Payload,
+				CreatedAt,
+			).
+			From(TableOutboxEvents).
+			Where(squirrel.Eq{PublishedAt: nil}).
+			OrderBy(Seq).
+			Limit(uint64(limit)).
+			Suffix("FOR UPDATE SKIP LOCKED")
+
+		query, params, err := stmt.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+
+		rows, err := tx.Query(ctx, query, params...)
+		if err != nil {
+			return err
+		}
+
+		events, err := scanEvents(rows)
+		if err != nil {
+			return err
+		}
+
+		if len(events) == 0 {
+			return nil
+		}
+
+		if err := fn(events); err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+
+		publishedAt := time.Now().UTC()
+		update := r.psql.
+			Update(TableOutboxEvents).
+			Set(PublishedAt, publishedAt).
+			Where(squirrel.Eq{ID: ids})
+
+		query, params, err = update.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, query, params...); err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := r.audit(ctx, domain.AuditEventPublished, TableOutboxEvents, event.ID, eventState{}, eventState{PublishedAt: &publishedAt}, tx); err != nil {
+				return err
+			}
+		}
+
+		processed = len(events)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return processed, nil
+}
+
+// GetOldestUnpublishedEventTime returns when the oldest event not published
+// yet was written, the zero time when there is none
+func (r *Repo) GetOldestUnpublishedEventTime(ctx context.Context) (time.Time, error) {
+	stmt := r.psql.
+		Select(CreatedAt).
+		From(TableOutboxEvents).
+		Where(squirrel.Eq{PublishedAt: nil}).
+		OrderBy(Seq).
+		Limit(1)
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var oldest time.Time
+	err = r.db(ctx).QueryRow(ctx, query, params...).Scan(&oldest)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+
+	return oldest, err
+}
+
+// ListAccountEvents returns up to limit events of the account with a sequence
+// number greater than afterSeq, in sequence order
+func (r *Repo) ListAccountEvents(ctx context.Context, accountID string, afterSeq int64, limit int) ([]domain.Event, error) {
+	stmt := r.psql.
+		Select(
+			Seq,
+			ID,
+			AccountID,
+			EventType,
+			Payload,
+			CreatedAt,
+		).
+		From(TableOutboxEvents).
+		Where(squirrel.Eq{AccountID: accountID}).
+		Where(squirrel.Gt{Seq: afterSeq}).
+		OrderBy(Seq).
+		Limit(uint64(limit))
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
+
+// GetLastEventSeq returns the sequence number of the latest event of the
+// account, 0 when it has none
+func (r *Repo) GetLastEventSeq(ctx context.Context, accountID string) (int64, error) {
+	stmt := r.psql.
+		Select("COALESCE(MAX(seq), 0)").
+		From(TableOutboxEvents).
+		Where(squirrel.Eq{AccountID: accountID})
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var seq int64
+	if err := r.db(ctx).QueryRow(ctx, query, params...).Scan(&seq); err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+// ListenAccountEvents calls fn with the account id of every event written to
+// the outbox until ctx is cancelled
+func (r *Repo) ListenAccountEvents(ctx context.Context, fn func(accountID string)) error {
+	return postgresPkg.Listen(ctx, r.pgx, ChannelAccountEvents, fn)
+}
+
+func scanEvents(rows pgx.Rows) ([]domain.Event, error) {
+	defer rows.Close()
+
+	events := make([]domain.Event, 0)
+	for rows.Next() {
+		var event domain.Event
+		if err := rows.Scan(
+			&event.Seq,
+			&event.ID,
+			&event.AccountID,
+			&event.Type,
+			&event.Payload,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -14,13 +14,25 @@ func SetupPSQL() squirrel.StatementBuilderType {
 }
 
 const (
-	TableAccounts     = "accounts"
-	TableTranscations = "transcations"
+	TableAccounts             = "accounts"
+	TableTranscations         = "transcations"
+	TableOutboxEvents         = "outbox_events"
+	TableWebhookSubscriptions = "webhook_subscriptions"
+	TableWebhookDeliveries    = "webhook_deliveries"
+	TableAPIKeys              = "api_keys"
+	TableFraudDecisions       = "fraud_decisions"
+	TableTranscationReviews   = "transcation_reviews"
+	TableAuditLog             = "audit_log"
+
+	// ChannelAccountEvents is notified with the account id whenever an event
+	// is written to the outbox
+	ChannelAccountEvents = "account_events"
 
 	ID              = "id"
 	AccountID       = "account_id"
 	OperationTypeID = "operation_type_id"
 	DocumentNumber  = "document_number"
+	OwnerID         = "owner_id"
 	CreatedAt       = "created_at"
 	UpdatedAt       = "updated_at"
 	EventAt         = "event_at"
@@ -28,4 +40,52 @@ const (
 	CreditLimit     = "credit_limit"
 	WithdrewalLimit = "withdrawal_limit"
 	Balance         = "balance"
+	Seq             = "seq"
+	EventType       = "event_type"
+	EventTypes      = "event_types"
+	EventID         = "event_id"
+	Payload         = "payload"
+	PublishedAt     = "published_at"
+	URL             = "url"
+	Secret          = "secret"
+	Active          = "active"
+	SubscriptionID  = "subscription_id"
+	Status          = "status"
+	Attempts        = "attempts"
+	NextAttemptAt   = "next_attempt_at"
+	LastStatusCode  = "last_status_code"
+	LastError       = "last_error"
+	Name            = "name"
+	Subject         = "subject"
+	Roles           = "roles"
+	Scopes          = "scopes"
+	RateLimit       = "rate_limit"
+	Hash            = "hash"
+	RotatedFrom     = "rotated_from"
+	ExpiresAt       = "expires_at"
+	RevokedAt       = "revoked_at"
+	LastUsedAt      = "last_used_at"
+	TranscationID   = "transcation_id"
+	Outcome         = "outcome"
+	Reasons         = "reasons"
+	DecisionID      = "decision_id"
+	Reviewer        = "reviewer"
+	Reason          = "reason"
+	DueAt           = "due_at"
+	DecidedAt       = "decided_at"
+	Actor           = "actor"
+	RequestID       = "request_id"
+	Action          = "action"
+	EntityType      = "entity_type"
+	EntityID        = "entity_id"
+	Before          = "before"
+	After           = "after"
+	PrevHash        = "prev_hash"
+	PrevSeq         = "prev_seq"
 )
+
+// qualify prefixes column with table, used when a query joins tables sharing
+// column names
+func qualify(table, column string) string {
+	return table + "." + column
+}
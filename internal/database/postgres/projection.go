@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// ListEvents returns up to limit events of every account with a sequence
+// number greater than afterSeq, in sequence order
+func (r *Repo) ListEvents(ctx context.Context, afterSeq int64, limit int) ([]domain.Event, error) {
+	stmt := r.psql.
+		Select(
+			Seq,
+			ID,
+			AccountID,
+			EventType,
+			Payload,
+			CreatedAt,
+		).
+		From(TableOutboxEvents).
+		Where(squirrel.Gt{Seq: afterSeq}).
+		OrderBy(Seq).
+		Limit(uint64(limit))
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanEvents(rows)
+}
+
+// ListAccountLimits returns up to limit account limits ordered by account id,
+// starting after afterID
+func (r *Repo) ListAccountLimits(ctx context.Context, afterID string, limit int) ([]domain.AccountLimits, error) {
+	stmt := r.psql.
+		Select(ID, WithdrewalLimit, CreditLimit).
+		From(TableAccounts).
+		OrderBy(ID).
+		Limit(uint64(limit))
+
+	if afterID != "" {
+		stmt = stmt.Where(squirrel.Gt{ID: afterID})
+	}
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]domain.AccountLimits, 0)
+	for rows.Next() {
+		var limits domain.AccountLimits
+		if err := rows.Scan(&limits.AccountID, &limits.WithdrawalLimit, &limits.CreditLimit); err != nil {
+			return nil, err
+		}
+
+		list = append(list, limits)
+	}
+
+	return list, rows.Err()
+}
+
+// ListTranscationBalances returns up to limit balances of posted transcations
+// ordered by id, starting after afterID
+func (r *Repo) ListTranscationBalances(ctx context.Context, afterID string, limit int) ([]domain.TranscationBalance, error) {
+	stmt := r.psql.
+		Select(ID, AccountID, "COALESCE(balance, 0)").
+		From(TableTranscations).
+		Where(squirrel.Eq{Status: domain.TranscationPosted}).
+		OrderBy(ID).
+		Limit(uint64(limit))
+
+	if afterID != "" {
+		stmt = stmt.Where(squirrel.Gt{ID: afterID})
+	}
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]domain.TranscationBalance, 0)
+	for rows.Next() {
+		var balance domain.TranscationBalance
+		if err := rows.Scan(&balance.ID, &balance.AccountID, &balance.Balance); err != nil {
+			return nil, err
+		}
+
+		list = append(list, balance)
+	}
+
+	return list, rows.Err()
+}
+
+// RebuildAccount sets the limits of the account, when given, and the balances
+// of its transcations to the projected ones. It fails with ErrProjectionStale
+// when events were written for the account after lastSeq, the projection
+// doesn't know about them.
+func (r *Repo) RebuildAccount(ctx context.Context, accountID string, lastSeq int64, limits *domain.AccountLimits, balances map[string]float64) error {
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		// events of an account are written while holding its lock
+		if _, err := r.lockLimits(ctx, accountID, tx); err != nil {
+			return err
+		}
+
+		query, params, err := r.psql.
+			Select("COALESCE(MAX(seq), 0)").
+			From(TableOutboxEvents).
+			Where(squirrel.Eq{AccountID: accountID}).
+			ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+
+		var seq int64
+		if err := tx.QueryRow(ctx, query, params...).Scan(&seq); err != nil {
+			return err
+		}
+
+		if seq > lastSeq {
+			return domain.ErrProjectionStale
+		}
+
+		if limits != nil {
+			stmt := r.psql.
+				Update(TableAccounts).
+				Set(WithdrewalLimit, limits.WithdrawalLimit).
+				Set(CreditLimit, limits.CreditLimit).
+				Set(UpdatedAt, time.Now().UTC()).
+				Where(squirrel.Eq{ID: accountID}).
+				Suffix(returningLimits)
+
+			if _, err := r.updateLimits(ctx, accountID, stmt, domain.ErrNotFound, tx); err != nil {
+				return err
+			}
+		}
+
+		for id, balance := range balances {
+			if _, err := r.updateBalance(ctx, id, balance, tx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,362 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+var reviewColumns = []string{
+	qualify(TableTranscationReviews, ID),
+	qualify(TableTranscations, ID),
+	qualify(TableTranscations, AccountID),
+	qualify(TableTranscations, OperationTypeID),
+	qualify(TableTranscations, Amount),
+	qualify(TableTranscations, EventAt),
+	"COALESCE(" + qualify(TableTranscations, Balance) + ", 0)",
+	qualify(TableTranscations, Status),
+	qualify(TableTranscationReviews, DecisionID),
+	qualify(TableFraudDecisions, Reasons),
+	qualify(TableTranscationReviews, Status),
+	qualify(TableTranscationReviews, Reviewer),
+	qualify(TableTranscationReviews, Reason),
+	qualify(TableTranscationReviews, DueAt),
+	qualify(TableTranscationReviews, DecidedAt),
+	qualify(TableTranscationReviews, CreatedAt),
+}
+
+// HoldTranscation writes a transcation pending review along with its review
+// and reserves the limit it uses until the review is decided. Debits are
+// checked against the velocity limits like posted ones.
+func (r *Repo) HoldTranscation(ctx context.Context, review domain.Review, velocity domain.VelocityLimits) error {
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		transcation := review.Transcation
+		if transcation.IsDebit() && velocity.Enabled() {
+			if err := r.checkVelocity(ctx, transcation, velocity, tx); err != nil {
+				return err
+			}
+		}
+
+		if err := r.createTranscation(ctx, transcation, tx); err != nil {
+			return err
+		}
+
+		limits, err := r.reserveLimit(ctx, transcation, transcation.Amount, tx)
+		if err != nil {
+			return err
+		}
+
+		if err := r.createReview(ctx, review, tx); err != nil {
+			return err
+		}
+
+		return r.createLimitsEvent(ctx, *limits, review.CreatedAt, tx)
+	})
+}
+
+// ApproveReview posts the transcation of a pending review, credits discharge
+// the debits of dbTxList. It fails with ErrReviewDecided when the review was
+// decided in the meantime.
+func (r *Repo) ApproveReview(ctx context.Context, review domain.Review, dbTxList []domain.DebitTx) error {
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		if err := r.decideReview(ctx, review, tx); err != nil {
+			return err
+		}
+
+		transcation := review.Transcation
+		discharges := make([]domain.Discharge, 0, len(dbTxList))
+		for _, update := range dbTxList {
+			changed, err := r.updateBalance(ctx, update.ID, update.Amount, tx)
+			if err != nil {
+				return err
+			}
+
+			if changed {
+				discharges = append(discharges, domain.Discharge{
+					TranscationID: update.ID,
+					AccountID:     transcation.AccountID,
+					Balance:       update.Amount,
+					DischargedBy:  transcation.ID,
+				})
+			}
+		}
+
+		if err := r.updateTranscationStatus(ctx, transcation, tx); err != nil {
+			return err
+		}
+
+		// the limit was already used when the transcation was held
+		return r.createTranscationEvents(ctx, transcation, discharges, nil, *review.DecidedAt, tx)
+	})
+}
+
+// RejectReview rejects the transcation of a pending review and releases the
+// limit it reserved. It fails with ErrReviewDecided when the review was
+// decided in the meantime.
+func (r *Repo) RejectReview(ctx context.Context, review domain.Review) error {
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		if err := r.decideReview(ctx, review, tx); err != nil {
+			return err
+		}
+
+		transcation := review.Transcation
+		if err := r.updateTranscationStatus(ctx, transcation, tx); err != nil {
+			return err
+		}
+
+		limits, err := r.reserveLimit(ctx, transcation, -transcation.Amount, tx)
+		if err != nil {
+			return err
+		}
+
+		return r.createLimitsEvent(ctx, *limits, *review.DecidedAt, tx)
+	})
+}
+
+// GetReview returns the review, within a unit of work the review is locked
+// until it ends so that it is decided once
+func (r *Repo) GetReview(ctx context.Context, id string) (*domain.Review, error) {
+	stmt := r.selectReviews().Where(squirrel.Eq{qualify(TableTranscationReviews, ID): id})
+
+	if txFromContext(ctx) != nil {
+		stmt = stmt.Suffix("FOR UPDATE OF " + TableTranscationReviews)
+	}
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews, err := scanReviews(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reviews) == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	return &reviews[0], nil
+}
+
+// ListReviews returns up to limit reviews with the status ordered by due time
+func (r *Repo) ListReviews(ctx context.Context, status string, limit int) ([]domain.Review, error) {
+	stmt := r.selectReviews().
+		Where(squirrel.Eq{qualify(TableTranscationReviews, Status): status}).
+		OrderBy(qualify(TableTranscationReviews, DueAt), qualify(TableTranscationReviews, ID)).
+		Limit(uint64(limit))
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanReviews(rows)
+}
+
+// ListDueReviews returns up to limit pending reviews due at the given time
+func (r *Repo) ListDueReviews(ctx context.Context, at time.Time, limit int) ([]domain.Review, error) {
+	stmt := r.selectReviews().
+		Where(squirrel.Eq{qualify(TableTranscationReviews, Status): domain.ReviewPending}).
+		Where(squirrel.LtOrEq{qualify(TableTranscationReviews, DueAt): at}).
+		OrderBy(qualify(TableTranscationReviews, DueAt), qualify(TableTranscationReviews, ID)).
+		Limit(uint64(limit))
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanReviews(rows)
+}
+
+func (r *Repo) createReview(ctx context.Context, review domain.Review, tx pgx.Tx) error {
+	stmt := r.psql.
+		Insert(TableTranscationReviews).
+		Columns(
+			ID,
+			TranscationID,
+			DecisionID,
+			Status,
+			DueAt,
+			CreatedAt,
+		).
+		Values(
+			review.ID,
+			review.Transcation.ID,
+			review.DecisionID,
+			review.Status,
+			review.DueAt,
+			review.CreatedAt,
+		)
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, query, params...); err != nil {
+		return err
+	}
+
+	return r.audit(ctx, domain.AuditReviewCreated, TableTranscationReviews, review.ID, nil, review, tx)
+}
+
+// reviewState is the audit snapshot of the decision of a review
+type reviewState struct {
+	Status    string     `json:"status"`
+	Reviewer  string     `json:"reviewer,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+// decideReview records the decision of a review that is still pending
+func (r *Repo) decideReview(ctx context.Context, review domain.Review, tx pgx.Tx) error {
+	stmt := r.psql.
+		Update(TableTranscationReviews).
+		Set(Status, review.Status).
+		Set(Reviewer, review.Reviewer).
+		Set(Reason, review.Reason).
+		Set(DecidedAt, review.DecidedAt).
+		Where(squirrel.Eq{ID: review.ID}).
+		Where(squirrel.Eq{Status: domain.ReviewPending})
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, query, params...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return domain.ErrReviewDecided
+	}
+
+	before := reviewState{Status: domain.ReviewPending}
+	after := reviewState{
+		Status:    review.Status,
+		Reviewer:  review.Reviewer,
+		Reason:    review.Reason,
+		DecidedAt: review.DecidedAt,
+	}
+
+	return r.audit(ctx, domain.AuditReviewDecided, TableTranscationReviews, review.ID, before, after, tx)
+}
+
+func (r *Repo) updateTranscationStatus(ctx context.Context, transcation domain.Transcation, tx pgx.Tx) error {
+	before, err := r.lockTranscationState(ctx, transcation.ID, tx)
+	if err != nil {
+		return err
+	}
+
+	stmt := r.psql.
+		Update(TableTranscations).
+		Set(Status, transcation.Status).
+		Set(Balance, transcation.Balance).
+		Where(squirrel.Eq{ID: transcation.ID})
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, query, params...); err != nil {
+		return err
+	}
+
+	after := transcationState{Status: transcation.Status, Balance: &transcation.Balance}
+
+	return r.audit(ctx, domain.AuditTranscationStatusChanged, TableTranscations, transcation.ID, before, after, tx)
+}
+
+// reserveLimit uses amount of the limit matching the operation type of the
+// transcation, the opposite amount releases it
+func (r *Repo) reserveLimit(ctx context.Context, transcation domain.Transcation, amount float64, tx pgx.Tx) (*domain.AccountLimits, error) {
+	if transcation.IsDebit() {
+		return r.updateDebitLimit(ctx, transcation.AccountID, amount, tx)
+	}
+
+	return r.updateCreditLimit(ctx, transcation.AccountID, amount, tx)
+}
+
+func (r *Repo) createLimitsEvent(ctx context.Context, limits domain.AccountLimits, at time.Time, tx pgx.Tx) error {
+	event, err := newEvent(domain.EventAccountLimitsChanged, limits.AccountID, limits, at)
+	if err != nil {
+		return err
+	}
+
+	return r.createEvent(ctx, event, tx)
+}
+
+func (r *Repo) selectReviews() squirrel.SelectBuilder {
+	return r.psql.
+		Select(reviewColumns...).
+		From(TableTranscationReviews).
+		Join(fmt.Sprintf("%s ON %s = %s", TableTranscations,
+			qualify(TableTranscations, ID), qualify(TableTranscationReviews, TranscationID))).
+		Join(fmt.Sprintf("%s ON %s = %s", TableFraudDecisions,
+			qualify(TableFraudDecisions, ID), qualify(TableTranscationReviews, DecisionID)))
+}
+
+func scanReviews(rows pgx.Rows) ([]domain.Review, error) {
+	defer rows.Close()
+
+	reviews := make([]domain.Review, 0)
+	for rows.Next() {
+		var (
+			review  domain.Review
+			reasons []byte
+		)
+		if err := rows.Scan(
+			&review.ID,
+			&review.Transcation.ID,
+			&review.Transcation.AccountID,
+			&review.Transcation.OperationTypeID,
+			&review.Transcation.Amount,
+			&review.Transcation.EventAt,
+			&review.Transcation.Balance,
+			&review.Transcation.Status,
+			&review.DecisionID,
+			&reasons,
+			&review.Status,
+			&review.Reviewer,
+			&review.Reason,
+			&review.DueAt,
+			&review.DecidedAt,
+			&review.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(reasons, &review.Reasons); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal fraud reasons: %w", err)
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	return reviews, rows.Err()
+}
@@ -2,66 +2,68 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v4"
 	"github.com/madhurikadam/app-transcation/internal/domain"
 )
 
 func (r *Repo) CreateCreditTranscation(ctx context.Context, transcation domain.Transcation, dbTxList []domain.DebitTx) error {
-	tx, err := r.pgx.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction")
-	}
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		discharges := make([]domain.Discharge, 0, len(dbTxList))
+		for _, update := range dbTxList {
+			changed, err := r.updateBalance(ctx, update.ID, update.Amount, tx)
+			if err != nil {
+				return err
+			}
 
-	for _, update := range dbTxList {
-		err := r.updateBalance(ctx, update.ID, update.Amount, tx)
-		if err != nil {
-			txErr := tx.Rollback(ctx)
-			if txErr != nil {
-				return txErr
+			if changed {
+				discharges = append(discharges, domain.Discharge{
+					TranscationID: update.ID,
+					AccountID:     transcation.AccountID,
+					Balance:       update.Amount,
+					DischargedBy:  transcation.ID,
+				})
 			}
+		}
 
+		if err := r.createTranscation(ctx, transcation, tx); err != nil {
 			return err
 		}
-	}
-
-	if err := r.createTranscation(ctx, transcation, tx); err != nil {
-		return err
-	}
 
-	if err := r.updateCreditLimit(ctx, transcation.AccountID, transcation.Amount, tx); err != nil {
-		txErr := tx.Rollback(ctx)
-		if txErr != nil {
-			return txErr
+		limits, err := r.updateCreditLimit(ctx, transcation.AccountID, transcation.Amount, tx)
+		if err != nil {
+			return err
 		}
 
-		return err
-	}
-
-	return tx.Commit(ctx)
+		return r.createTranscationEvents(ctx, transcation, discharges, limits, transcation.EventAt, tx)
+	})
 }
 
-func (r *Repo) CreateDebitTranscation(ctx context.Context, transcation domain.Transcation) error {
-	tx, err := r.pgx.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction")
-	}
-
-	if err := r.createTranscation(ctx, transcation, tx); err != nil {
-		return err
-	}
+// CreateDebitTranscation posts a debit, it fails with a domain.VelocityError
+// when the debit exceeds the velocity limits of the account
+func (r *Repo) CreateDebitTranscation(ctx context.Context, transcation domain.Transcation, velocity domain.VelocityLimits) error {
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		if velocity.Enabled() {
+			if err := r.checkVelocity(ctx, transcation, velocity, tx); err != nil {
+				return err
+			}
+		}
 
-	if err := r.updateDebitLimit(ctx, transcation.AccountID, transcation.Amount, tx); err != nil {
-		txErr := tx.Rollback(ctx)
-		if txErr != nil {
-			return txErr
+		if err := r.createTranscation(ctx, transcation, tx); err != nil {
+			return err
 		}
 
-		return err
-	}
+		limits, err := r.updateDebitLimit(ctx, transcation.AccountID, transcation.Amount, tx)
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit(ctx)
+		return r.createTranscationEvents(ctx, transcation, nil, limits, transcation.EventAt, tx)
+	})
 }
 
 func (r *Repo) createTranscation(ctx context.Context, transcation domain.Transcation, tx pgx.Tx) error {
@@ -74,6 +76,7 @@ func (r *Repo) createTranscation(ctx context.Context, transcation domain.Transca
 			Amount,
 			EventAt,
 			Balance,
+			Status,
 		).
 		Values(
 			transcation.ID,
@@ -82,6 +85,7 @@ func (r *Repo) createTranscation(ctx context.Context, transcation domain.Transca
 			transcation.Amount,
 			transcation.EventAt,
 			transcation.Balance,
+			transcation.Status,
 		)
 
 	query, params, err := stmt.ToSql()
@@ -93,9 +97,171 @@ func (r *Repo) createTranscation(ctx context.Context, transcation domain.Transca
 		return err
 	}
 
+	return r.audit(ctx, domain.AuditTranscationCreated, TableTranscations, transcation.ID, nil, transcation, tx)
+}
+
+// transcationState is the audit snapshot of the mutable columns of a
+// transcation
+type transcationState struct {
+	Status  string   `json:"status"`
+	Balance *float64 `json:"balance"`
+}
+
+// lockTranscationState returns the state of the transcation, locking it until
+// tx ends
+func (r *Repo) lockTranscationState(ctx context.Context, id string, tx pgx.Tx) (*transcationState, error) {
+	stmt := r.psql.
+		Select(Status, Balance).
+		From(TableTranscations).
+		Where(squirrel.Eq{ID: id}).
+		Suffix("FOR NO KEY UPDATE")
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var state transcationState
+	if err := tx.QueryRow(ctx, query, params...).Scan(&state.Status, &state.Balance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// createTranscationEvents writes the events of a posted transcation to the
+// outbox: the transcation itself, every debit it discharged and the new limits
+// of the account when they changed, at is when the transcation got posted
+func (r *Repo) createTranscationEvents(ctx context.Context, transcation domain.Transcation, discharges []domain.Discharge, limits *domain.AccountLimits, at time.Time, tx pgx.Tx) error {
+	events := make([]domain.Event, 0, len(discharges)+2)
+
+	event, err := newEvent(domain.EventTranscationCreated, transcation.AccountID, transcation, at)
+	if err != nil {
+		return err
+	}
+	events = append(events, event)
+
+	for _, discharge := range discharges {
+		event, err := newEvent(domain.EventTranscationDischarged, transcation.AccountID, discharge, at)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	if limits != nil {
+		event, err = newEvent(domain.EventAccountLimitsChanged, transcation.AccountID, limits, at)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, event := range events {
+		if err := r.createEvent(ctx, event, tx); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (r *Repo) ListDebitTx(ctx context.Context) ([]domain.Transcation, error) {
-	return nil, nil
+// ListTranscations returns up to limit transcations of the account ordered by
+// event time, starting after the cursor when one is given
+func (r *Repo) ListTranscations(ctx context.Context, accountID string, after *domain.TranscationCursor, limit int) ([]domain.Transcation, error) {
+	stmt := r.psql.
+		Select(
+			ID,
+			AccountID,
+			OperationTypeID,
+			Amount,
+			EventAt,
+			"COALESCE(balance, 0)",
+			Status,
+		).
+		From(TableTranscations).
+		Where(squirrel.Eq{AccountID: accountID}).
+		OrderBy(EventAt, ID).
+		Limit(uint64(limit))
+
+	if after != nil {
+		if after.ID == "" {
+			stmt = stmt.Where(squirrel.Gt{EventAt: after.EventAt})
+		} else {
+			stmt = stmt.Where("(event_at, id) > (?, ?)", after.EventAt, after.ID)
+		}
+	}
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTranscations(rows)
+}
+
+func scanTranscations(rows pgx.Rows) ([]domain.Transcation, error) {
+	defer rows.Close()
+
+	transcations := make([]domain.Transcation, 0)
+	for rows.Next() {
+		var transcation domain.Transcation
+		if err := rows.Scan(
+			&transcation.ID,
+			&transcation.AccountID,
+			&transcation.OperationTypeID,
+			&transcation.Amount,
+			&transcation.EventAt,
+			&transcation.Balance,
+			&transcation.Status,
+		); err != nil {
+			return nil, err
+		}
+
+		transcations = append(transcations, transcation)
+	}
+
+	return transcations, rows.Err()
+}
+
+// ListDebitTx returns the posted debits of the account that are not paid off
+// yet, oldest first so that credits discharge them in order
+func (r *Repo) ListDebitTx(ctx context.Context, accountID string) ([]domain.Transcation, error) {
+	stmt := r.psql.
+		Select(
+			ID,
+			AccountID,
+			OperationTypeID,
+			Amount,
+			EventAt,
+			Balance,
+			Status,
+		).
+		From(TableTranscations).
+		Where(squirrel.Eq{AccountID: accountID}).
+		Where(squirrel.Eq{OperationTypeID: []int{1, 2, 3}}).
+		Where(squirrel.Eq{Status: domain.TranscationPosted}).
+		Where(squirrel.Lt{Balance: 0}).
+		OrderBy(EventAt, ID)
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTranscations(rows)
 }
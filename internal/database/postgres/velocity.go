@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// checkVelocity fails with a domain.VelocityError when the debit would exceed
+// the velocity limits of its account. The account row stays locked until tx
+// ends so that concurrent debits, whichever replica they come from, are
+// checked one after the other.
+func (r *Repo) checkVelocity(ctx context.Context, transcation domain.Transcation, limits domain.VelocityLimits, tx pgx.Tx) error {
+	if err := r.lockAccount(ctx, transcation.AccountID, tx); err != nil {
+		return err
+	}
+
+	debits, err := r.velocityDebits(ctx, transcation.AccountID, transcation.EventAt.Add(-limits.Window()), tx)
+	if err != nil {
+		return err
+	}
+
+	// debit amounts are negative
+	return limits.Check(debits, -transcation.Amount, transcation.EventAt)
+}
+
+func (r *Repo) lockAccount(ctx context.Context, accountID string, tx pgx.Tx) error {
+	stmt := r.psql.
+		Select(ID).
+		From(TableAccounts).
+		Where(squirrel.Eq{ID: accountID}).
+		Suffix("FOR NO KEY UPDATE")
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var id string
+	if err := tx.QueryRow(ctx, query, params...).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// velocityDebits returns the debits of the account made after since, oldest
+// first, including the ones held for review
+func (r *Repo) velocityDebits(ctx context.Context, accountID string, since time.Time, tx pgx.Tx) ([]domain.VelocityDebit, error) {
+	stmt := r.psql.
+		Select(EventAt, "-"+Amount).
+		From(TableTranscations).
+		Where(squirrel.Eq{AccountID: accountID}).
+		Where(squirrel.Eq{OperationTypeID: []int{1, 2, 3}}).
+		Where(squirrel.NotEq{Status: domain.TranscationRejected}).
+		Where(squirrel.Gt{EventAt: since}).
+		OrderBy(EventAt)
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	debits := make([]domain.VelocityDebit, 0)
+	for rows.Next() {
+		var debit domain.VelocityDebit
+		if err := rows.Scan(&debit.EventAt, &debit.Amount); err != nil {
+			return nil, err
+		}
+
+		debits = append(debits, debit)
+	}
+
+	return debits, rows.Err()
+}
@@ -0,0 +1,475 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+var deliveryColumns = []string{
+	qualify(TableWebhookDeliveries, ID),
+	qualify(TableWebhookDeliveries, SubscriptionID),
+	qualify(TableWebhookDeliveries, EventID),
+	qualify(TableWebhookDeliveries, EventType),
+	qualify(TableWebhookDeliveries, Payload),
+	qualify(TableWebhookDeliveries, Status),
+	qualify(TableWebhookDeliveries, Attempts),
+	qualify(TableWebhookDeliveries, NextAttemptAt),
+	qualify(TableWebhookDeliveries, LastStatusCode),
+	qualify(TableWebhookDeliveries, LastError),
+	qualify(TableWebhookDeliveries, CreatedAt),
+	qualify(TableWebhookDeliveries, UpdatedAt),
+}
+
+func (r *Repo) CreateWebhookSubscription(ctx context.Context, sub domain.WebhookSubscription) error {
+	stmt := r.psql.
+		Insert(TableWebhookSubscriptions).
+		Columns(
+			ID,
+			URL,
+			EventTypes,
+			Secret,
+			Active,
+			CreatedAt,
+			UpdatedAt,
+		).
+		Values(
+			sub.ID,
+			sub.URL,
+			sub.EventTypes,
+			sub.Secret,
+			sub.Active,
+			sub.CreatedAt,
+			sub.UpdatedAt,
+		)
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query, params...); err != nil {
+			return err
+		}
+
+		// the secret stays out of the audit log
+		sub.Secret = ""
+		return r.audit(ctx, domain.AuditWebhookCreated, TableWebhookSubscriptions, sub.ID, nil, sub, tx)
+	})
+}
+
+func (r *Repo) GetWebhookSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	stmt := r.selectSubscriptions().Where(squirrel.Eq{ID: id})
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := scanSubscriptions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subs) == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	return &subs[0], nil
+}
+
+func (r *Repo) ListWebhookSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	query, params, err := r.selectSubscriptions().OrderBy(CreatedAt).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubscriptions(rows)
+}
+
+func (r *Repo) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	stmt := r.psql.
+		Delete(TableWebhookSubscriptions).
+		Where(squirrel.Eq{ID: id}).
+		Suffix("RETURNING id, url, event_types, active, created_at, updated_at")
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		var sub domain.WebhookSubscription
+		if err := tx.QueryRow(ctx, query, params...).Scan(
+			&sub.ID,
+			&sub.URL,
+			&sub.EventTypes,
+			&sub.Active,
+			&sub.CreatedAt,
+			&sub.UpdatedAt,
+		); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrNotFound
+			}
+
+			return err
+		}
+
+		return r.audit(ctx, domain.AuditWebhookDeleted, TableWebhookSubscriptions, id, sub, nil, tx)
+	})
+}
+
+// CreateWebhookDeliveries enqueues deliveries, an event already enqueued for a
+// subscription is ignored so that relaying the same event twice is harmless
+func (r *Repo) CreateWebhookDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	stmt := r.psql.
+		Insert(TableWebhookDeliveries).
+		Columns(
+			ID,
+			SubscriptionID,
+			EventID,
+			EventType,
+			Payload,
+			Status,
+			Attempts,
+			NextAttemptAt,
+			CreatedAt,
+			UpdatedAt,
+		).
+		Suffix("ON CONFLICT (subscription_id, event_id) DO NOTHING")
+
+	for _, d := range deliveries {
+		stmt = stmt.Values(
+			d.ID,
+			d.SubscriptionID,
+			d.EventID,
+			d.EventType,
+			[]byte(d.Payload),
+			d.Status,
+			d.Attempts,
+			d.NextAttemptAt,
+			d.CreatedAt,
+			d.UpdatedAt,
+		)
+	}
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if _, err := r.db(ctx).Exec(ctx, query, params...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Repo) ListWebhookDeliveries(ctx context.Context, subscriptionID string) ([]domain.WebhookDelivery, error) {
+	stmt := r.psql.
+		Select(deliveryColumns...).
+		From(TableWebhookDeliveries).
+		Where(squirrel.Eq{SubscriptionID: subscriptionID}).
+		OrderBy(CreatedAt + " DESC")
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		if err := rows.Scan(deliveryDest(&d)...); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *Repo) GetWebhookDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	stmt := r.psql.
+		Select(deliveryColumns...).
+		From(TableWebhookDeliveries).
+		Where(squirrel.Eq{ID: id})
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var d domain.WebhookDelivery
+	if err := r.db(ctx).QueryRow(ctx, query, params...).Scan(deliveryDest(&d)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due
+// at now, together with the url and secret of their subscription. Claimed
+// deliveries are leased by pushing next_attempt_at forward so that other
+// dispatchers skip them while they are in flight.
+func (r *Repo) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	var claimed []domain.WebhookDelivery
+	err := r.withTx(ctx, func(tx pgx.Tx) error {
+		claimed = nil
+
+		stmt := r.psql.
+			Select(deliveryColumns...).
+			Columns(
+				qualify(TableWebhookSubscriptions, URL),
+				qualify(TableWebhookSubscriptions, Secret),
+			).
+			From(TableWebhookDeliveries).
+			Join(fmt.Sprintf("%s ON %s = %s",
+				TableWebhookSubscriptions,
+				qualify(TableWebhookSubscriptions, ID),
+				qualify(TableWebhookDeliveries, SubscriptionID),
+			)).
+			Where(squirrel.Eq{qualify(TableWebhookDeliveries, Status): domain.DeliveryStatusPending}).
+			Where(squirrel.LtOrEq{qualify(TableWebhookDeliveries, NextAttemptAt): now}).
+			OrderBy(qualify(TableWebhookDeliveries, NextAttemptAt)).
+			Limit(uint64(limit)).
+			Suffix("FOR UPDATE OF " + TableWebhookDeliveries + " SKIP LOCKED")
+
+		query, params, err := stmt.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+
+		rows, err := tx.Query(ctx, query, params...)
+		if err != nil {
+			return err
+		}
+
+		deliveries := make([]domain.WebhookDelivery, 0)
+		for rows.Next() {
+			var d domain.WebhookDelivery
+			if err := rows.Scan(append(deliveryDest(&d), &d.URL, &d.Secret)...); err != nil {
+				rows.Close()
+				return err
+			}
+
+			deliveries = append(deliveries, d)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		claimed = deliveries
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]string, 0, len(deliveries))
+		for _, d := range deliveries {
+			ids = append(ids, d.ID)
+		}
+
+		update := r.psql.
+			Update(TableWebhookDeliveries).
+			Set(NextAttemptAt, now.Add(lease)).
+			Where(squirrel.Eq{ID: ids})
+
+		query, params, err = update.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, query, params...); err != nil {
+			return err
+		}
+
+		for _, d := range deliveries {
+			leased := newDeliveryState(d)
+			leased.NextAttemptAt = now.Add(lease)
+
+			if err := r.audit(ctx, domain.AuditWebhookDeliveryClaimed, TableWebhookDeliveries, d.ID, newDeliveryState(d), leased, tx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+// deliveryState is the part of a webhook delivery its attempts change, its
+// snapshot in the audit log
+type deliveryState struct {
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	LastStatusCode *int      `json:"last_status_code"`
+	LastError      *string   `json:"last_error"`
+}
+
+func newDeliveryState(d domain.WebhookDelivery) deliveryState {
+	return deliveryState{
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+	}
+}
+
+// UpdateWebhookDelivery records the outcome of an attempt of the delivery, or
+// its redelivery
+func (r *Repo) UpdateWebhookDelivery(ctx context.Context, d domain.WebhookDelivery) error {
+	stmt := r.psql.
+		Update(TableWebhookDeliveries).
+		Set(Status, d.Status).
+		Set(Attempts, d.Attempts).
+		Set(NextAttemptAt, d.NextAttemptAt).
+		Set(LastStatusCode, d.LastStatusCode).
+		Set(LastError, d.LastError).
+		Set(UpdatedAt, d.UpdatedAt).
+		Where(squirrel.Eq{ID: d.ID})
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return r.withTx(ctx, func(tx pgx.Tx) error {
+		before, err := r.lockDeliveryState(ctx, d.ID, tx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, query, params...); err != nil {
+			return err
+		}
+
+		return r.audit(ctx, domain.AuditWebhookDeliveryUpdated, TableWebhookDeliveries, d.ID, before, newDeliveryState(d), tx)
+	})
+}
+
+// lockDeliveryState returns the state of the delivery, locking it until tx
+// ends
+func (r *Repo) lockDeliveryState(ctx context.Context, id string, tx pgx.Tx) (*deliveryState, error) {
+	stmt := r.psql.
+		Select(Status, Attempts, NextAttemptAt, LastStatusCode, LastError).
+		From(TableWebhookDeliveries).
+		Where(squirrel.Eq{ID: id}).
+		Suffix("FOR UPDATE")
+
+	query, params, err := stmt.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var state deliveryState
+	if err := tx.QueryRow(ctx, query, params...).Scan(
+		&state.Status,
+		&state.Attempts,
+		&state.NextAttemptAt,
+		&state.LastStatusCode,
+		&state.LastError,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func (r *Repo) selectSubscriptions() squirrel.SelectBuilder {
+	return r.psql.
+		Select(
+			ID,
+			URL,
+			EventTypes,
+			Secret,
+			Active,
+			CreatedAt,
+			UpdatedAt,
+		).
+		From(TableWebhookSubscriptions)
+}
+
+func scanSubscriptions(rows pgx.Rows) ([]domain.WebhookSubscription, error) {
+	defer rows.Close()
+
+	subs := make([]domain.WebhookSubscription, 0)
+	for rows.Next() {
+		var sub domain.WebhookSubscription
+		if err := rows.Scan(
+			&sub.ID,
+			&sub.URL,
+			&sub.EventTypes,
+			&sub.Secret,
+			&sub.Active,
+			&sub.CreatedAt,
+			&sub.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+func deliveryDest(d *domain.WebhookDelivery) []interface{} {
+	return []interface{}{
+		&d.ID,
+		&d.SubscriptionID,
+		&d.EventID,
+		&d.EventType,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	}
+}
@@ -0,0 +1,443 @@
+// Package repotest is the conformance suite of the repositories, every
+// implementation of service.Repo runs it to show it behaves like the others.
+// Tests only touch the accounts they create, so a repo may be shared.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/fraud"
+	"github.com/madhurikadam/app-transcation/internal/service"
+)
+
+// Repo is what the suite runs against, the fraud decisions are recorded so
+// that transcations can be held for review
+type Repo interface {
+	service.Repo
+	fraud.Recorder
+}
+
+// NewRepo returns the repo a test of the suite runs against
+type NewRepo func(t *testing.T) Repo
+
+var errRollback = errors.New("rollback")
+
+// Run runs the conformance suite against the repos returned by newRepo
+func Run(t *testing.T, newRepo NewRepo) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo Repo)
+	}{
+		{name: "create and get account", test: testCreateAccount},
+		{name: "get unknown account", test: testGetUnknownAccount},
+		{name: "update account limits", test: testUpdateAccountLimits},
+		{name: "debit consumes the withdrawal limit", test: testDebit},
+		{name: "debit exceeding the withdrawal limit", test: testDebitExceedingLimit},
+		{name: "credit discharges debits", test: testCredit},
+		{name: "credit exceeding the credit limit", test: testCreditExceedingLimit},
+		{name: "list transcations", test: testListTranscations},
+		{name: "list debts", test: testListDebitTx},
+		{name: "list account events", test: testListAccountEvents},
+		{name: "hold and approve a debit", test: testApproveReview},
+		{name: "hold and reject a debit", test: testRejectReview},
+		{name: "hold exceeding the withdrawal limit", test: testHoldExceedingLimit},
+		{name: "list due reviews", test: testListDueReviews},
+		{name: "unit of work commits", test: testWithinTxCommits},
+		{name: "unit of work rolls back", test: testWithinTxRollsBack},
+		{name: "velocity limits", test: testVelocity},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+// now is truncated to the precision postgres keeps
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func createAccount(t *testing.T, repo Repo, withdrawalLimit, creditLimit float64) domain.Account {
+	t.Helper()
+
+	at := now()
+	account := domain.Account{
+		ID:              uuid.NewString(),
+		DocumentNumber:  "12345678900",
+		WithdrawalLimit: withdrawalLimit,
+		CreaditLimit:    creditLimit,
+		CreatedAt:       at,
+		UpdatedAt:       &at,
+	}
+	require.NoError(t, repo.CreateAccount(context.Background(), account))
+
+	return account
+}
+
+func debit(accountID string, amount float64, at time.Time) domain.Transcation {
+	return domain.Transcation{
+		ID:              uuid.NewString(),
+		AccountID:       accountID,
+		OperationTypeID: 1,
+		Amount:          -amount,
+		Balance:         -amount,
+		EventAt:         at,
+		Status:          domain.TranscationPosted,
+	}
+}
+
+func credit(accountID string, amount, balance float64, at time.Time) domain.Transcation {
+	return domain.Transcation{
+		ID:              uuid.NewString(),
+		AccountID:       accountID,
+		OperationTypeID: 4,
+		Amount:          amount,
+		Balance:         balance,
+		EventAt:         at,
+		Status:          domain.TranscationPosted,
+	}
+}
+
+func requireLimits(ctx context.Context, t *testing.T, repo Repo, accountID string, withdrawalLimit, creditLimit float64) {
+	t.Helper()
+
+	account, err := repo.GetAccount(ctx, accountID)
+	require.NoError(t, err)
+	require.Equal(t, withdrawalLimit, account.WithdrawalLimit)
+	require.Equal(t, creditLimit, account.CreaditLimit)
+}
+
+func transcationIDs(transcations []domain.Transcation) []string {
+	ids := make([]string, 0, len(transcations))
+	for _, t := range transcations {
+		ids = append(ids, t.ID)
+	}
+
+	return ids
+}
+
+func testCreateAccount(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 200)
+
+	found, err := repo.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.ID, found.ID)
+	require.Equal(t, account.DocumentNumber, found.DocumentNumber)
+	require.Equal(t, account.WithdrawalLimit, found.WithdrawalLimit)
+	require.Equal(t, account.CreaditLimit, found.CreaditLimit)
+	require.True(t, account.CreatedAt.Equal(found.CreatedAt))
+
+	require.Error(t, repo.CreateAccount(ctx, account), "an account id is unique")
+
+	events, err := repo.ListAccountEvents(ctx, account.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, domain.EventAccountCreated, events[0].Type)
+
+	seq, err := repo.GetLastEventSeq(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, events[0].Seq, seq)
+}
+
+func testGetUnknownAccount(t *testing.T, repo Repo) {
+	ctx := context.Background()
+
+	_, err := repo.GetAccount(ctx, uuid.NewString())
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	seq, err := repo.GetLastEventSeq(ctx, uuid.NewString())
+	require.NoError(t, err)
+	require.Zero(t, seq)
+}
+
+func testUpdateAccountLimits(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 200)
+
+	withdrawalLimit := 50.0
+	limits, err := repo.UpdateAccountLimits(ctx, account.ID, domain.AccountLimitsReq{WithdrawalLimit: &withdrawalLimit}, now())
+	require.NoError(t, err)
+	require.Equal(t, domain.AccountLimits{AccountID: account.ID, WithdrawalLimit: 50, CreditLimit: 200}, *limits)
+	requireLimits(ctx, t, repo, account.ID, 50, 200)
+
+	events, err := repo.ListAccountEvents(ctx, account.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, domain.EventAccountLimitsChanged, events[1].Type)
+	require.Greater(t, events[1].Seq, events[0].Seq)
+
+	_, err = repo.UpdateAccountLimits(ctx, uuid.NewString(), domain.AccountLimitsReq{WithdrawalLimit: &withdrawalLimit}, now())
+	require.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testDebit(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	tx := debit(account.ID, 30, now())
+	require.NoError(t, repo.CreateDebitTranscation(ctx, tx, domain.VelocityLimits{}))
+	requireLimits(ctx, t, repo, account.ID, 70, 100)
+
+	debts, err := repo.ListDebitTx(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{tx.ID}, transcationIDs(debts))
+	require.Equal(t, -30.0, debts[0].Balance)
+
+	require.Error(t, repo.CreateDebitTranscation(ctx, tx, domain.VelocityLimits{}), "a transcation id is unique")
+	requireLimits(ctx, t, repo, account.ID, 70, 100)
+}
+
+func testDebitExceedingLimit(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	seq, err := repo.GetLastEventSeq(ctx, account.ID)
+	require.NoError(t, err)
+
+	err = repo.CreateDebitTranscation(ctx, debit(account.ID, 101, now()), domain.VelocityLimits{})
+	require.ErrorIs(t, err, domain.ErrExceedWithdrawalLimit)
+
+	// nothing of the debit is kept
+	requireLimits(ctx, t, repo, account.ID, 100, 100)
+
+	transcations, err := repo.ListTranscations(ctx, account.ID, nil, 10)
+	require.NoError(t, err)
+	require.Empty(t, transcations)
+
+	last, err := repo.GetLastEventSeq(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, seq, last)
+}
+
+func testCredit(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	at := now()
+	first := debit(account.ID, 20, at.Add(-2*time.Second))
+	second := debit(account.ID, 30, at.Add(-time.Second))
+	require.NoError(t, repo.CreateDebitTranscation(ctx, second, domain.VelocityLimits{}))
+	require.NoError(t, repo.CreateDebitTranscation(ctx, first, domain.VelocityLimits{}))
+
+	// debts are paid off oldest first
+	debts, err := repo.ListDebitTx(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{first.ID, second.ID}, transcationIDs(debts))
+
+	tx := credit(account.ID, 35, 0, at)
+	require.NoError(t, repo.CreateCreditTranscation(ctx, tx, []domain.DebitTx{
+		{ID: first.ID, Amount: 0},
+		{ID: second.ID, Amount: -15},
+	}))
+	requireLimits(ctx, t, repo, account.ID, 50, 65)
+
+	debts, err = repo.ListDebitTx(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{second.ID}, transcationIDs(debts))
+	require.Equal(t, -15.0, debts[0].Balance)
+
+	events, err := repo.ListAccountEvents(ctx, account.ID, 0, 20)
+	require.NoError(t, err)
+
+	types := make(map[string]int)
+	for _, event := range events {
+		types[event.Type]++
+	}
+	require.Equal(t, 3, types[domain.EventTranscationCreated])
+	require.Equal(t, 2, types[domain.EventTranscationDischarged])
+}
+
+func testCreditExceedingLimit(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 10)
+
+	at := now()
+	tx := debit(account.ID, 20, at.Add(-time.Second))
+	require.NoError(t, repo.CreateDebitTranscation(ctx, tx, domain.VelocityLimits{}))
+
+	err := repo.CreateCreditTranscation(ctx, credit(account.ID, 20, 0, at), []domain.DebitTx{{ID: tx.ID, Amount: 0}})
+	require.ErrorIs(t, err, domain.ErrExceedCreditLimit)
+
+	// the balance of the debit is rolled back along with the credit
+	debts, err := repo.ListDebitTx(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{tx.ID}, transcationIDs(debts))
+	require.Equal(t, -20.0, debts[0].Balance)
+	requireLimits(ctx, t, repo, account.ID, 80, 10)
+}
+
+func testListTranscations(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 1000, 1000)
+
+	at := now().Add(-time.Hour)
+	want := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		tx := debit(account.ID, 1, at.Add(time.Duration(i)*time.Second))
+		require.NoError(t, repo.CreateDebitTranscation(ctx, tx, domain.VelocityLimits{}))
+		want = append(want, tx.ID)
+	}
+
+	got := make([]string, 0, 5)
+	var after *domain.TranscationCursor
+	for {
+		page, err := repo.ListTranscations(ctx, account.ID, after, 2)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), 2)
+
+		if len(page) == 0 {
+			break
+		}
+
+		got = append(got, transcationIDs(page)...)
+		last := page[len(page)-1]
+		after = &domain.TranscationCursor{EventAt: last.EventAt, ID: last.ID}
+	}
+	require.Equal(t, want, got)
+
+	// a cursor without an id starts after the event time
+	page, err := repo.ListTranscations(ctx, account.ID, &domain.TranscationCursor{EventAt: at.Add(2 * time.Second)}, 10)
+	require.NoError(t, err)
+	require.Equal(t, want[3:], transcationIDs(page))
+}
+
+func testWithinTxCommits(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	tx := debit(account.ID, 40, now())
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		found, err := repo.GetAccount(ctx, account.ID)
+		if err != nil {
+			return err
+		}
+
+		if found.WithdrawalLimit < -tx.Amount {
+			return domain.ErrExceedWithdrawalLimit
+		}
+
+		return repo.CreateDebitTranscation(ctx, tx, domain.VelocityLimits{})
+	})
+	require.NoError(t, err)
+	requireLimits(ctx, t, repo, account.ID, 60, 100)
+}
+
+func testWithinTxRollsBack(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.CreateDebitTranscation(ctx, debit(account.ID, 40, now()), domain.VelocityLimits{}); err != nil {
+			return err
+		}
+
+		withdrawalLimit := 10.0
+		if _, err := repo.UpdateAccountLimits(ctx, account.ID, domain.AccountLimitsReq{WithdrawalLimit: &withdrawalLimit}, now()); err != nil {
+			return err
+		}
+
+		// the writes of the unit of work are seen inside of it
+		requireLimits(ctx, t, repo, account.ID, 10, 100)
+
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	requireLimits(ctx, t, repo, account.ID, 100, 100)
+
+	transcations, err := repo.ListTranscations(ctx, account.ID, nil, 10)
+	require.NoError(t, err)
+	require.Empty(t, transcations)
+}
+
+func testVelocity(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 1000, 1000)
+
+	velocity := domain.VelocityLimits{MaxDebitsPerMinute: 2, MaxDebitAmountPerDay: 100}
+	at := now()
+	require.NoError(t, repo.CreateDebitTranscation(ctx, debit(account.ID, 10, at.Add(-2*time.Second)), velocity))
+	require.NoError(t, repo.CreateDebitTranscation(ctx, debit(account.ID, 10, at.Add(-time.Second)), velocity))
+
+	var velocityErr *domain.VelocityError
+	err := repo.CreateDebitTranscation(ctx, debit(account.ID, 10, at), velocity)
+	require.ErrorAs(t, err, &velocityErr)
+	require.Equal(t, domain.VelocityDebitsPerMinute, velocityErr.Limit)
+	require.Equal(t, 58*time.Second, velocityErr.RetryAfter)
+
+	// the debit fits once the first one leaves the window
+	err = repo.CreateDebitTranscation(ctx, debit(account.ID, 90, at.Add(2*time.Minute)), velocity)
+	require.ErrorAs(t, err, &velocityErr)
+	require.Equal(t, domain.VelocityDebitAmountPerDay, velocityErr.Limit)
+	require.Equal(t, 24*time.Hour-2*time.Minute-2*time.Second, velocityErr.RetryAfter)
+
+	// a debit over the limit alone never fits
+	err = repo.CreateDebitTranscation(ctx, debit(account.ID, 120, at.Add(2*time.Minute)), velocity)
+	require.ErrorAs(t, err, &velocityErr)
+	require.Zero(t, velocityErr.RetryAfter)
+
+	require.NoError(t, repo.CreateDebitTranscation(ctx, debit(account.ID, 80, at.Add(2*time.Minute)), velocity))
+	requireLimits(ctx, t, repo, account.ID, 900, 1000)
+}
+
+func testListDebitTx(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	at := now()
+	paid := debit(account.ID, 10, at.Add(-3*time.Second))
+	owed := debit(account.ID, 20, at.Add(-2*time.Second))
+	require.NoError(t, repo.CreateDebitTranscation(ctx, paid, domain.VelocityLimits{}))
+	require.NoError(t, repo.CreateDebitTranscation(ctx, owed, domain.VelocityLimits{}))
+	require.NoError(t, repo.CreateCreditTranscation(ctx, credit(account.ID, 10, 0, at.Add(-time.Second)), []domain.DebitTx{{ID: paid.ID, Amount: 0}}))
+
+	// credits and paid off debits are no debts
+	debts, err := repo.ListDebitTx(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{owed.ID}, transcationIDs(debts))
+
+	debts, err = repo.ListDebitTx(ctx, uuid.NewString())
+	require.NoError(t, err)
+	require.Empty(t, debts)
+}
+
+func testListAccountEvents(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	at := now()
+	require.NoError(t, repo.CreateDebitTranscation(ctx, debit(account.ID, 10, at.Add(-time.Second)), domain.VelocityLimits{}))
+	require.NoError(t, repo.CreateDebitTranscation(ctx, debit(account.ID, 10, at), domain.VelocityLimits{}))
+
+	events, err := repo.ListAccountEvents(ctx, account.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+
+	for i, event := range events {
+		require.Equal(t, account.ID, event.AccountID)
+		if i > 0 {
+			require.Greater(t, event.Seq, events[i-1].Seq)
+		}
+	}
+	require.Equal(t, domain.EventTranscationCreated, events[1].Type)
+	require.Equal(t, domain.EventAccountLimitsChanged, events[2].Type)
+
+	page, err := repo.ListAccountEvents(ctx, account.ID, events[0].Seq, 2)
+	require.NoError(t, err)
+	require.Equal(t, events[1:3], page)
+
+	seq, err := repo.GetLastEventSeq(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, events[4].Seq, seq)
+}
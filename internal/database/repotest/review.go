@@ -0,0 +1,201 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// hold records a review decision of the fraud rules for a debit of amount and
+// holds the debit until dueAt
+func hold(t *testing.T, repo Repo, accountID string, amount float64, dueAt time.Time) (domain.Review, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	transcation := debit(accountID, amount, now())
+	transcation.Status = domain.TranscationPendingReview
+
+	decision := domain.FraudDecision{
+		ID:              uuid.NewString(),
+		TranscationID:   transcation.ID,
+		AccountID:       accountID,
+		OperationTypeID: transcation.OperationTypeID,
+		Amount:          amount,
+		Outcome:         domain.FraudReview,
+		Reasons: []domain.FraudReason{
+			{Rule: "large-amount", Outcome: domain.FraudReview, Reason: "amount over 50"},
+		},
+		CreatedAt: now(),
+	}
+	require.NoError(t, repo.RecordFraudDecision(ctx, decision))
+
+	review := domain.Review{
+		ID:          uuid.NewString(),
+		Transcation: transcation,
+		DecisionID:  decision.ID,
+		Reasons:     decision.Reasons,
+		Status:      domain.ReviewPending,
+		DueAt:       dueAt,
+		CreatedAt:   now(),
+	}
+
+	return review, repo.HoldTranscation(ctx, review, domain.VelocityLimits{})
+}
+
+func decide(review domain.Review, status string) domain.Review {
+	at := now()
+	review.Status = status
+	review.Reviewer = "operator"
+	review.Reason = "checked with the card holder"
+	review.DecidedAt = &at
+
+	if status == domain.ReviewRejected {
+		review.Transcation.Status = domain.TranscationRejected
+		review.Transcation.Balance = 0
+	} else {
+		review.Transcation.Status = domain.TranscationPosted
+	}
+
+	return review
+}
+
+func testApproveReview(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	review, err := hold(t, repo, account.ID, 60, now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// the limit is reserved while the debit is held, it is no debt yet
+	requireLimits(ctx, t, repo, account.ID, 40, 100)
+
+	debts, err := repo.ListDebitTx(ctx, account.ID)
+	require.NoError(t, err)
+	require.Empty(t, debts)
+
+	found, err := repo.GetReview(ctx, review.ID)
+	require.NoError(t, err)
+	require.Equal(t, domain.ReviewPending, found.Status)
+	require.Equal(t, review.Transcation.ID, found.Transcation.ID)
+	require.Equal(t, domain.TranscationPendingReview, found.Transcation.Status)
+	require.Equal(t, review.Reasons, found.Reasons)
+	require.Nil(t, found.DecidedAt)
+
+	pending, err := repo.ListReviews(ctx, domain.ReviewPending, 100)
+	require.NoError(t, err)
+	require.Contains(t, reviewIDs(pending), review.ID)
+
+	approved := decide(*found, domain.ReviewApproved)
+	require.NoError(t, repo.ApproveReview(ctx, approved, nil))
+	require.ErrorIs(t, repo.ApproveReview(ctx, approved, nil), domain.ErrReviewDecided)
+	require.ErrorIs(t, repo.RejectReview(ctx, decide(*found, domain.ReviewRejected)), domain.ErrReviewDecided)
+
+	found, err = repo.GetReview(ctx, review.ID)
+	require.NoError(t, err)
+	require.Equal(t, domain.ReviewApproved, found.Status)
+	require.Equal(t, "operator", found.Reviewer)
+	require.Equal(t, domain.TranscationPosted, found.Transcation.Status)
+	require.NotNil(t, found.DecidedAt)
+
+	// the limit used by the hold stays used
+	requireLimits(ctx, t, repo, account.ID, 40, 100)
+
+	debts, err = repo.ListDebitTx(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{review.Transcation.ID}, transcationIDs(debts))
+}
+
+func testRejectReview(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 100, 100)
+
+	review, err := hold(t, repo, account.ID, 60, now().Add(time.Hour))
+	require.NoError(t, err)
+
+	rejected := decide(review, domain.ReviewRejected)
+	require.NoError(t, repo.RejectReview(ctx, rejected))
+	require.ErrorIs(t, repo.RejectReview(ctx, rejected), domain.ErrReviewDecided)
+
+	found, err := repo.GetReview(ctx, review.ID)
+	require.NoError(t, err)
+	require.Equal(t, domain.ReviewRejected, found.Status)
+	require.Equal(t, domain.TranscationRejected, found.Transcation.Status)
+
+	// the reserved limit is released
+	requireLimits(ctx, t, repo, account.ID, 100, 100)
+
+	_, err = repo.GetReview(ctx, uuid.NewString())
+	require.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testHoldExceedingLimit(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 50, 100)
+
+	review, err := hold(t, repo, account.ID, 60, now().Add(time.Hour))
+	require.ErrorIs(t, err, domain.ErrExceedWithdrawalLimit)
+
+	// neither the transcation nor its review is kept
+	_, err = repo.GetReview(ctx, review.ID)
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	transcations, err := repo.ListTranscations(ctx, account.ID, nil, 10)
+	require.NoError(t, err)
+	require.Empty(t, transcations)
+	requireLimits(ctx, t, repo, account.ID, 50, 100)
+}
+
+func testListDueReviews(t *testing.T, repo Repo) {
+	ctx := context.Background()
+	account := createAccount(t, repo, 1000, 1000)
+
+	at := now()
+	later, err := hold(t, repo, account.ID, 10, at.Add(time.Hour))
+	require.NoError(t, err)
+	due, err := hold(t, repo, account.ID, 10, at.Add(-time.Hour))
+	require.NoError(t, err)
+
+	reviews, err := repo.ListDueReviews(ctx, at, 100)
+	require.NoError(t, err)
+	require.Contains(t, reviewIDs(reviews), due.ID)
+	require.NotContains(t, reviewIDs(reviews), later.ID)
+
+	// pending reviews are ordered by due time
+	reviews, err = repo.ListReviews(ctx, domain.ReviewPending, 100)
+	require.NoError(t, err)
+
+	ids := reviewIDs(reviews)
+	require.Less(t, indexOf(ids, due.ID), indexOf(ids, later.ID))
+
+	// decided reviews aren't due anymore
+	require.NoError(t, repo.RejectReview(ctx, decide(due, domain.ReviewRejected)))
+	require.NoError(t, repo.RejectReview(ctx, decide(later, domain.ReviewRejected)))
+
+	reviews, err = repo.ListDueReviews(ctx, at, 100)
+	require.NoError(t, err)
+	require.NotContains(t, reviewIDs(reviews), due.ID)
+}
+
+func reviewIDs(reviews []domain.Review) []string {
+	ids := make([]string, 0, len(reviews))
+	for _, r := range reviews {
+		ids = append(ids, r.ID)
+	}
+
+	return ids
+}
+
+func indexOf(ids []string, id string) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+
+	return -1
+}
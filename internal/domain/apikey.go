@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+// APIKey authenticates a server to server integration, only the hash of the
+// key is stored
+type APIKey struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	Scopes  []string `json:"scopes"`
+	// RateLimit is the number of requests allowed per minute, 0 is unlimited
+	RateLimit   int        `json:"rate_limit"`
+	Hash        []byte     `json:"-"`
+	RotatedFrom *string    `json:"rotated_from"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Valid reports whether the key may be used at the given time
+func (k APIKey) Valid(at time.Time) bool {
+	if k.RevokedAt != nil && !at.Before(*k.RevokedAt) {
+		return false
+	}
+
+	return k.ExpiresAt == nil || at.Before(*k.ExpiresAt)
+}
+
+type APIKeyReq struct {
+	Name      string     `json:"name"`
+	Subject   string     `json:"subject"`
+	Roles     []string   `json:"roles"`
+	Scopes    []string   `json:"scopes"`
+	RateLimit int        `json:"rate_limit"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyRotationReq rotates a key, the old key stays valid for Overlap so that
+// clients can switch to the new key without downtime. The new key expires at
+// ExpiresAt, or when the old key did when it is missing.
+type APIKeyRotationReq struct {
+	Overlap   string     `json:"overlap"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// IssuedAPIKey is returned once when a key is issued, Key is never shown again
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
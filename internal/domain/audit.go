@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// audited actions, the entity type of an entry is the table it changed
+const (
+	AuditAccountCreated            = "account.created"
+	AuditAccountLimitsChanged      = "account.limits_changed"
+	AuditTranscationCreated        = "transcation.created"
+	AuditTranscationBalanceChanged = "transcation.balance_changed"
+	AuditTranscationStatusChanged  = "transcation.status_changed"
+	AuditReviewCreated             = "review.created"
+	AuditReviewDecided             = "review.decided"
+	AuditFraudDecisionRecorded     = "fraud_decision.recorded"
+	AuditWebhookCreated            = "webhook_subscription.created"
+	AuditWebhookDeleted            = "webhook_subscription.deleted"
+	AuditAPIKeyCreated             = "api_key.created"
+	AuditAPIKeyExpiryChanged       = "api_key.expiry_changed"
+	AuditAPIKeyRevoked             = "api_key.revoked"
+	AuditWebhookDeliveryClaimed    = "webhook_delivery.claimed"
+	AuditWebhookDeliveryUpdated    = "webhook_delivery.updated"
+	AuditEventPublished            = "outbox_event.published"
+	AuditAccessDenied              = "access.denied"
+
+	// AuditEntityAccess is the entity type of the denied actions, the entity
+	// id is the subject that was denied
+	AuditEntityAccess = "access"
+
+	// AuditActorSystem is the actor of the writes made without a caller, by
+	// the background jobs for instance
+	AuditActorSystem = "system"
+	// AuditAnonymous is the entity id of the denied actions of callers that
+	// didn't authenticate
+	AuditAnonymous = "anonymous"
+)
+
+// AuditEntry records a write: who made it, in which request, and the entity
+// before and after it. The entries of an entity form a hash chain, each hash
+// covers the entry and the hash of the entry PrevSeq before it, so that
+// changing or removing an entry breaks the chain from there on.
+type AuditEntry struct {
+	Seq int64 `json:"seq"`
+	// PrevSeq is the entry chained before this one, 0 for the first entry.
+	// Entries written before the log was chained per entity chain to the
+	// entry before them in the log.
+	PrevSeq    int64           `json:"prev_seq"`
+	ID         string          `json:"id"`
+	Actor      string          `json:"actor"`
+	RequestID  string          `json:"request_id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// Stream identifies the hash chain of the entry, the one of its entity
+func (e AuditEntry) Stream() string {
+	return e.EntityType + "/" + e.EntityID
+}
+
+// ComputeHash returns the hash of the entry chained to its PrevHash. The
+// snapshots are hashed in a canonical form so that the hash doesn't depend on
+// how the database stored the json.
+func (e AuditEntry) ComputeHash() (string, error) {
+	before, err := canonicalJSON(e.Before)
+	if err != nil {
+		return "", fmt.Errorf("invalid before snapshot: %w", err)
+	}
+
+	after, err := canonicalJSON(e.After)
+	if err != nil {
+		return "", fmt.Errorf("invalid after snapshot: %w", err)
+	}
+
+	data, err := json.Marshal(struct {
+		PrevHash   string          `json:"prev_hash"`
+		ID         string          `json:"id"`
+		Actor      string          `json:"actor"`
+		RequestID  string          `json:"request_id"`
+		Action     string          `json:"action"`
+		EntityType string          `json:"entity_type"`
+		EntityID   string          `json:"entity_id"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		CreatedAt  string          `json:"created_at"`
+	}{
+		PrevHash:   e.PrevHash,
+		ID:         e.ID,
+		Actor:      e.Actor,
+		RequestID:  e.RequestID,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Before:     before,
+		After:      after,
+		CreatedAt:  e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON re-encodes a json document with sorted keys and no spaces, an
+// empty document is null
+func canonicalJSON(data json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return json.RawMessage("null"), nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// AuditBreak is an entry at which the hash chain doesn't hold
+type AuditBreak struct {
+	Seq    int64  `json:"seq"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
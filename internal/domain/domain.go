@@ -1,12 +1,25 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+
+	// the limits are checked again by the repo while the account is locked,
+	// concurrent transcations can't overdraw them
+	ErrExceedWithdrawalLimit = errors.New("exceed withdrwal limit")
+	ErrExceedCreditLimit     = errors.New("exceed credit limit")
+)
 
 type Account struct {
 	ID              string     `json:"id"`
 	DocumentNumber  string     `json:"document_number"`
 	WithdrawalLimit float64    `json:"withdrawal_limit"`
 	CreaditLimit    float64    `json:"credit_limit"`
+	OwnerID         string     `json:"owner_id,omitempty"`
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       *time.Time `json:"updated_at"`
 }
@@ -15,6 +28,14 @@ type AccountReq struct {
 	DocumentNumber string `json:"document_number"`
 }
 
+// statuses of a transcation, only posted transcations count towards the
+// balance of the account
+const (
+	TranscationPosted        = "posted"
+	TranscationPendingReview = "pending_review"
+	TranscationRejected      = "rejected"
+)
+
 type Transcation struct {
 	ID              string    `json:"id"`
 	AccountID       string    `json:"account_id"`
@@ -22,6 +43,27 @@ type Transcation struct {
 	Amount          float64   `json:"amount"`
 	EventAt         time.Time `json:"event_at"`
 	Balance         float64   `json:"balance"`
+	Status          string    `json:"status"`
+}
+
+// IsDebit reports whether the transcation takes money from the account, every
+// operation type but credit vouchers does
+func (t Transcation) IsDebit() bool {
+	return t.OperationTypeID != 4
+}
+
+// TranscationPage is a page of an account's transcations, NextPageToken is
+// empty on the last page
+type TranscationPage struct {
+	Transcations  []Transcation `json:"transcations"`
+	NextPageToken string        `json:"next_page_token"`
+}
+
+// TranscationCursor is the position after which a page of transcations
+// starts, transcations are ordered by event time and id
+type TranscationCursor struct {
+	EventAt time.Time
+	ID      string
 }
 
 type DebitTx struct {
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventTranscationCreated    = "transcation.created"
+	EventTranscationDischarged = "transcation.discharged"
+	EventAccountLimitsChanged  = "account.limits_changed"
+	EventAccountCreated        = "account.created"
+)
+
+// EventTypes lists every event type that can be published by the service
+var EventTypes = []string{
+	EventTranscationCreated,
+	EventTranscationDischarged,
+	EventAccountLimitsChanged,
+	EventAccountCreated,
+}
+
+type Event struct {
+	ID        string          `json:"id"`
+	Seq       int64           `json:"seq"`
+	AccountID string          `json:"account_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AccountLimits is the payload of account.limits_changed and account.created
+type AccountLimits struct {
+	AccountID       string  `json:"account_id"`
+	WithdrawalLimit float64 `json:"withdrawal_limit"`
+	CreditLimit     float64 `json:"credit_limit"`
+}
+
+// AccountLimitsReq sets the limits of an account, nil limits are left as is
+type AccountLimitsReq struct {
+	WithdrawalLimit *float64 `json:"withdrawal_limit"`
+	CreditLimit     *float64 `json:"credit_limit"`
+}
+
+// Discharge is the payload of transcation.discharged, it is published when a
+// credit pays off part or all of a debit
+type Discharge struct {
+	TranscationID string  `json:"transcation_id"`
+	AccountID     string  `json:"account_id"`
+	Balance       float64 `json:"balance"`
+	DischargedBy  string  `json:"discharged_by"`
+}
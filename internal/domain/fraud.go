@@ -0,0 +1,52 @@
+package domain
+
+import "time"
+
+type FraudOutcome string
+
+// outcomes of the fraud rules, from the least to the most severe
+const (
+	FraudApprove FraudOutcome = "approve"
+	FraudReview  FraudOutcome = "review"
+	FraudDecline FraudOutcome = "decline"
+)
+
+// Severity orders the outcomes, the most severe outcome of the matching rules
+// is the outcome of a decision
+func (o FraudOutcome) Severity() int {
+	switch o {
+	case FraudReview:
+		return 1
+	case FraudDecline:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// FraudReason is a fraud rule a transcation matched
+type FraudReason struct {
+	Rule    string       `json:"rule"`
+	Outcome FraudOutcome `json:"outcome"`
+	Reason  string       `json:"reason"`
+}
+
+// FraudDecision is the outcome of the fraud rules for a transcation, every
+// decision is recorded whether the transcation was posted or not
+type FraudDecision struct {
+	ID              string        `json:"id"`
+	TranscationID   string        `json:"transcation_id"`
+	AccountID       string        `json:"account_id"`
+	OperationTypeID int           `json:"operation_type_id"`
+	Amount          float64       `json:"amount"`
+	Outcome         FraudOutcome  `json:"outcome"`
+	Reasons         []FraudReason `json:"reasons"`
+	CreatedAt       time.Time     `json:"created_at"`
+}
+
+// TranscationStats sums up the transcations of an account, Amount adds up
+// absolute amounts
+type TranscationStats struct {
+	Count  int
+	Amount float64
+}
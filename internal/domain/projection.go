@@ -0,0 +1,14 @@
+package domain
+
+import "errors"
+
+// ErrProjectionStale is returned when rebuilding an account from a projection
+// that missed its latest events
+var ErrProjectionStale = errors.New("events were written after the projection")
+
+// TranscationBalance is the balance of a posted transcation
+type TranscationBalance struct {
+	ID        string  `json:"id"`
+	AccountID string  `json:"account_id"`
+	Balance   float64 `json:"balance"`
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrReviewDecided = errors.New("review already decided")
+
+// statuses of a review
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review holds a transcation flagged by the fraud rules until an operator
+// decides on it, or until DueAt when it is decided automatically. The limit
+// the transcation uses stays reserved while the review is pending.
+type Review struct {
+	ID          string        `json:"id"`
+	Transcation Transcation   `json:"transcation"`
+	DecisionID  string        `json:"decision_id"`
+	Reasons     []FraudReason `json:"reasons"`
+	Status      string        `json:"status"`
+	Reviewer    string        `json:"reviewer,omitempty"`
+	Reason      string        `json:"reason,omitempty"`
+	DueAt       time.Time     `json:"due_at"`
+	DecidedAt   *time.Time    `json:"decided_at"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// ReviewDecisionReq is the decision of an operator on a review, a reason is
+// required to reject a transcation
+type ReviewDecisionReq struct {
+	Reason string `json:"reason"`
+}
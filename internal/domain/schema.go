@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"embed"
+	"fmt"
+)
+
+// Schemas holds the JSON Schema of every published event version, schema
+// files are named <event type>.v<version>.json
+//
+//go:embed schema/*.json
+var Schemas embed.FS
+
+// EventSchemaVersions is the schema version currently published for each
+// event type. Any change to an event payload needs a new schema file and a
+// bump of its version here.
+var EventSchemaVersions = map[string]int{
+	EventTranscationCreated:    2,
+	EventTranscationDischarged: 1,
+	EventAccountLimitsChanged:  1,
+	EventAccountCreated:        1,
+}
+
+// SchemaFile returns the path inside Schemas of the schema describing the
+// given version of eventType
+func SchemaFile(eventType string, version int) string {
+	return fmt.Sprintf("schema/%s.v%d.json", eventType, version)
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// schemaPayloads holds a sample payload for every event type, the json
+// encoding of the sample must match the payload schema exactly
+var schemaPayloads = map[string]interface{}{
+	EventTranscationCreated:    Transcation{EventAt: time.Now()},
+	EventTranscationDischarged: Discharge{},
+	EventAccountLimitsChanged:  AccountLimits{},
+	EventAccountCreated:        AccountLimits{},
+}
+
+type jsonSchema struct {
+	Properties map[string]jsonSchema `json:"properties"`
+}
+
+func TestEventSchemas(t *testing.T) {
+	t.Parallel()
+
+	for _, eventType := range EventTypes {
+		eventType := eventType
+
+		t.Run(eventType, func(t *testing.T) {
+			version, ok := EventSchemaVersions[eventType]
+			require.True(t, ok, "missing schema version")
+
+			data, err := Schemas.ReadFile(SchemaFile(eventType, version))
+			require.NoError(t, err)
+
+			var schema jsonSchema
+			require.NoError(t, json.Unmarshal(data, &schema))
+
+			payloadSchema, ok := schema.Properties["payload"]
+			require.True(t, ok, "schema has no payload")
+
+			sample, ok := schemaPayloads[eventType]
+			require.True(t, ok, "missing sample payload")
+
+			encoded, err := json.Marshal(sample)
+			require.NoError(t, err)
+
+			var fields map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(encoded, &fields))
+
+			encodedKeys := make([]string, 0, len(fields))
+			for key := range fields {
+				encodedKeys = append(encodedKeys, key)
+			}
+
+			schemaKeys := make([]string, 0, len(payloadSchema.Properties))
+			for key := range payloadSchema.Properties {
+				schemaKeys = append(schemaKeys, key)
+			}
+
+			sort.Strings(encodedKeys)
+			sort.Strings(schemaKeys)
+			require.Equal(t, schemaKeys, encodedKeys, "payload fields changed, add a new schema version")
+		})
+	}
+}
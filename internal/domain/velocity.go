@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrVelocityLimitExceeded = errors.New("velocity limit exceeded")
+
+const (
+	VelocityDebitsPerMinute   = "debits_per_minute"
+	VelocityDebitAmountPerDay = "debit_amount_per_day"
+
+	velocityMinute = time.Minute
+	velocityDay    = 24 * time.Hour
+)
+
+// VelocityLimits bound how fast an account spends, a zero limit is disabled
+type VelocityLimits struct {
+	MaxDebitsPerMinute   int
+	MaxDebitAmountPerDay float64
+}
+
+// Enabled reports whether any of the limits is set
+func (v VelocityLimits) Enabled() bool {
+	return v.MaxDebitsPerMinute > 0 || v.MaxDebitAmountPerDay > 0
+}
+
+// Window is how far back the debits counted by the limits go
+func (v VelocityLimits) Window() time.Duration {
+	if v.MaxDebitAmountPerDay > 0 {
+		return velocityDay
+	}
+
+	return velocityMinute
+}
+
+// VelocityDebit is a debit counted by the velocity limits, its amount is
+// positive
+type VelocityDebit struct {
+	EventAt time.Time
+	Amount  float64
+}
+
+// Check fails with a VelocityError when a debit of amount made at would
+// exceed the limits. debits are the ones of the account made within Window
+// before at, oldest first.
+func (v VelocityLimits) Check(debits []VelocityDebit, amount float64, at time.Time) error {
+	if v.MaxDebitsPerMinute > 0 {
+		recent := debitsAfter(debits, at.Add(-velocityMinute))
+		if len(recent) >= v.MaxDebitsPerMinute {
+			// the debit fits once all but the latest MaxDebitsPerMinute-1 left
+			// the window
+			leaving := recent[len(recent)-v.MaxDebitsPerMinute]
+
+			return &VelocityError{
+				Limit:      VelocityDebitsPerMinute,
+				RetryAfter: leaving.EventAt.Add(velocityMinute).Sub(at),
+			}
+		}
+	}
+
+	if v.MaxDebitAmountPerDay > 0 {
+		recent := debitsAfter(debits, at.Add(-velocityDay))
+
+		var total float64
+		for _, debit := range recent {
+			total += debit.Amount
+		}
+
+		if total+amount > v.MaxDebitAmountPerDay {
+			err := &VelocityError{Limit: VelocityDebitAmountPerDay}
+
+			// the debit fits once enough of the amount left the window, never
+			// when it exceeds the limit alone
+			if amount <= v.MaxDebitAmountPerDay {
+				for _, debit := range recent {
+					total -= debit.Amount
+					if total+amount <= v.MaxDebitAmountPerDay {
+						err.RetryAfter = debit.EventAt.Add(velocityDay).Sub(at)
+						break
+					}
+				}
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// debitsAfter returns the debits made after since, debits are oldest first
+func debitsAfter(debits []VelocityDebit, since time.Time) []VelocityDebit {
+	for i, debit := range debits {
+		if debit.EventAt.After(since) {
+			return debits[i:]
+		}
+	}
+
+	return nil
+}
+
+// VelocityError is returned for a debit exceeding a velocity limit of its
+// account, RetryAfter is the earliest time the debit may be accepted, zero
+// when it never will be
+type VelocityError struct {
+	Limit      string
+	RetryAfter time.Duration
+}
+
+func (e *VelocityError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrVelocityLimitExceeded, e.Limit)
+}
+
+func (e *VelocityError) Unwrap() error {
+	return ErrVelocityLimitExceeded
+}
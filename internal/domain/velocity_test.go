@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVelocityLimitsCheck(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration, amount float64) VelocityDebit {
+		return VelocityDebit{EventAt: at.Add(-d), Amount: amount}
+	}
+
+	tests := []struct {
+		name       string
+		limits     VelocityLimits
+		debits     []VelocityDebit
+		amount     float64
+		limit      string
+		retryAfter time.Duration
+	}{
+		{
+			name:   "under the limits",
+			limits: VelocityLimits{MaxDebitsPerMinute: 3, MaxDebitAmountPerDay: 100},
+			debits: []VelocityDebit{ago(2*time.Hour, 50), ago(30*time.Second, 10)},
+			amount: 40,
+		},
+		{
+			name:   "debits out of the windows aren't counted",
+			limits: VelocityLimits{MaxDebitsPerMinute: 1, MaxDebitAmountPerDay: 100},
+			debits: []VelocityDebit{ago(24*time.Hour, 90), ago(time.Minute, 10)},
+			amount: 90,
+		},
+		{
+			name:       "too many debits wait for the oldest to leave the window",
+			limits:     VelocityLimits{MaxDebitsPerMinute: 2},
+			debits:     []VelocityDebit{ago(50*time.Second, 1), ago(2*time.Second, 1)},
+			amount:     1,
+			limit:      VelocityDebitsPerMinute,
+			retryAfter: 10 * time.Second,
+		},
+		{
+			name:       "far too many debits wait for several to leave the window",
+			limits:     VelocityLimits{MaxDebitsPerMinute: 2},
+			debits:     []VelocityDebit{ago(50*time.Second, 1), ago(40*time.Second, 1), ago(30*time.Second, 1), ago(time.Second, 1)},
+			amount:     1,
+			limit:      VelocityDebitsPerMinute,
+			retryAfter: 30 * time.Second,
+		},
+		{
+			name:       "the amount waits for enough of the window amount to leave",
+			limits:     VelocityLimits{MaxDebitAmountPerDay: 100},
+			debits:     []VelocityDebit{ago(20*time.Hour, 10), ago(10*time.Hour, 30), ago(time.Hour, 50)},
+			amount:     50,
+			limit:      VelocityDebitAmountPerDay,
+			retryAfter: 14 * time.Hour,
+		},
+		{
+			name:   "an amount over the limit alone never fits",
+			limits: VelocityLimits{MaxDebitAmountPerDay: 100},
+			debits: []VelocityDebit{ago(time.Hour, 10)},
+			amount: 120,
+			limit:  VelocityDebitAmountPerDay,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Check(tt.debits, tt.amount, at)
+			if tt.limit == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var velocityErr *VelocityError
+			require.True(t, errors.As(err, &velocityErr))
+			require.Equal(t, tt.limit, velocityErr.Limit)
+			require.Equal(t, tt.retryAfter, velocityErr.RetryAfter)
+
+			// the debit fits at the time given
+			if tt.retryAfter > 0 {
+				require.NoError(t, tt.limits.Check(tt.debits, tt.amount, at.Add(tt.retryAfter)))
+				require.Error(t, tt.limits.Check(tt.debits, tt.amount, at.Add(tt.retryAfter-time.Microsecond)))
+			}
+		})
+	}
+}
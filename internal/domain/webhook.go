@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// EventTypeAll subscribes a webhook to every event type
+	EventTypeAll = "*"
+
+	DeliveryStatusPending    = "pending"
+	DeliveryStatusDelivered  = "delivered"
+	DeliveryStatusDeadLetter = "dead_letter"
+)
+
+type WebhookSubscription struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookSubscriptionReq struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+// Matches reports whether the subscription is interested in the given event type
+func (w WebhookSubscription) Matches(eventType string) bool {
+	if !w.Active {
+		return false
+	}
+
+	for _, val := range w.EventTypes {
+		if val == EventTypeAll || val == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+
+	// URL and Secret are loaded from the subscription when a delivery is
+	// claimed for dispatch, they are never exposed over the api
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
@@ -0,0 +1,166 @@
+package fraud
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// rule types of the rules file
+const (
+	TypeAmount           = "amount"
+	TypeVelocity         = "velocity"
+	TypeFirstTranscation = "first_transcation"
+	TypeUnusualHour      = "unusual_hour"
+	TypeRepeatedDeclines = "repeated_declines"
+)
+
+type (
+	rulesFile struct {
+		Rules []ruleConfig `yaml:"rules"`
+	}
+
+	// ruleConfig is a rule of the rules file, which fields apply depends on
+	// its type
+	ruleConfig struct {
+		Name           string              `yaml:"name"`
+		Type           string              `yaml:"type"`
+		Outcome        domain.FraudOutcome `yaml:"outcome"`
+		Reason         string              `yaml:"reason"`
+		OperationTypes []int               `yaml:"operation_types"`
+		MinAmount      float64             `yaml:"min_amount"`
+
+		Window        time.Duration `yaml:"window"`
+		MaxCount      int           `yaml:"max_count"`
+		MaxAmount     float64       `yaml:"max_amount"`
+		MaxAccountAge time.Duration `yaml:"max_account_age"`
+		FromHour      int           `yaml:"from_hour"`
+		ToHour        int           `yaml:"to_hour"`
+		Timezone      string        `yaml:"timezone"`
+	}
+)
+
+// LoadRules reads the rule definitions of a rules file
+func LoadRules(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fraud rules: %w", err)
+	}
+
+	return ParseRules(data)
+}
+
+// ParseRules parses the yaml rule definitions of a rules file
+func ParseRules(data []byte) ([]Definition, error) {
+	var file rulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse fraud rules: %w", err)
+	}
+
+	names := make(map[string]bool, len(file.Rules))
+	definitions := make([]Definition, 0, len(file.Rules))
+	for i, cfg := range file.Rules {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("fraud rule %d has no name", i)
+		}
+
+		if names[cfg.Name] {
+			return nil, fmt.Errorf("fraud rule %s is defined twice", cfg.Name)
+		}
+		names[cfg.Name] = true
+
+		definition, err := cfg.definition()
+		if err != nil {
+			return nil, fmt.Errorf("invalid fraud rule %s: %w", cfg.Name, err)
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+func (c ruleConfig) definition() (Definition, error) {
+	if c.Outcome != domain.FraudReview && c.Outcome != domain.FraudDecline {
+		return Definition{}, fmt.Errorf("outcome must be %s or %s", domain.FraudReview, domain.FraudDecline)
+	}
+
+	reason := c.Reason
+	if reason == "" {
+		reason = c.Name
+	}
+
+	rule, err := c.rule()
+	if err != nil {
+		return Definition{}, err
+	}
+
+	return Definition{
+		Name:           c.Name,
+		Outcome:        c.Outcome,
+		Reason:         reason,
+		OperationTypes: c.OperationTypes,
+		MinAmount:      c.MinAmount,
+		Rule:           rule,
+	}, nil
+}
+
+func (c ruleConfig) rule() (Rule, error) {
+	switch c.Type {
+	case TypeAmount:
+		if c.MinAmount <= 0 {
+			return nil, fmt.Errorf("min_amount must be positive")
+		}
+
+		return AmountRule{}, nil
+	case TypeVelocity:
+		if c.Window <= 0 {
+			return nil, fmt.Errorf("window must be positive")
+		}
+
+		if c.MaxCount <= 0 && c.MaxAmount <= 0 {
+			return nil, fmt.Errorf("max_count or max_amount must be positive")
+		}
+
+		return VelocityRule{
+			Window:         c.Window,
+			MaxCount:       c.MaxCount,
+			MaxAmount:      c.MaxAmount,
+			OperationTypes: c.OperationTypes,
+		}, nil
+	case TypeFirstTranscation:
+		return FirstTranscationRule{
+			MaxAccountAge: c.MaxAccountAge,
+		}, nil
+	case TypeUnusualHour:
+		if c.FromHour < 0 || c.FromHour > 23 || c.ToHour < 0 || c.ToHour > 24 || c.FromHour == c.ToHour {
+			return nil, fmt.Errorf("from_hour and to_hour must be distinct hours of the day")
+		}
+
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %w", err)
+		}
+
+		return UnusualHourRule{
+			FromHour: c.FromHour,
+			ToHour:   c.ToHour,
+			Location: loc,
+		}, nil
+	case TypeRepeatedDeclines:
+		if c.Window <= 0 || c.MaxCount <= 0 {
+			return nil, fmt.Errorf("window and max_count must be positive")
+		}
+
+		return RepeatedDeclinesRule{
+			Window:   c.Window,
+			MaxCount: c.MaxCount,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", c.Type)
+	}
+}
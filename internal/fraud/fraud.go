@@ -0,0 +1,142 @@
+// Package fraud evaluates incoming transcations against declarative rules
+// before they are posted.
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// Input is what rules are evaluated against
+type Input struct {
+	Account     domain.Account
+	Transcation domain.Transcation
+}
+
+// Facts gives rules access to the history of the account
+type Facts interface {
+	// TranscationStats sums up the transcations of the account with one of the
+	// operation types, all of them when none is given, posted after since
+	TranscationStats(ctx context.Context, accountID string, operationTypes []int, since time.Time) (domain.TranscationStats, error)
+	// CountFraudDecisions counts the decisions with the outcome taken for the
+	// account after since
+	CountFraudDecisions(ctx context.Context, accountID string, outcome domain.FraudOutcome, since time.Time) (int, error)
+}
+
+// Recorder keeps every decision for later analysis
+type Recorder interface {
+	RecordFraudDecision(ctx context.Context, decision domain.FraudDecision) error
+}
+
+// Rule is a check a transcation may match, custom rules implement it to be
+// evaluated next to the ones loaded from the rules file
+type Rule interface {
+	Match(ctx context.Context, in Input, facts Facts) (bool, error)
+}
+
+// Definition gives a rule its name and the outcome and reason of a match
+type Definition struct {
+	Name    string
+	Outcome domain.FraudOutcome
+	Reason  string
+	// OperationTypes restricts the rule to some operation types, it applies
+	// to all of them when empty
+	OperationTypes []int
+	// MinAmount is the amount from which the rule applies
+	MinAmount float64
+	Rule      Rule
+}
+
+func (d Definition) applies(transcation domain.Transcation) bool {
+	if transcation.Amount < d.MinAmount {
+		return false
+	}
+
+	if len(d.OperationTypes) == 0 {
+		return true
+	}
+
+	for _, opType := range d.OperationTypes {
+		if opType == transcation.OperationTypeID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Engine evaluates the rules, the most severe outcome of the matching rules is
+// the outcome of a transcation and transcations matching none are approved
+type Engine struct {
+	definitions []Definition
+	facts       Facts
+	recorder    Recorder
+	now         func() time.Time
+}
+
+func New(definitions []Definition, facts Facts, recorder Recorder) *Engine {
+	return &Engine{
+		definitions: definitions,
+		facts:       facts,
+		recorder:    recorder,
+		now: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+// Evaluate decides on a transcation that is about to be posted and records the
+// decision, amounts are expected positive whatever the operation type
+func (e *Engine) Evaluate(ctx context.Context, account domain.Account, transcation domain.Transcation) (*domain.FraudDecision, error) {
+	in := Input{
+		Account:     account,
+		Transcation: transcation,
+	}
+
+	decision := domain.FraudDecision{
+		ID:              uuid.NewString(),
+		TranscationID:   transcation.ID,
+		AccountID:       transcation.AccountID,
+		OperationTypeID: transcation.OperationTypeID,
+		Amount:          transcation.Amount,
+		Outcome:         domain.FraudApprove,
+		Reasons:         make([]domain.FraudReason, 0),
+		CreatedAt:       e.now(),
+	}
+
+	for _, definition := range e.definitions {
+		if !definition.applies(transcation) {
+			continue
+		}
+
+		matched, err := definition.Rule.Match(ctx, in, e.facts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate fraud rule %s: %w", definition.Name, err)
+		}
+
+		if !matched {
+			continue
+		}
+
+		decision.Reasons = append(decision.Reasons, domain.FraudReason{
+			Rule:    definition.Name,
+			Outcome: definition.Outcome,
+			Reason:  definition.Reason,
+		})
+
+		if definition.Outcome.Severity() > decision.Outcome.Severity() {
+			decision.Outcome = definition.Outcome
+		}
+	}
+
+	if err := e.recorder.RecordFraudDecision(ctx, decision); err != nil {
+		return nil, fmt.Errorf("failed to record fraud decision: %w", err)
+	}
+
+	return &decision, nil
+}
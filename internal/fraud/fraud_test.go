@@ -0,0 +1,241 @@
+package fraud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// facts answers rules from the fields, recorded decisions count as declines
+type facts struct {
+	transcations []domain.Transcation
+	decisions    []domain.FraudDecision
+}
+
+func (f *facts) TranscationStats(_ context.Context, accountID string, operationTypes []int, since time.Time) (domain.TranscationStats, error) {
+	var stats domain.TranscationStats
+	for _, transcation := range f.transcations {
+		if transcation.AccountID != accountID || !transcation.EventAt.After(since) {
+			continue
+		}
+
+		if len(operationTypes) > 0 && !(Definition{OperationTypes: operationTypes}).applies(transcation) {
+			continue
+		}
+
+		stats.Count++
+		stats.Amount += transcation.Amount
+	}
+
+	return stats, nil
+}
+
+func (f *facts) CountFraudDecisions(_ context.Context, accountID string, outcome domain.FraudOutcome, since time.Time) (int, error) {
+	count := 0
+	for _, decision := range f.decisions {
+		if decision.AccountID == accountID && decision.Outcome == outcome && decision.CreatedAt.After(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (f *facts) RecordFraudDecision(_ context.Context, decision domain.FraudDecision) error {
+	f.decisions = append(f.decisions, decision)
+	return nil
+}
+
+const testRules = `
+rules:
+  - name: large_purchase
+    type: amount
+    outcome: review
+    reason: large purchase
+    operation_types: [1, 2]
+    min_amount: 5000
+  - name: withdrawal_velocity
+    type: velocity
+    outcome: decline
+    reason: too many withdrawals
+    operation_types: [3]
+    window: 10m
+    max_count: 2
+  - name: first_transcation
+    type: first_transcation
+    outcome: review
+    min_amount: 500
+    max_account_age: 24h
+  - name: night
+    type: unusual_hour
+    outcome: review
+    reason: unusual hour
+    from_hour: 22
+    to_hour: 6
+  - name: repeated_declines
+    type: repeated_declines
+    outcome: decline
+    reason: repeated declines
+    window: 1h
+    max_count: 2
+`
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	rules, err := ParseRules([]byte(testRules))
+	require.NoError(t, err)
+	require.Len(t, rules, 5)
+
+	noon := time.Date(2022, 10, 3, 12, 0, 0, 0, time.UTC)
+	account := domain.Account{ID: "account-1", CreatedAt: noon.Add(-48 * time.Hour)}
+	history := []domain.Transcation{
+		{AccountID: "account-1", OperationTypeID: 4, Amount: 100, EventAt: noon.Add(-time.Hour)},
+		{AccountID: "account-1", OperationTypeID: 3, Amount: 50, EventAt: noon.Add(-5 * time.Minute)},
+		{AccountID: "account-1", OperationTypeID: 3, Amount: 50, EventAt: noon.Add(-time.Minute)},
+	}
+
+	tests := []struct {
+		name        string
+		account     domain.Account
+		transcation domain.Transcation
+		declines    int
+		outcome     domain.FraudOutcome
+		rules       []string
+	}{
+		{
+			name:        "approved",
+			account:     account,
+			transcation: domain.Transcation{OperationTypeID: 1, Amount: 100, EventAt: noon},
+			outcome:     domain.FraudApprove,
+		},
+		{
+			name:        "large purchase",
+			account:     account,
+			transcation: domain.Transcation{OperationTypeID: 2, Amount: 6000, EventAt: noon},
+			outcome:     domain.FraudReview,
+			rules:       []string{"large_purchase"},
+		},
+		{
+			name:        "large credit",
+			account:     account,
+			transcation: domain.Transcation{OperationTypeID: 4, Amount: 6000, EventAt: noon},
+			outcome:     domain.FraudApprove,
+		},
+		{
+			name:        "third withdrawal within the window",
+			account:     account,
+			transcation: domain.Transcation{OperationTypeID: 3, Amount: 10, EventAt: noon},
+			outcome:     domain.FraudDecline,
+			rules:       []string{"withdrawal_velocity"},
+		},
+		{
+			name:        "first transcation of a new account",
+			account:     domain.Account{ID: "account-2", CreatedAt: noon.Add(-time.Hour)},
+			transcation: domain.Transcation{OperationTypeID: 1, Amount: 600, EventAt: noon},
+			outcome:     domain.FraudReview,
+			rules:       []string{"first_transcation"},
+		},
+		{
+			name:        "first transcation of an old account",
+			account:     domain.Account{ID: "account-2", CreatedAt: noon.Add(-48 * time.Hour)},
+			transcation: domain.Transcation{OperationTypeID: 1, Amount: 600, EventAt: noon},
+			outcome:     domain.FraudApprove,
+		},
+		{
+			name:        "night purchase after midnight",
+			account:     account,
+			transcation: domain.Transcation{OperationTypeID: 1, Amount: 100, EventAt: noon.Add(-9 * time.Hour)},
+			outcome:     domain.FraudReview,
+			rules:       []string{"night"},
+		},
+		{
+			name:        "most severe outcome wins",
+			account:     account,
+			transcation: domain.Transcation{OperationTypeID: 1, Amount: 6000, EventAt: noon},
+			declines:    2,
+			outcome:     domain.FraudDecline,
+			rules:       []string{"large_purchase", "repeated_declines"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := &facts{transcations: history}
+			for i := 0; i < tt.declines; i++ {
+				f.decisions = append(f.decisions, domain.FraudDecision{
+					AccountID: tt.account.ID,
+					Outcome:   domain.FraudDecline,
+					CreatedAt: noon.Add(-time.Minute),
+				})
+			}
+			recorded := len(f.decisions)
+
+			engine := New(rules, f, f)
+			engine.now = func() time.Time {
+				return noon
+			}
+
+			transcation := tt.transcation
+			transcation.ID = "transcation-1"
+			transcation.AccountID = tt.account.ID
+
+			decision, err := engine.Evaluate(context.Background(), tt.account, transcation)
+			require.NoError(t, err)
+			require.Equal(t, tt.outcome, decision.Outcome)
+			require.Equal(t, "transcation-1", decision.TranscationID)
+
+			matched := make([]string, 0, len(decision.Reasons))
+			for _, reason := range decision.Reasons {
+				matched = append(matched, reason.Rule)
+			}
+			require.ElementsMatch(t, tt.rules, matched)
+
+			require.Len(t, f.decisions, recorded+1)
+			require.Equal(t, *decision, f.decisions[recorded])
+		})
+	}
+}
+
+func TestParseRulesInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"no name":          "rules: [{type: amount, outcome: review, min_amount: 10}]",
+		"duplicate name":   "rules: [{name: a, type: amount, outcome: review, min_amount: 10}, {name: a, type: amount, outcome: review, min_amount: 10}]",
+		"unknown type":     "rules: [{name: a, type: moon_phase, outcome: review}]",
+		"approve outcome":  "rules: [{name: a, type: amount, outcome: approve, min_amount: 10}]",
+		"no threshold":     "rules: [{name: a, type: amount, outcome: review}]",
+		"no window":        "rules: [{name: a, type: velocity, outcome: decline, max_count: 3}]",
+		"empty hours":      "rules: [{name: a, type: unusual_hour, outcome: review, from_hour: 2, to_hour: 2}]",
+		"unknown timezone": "rules: [{name: a, type: unusual_hour, outcome: review, from_hour: 1, to_hour: 2, timezone: Mars/Olympus}]",
+		"invalid duration": "rules: [{name: a, type: repeated_declines, outcome: decline, window: often, max_count: 3}]",
+	}
+
+	for name, rules := range tests {
+		rules := rules
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseRules([]byte(rules))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoadRulesExample(t *testing.T) {
+	t.Parallel()
+
+	rules, err := LoadRules("../../config/fraud_rules.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, rules)
+}
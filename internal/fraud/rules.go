@@ -0,0 +1,95 @@
+package fraud
+
+import (
+	"context"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// AmountRule matches every transcation its definition applies to, the
+// threshold is the minimum amount of the definition
+type AmountRule struct{}
+
+func (AmountRule) Match(ctx context.Context, in Input, facts Facts) (bool, error) {
+	return true, nil
+}
+
+// VelocityRule matches a transcation taking the account over MaxCount
+// transcations or over MaxAmount within Window, a zero maximum is not checked
+type VelocityRule struct {
+	Window         time.Duration
+	MaxCount       int
+	MaxAmount      float64
+	OperationTypes []int
+}
+
+func (r VelocityRule) Match(ctx context.Context, in Input, facts Facts) (bool, error) {
+	stats, err := facts.TranscationStats(ctx, in.Account.ID, r.OperationTypes, in.Transcation.EventAt.Add(-r.Window))
+	if err != nil {
+		return false, err
+	}
+
+	if r.MaxCount > 0 && stats.Count+1 > r.MaxCount {
+		return true, nil
+	}
+
+	return r.MaxAmount > 0 && stats.Amount+in.Transcation.Amount > r.MaxAmount, nil
+}
+
+// FirstTranscationRule matches the first transcation of an account, only
+// within MaxAccountAge of its creation when set
+type FirstTranscationRule struct {
+	MaxAccountAge time.Duration
+}
+
+func (r FirstTranscationRule) Match(ctx context.Context, in Input, facts Facts) (bool, error) {
+	if r.MaxAccountAge > 0 && in.Transcation.EventAt.Sub(in.Account.CreatedAt) > r.MaxAccountAge {
+		return false, nil
+	}
+
+	stats, err := facts.TranscationStats(ctx, in.Account.ID, nil, time.Time{})
+	if err != nil {
+		return false, err
+	}
+
+	return stats.Count == 0, nil
+}
+
+// UnusualHourRule matches transcations from FromHour up to ToHour excluded in
+// Location, the range wraps around midnight when FromHour is after ToHour
+type UnusualHourRule struct {
+	FromHour int
+	ToHour   int
+	Location *time.Location
+}
+
+func (r UnusualHourRule) Match(ctx context.Context, in Input, facts Facts) (bool, error) {
+	loc := r.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	hour := in.Transcation.EventAt.In(loc).Hour()
+	if r.FromHour <= r.ToHour {
+		return hour >= r.FromHour && hour < r.ToHour, nil
+	}
+
+	return hour >= r.FromHour || hour < r.ToHour, nil
+}
+
+// RepeatedDeclinesRule matches transcations of accounts that had MaxCount
+// transcations declined within Window
+type RepeatedDeclinesRule struct {
+	Window   time.Duration
+	MaxCount int
+}
+
+func (r RepeatedDeclinesRule) Match(ctx context.Context, in Input, facts Facts) (bool, error) {
+	count, err := facts.CountFraudDecisions(ctx, in.Account.ID, domain.FraudDecline, in.Transcation.EventAt.Add(-r.Window))
+	if err != nil {
+		return false, err
+	}
+
+	return count >= r.MaxCount, nil
+}
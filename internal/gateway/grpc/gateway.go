@@ -0,0 +1,161 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	transcationv1 "github.com/madhurikadam/app-transcation/api/transcation/v1"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/internal/service"
+)
+
+type (
+	TranscationService interface {
+		CreateAccount(ctx context.Context, documentNumber string) (*domain.Account, error)
+		GetAccount(ctx context.Context, accountID string) (*domain.Account, error)
+		CreateTranscation(ctx context.Context, transcation domain.Transcation) (*domain.Transcation, error)
+		ListTranscations(ctx context.Context, accountID string, pageSize int, pageToken string) (*domain.TranscationPage, error)
+		StreamTranscations(ctx context.Context, accountID string, since time.Time, fn func(domain.Transcation) error) error
+	}
+
+	Gateway struct {
+		transcationv1.UnimplementedTranscationServiceServer
+		transcationSvc TranscationService
+	}
+)
+
+func NewGateway(transcationSvc TranscationService) *Gateway {
+	return &Gateway{
+		transcationSvc: transcationSvc,
+	}
+}
+
+func (g *Gateway) CreateAccount(ctx context.Context, req *transcationv1.CreateAccountRequest) (*transcationv1.CreateAccountResponse, error) {
+	account, err := g.transcationSvc.CreateAccount(ctx, req.GetDocumentNumber())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &transcationv1.CreateAccountResponse{Account: toAccount(account)}, nil
+}
+
+func (g *Gateway) GetAccount(ctx context.Context, req *transcationv1.GetAccountRequest) (*transcationv1.GetAccountResponse, error) {
+	account, err := g.transcationSvc.GetAccount(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &transcationv1.GetAccountResponse{Account: toAccount(account)}, nil
+}
+
+func (g *Gateway) CreateTranscation(ctx context.Context, req *transcationv1.CreateTranscationRequest) (*transcationv1.CreateTranscationResponse, error) {
+	tx, err := g.transcationSvc.CreateTranscation(ctx, domain.Transcation{
+		AccountID:       req.GetAccountId(),
+		OperationTypeID: int(req.GetOperationTypeId()),
+		Amount:          req.GetAmount(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &transcationv1.CreateTranscationResponse{Transcation: toTranscation(*tx)}, nil
+}
+
+func (g *Gateway) ListTranscations(ctx context.Context, req *transcationv1.ListTranscationsRequest) (*transcationv1.ListTranscationsResponse, error) {
+	page, err := g.transcationSvc.ListTranscations(ctx, req.GetAccountId(), int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &transcationv1.ListTranscationsResponse{
+		Transcations:  make([]*transcationv1.Transcation, 0, len(page.Transcations)),
+		NextPageToken: page.NextPageToken,
+	}
+
+	for _, tx := range page.Transcations {
+		resp.Transcations = append(resp.Transcations, toTranscation(tx))
+	}
+
+	return resp, nil
+}
+
+func (g *Gateway) StreamTranscations(req *transcationv1.StreamTranscationsRequest, stream transcationv1.TranscationService_StreamTranscationsServer) error {
+	var since time.Time
+	if req.GetSince() != nil {
+		since = req.GetSince().AsTime()
+	}
+
+	err := g.transcationSvc.StreamTranscations(stream.Context(), req.GetAccountId(), since, func(tx domain.Transcation) error {
+		return stream.Send(&transcationv1.StreamTranscationsResponse{Transcation: toTranscation(tx)})
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+
+	return nil
+}
+
+func toAccount(account *domain.Account) *transcationv1.Account {
+	pb := &transcationv1.Account{
+		Id:              account.ID,
+		DocumentNumber:  account.DocumentNumber,
+		WithdrawalLimit: account.WithdrawalLimit,
+		CreditLimit:     account.CreaditLimit,
+		CreatedAt:       timestamppb.New(account.CreatedAt),
+	}
+
+	if account.UpdatedAt != nil {
+		pb.UpdatedAt = timestamppb.New(*account.UpdatedAt)
+	}
+
+	return pb
+}
+
+func toTranscation(tx domain.Transcation) *transcationv1.Transcation {
+	return &transcationv1.Transcation{
+		Id:              tx.ID,
+		AccountId:       tx.AccountID,
+		OperationTypeId: int32(tx.OperationTypeID),
+		Amount:          tx.Amount,
+		EventAt:         timestamppb.New(tx.EventAt),
+		Balance:         tx.Balance,
+		Status:          tx.Status,
+	}
+}
+
+// toStatus maps errors returned by the service to grpc status errors
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, policy.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrInvalidAccountID),
+		errors.Is(err, service.ErrInvalidDocumentNumber),
+		errors.Is(err, service.ErrInvalidOperationTypeID),
+		errors.Is(err, service.ErrInvalidPageToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrExceedWithdrawalLimit),
+		errors.Is(err, service.ErrExceedCreditLimit),
+		errors.Is(err, service.ErrTranscationDeclined):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrVelocityLimitExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+
+		return status.Error(codes.Internal, err.Error())
+	}
+}
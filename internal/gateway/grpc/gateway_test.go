@@ -0,0 +1,209 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	transcationv1 "github.com/madhurikadam/app-transcation/api/transcation/v1"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/service"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+	"github.com/madhurikadam/app-transcation/pkg/requestid"
+)
+
+// fakeService answers every call from fixed data
+type fakeService struct {
+	TranscationService
+
+	accounts     map[string]domain.Account
+	transcations []domain.Transcation
+}
+
+func (f *fakeService) GetAccount(_ context.Context, accountID string) (*domain.Account, error) {
+	if accountID == "" {
+		return nil, service.ErrInvalidAccountID
+	}
+
+	account, ok := f.accounts[accountID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	return &account, nil
+}
+
+func (f *fakeService) StreamTranscations(ctx context.Context, accountID string, _ time.Time, fn func(domain.Transcation) error) error {
+	if _, err := f.GetAccount(ctx, accountID); err != nil {
+		return err
+	}
+
+	for _, tx := range f.transcations {
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newTestClient(t *testing.T, svc TranscationService, opts ...grpc.ServerOption) transcationv1.TranscationServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(opts...)
+	transcationv1.RegisterTranscationServiceServer(server, NewGateway(svc))
+
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return transcationv1.NewTranscationServiceClient(conn)
+}
+
+func TestGetAccount(t *testing.T) {
+	t.Parallel()
+
+	accountID := uuid.NewString()
+	client := newTestClient(t, &fakeService{
+		accounts: map[string]domain.Account{
+			accountID: {ID: accountID, DocumentNumber: "12345678", WithdrawalLimit: 100, CreaditLimit: 200},
+		},
+	})
+
+	tests := []struct {
+		name      string
+		accountID string
+		expCode   codes.Code
+	}{
+		{name: "invalid account id", accountID: "", expCode: codes.InvalidArgument},
+		{name: "unknown account", accountID: uuid.NewString(), expCode: codes.NotFound},
+		{name: "get account with success", accountID: accountID, expCode: codes.OK},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.GetAccount(context.Background(), &transcationv1.GetAccountRequest{Id: tt.accountID})
+			require.Equal(t, tt.expCode, status.Code(err))
+
+			if tt.expCode == codes.OK {
+				require.Equal(t, accountID, resp.GetAccount().GetId())
+				require.Equal(t, float64(200), resp.GetAccount().GetCreditLimit())
+			}
+		})
+	}
+}
+
+func TestStreamTranscations(t *testing.T) {
+	t.Parallel()
+
+	accountID := uuid.NewString()
+	transcations := []domain.Transcation{
+		{ID: uuid.NewString(), AccountID: accountID, OperationTypeID: 1, Amount: -10, EventAt: time.Now().UTC()},
+		{ID: uuid.NewString(), AccountID: accountID, OperationTypeID: 4, Amount: 25, EventAt: time.Now().UTC()},
+	}
+
+	client := newTestClient(t, &fakeService{
+		accounts:     map[string]domain.Account{accountID: {ID: accountID}},
+		transcations: transcations,
+	})
+
+	stream, err := client.StreamTranscations(context.Background(), &transcationv1.StreamTranscationsRequest{AccountId: accountID})
+	require.NoError(t, err)
+
+	received := make([]string, 0)
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		received = append(received, resp.GetTranscation().GetId())
+		require.Equal(t, accountID, resp.GetTranscation().GetAccountId())
+	}
+
+	require.Equal(t, []string{transcations[0].ID, transcations[1].ID}, received)
+
+	stream, err = client.StreamTranscations(context.Background(), &transcationv1.StreamTranscationsRequest{AccountId: uuid.NewString()})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// correlatedService records the request id the streams are served with
+type correlatedService struct {
+	*fakeService
+
+	requestID string
+}
+
+func (c *correlatedService) StreamTranscations(ctx context.Context, accountID string, from time.Time, fn func(domain.Transcation) error) error {
+	c.requestID = requestid.FromContext(ctx)
+	logging.FromContext(ctx).Info("streaming transcations")
+
+	return c.fakeService.StreamTranscations(ctx, accountID, from, fn)
+}
+
+func TestStreamTranscationsCorrelated(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	accountID := uuid.NewString()
+	svc := &correlatedService{fakeService: &fakeService{accounts: map[string]domain.Account{accountID: {ID: accountID}}}}
+	client := newTestClient(t, svc,
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), logging.StreamServerInterceptor()),
+	)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestid.MetadataKey, "req-1")
+	stream, err := client.StreamTranscations(ctx, &transcationv1.StreamTranscationsRequest{AccountId: accountID})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	header, err := stream.Header()
+	require.NoError(t, err)
+	require.Equal(t, []string{"req-1"}, header.Get(requestid.MetadataKey))
+	require.Equal(t, "req-1", svc.requestID)
+
+	// the lines of the stream and its access log carry the request id
+	var served, call bool
+	for _, e := range hook.AllEntries() {
+		switch e.Message {
+		case "streaming transcations":
+			served = true
+			require.Equal(t, "req-1", e.Data["request_id"])
+		case "grpc call":
+			call = true
+			require.Equal(t, "req-1", e.Data["request_id"])
+			require.Equal(t, "/transcation.v1.TranscationService/StreamTranscations", e.Data["method"])
+			require.Equal(t, codes.OK.String(), e.Data["code"])
+		}
+	}
+	require.True(t, served)
+	require.True(t, call)
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/pkg/http/controller"
+)
+
+type (
+	APIKeyService interface {
+		Issue(ctx context.Context, req domain.APIKeyReq) (*domain.IssuedAPIKey, error)
+		Rotate(ctx context.Context, id string, req domain.APIKeyRotationReq) (*domain.IssuedAPIKey, error)
+		List(ctx context.Context) ([]domain.APIKey, error)
+		Get(ctx context.Context, id string) (*domain.APIKey, error)
+		Revoke(ctx context.Context, id string) error
+	}
+
+	APIKeyGateway struct {
+		controller.BaseController
+		apiKeySvc APIKeyService
+	}
+)
+
+func NewAPIKeyGateway(apiKeySvc APIKeyService) APIKeyGateway {
+	return APIKeyGateway{
+		apiKeySvc: apiKeySvc,
+	}
+}
+
+func (g APIKeyGateway) IssueKey(w http.ResponseWriter, r *http.Request) {
+	var issue domain.APIKeyReq
+	if err := json.NewDecoder(r.Body).Decode(&issue); err != nil {
+		g.WriteErrorResponseMsg(w, http.StatusBadRequest, "missing or invalid json body")
+		return
+	}
+
+	key, err := g.apiKeySvc.Issue(r.Context(), issue)
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusCreated, key)
+}
+
+func (g APIKeyGateway) RotateKey(w http.ResponseWriter, r *http.Request) {
+	var rotation domain.APIKeyRotationReq
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&rotation); err != nil {
+			g.WriteErrorResponseMsg(w, http.StatusBadRequest, "invalid json body")
+			return
+		}
+	}
+
+	key, err := g.apiKeySvc.Rotate(r.Context(), routeVar(r, "id"), rotation)
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusCreated, key)
+}
+
+func (g APIKeyGateway) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := g.apiKeySvc.List(r.Context())
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, keys)
+}
+
+func (g APIKeyGateway) GetKey(w http.ResponseWriter, r *http.Request) {
+	key, err := g.apiKeySvc.Get(r.Context(), routeVar(r, "id"))
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, key)
+}
+
+func (g APIKeyGateway) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if err := g.apiKeySvc.Revoke(r.Context(), routeVar(r, "id")); err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
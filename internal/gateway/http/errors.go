@@ -0,0 +1,51 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/internal/service"
+)
+
+// errorStatus maps errors returned by the services to http status codes
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, policy.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidAccountID),
+		errors.Is(err, service.ErrInvalidDocumentNumber),
+		errors.Is(err, service.ErrInvalidOperationTypeID),
+		errors.Is(err, service.ErrInvalidPageToken),
+		errors.Is(err, service.ErrInvalidLimit),
+		errors.Is(err, service.ErrInvalidWebhookURL),
+		errors.Is(err, service.ErrInvalidEventType),
+		errors.Is(err, service.ErrInvalidSubscriptionID),
+		errors.Is(err, service.ErrInvalidDeliveryID),
+		errors.Is(err, service.ErrInvalidAPIKeyID),
+		errors.Is(err, service.ErrInvalidAPIKeyName),
+		errors.Is(err, service.ErrInvalidSubject),
+		errors.Is(err, service.ErrInvalidRole),
+		errors.Is(err, service.ErrInvalidScope),
+		errors.Is(err, service.ErrInvalidRateLimit),
+		errors.Is(err, service.ErrInvalidExpiry),
+		errors.Is(err, service.ErrInvalidOverlap),
+		errors.Is(err, service.ErrInvalidReviewID),
+		errors.Is(err, service.ErrInvalidReviewStatus),
+		errors.Is(err, service.ErrInvalidReviewReason):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrExceedWithdrawalLimit),
+		errors.Is(err, service.ErrExceedCreditLimit),
+		errors.Is(err, service.ErrTranscationDeclined):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrReviewDecided):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrVelocityLimitExceeded):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
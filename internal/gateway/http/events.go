@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+// heartbeatInterval is how often an idle event stream sends a comment so that
+// proxies do not close it
+var heartbeatInterval = 15 * time.Second
+
+// StreamAccountEvents streams the events of the account as server-sent events.
+// Every event carries its sequence number as id so that clients resume after
+// the last event they received with the Last-Event-ID header, or the
+// last_event_id query parameter for clients unable to set headers.
+func (g Gateway) StreamAccountEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		g.WriteErrorResponseMsg(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	afterSeq, err := lastEventID(r)
+	if err != nil {
+		g.WriteErrorResponseMsg(w, http.StatusBadRequest, "invalid last event id")
+		return
+	}
+
+	accountID := routeVar(r, "id")
+	if _, err := g.transcationSvc.GetAccount(r.Context(), accountID); err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var mu sync.Mutex
+	write := func(format string, args ...interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		flusher.Flush()
+
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := write(": heartbeat\n\n"); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = g.transcationSvc.StreamAccountEvents(ctx, accountID, afterSeq, func(event domain.Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		return write("id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+	})
+	if err != nil && ctx.Err() == nil {
+		logging.FromContext(ctx).WithField("account_id", accountID).WithError(err).Error("account event stream failed")
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+// lastEventID returns the sequence number the stream resumes after, -1 when
+// the client starts a new stream
+func lastEventID(r *http.Request) (int64, error) {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("last_event_id")
+	}
+
+	if id == "" {
+		return -1, nil
+	}
+
+	seq, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, fmt.Errorf("invalid last event id %q", id)
+	}
+
+	return seq, nil
+}
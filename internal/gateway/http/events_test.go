@@ -0,0 +1,111 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// fakeService streams the events of a single account from memory
+type fakeService struct {
+	TranscationService
+
+	accountID string
+	events    []domain.Event
+}
+
+func (f *fakeService) GetAccount(_ context.Context, accountID string) (*domain.Account, error) {
+	if accountID != f.accountID {
+		return nil, domain.ErrNotFound
+	}
+
+	return &domain.Account{ID: accountID}, nil
+}
+
+func (f *fakeService) StreamAccountEvents(ctx context.Context, _ string, afterSeq int64, fn func(domain.Event) error) error {
+	for _, event := range f.events {
+		if event.Seq <= afterSeq {
+			continue
+		}
+
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestStreamAccountEvents(t *testing.T) {
+	t.Parallel()
+
+	accountID := uuid.NewString()
+	svc := &fakeService{
+		accountID: accountID,
+		events: []domain.Event{
+			{ID: uuid.NewString(), Seq: 1, AccountID: accountID, Type: domain.EventTranscationCreated, Payload: []byte(`{}`)},
+			{ID: uuid.NewString(), Seq: 2, AccountID: accountID, Type: domain.EventAccountLimitsChanged, Payload: []byte(`{}`)},
+		},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{id}/events", NewGateway(svc).StreamAccountEvents)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name        string
+		accountID   string
+		lastEventID string
+		expStatus   int
+		expIDs      []string
+	}{
+		{name: "unknown account", accountID: uuid.NewString(), expStatus: http.StatusNotFound},
+		{name: "invalid last event id", accountID: accountID, lastEventID: "abc", expStatus: http.StatusBadRequest},
+		{name: "stream from the start", accountID: accountID, lastEventID: "0", expStatus: http.StatusOK, expIDs: []string{"1", "2"}},
+		{name: "resume after last event id", accountID: accountID, lastEventID: "1", expStatus: http.StatusOK, expIDs: []string{"2"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/accounts/"+tt.accountID+"/events", nil)
+			require.NoError(t, err)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+
+			resp, err := server.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, tt.expStatus, resp.StatusCode)
+			if tt.expStatus != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+			ids := make([]string, 0)
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if strings.HasPrefix(scanner.Text(), "id: ") {
+					ids = append(ids, strings.TrimPrefix(scanner.Text(), "id: "))
+				}
+			}
+			require.NoError(t, scanner.Err())
+			require.Equal(t, tt.expIDs, ids)
+		})
+	}
+}
@@ -3,10 +3,13 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/service"
 	"github.com/madhurikadam/app-transcation/pkg/http/controller"
 )
 
@@ -14,13 +17,24 @@ type (
 	TranscationService interface {
 		CreateAccount(ctx context.Context, documentNumber string) (*domain.Account, error)
 		GetAccount(ctx context.Context, accountID string) (*domain.Account, error)
+		UpdateAccountLimits(ctx context.Context, accountID string, req domain.AccountLimitsReq) (*domain.AccountLimits, error)
 		CreateTranscation(ctx context.Context, transcation domain.Transcation) (*domain.Transcation, error)
+		ListTranscations(ctx context.Context, accountID string, pageSize int, pageToken string) (*domain.TranscationPage, error)
+		StreamAccountEvents(ctx context.Context, accountID string, afterSeq int64, fn func(domain.Event) error) error
 	}
 
 	Gateway struct {
 		controller.BaseController
 		transcationSvc TranscationService
 	}
+
+	// declinedResponse tells the caller which fraud rules declined a
+	// transcation
+	declinedResponse struct {
+		Error      string               `json:"error"`
+		DecisionID string               `json:"decision_id"`
+		Reasons    []domain.FraudReason `json:"reasons"`
+	}
 )
 
 func NewGateway(transcationSvc TranscationService) Gateway {
@@ -38,7 +52,7 @@ func (g Gateway) CreateAccount(w http.ResponseWriter, r *http.Request) {
 
 	account, err := g.transcationSvc.CreateAccount(r.Context(), create.DocumentNumber)
 	if err != nil {
-		g.WriteErrorResponseMsg(w, http.StatusInternalServerError, err.Error())
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
 		return
 	}
 
@@ -56,13 +70,29 @@ func routeVar(r *http.Request, key string) string {
 func (g Gateway) GetAccount(w http.ResponseWriter, r *http.Request) {
 	account, err := g.transcationSvc.GetAccount(r.Context(), routeVar(r, "id"))
 	if err != nil {
-		g.WriteErrorResponseMsg(w, http.StatusInternalServerError, err.Error())
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
 		return
 	}
 
 	g.WriteJSONResponse(w, http.StatusOK, account)
 }
 
+func (g Gateway) UpdateAccountLimits(w http.ResponseWriter, r *http.Request) {
+	var update domain.AccountLimitsReq
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		g.WriteErrorResponseMsg(w, http.StatusBadRequest, "missing or invalid json body")
+		return
+	}
+
+	limits, err := g.transcationSvc.UpdateAccountLimits(r.Context(), routeVar(r, "id"), update)
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, limits)
+}
+
 func (g Gateway) CreateTranscation(w http.ResponseWriter, r *http.Request) {
 	var create domain.Transcation
 	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
@@ -72,9 +102,52 @@ func (g Gateway) CreateTranscation(w http.ResponseWriter, r *http.Request) {
 
 	tx, err := g.transcationSvc.CreateTranscation(r.Context(), create)
 	if err != nil {
-		g.WriteErrorResponseMsg(w, http.StatusInternalServerError, err.Error())
+		var declinedErr *service.DeclinedError
+		if errors.As(err, &declinedErr) {
+			g.WriteJSONResponse(w, http.StatusUnprocessableEntity, declinedResponse{
+				Error:      err.Error(),
+				DecisionID: declinedErr.Decision.ID,
+				Reasons:    declinedErr.Decision.Reasons,
+			})
+			return
+		}
+
+		// debits exceeding a limit alone never pass, they get no retry time
+		var velocityErr *domain.VelocityError
+		if errors.As(err, &velocityErr) && velocityErr.RetryAfter > 0 {
+			g.SetRetryAfter(w, velocityErr.RetryAfter)
+		}
+
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	// transcations held for review are only posted once approved
+	if tx.Status == domain.TranscationPendingReview {
+		g.WriteJSONResponse(w, http.StatusAccepted, tx)
 		return
 	}
 
 	g.WriteJSONResponse(w, http.StatusOK, tx)
 }
+
+func (g Gateway) ListTranscations(w http.ResponseWriter, r *http.Request) {
+	var pageSize int
+	if val := r.URL.Query().Get("page_size"); val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil {
+			g.WriteErrorResponseMsg(w, http.StatusBadRequest, "invalid page size")
+			return
+		}
+
+		pageSize = size
+	}
+
+	page, err := g.transcationSvc.ListTranscations(r.Context(), routeVar(r, "id"), pageSize, r.URL.Query().Get("page_token"))
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, page)
+}
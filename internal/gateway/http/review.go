@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/pkg/http/controller"
+)
+
+type (
+	ReviewService interface {
+		ListReviews(ctx context.Context, status string, pageSize int) ([]domain.Review, error)
+		GetReview(ctx context.Context, id string) (*domain.Review, error)
+		ApproveReview(ctx context.Context, id string, req domain.ReviewDecisionReq) (*domain.Review, error)
+		RejectReview(ctx context.Context, id string, req domain.ReviewDecisionReq) (*domain.Review, error)
+	}
+
+	ReviewGateway struct {
+		controller.BaseController
+		reviewSvc ReviewService
+	}
+)
+
+func NewReviewGateway(reviewSvc ReviewService) ReviewGateway {
+	return ReviewGateway{
+		reviewSvc: reviewSvc,
+	}
+}
+
+func (g ReviewGateway) ListReviews(w http.ResponseWriter, r *http.Request) {
+	var pageSize int
+	if val := r.URL.Query().Get("page_size"); val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil {
+			g.WriteErrorResponseMsg(w, http.StatusBadRequest, "invalid page size")
+			return
+		}
+
+		pageSize = size
+	}
+
+	reviews, err := g.reviewSvc.ListReviews(r.Context(), r.URL.Query().Get("status"), pageSize)
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, reviews)
+}
+
+func (g ReviewGateway) GetReview(w http.ResponseWriter, r *http.Request) {
+	review, err := g.reviewSvc.GetReview(r.Context(), routeVar(r, "id"))
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, review)
+}
+
+func (g ReviewGateway) ApproveReview(w http.ResponseWriter, r *http.Request) {
+	var req domain.ReviewDecisionReq
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			g.WriteErrorResponseMsg(w, http.StatusBadRequest, "invalid json body")
+			return
+		}
+	}
+
+	review, err := g.reviewSvc.ApproveReview(r.Context(), routeVar(r, "id"), req)
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, review)
+}
+
+func (g ReviewGateway) RejectReview(w http.ResponseWriter, r *http.Request) {
+	var req domain.ReviewDecisionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		g.WriteErrorResponseMsg(w, http.StatusBadRequest, "missing or invalid json body")
+		return
+	}
+
+	review, err := g.reviewSvc.RejectReview(r.Context(), routeVar(r, "id"), req)
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, review)
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/pkg/http/controller"
+)
+
+type (
+	WebhookService interface {
+		CreateSubscription(ctx context.Context, req domain.WebhookSubscriptionReq) (*domain.WebhookSubscription, error)
+		GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+		ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+		DeleteSubscription(ctx context.Context, id string) error
+		ListDeliveries(ctx context.Context, subscriptionID string) ([]domain.WebhookDelivery, error)
+		Redeliver(ctx context.Context, subscriptionID, deliveryID string) (*domain.WebhookDelivery, error)
+	}
+
+	WebhookGateway struct {
+		controller.BaseController
+		webhookSvc WebhookService
+	}
+)
+
+func NewWebhookGateway(webhookSvc WebhookService) WebhookGateway {
+	return WebhookGateway{
+		webhookSvc: webhookSvc,
+	}
+}
+
+func (g WebhookGateway) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var create domain.WebhookSubscriptionReq
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		g.WriteErrorResponseMsg(w, http.StatusBadRequest, "missing or invalid json body")
+		return
+	}
+
+	sub, err := g.webhookSvc.CreateSubscription(r.Context(), create)
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusCreated, sub)
+}
+
+func (g WebhookGateway) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := g.webhookSvc.ListSubscriptions(r.Context())
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, subs)
+}
+
+func (g WebhookGateway) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	sub, err := g.webhookSvc.GetSubscription(r.Context(), routeVar(r, "id"))
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, sub)
+}
+
+func (g WebhookGateway) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	if err := g.webhookSvc.DeleteSubscription(r.Context(), routeVar(r, "id")); err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g WebhookGateway) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := g.webhookSvc.ListDeliveries(r.Context(), routeVar(r, "id"))
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusOK, deliveries)
+}
+
+func (g WebhookGateway) Redeliver(w http.ResponseWriter, r *http.Request) {
+	delivery, err := g.webhookSvc.Redeliver(r.Context(), routeVar(r, "id"), routeVar(r, "delivery_id"))
+	if err != nil {
+		g.WriteErrorResponseMsg(w, errorStatus(err), err.Error())
+		return
+	}
+
+	g.WriteJSONResponse(w, http.StatusAccepted, delivery)
+}
@@ -0,0 +1,136 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// StatusError is a response with an unexpected status
+type StatusError struct {
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.Status)
+}
+
+// Client calls the http api of the service
+type Client struct {
+	baseURL       string
+	authorization string
+	http          *http.Client
+}
+
+func NewClient(baseURL, authorization string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		authorization: authorization,
+		http:          httpClient,
+	}
+}
+
+// CreateAccount creates an account with the document number
+func (c *Client) CreateAccount(ctx context.Context, documentNumber string) (*domain.Account, error) {
+	var account domain.Account
+	status, err := c.do(ctx, http.MethodPost, "/accounts", domain.AccountReq{DocumentNumber: documentNumber}, &account)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusCreated {
+		return nil, &StatusError{Status: status}
+	}
+
+	return &account, nil
+}
+
+// UpdateAccountLimits sets the limits of the account
+func (c *Client) UpdateAccountLimits(ctx context.Context, accountID string, req domain.AccountLimitsReq) error {
+	status, err := c.do(ctx, http.MethodPatch, "/accounts/"+accountID+"/limits", req, nil)
+	if err != nil {
+		return err
+	}
+
+	if status != http.StatusOK {
+		return &StatusError{Status: status}
+	}
+
+	return nil
+}
+
+// GetAccount returns the account along with the status of the response, the
+// account is nil unless the status is 200 and the status is 0 when the
+// request failed
+func (c *Client) GetAccount(ctx context.Context, accountID string) (*domain.Account, int) {
+	var account domain.Account
+	status, err := c.do(ctx, http.MethodGet, "/accounts/"+accountID, nil, &account)
+	if err != nil {
+		return nil, status
+	}
+
+	if status != http.StatusOK {
+		return nil, status
+	}
+
+	return &account, status
+}
+
+// CreateTranscation posts the transcation and returns the status of the
+// response, 0 when the request failed
+func (c *Client) CreateTranscation(ctx context.Context, transcation domain.Transcation) int {
+	status, _ := c.do(ctx, http.MethodPost, "/transcations", transcation, nil)
+	return status
+}
+
+// do sends body as json and decodes the response into out when it is a 2xx,
+// the status is 0 when there is no response
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal request: %w", err)
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// the body is drained so that the connection is reused
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,553 @@
+// Package loadtest drives the http api with transcations and account reads
+// and reports how it held up: latency percentiles, errors by status and the
+// invariants the responses broke. The accounts are created by the run, so the
+// limits they end with can be checked against the transcations posted.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/seed"
+)
+
+// OpRead is the name of account reads in a mix, the other operations are the
+// operation types of the transcations posted
+const OpRead = "read"
+
+// rateLimitBackoff is how long the setup waits once rate limited, the api
+// refills its buckets every second
+const rateLimitBackoff = time.Second
+
+// limitsTolerance absorbs the rounding of the limits stored as floats
+const limitsTolerance = 0.005
+
+// Config sizes and shapes the load
+type Config struct {
+	// BaseURL is where the http api listens, e.g. http://localhost:8080
+	BaseURL string
+	// Authorization is sent as is in the Authorization header when set
+	Authorization string
+
+	Accounts    int
+	Concurrency int
+	// Rate caps the requests per second of all the workers together, 0
+	// leaves them unbounded
+	Rate float64
+	// the run stops after Duration or Requests, whichever comes first. 0
+	// leaves either one unbounded.
+	Duration time.Duration
+	Requests int
+
+	Mix Mix
+	// Skew is the exponent of the zipf distribution the accounts are picked
+	// with, hot accounts get most of the load. 0 picks them uniformly,
+	// otherwise it must be greater than 1.
+	Skew float64
+
+	// the limits every account starts with
+	WithdrawalLimit float64
+	CreditLimit     float64
+	// amounts are drawn uniformly between MinAmount and MaxAmount
+	MinAmount float64
+	MaxAmount float64
+
+	Seed int64
+}
+
+// Mix is the weight of every operation, the operation types of the
+// transcations and OpRead
+type Mix map[string]int
+
+// ParseMix parses weights in the form 1=50,2=10,3=10,4=20,read=10
+func ParseMix(s string) (Mix, error) {
+	mix := make(Mix)
+	for _, part := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid mix entry %q, expected op=weight", part)
+		}
+
+		name, value := parts[0], parts[1]
+
+		if name != OpRead {
+			if opType, err := strconv.Atoi(name); err != nil || opType < 1 || opType > 4 {
+				return nil, fmt.Errorf("invalid mix operation %q, expected an operation type from 1 to 4 or %s", name, OpRead)
+			}
+		}
+
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid mix weight %q of %s", value, name)
+		}
+
+		if _, ok := mix[name]; ok {
+			return nil, fmt.Errorf("mix operation %s is given twice", name)
+		}
+
+		mix[name] = weight
+	}
+
+	return mix, nil
+}
+
+func (m Mix) String() string {
+	ops := m.ops()
+	parts := make([]string, 0, len(ops))
+	for _, op := range ops {
+		parts = append(parts, fmt.Sprintf("%s=%d", op, m[op]))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ops returns the operations of the mix in order, the operation types first
+func (m Mix) ops() []string {
+	ops := make([]string, 0, len(m))
+	for op := range m {
+		ops = append(ops, op)
+	}
+
+	sort.Strings(ops)
+
+	return ops
+}
+
+// Report is the outcome of a run
+type Report struct {
+	Duration time.Duration `json:"duration"`
+	Requests int           `json:"requests"`
+	// Throughput is the requests per second
+	Throughput float64 `json:"throughput"`
+	// Operations are the latencies and statuses of every operation, by name
+	Operations map[string]*OpReport `json:"operations"`
+	Violations []Violation          `json:"violations"`
+}
+
+// OpReport is how an operation fared, transport errors are counted under
+// status 0
+type OpReport struct {
+	Requests int            `json:"requests"`
+	ByStatus map[int]int    `json:"by_status"`
+	Latency  LatencySummary `json:"latency"`
+
+	latencies []time.Duration
+}
+
+// LatencySummary holds the percentiles of the latencies of an operation
+type LatencySummary struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Violation is an invariant an account broke
+type Violation struct {
+	AccountID string `json:"account_id"`
+	Message   string `json:"message"`
+}
+
+// ledger is what the run knows of the transcations of an account. Posted
+// amounts were taken from the limits for sure, uncertain ones may have been:
+// they are held for review or their response was lost.
+type ledger struct {
+	postedDebits, uncertainDebits   float64
+	postedCredits, uncertainCredits float64
+}
+
+// run is the state shared by the workers of a run
+type run struct {
+	cfg    Config
+	client *Client
+
+	accounts []string
+	ops      []string
+
+	mu         sync.Mutex
+	operations map[string]*OpReport
+	ledgers    map[string]*ledger
+	violations []Violation
+	requests   int
+}
+
+// Run creates the accounts, drives the load until the duration or the number
+// of requests is reached or ctx is done, then checks the final limits of
+// every account
+func Run(ctx context.Context, cfg Config, httpClient *http.Client) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	r := &run{
+		cfg:        cfg,
+		client:     NewClient(cfg.BaseURL, cfg.Authorization, httpClient),
+		operations: make(map[string]*OpReport),
+		ledgers:    make(map[string]*ledger),
+	}
+
+	for _, op := range cfg.Mix.ops() {
+		if cfg.Mix[op] > 0 {
+			r.ops = append(r.ops, op)
+			r.operations[op] = &OpReport{ByStatus: make(map[int]int)}
+		}
+	}
+
+	if err := r.setup(ctx); err != nil {
+		return nil, err
+	}
+
+	loadCtx := ctx
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		loadCtx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	started := time.Now()
+	r.drive(loadCtx)
+	elapsed := time.Since(started)
+
+	// the final limits are read even when the run was interrupted, ctx may
+	// be done already
+	if err := r.check(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return r.report(elapsed), nil
+}
+
+func (c Config) validate() error {
+	switch {
+	case c.BaseURL == "":
+		return errors.New("the base url of the api is required")
+	case c.Accounts <= 0:
+		return fmt.Errorf("invalid number of accounts: %d", c.Accounts)
+	case c.Concurrency <= 0:
+		return fmt.Errorf("invalid concurrency: %d", c.Concurrency)
+	case c.Rate < 0:
+		return fmt.Errorf("invalid rate: %v", c.Rate)
+	case c.Duration <= 0 && c.Requests <= 0:
+		return errors.New("a duration or a number of requests is required")
+	case c.Skew != 0 && c.Skew <= 1:
+		return fmt.Errorf("invalid skew %v, it is 0 or greater than 1", c.Skew)
+	case c.MinAmount <= 0 || c.MaxAmount < c.MinAmount:
+		return fmt.Errorf("invalid amounts from %v to %v", c.MinAmount, c.MaxAmount)
+	case c.WithdrawalLimit < 0 || c.CreditLimit < 0:
+		return fmt.Errorf("invalid limits: withdrawal %v, credit %v", c.WithdrawalLimit, c.CreditLimit)
+	}
+
+	total := 0
+	for _, weight := range c.Mix {
+		total += weight
+	}
+
+	if total == 0 {
+		return errors.New("the mix has no operation with a weight")
+	}
+
+	return nil
+}
+
+// setup creates the accounts and sets their limits
+func (r *run) setup(ctx context.Context) error {
+	rnd := rand.New(rand.NewSource(r.cfg.Seed))
+	limits := domain.AccountLimitsReq{WithdrawalLimit: &r.cfg.WithdrawalLimit, CreditLimit: &r.cfg.CreditLimit}
+
+	for i := 0; i < r.cfg.Accounts; i++ {
+		documentNumber := seed.DocumentNumber(rnd)
+
+		var account *domain.Account
+		err := retryRateLimited(ctx, func() error {
+			var err error
+			account, err = r.client.CreateAccount(ctx, documentNumber)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create account: %w", err)
+		}
+
+		err = retryRateLimited(ctx, func() error {
+			return r.client.UpdateAccountLimits(ctx, account.ID, limits)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set the limits of account %s: %w", account.ID, err)
+		}
+
+		r.accounts = append(r.accounts, account.ID)
+		r.ledgers[account.ID] = &ledger{}
+	}
+
+	return nil
+}
+
+// retryRateLimited retries fn while the api rate limits it, the setup isn't
+// measured and may go as slow as the api wants
+func retryRateLimited(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Status != http.StatusTooManyRequests {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rateLimitBackoff):
+		}
+	}
+}
+
+// drive runs the workers until ctx is done or the requests are all sent
+func (r *run) drive(ctx context.Context) {
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if r.cfg.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(r.cfg.Rate), 1)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(worker int64) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(r.cfg.Seed + worker))
+			pick := r.picker(rnd)
+			for r.take() {
+				if err := limiter.Wait(ctx); err != nil {
+					return
+				}
+
+				r.do(ctx, rnd, r.accounts[pick()])
+			}
+		}(int64(i + 1))
+	}
+
+	wg.Wait()
+}
+
+// take reserves a request, false once they were all sent
+func (r *run) take() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cfg.Requests > 0 && r.requests >= r.cfg.Requests {
+		return false
+	}
+
+	r.requests++
+
+	return true
+}
+
+// picker returns the func picking the index of the next account
+func (r *run) picker(rnd *rand.Rand) func() int {
+	if r.cfg.Skew == 0 || len(r.accounts) == 1 {
+		return func() int { return rnd.Intn(len(r.accounts)) }
+	}
+
+	zipf := rand.NewZipf(rnd, r.cfg.Skew, 1, uint64(len(r.accounts)-1))
+
+	return func() int { return int(zipf.Uint64()) }
+}
+
+// do sends an operation picked from the mix for the account
+func (r *run) do(ctx context.Context, rnd *rand.Rand, accountID string) {
+	op := r.pick(rnd)
+
+	if op == OpRead {
+		started := time.Now()
+		account, status := r.client.GetAccount(ctx, accountID)
+		if ctx.Err() != nil && status == 0 {
+			return
+		}
+
+		r.record(op, status, time.Since(started))
+		if account != nil && (account.WithdrawalLimit < 0 || account.CreaditLimit < 0) {
+			r.violate(accountID, "negative limits read: withdrawal %.2f, credit %.2f", account.WithdrawalLimit, account.CreaditLimit)
+		}
+
+		return
+	}
+
+	opType, _ := strconv.Atoi(op)
+	amount := math.Round((r.cfg.MinAmount+(r.cfg.MaxAmount-r.cfg.MinAmount)*rnd.Float64())*100) / 100
+
+	started := time.Now()
+	status := r.client.CreateTranscation(ctx, domain.Transcation{
+		AccountID:       accountID,
+		OperationTypeID: opType,
+		Amount:          amount,
+	})
+	if ctx.Err() != nil && status == 0 {
+		// the run ended while the request was in flight, it may have been
+		// posted all the same
+		r.account(accountID, opType, amount, false)
+		return
+	}
+
+	r.record(op, status, time.Since(started))
+
+	switch {
+	case status == http.StatusOK:
+		r.account(accountID, opType, amount, true)
+	case status == http.StatusAccepted, status == 0, status >= http.StatusInternalServerError:
+		r.account(accountID, opType, amount, false)
+	}
+}
+
+func (r *run) pick(rnd *rand.Rand) string {
+	total := 0
+	for _, op := range r.ops {
+		total += r.cfg.Mix[op]
+	}
+
+	n := rnd.Intn(total)
+	for _, op := range r.ops {
+		if n < r.cfg.Mix[op] {
+			return op
+		}
+		n -= r.cfg.Mix[op]
+	}
+
+	return r.ops[len(r.ops)-1]
+}
+
+func (r *run) record(op string, status int, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o := r.operations[op]
+	o.Requests++
+	o.ByStatus[status]++
+	o.latencies = append(o.latencies, latency)
+}
+
+// account adds the amount of a transcation to the ledger of the account
+func (r *run) account(accountID string, opType int, amount float64, posted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l := r.ledgers[accountID]
+	debit := domain.Transcation{OperationTypeID: opType}.IsDebit()
+	switch {
+	case debit && posted:
+		l.postedDebits += amount
+	case debit:
+		l.uncertainDebits += amount
+	case posted:
+		l.postedCredits += amount
+	default:
+		l.uncertainCredits += amount
+	}
+}
+
+func (r *run) violate(accountID, format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.violations = append(r.violations, Violation{AccountID: accountID, Message: fmt.Sprintf(format, args...)})
+}
+
+// check reads the final limits of every account. They are never negative and
+// they went down by the posted transcations, plus at most the uncertain ones.
+func (r *run) check(ctx context.Context) error {
+	for _, accountID := range r.accounts {
+		var account *domain.Account
+		err := retryRateLimited(ctx, func() error {
+			var status int
+			if account, status = r.client.GetAccount(ctx, accountID); account == nil {
+				return &StatusError{Status: status}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read account %s: %w", accountID, err)
+		}
+
+		if account.WithdrawalLimit < 0 || account.CreaditLimit < 0 {
+			r.violate(accountID, "negative final limits: withdrawal %.2f, credit %.2f", account.WithdrawalLimit, account.CreaditLimit)
+		}
+
+		l := r.ledgers[accountID]
+		if !within(account.WithdrawalLimit, r.cfg.WithdrawalLimit-l.postedDebits-l.uncertainDebits, r.cfg.WithdrawalLimit-l.postedDebits) {
+			r.violate(accountID, "withdrawal limit %.2f doesn't match the debits: %.2f posted, %.2f uncertain out of %.2f",
+				account.WithdrawalLimit, l.postedDebits, l.uncertainDebits, r.cfg.WithdrawalLimit)
+		}
+
+		if !within(account.CreaditLimit, r.cfg.CreditLimit-l.postedCredits-l.uncertainCredits, r.cfg.CreditLimit-l.postedCredits) {
+			r.violate(accountID, "credit limit %.2f doesn't match the credits: %.2f posted, %.2f uncertain out of %.2f",
+				account.CreaditLimit, l.postedCredits, l.uncertainCredits, r.cfg.CreditLimit)
+		}
+	}
+
+	return nil
+}
+
+func within(v, min, max float64) bool {
+	return v >= min-limitsTolerance && v <= max+limitsTolerance
+}
+
+func (r *run) report(elapsed time.Duration) *Report {
+	report := &Report{
+		Duration:   elapsed,
+		Operations: r.operations,
+		Violations: r.violations,
+	}
+
+	for _, o := range r.operations {
+		report.Requests += o.Requests
+		o.Latency = summarize(o.latencies)
+	}
+
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	}
+
+	if report.Violations == nil {
+		report.Violations = make([]Violation, 0)
+	}
+
+	return report
+}
+
+// summarize returns the percentiles of the latencies, nearest rank
+func summarize(latencies []time.Duration) LatencySummary {
+	if len(latencies) == 0 {
+		return LatencySummary{}
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentile := func(p float64) time.Duration {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+
+		return sorted[rank-1]
+	}
+
+	return LatencySummary{
+		P50: percentile(50),
+		P90: percentile(90),
+		P99: percentile(99),
+		Max: sorted[len(sorted)-1],
+	}
+}
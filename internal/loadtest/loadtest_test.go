@@ -0,0 +1,192 @@
+package loadtest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/database/memory"
+	httpGW "github.com/madhurikadam/app-transcation/internal/gateway/http"
+	"github.com/madhurikadam/app-transcation/internal/loadtest"
+	"github.com/madhurikadam/app-transcation/internal/service"
+)
+
+// newServer serves the account and transcation routes on an in memory repo
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	svc := service.New(memory.NewRepo())
+	gw := httpGW.NewGateway(&svc)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts", gw.CreateAccount).Methods(http.MethodPost)
+	router.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}", gw.GetAccount).Methods(http.MethodGet)
+	router.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}/limits", gw.UpdateAccountLimits).Methods(http.MethodPatch)
+	router.HandleFunc("/transcations", gw.CreateTranscation).Methods(http.MethodPost)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	server := newServer(t)
+	mix, err := loadtest.ParseMix("1=40,3=20,4=20,read=20")
+	require.NoError(t, err)
+
+	report, err := loadtest.Run(context.Background(), loadtest.Config{
+		BaseURL:     server.URL,
+		Accounts:    5,
+		Concurrency: 8,
+		Requests:    400,
+		Mix:         mix,
+		Skew:        1.5,
+		// the limits run out, some transcations are declined
+		WithdrawalLimit: 2000,
+		CreditLimit:     1000,
+		MinAmount:       1,
+		MaxAmount:       100,
+		Seed:            1,
+	}, server.Client())
+	require.NoError(t, err)
+
+	require.Equal(t, 400, report.Requests)
+	require.Empty(t, report.Violations)
+	require.ElementsMatch(t, []string{"1", "3", "4", loadtest.OpRead}, keys(report.Operations))
+
+	declined := 0
+	for _, o := range report.Operations {
+		require.NotZero(t, o.Requests)
+		require.LessOrEqual(t, o.Latency.P50, o.Latency.P90)
+		require.LessOrEqual(t, o.Latency.P90, o.Latency.P99)
+		require.LessOrEqual(t, o.Latency.P99, o.Latency.Max)
+		require.Zero(t, o.ByStatus[0])
+
+		declined += o.ByStatus[http.StatusUnprocessableEntity]
+	}
+	require.NotZero(t, declined)
+}
+
+// TestRunReportsViolations runs against a server whose accounts go negative
+// and accept every transcation without touching the limits
+func TestRunReportsViolations(t *testing.T) {
+	t.Parallel()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"a"}`))
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/accounts/a/limits", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPatch)
+	router.HandleFunc("/accounts/a", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"a","withdrawal_limit":-1,"credit_limit":10}`))
+	}).Methods(http.MethodGet)
+	router.HandleFunc("/transcations", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	report, err := loadtest.Run(context.Background(), loadtest.Config{
+		BaseURL:         server.URL,
+		Accounts:        1,
+		Concurrency:     1,
+		Requests:        10,
+		Mix:             loadtest.Mix{"1": 1, "4": 1},
+		WithdrawalLimit: 10,
+		CreditLimit:     10,
+		MinAmount:       1,
+		MaxAmount:       1,
+		Seed:            1,
+	}, server.Client())
+	require.NoError(t, err)
+
+	messages := make([]string, 0, len(report.Violations))
+	for _, v := range report.Violations {
+		require.Equal(t, "a", v.AccountID)
+		messages = append(messages, v.Message)
+	}
+
+	require.Len(t, messages, 3)
+	require.Contains(t, messages[0], "negative final limits")
+	require.Contains(t, messages[1], "withdrawal limit -1.00 doesn't match the debits")
+	require.Contains(t, messages[2], "credit limit 10.00 doesn't match the credits")
+}
+
+func TestRunStopsAfterDuration(t *testing.T) {
+	t.Parallel()
+
+	server := newServer(t)
+
+	started := time.Now()
+	report, err := loadtest.Run(context.Background(), loadtest.Config{
+		BaseURL:         server.URL,
+		Accounts:        2,
+		Concurrency:     2,
+		Rate:            50,
+		Duration:        200 * time.Millisecond,
+		Mix:             loadtest.Mix{loadtest.OpRead: 1},
+		WithdrawalLimit: 100,
+		CreditLimit:     100,
+		MinAmount:       1,
+		MaxAmount:       1,
+	}, server.Client())
+	require.NoError(t, err)
+
+	require.Less(t, time.Since(started), 2*time.Second)
+	require.NotZero(t, report.Requests)
+	// 50 requests a second for 200ms, and the first one right away
+	require.LessOrEqual(t, report.Requests, 12)
+	require.Empty(t, report.Violations)
+}
+
+func TestParseMix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		mix     string
+		want    loadtest.Mix
+		wantErr bool
+	}{
+		{name: "every operation", mix: "1=45, 2=10,3=10,4=15,read=20", want: loadtest.Mix{"1": 45, "2": 10, "3": 10, "4": 15, "read": 20}},
+		{name: "reads only", mix: "read=1", want: loadtest.Mix{"read": 1}},
+		{name: "unknown operation type", mix: "5=1", wantErr: true},
+		{name: "unknown operation", mix: "write=1", wantErr: true},
+		{name: "missing weight", mix: "1", wantErr: true},
+		{name: "negative weight", mix: "1=-1", wantErr: true},
+		{name: "repeated operation", mix: "1=1,1=2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mix, err := loadtest.ParseMix(tt.mix)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, mix)
+		})
+	}
+}
+
+func keys(operations map[string]*loadtest.OpReport) []string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+
+	return names
+}
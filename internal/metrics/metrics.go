@@ -0,0 +1,58 @@
+// Package metrics exposes what the transcation service does to prometheus
+package metrics
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+// Transcations counts the transcations created by operation type and status,
+// the declined ones by reason, and observes their amounts
+type Transcations struct {
+	created  *prometheus.CounterVec
+	declined *prometheus.CounterVec
+	amounts  *prometheus.HistogramVec
+}
+
+// NewTranscations registers the transcation metrics with registerer
+func NewTranscations(registerer prometheus.Registerer) (*Transcations, error) {
+	t := &Transcations{
+		created: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transcations_created_total",
+			Help: "Transcations posted or held for review, by operation type and status.",
+		}, []string{"operation_type", "status"}),
+		declined: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transcations_declined_total",
+			Help: "Transcations declined, by operation type and reason.",
+		}, []string{"operation_type", "reason"}),
+		amounts: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "transcation_amount",
+			Help:    "Amounts of the transcations created, by operation type.",
+			Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+		}, []string{"operation_type"}),
+	}
+
+	for _, c := range []prometheus.Collector{t.created, t.declined, t.amounts} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func (t *Transcations) TranscationCreated(transcation domain.Transcation) {
+	opType := strconv.Itoa(transcation.OperationTypeID)
+
+	t.created.WithLabelValues(opType, transcation.Status).Inc()
+	// debits are stored negative
+	t.amounts.WithLabelValues(opType).Observe(math.Abs(transcation.Amount))
+}
+
+func (t *Transcations) TranscationDeclined(operationTypeID int, reason string) {
+	t.declined.WithLabelValues(strconv.Itoa(operationTypeID), reason).Inc()
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/service"
+)
+
+func TestTranscations(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewTranscations(registry)
+	require.NoError(t, err)
+
+	m.TranscationCreated(domain.Transcation{OperationTypeID: 1, Amount: -20, Status: domain.TranscationPosted})
+	m.TranscationCreated(domain.Transcation{OperationTypeID: 1, Amount: -30, Status: domain.TranscationPendingReview})
+	m.TranscationCreated(domain.Transcation{OperationTypeID: 4, Amount: 50, Status: domain.TranscationPosted})
+	m.TranscationDeclined(3, service.DeclineWithdrawalLimit)
+	m.TranscationDeclined(3, service.DeclineWithdrawalLimit)
+	m.TranscationDeclined(1, service.DeclineFraud)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.created.WithLabelValues("1", domain.TranscationPosted)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.created.WithLabelValues("1", domain.TranscationPendingReview)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.created.WithLabelValues("4", domain.TranscationPosted)))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.declined.WithLabelValues("3", service.DeclineWithdrawalLimit)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.declined.WithLabelValues("1", service.DeclineFraud)))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	sums := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "transcation_amount" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			sums[metric.GetLabel()[0].GetValue()] = metric.GetHistogram().GetSampleSum()
+		}
+	}
+
+	// debits are observed as positive amounts
+	require.Equal(t, map[string]float64{"1": 50, "4": 50}, sums)
+
+	// the metrics can't be registered twice
+	_, err = NewTranscations(registry)
+	require.Error(t, err)
+}
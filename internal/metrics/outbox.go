@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/madhurikadam/app-transcation/internal/service"
+)
+
+// lagTimeout bounds the read of the outbox lag on every scrape
+const lagTimeout = time.Second
+
+var outboxLagDesc = prometheus.NewDesc("outbox_lag_seconds",
+	"Time the oldest unpublished event has waited for the relay, 0 when every event is published.", nil, nil)
+
+// Outbox exposes how far behind the outbox relay is, the lag is read from the
+// repo on every scrape
+type Outbox struct {
+	repo service.OutboxRepo
+}
+
+func NewOutbox(repo service.OutboxRepo) *Outbox {
+	return &Outbox{repo: repo}
+}
+
+func (o *Outbox) Describe(ch chan<- *prometheus.Desc) {
+	ch <- outboxLagDesc
+}
+
+func (o *Outbox) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), lagTimeout)
+	defer cancel()
+
+	lag, err := service.OutboxLag(ctx, o.repo)
+	if err != nil {
+		log.WithError(err).Warn("failed to read the outbox lag")
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(outboxLagDesc, prometheus.GaugeValue, lag.Seconds())
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+type outboxStub struct {
+	oldest time.Time
+	err    error
+}
+
+func (o outboxStub) ProcessOutbox(ctx context.Context, limit int, fn func([]domain.Event) error) (int, error) {
+	return 0, nil
+}
+
+func (o outboxStub) GetOldestUnpublishedEventTime(ctx context.Context) (time.Time, error) {
+	return o.oldest, o.err
+}
+
+func TestOutbox(t *testing.T) {
+	require.Equal(t, 0.0, testutil.ToFloat64(NewOutbox(outboxStub{})))
+
+	lag := testutil.ToFloat64(NewOutbox(outboxStub{oldest: time.Now().Add(-2 * time.Minute)}))
+	require.GreaterOrEqual(t, lag, 120.0)
+	require.Less(t, lag, 180.0)
+
+	// the lag is left out of the scrape when the outbox can't be read
+	require.Zero(t, testutil.CollectAndCount(NewOutbox(outboxStub{err: errors.New("connection refused")})))
+}
@@ -0,0 +1,180 @@
+// Package policy decides which principal may perform which action on which
+// account.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+const (
+	// RoleCustomer owns accounts and may only use its own accounts
+	RoleCustomer = "customer"
+	// RoleMerchant charges purchases to accounts
+	RoleMerchant = "merchant"
+	// RoleOperator is the back office, it may do anything
+	RoleOperator = "operator"
+	// RoleAuditor may read everything and change nothing
+	RoleAuditor = "auditor"
+)
+
+type Action string
+
+const (
+	ActionCreateAccount       Action = "account:create"
+	ActionReadAccount         Action = "account:read"
+	ActionUpdateAccountLimits Action = "account:update_limits"
+	ActionCreateTranscation   Action = "transcation:create"
+	ActionReadTranscations    Action = "transcation:read"
+	ActionReadEvents          Action = "event:read"
+	ActionManageWebhooks      Action = "webhook:manage"
+	ActionReadWebhooks        Action = "webhook:read"
+	ActionManageAPIKeys       Action = "api_key:manage"
+	ActionReadReviews         Action = "review:read"
+	ActionDecideReviews       Action = "review:decide"
+)
+
+// Actions lists every action, they are also the scopes API keys are granted
+var Actions = []Action{
+	ActionCreateAccount,
+	ActionReadAccount,
+	ActionUpdateAccountLimits,
+	ActionCreateTranscation,
+	ActionReadTranscations,
+	ActionReadEvents,
+	ActionManageWebhooks,
+	ActionReadWebhooks,
+	ActionManageAPIKeys,
+	ActionReadReviews,
+	ActionDecideReviews,
+}
+
+// Roles lists every role
+var Roles = []string{RoleCustomer, RoleMerchant, RoleOperator, RoleAuditor}
+
+var ErrForbidden = errors.New("forbidden")
+
+// Resource is what an action applies to, fields not relevant to the action
+// are left empty
+type Resource struct {
+	AccountID       string `json:"account_id,omitempty"`
+	OwnerID         string `json:"owner_id,omitempty"`
+	OperationTypeID int    `json:"operation_type_id,omitempty"`
+}
+
+// Denial describes a refused action
+type Denial struct {
+	Subject  string   `json:"subject"`
+	Roles    []string `json:"roles"`
+	Action   Action   `json:"action"`
+	Resource Resource `json:"resource"`
+	Reason   string   `json:"reason"`
+}
+
+// Recorder keeps track of denied actions
+type Recorder interface {
+	RecordDenial(ctx context.Context, denial Denial)
+}
+
+// Recorders records denials with every one of its recorders
+type Recorders []Recorder
+
+func (rs Recorders) RecordDenial(ctx context.Context, denial Denial) {
+	for _, r := range rs {
+		r.RecordDenial(ctx, denial)
+	}
+}
+
+// LogRecorder writes denials to the audit entries of the service log
+type LogRecorder struct{}
+
+func (LogRecorder) RecordDenial(ctx context.Context, denial Denial) {
+	logging.FromContext(ctx).WithFields(log.Fields{
+		"audit":        "access_denied",
+		"subject":      denial.Subject,
+		"roles":        denial.Roles,
+		"action":       denial.Action,
+		"account_id":   denial.Resource.AccountID,
+		"operation_id": denial.Resource.OperationTypeID,
+		"reason":       denial.Reason,
+	}).Warn("access denied")
+}
+
+type Policy struct {
+	recorder Recorder
+}
+
+func New(recorder Recorder) *Policy {
+	return &Policy{
+		recorder: recorder,
+	}
+}
+
+// Authorize allows the action when one of the roles of the principal of ctx
+// allows it, otherwise the denial is recorded and ErrForbidden returned
+func (p *Policy) Authorize(ctx context.Context, action Action, resource Resource) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return p.deny(ctx, Denial{Action: action, Resource: resource, Reason: "no authenticated principal"})
+	}
+
+	denial := Denial{
+		Subject:  principal.Subject,
+		Roles:    principal.Roles,
+		Action:   action,
+		Resource: resource,
+	}
+
+	// API keys are further restricted to the actions they were granted
+	if principal.Method == auth.SchemeAPIKey && !principal.HasScope(string(action)) {
+		denial.Reason = "the api key is not granted the action"
+		return p.deny(ctx, denial)
+	}
+
+	for _, role := range principal.Roles {
+		if allows(role, principal.Subject, action, resource) {
+			return nil
+		}
+	}
+
+	denial.Reason = "no role of the principal allows the action"
+	return p.deny(ctx, denial)
+}
+
+func (p *Policy) deny(ctx context.Context, denial Denial) error {
+	p.recorder.RecordDenial(ctx, denial)
+
+	return fmt.Errorf("%w: %s", ErrForbidden, denial.Action)
+}
+
+func allows(role, subject string, action Action, resource Resource) bool {
+	switch role {
+	case RoleOperator:
+		return true
+	case RoleAuditor:
+		switch action {
+		case ActionReadAccount, ActionReadTranscations, ActionReadEvents, ActionReadWebhooks, ActionReadReviews:
+			return true
+		}
+	case RoleCustomer:
+		switch action {
+		case ActionCreateAccount:
+			return true
+		case ActionReadAccount, ActionReadTranscations, ActionReadEvents, ActionCreateTranscation:
+			return resource.OwnerID != "" && resource.OwnerID == subject
+		}
+	case RoleMerchant:
+		// merchants only charge purchases, cash withdrawals and payments are
+		// made by the account owner
+		return action == ActionCreateTranscation &&
+			(resource.OperationTypeID == 1 || resource.OperationTypeID == 2)
+	}
+
+	return false
+}
@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+)
+
+// recorder keeps the denials in memory
+type recorder struct {
+	denials []Denial
+}
+
+func (r *recorder) RecordDenial(_ context.Context, denial Denial) {
+	r.denials = append(r.denials, denial)
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	principal := func(subject string, roles ...string) *auth.Principal {
+		return &auth.Principal{Subject: subject, Roles: roles}
+	}
+
+	own := Resource{AccountID: "account-1", OwnerID: "alice"}
+	other := Resource{AccountID: "account-2", OwnerID: "bob"}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		action    Action
+		resource  Resource
+		allowed   bool
+	}{
+		{name: "anonymous", action: ActionReadAccount, resource: own},
+		{name: "customer reads own account", principal: principal("alice", RoleCustomer), action: ActionReadAccount, resource: own, allowed: true},
+		{name: "customer reads another account", principal: principal("alice", RoleCustomer), action: ActionReadAccount, resource: other},
+		{name: "customer reads unowned account", principal: principal("alice", RoleCustomer), action: ActionReadAccount, resource: Resource{AccountID: "account-3"}},
+		{name: "customer pays own account", principal: principal("alice", RoleCustomer), action: ActionCreateTranscation, resource: Resource{OwnerID: "alice", OperationTypeID: 4}, allowed: true},
+		{name: "customer creates account", principal: principal("alice", RoleCustomer), action: ActionCreateAccount, allowed: true},
+		{name: "customer changes limits", principal: principal("alice", RoleCustomer), action: ActionUpdateAccountLimits, resource: own},
+		{name: "customer manages webhooks", principal: principal("alice", RoleCustomer), action: ActionManageWebhooks},
+		{name: "merchant charges a purchase", principal: principal("shop", RoleMerchant), action: ActionCreateTranscation, resource: Resource{OwnerID: "bob", OperationTypeID: 1}, allowed: true},
+		{name: "merchant charges an installment purchase", principal: principal("shop", RoleMerchant), action: ActionCreateTranscation, resource: Resource{OwnerID: "bob", OperationTypeID: 2}, allowed: true},
+		{name: "merchant withdraws", principal: principal("shop", RoleMerchant), action: ActionCreateTranscation, resource: Resource{OwnerID: "bob", OperationTypeID: 3}},
+		{name: "merchant credits", principal: principal("shop", RoleMerchant), action: ActionCreateTranscation, resource: Resource{OwnerID: "bob", OperationTypeID: 4}},
+		{name: "merchant reads account", principal: principal("shop", RoleMerchant), action: ActionReadAccount, resource: other},
+		{name: "auditor reads any account", principal: principal("audit", RoleAuditor), action: ActionReadAccount, resource: other, allowed: true},
+		{name: "auditor reads webhooks", principal: principal("audit", RoleAuditor), action: ActionReadWebhooks, allowed: true},
+		{name: "auditor changes limits", principal: principal("audit", RoleAuditor), action: ActionUpdateAccountLimits, resource: other},
+		{name: "auditor creates transcation", principal: principal("audit", RoleAuditor), action: ActionCreateTranscation, resource: other},
+		{name: "operator changes limits", principal: principal("ops", RoleOperator), action: ActionUpdateAccountLimits, resource: other, allowed: true},
+		{name: "operator manages webhooks", principal: principal("ops", RoleOperator), action: ActionManageWebhooks, allowed: true},
+		{name: "auditor reads reviews", principal: principal("audit", RoleAuditor), action: ActionReadReviews, allowed: true},
+		{name: "auditor decides reviews", principal: principal("audit", RoleAuditor), action: ActionDecideReviews},
+		{name: "operator decides reviews", principal: principal("ops", RoleOperator), action: ActionDecideReviews, allowed: true},
+		{name: "any role allowing is enough", principal: principal("bob", RoleMerchant, RoleCustomer), action: ActionReadAccount, resource: other, allowed: true},
+		{name: "api key granted the action", principal: &auth.Principal{Subject: "shop", Roles: []string{RoleMerchant}, Scopes: []string{string(ActionCreateTranscation)}, Method: auth.SchemeAPIKey}, action: ActionCreateTranscation, resource: Resource{OperationTypeID: 1}, allowed: true},
+		{name: "api key not granted the action", principal: &auth.Principal{Subject: "ops", Roles: []string{RoleOperator}, Scopes: []string{string(ActionReadAccount)}, Method: auth.SchemeAPIKey}, action: ActionUpdateAccountLimits, resource: other},
+		{name: "unknown role", principal: principal("bob", "admin"), action: ActionReadAccount, resource: other},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = auth.WithPrincipal(ctx, tt.principal)
+			}
+
+			err := New(rec).Authorize(ctx, tt.action, tt.resource)
+			if tt.allowed {
+				require.NoError(t, err)
+				require.Empty(t, rec.denials)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrForbidden)
+			require.Len(t, rec.denials, 1)
+			require.Equal(t, tt.action, rec.denials[0].Action)
+			require.Equal(t, tt.resource, rec.denials[0].Resource)
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Package projection derives the limits of the accounts and the balances of
+// their transcations from the event history, to rebuild the tables or to
+// check them against it
+package projection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+const defaultBatchSize = 1000
+
+// kinds of discrepancy, only the limits and the balances can be rebuilt
+const (
+	KindWithdrawalLimit = "withdrawal_limit"
+	KindCreditLimit     = "credit_limit"
+	KindBalance         = "balance"
+	// KindMissingHistory is a row the events know nothing about
+	KindMissingHistory = "missing_history"
+	// KindMissingRow is an entity of the events the tables don't have
+	KindMissingRow = "missing_row"
+)
+
+type (
+	// EventSource reads the events of every account in sequence order
+	EventSource interface {
+		ListEvents(ctx context.Context, afterSeq int64, limit int) ([]domain.Event, error)
+	}
+
+	// State reads the current limits and balances, in id order
+	State interface {
+		ListAccountLimits(ctx context.Context, afterID string, limit int) ([]domain.AccountLimits, error)
+		ListTranscationBalances(ctx context.Context, afterID string, limit int) ([]domain.TranscationBalance, error)
+	}
+
+	// Writer sets the limits and balances of an account to the projected ones
+	Writer interface {
+		RebuildAccount(ctx context.Context, accountID string, lastSeq int64, limits *domain.AccountLimits, balances map[string]float64) error
+	}
+)
+
+// Account is the projected state of an account
+type Account struct {
+	// Limits is nil when the history of the account doesn't tell them
+	Limits   *domain.AccountLimits
+	Balances map[string]float64
+	LastSeq  int64
+}
+
+// Projection is the state of every account folded from the events
+type Projection struct {
+	Accounts map[string]*Account
+	Events   int
+	LastSeq  int64
+}
+
+// Discrepancy is a difference between the tables and the projection
+type Discrepancy struct {
+	Kind          string   `json:"kind"`
+	AccountID     string   `json:"account_id"`
+	TranscationID string   `json:"transcation_id,omitempty"`
+	Projected     *float64 `json:"projected,omitempty"`
+	Current       *float64 `json:"current,omitempty"`
+}
+
+// Rebuildable reports whether rebuilding from the projection fixes it
+func (d Discrepancy) Rebuildable() bool {
+	return d.Kind == KindWithdrawalLimit || d.Kind == KindCreditLimit || d.Kind == KindBalance
+}
+
+// Build folds the whole event history into a projection
+func Build(ctx context.Context, source EventSource, batchSize int) (*Projection, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	p := &Projection{Accounts: make(map[string]*Account)}
+	for {
+		events, err := source.ListEvents(ctx, p.LastSeq, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events: %w", err)
+		}
+
+		for _, event := range events {
+			if err := p.Apply(event); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(events) < batchSize {
+			return p, nil
+		}
+	}
+}
+
+// Apply folds an event into the projection. The limits events carry the
+// limits they result in, the transcation events the balances.
+func (p *Projection) Apply(event domain.Event) error {
+	account := p.account(event.AccountID)
+
+	switch event.Type {
+	case domain.EventAccountCreated, domain.EventAccountLimitsChanged:
+		var limits domain.AccountLimits
+		if err := json.Unmarshal(event.Payload, &limits); err != nil {
+			return fmt.Errorf("invalid payload of event %d: %w", event.Seq, err)
+		}
+
+		account.Limits = &limits
+	case domain.EventTranscationCreated:
+		var transcation domain.Transcation
+		if err := json.Unmarshal(event.Payload, &transcation); err != nil {
+			return fmt.Errorf("invalid payload of event %d: %w", event.Seq, err)
+		}
+
+		account.Balances[transcation.ID] = transcation.Balance
+	case domain.EventTranscationDischarged:
+		var discharge domain.Discharge
+		if err := json.Unmarshal(event.Payload, &discharge); err != nil {
+			return fmt.Errorf("invalid payload of event %d: %w", event.Seq, err)
+		}
+
+		account.Balances[discharge.TranscationID] = discharge.Balance
+	default:
+		return fmt.Errorf("unknown type %q of event %d", event.Type, event.Seq)
+	}
+
+	account.LastSeq = event.Seq
+	p.LastSeq = event.Seq
+	p.Events++
+
+	return nil
+}
+
+func (p *Projection) account(id string) *Account {
+	account, ok := p.Accounts[id]
+	if !ok {
+		account = &Account{Balances: make(map[string]float64)}
+		p.Accounts[id] = account
+	}
+
+	return account
+}
+
+// Compare reports the differences between the current tables and the
+// projection, ordered by account
+func Compare(ctx context.Context, p *Projection, state State, batchSize int) ([]Discrepancy, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	discrepancies := make([]Discrepancy, 0)
+	seenAccounts := make(map[string]bool, len(p.Accounts))
+	seenTranscations := make(map[string]bool)
+
+	afterID := ""
+	for {
+		list, err := state.ListAccountLimits(ctx, afterID, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list account limits: %w", err)
+		}
+
+		for _, current := range list {
+			afterID = current.AccountID
+			seenAccounts[current.AccountID] = true
+
+			account, ok := p.Accounts[current.AccountID]
+			if !ok || account.Limits == nil {
+				discrepancies = append(discrepancies, Discrepancy{Kind: KindMissingHistory, AccountID: current.AccountID})
+				continue
+			}
+
+			discrepancies = appendDiff(discrepancies, Discrepancy{Kind: KindWithdrawalLimit, AccountID: current.AccountID},
+				account.Limits.WithdrawalLimit, current.WithdrawalLimit)
+			discrepancies = appendDiff(discrepancies, Discrepancy{Kind: KindCreditLimit, AccountID: current.AccountID},
+				account.Limits.CreditLimit, current.CreditLimit)
+		}
+
+		if len(list) < batchSize {
+			break
+		}
+	}
+
+	afterID = ""
+	for {
+		list, err := state.ListTranscationBalances(ctx, afterID, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list transcation balances: %w", err)
+		}
+
+		for _, current := range list {
+			afterID = current.ID
+			seenTranscations[current.ID] = true
+
+			var (
+				projected float64
+				ok        bool
+			)
+			if account, found := p.Accounts[current.AccountID]; found {
+				projected, ok = account.Balances[current.ID]
+			}
+
+			if !ok {
+				discrepancies = append(discrepancies, Discrepancy{
+					Kind:          KindMissingHistory,
+					AccountID:     current.AccountID,
+					TranscationID: current.ID,
+				})
+				continue
+			}
+
+			discrepancies = appendDiff(discrepancies, Discrepancy{
+				Kind:          KindBalance,
+				AccountID:     current.AccountID,
+				TranscationID: current.ID,
+			}, projected, current.Balance)
+		}
+
+		if len(list) < batchSize {
+			break
+		}
+	}
+
+	for id, account := range p.Accounts {
+		if !seenAccounts[id] {
+			discrepancies = append(discrepancies, Discrepancy{Kind: KindMissingRow, AccountID: id})
+		}
+
+		for transcationID := range account.Balances {
+			if !seenTranscations[transcationID] {
+				discrepancies = append(discrepancies, Discrepancy{
+					Kind:          KindMissingRow,
+					AccountID:     id,
+					TranscationID: transcationID,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(discrepancies, func(i, j int) bool {
+		if discrepancies[i].AccountID != discrepancies[j].AccountID {
+			return discrepancies[i].AccountID < discrepancies[j].AccountID
+		}
+
+		return discrepancies[i].TranscationID < discrepancies[j].TranscationID
+	})
+
+	return discrepancies, nil
+}
+
+func appendDiff(discrepancies []Discrepancy, d Discrepancy, projected, current float64) []Discrepancy {
+	if projected == current {
+		return discrepancies
+	}
+
+	d.Projected = &projected
+	d.Current = &current
+
+	return append(discrepancies, d)
+}
+
+// RebuildResult tells which accounts were rebuilt
+type RebuildResult struct {
+	Rebuilt []string `json:"rebuilt"`
+	// Stale accounts got events while rebuilding, they are left as is
+	Stale []string `json:"stale"`
+}
+
+// Rebuild fixes the rebuildable discrepancies, one account at a time
+func Rebuild(ctx context.Context, p *Projection, discrepancies []Discrepancy, writer Writer) (RebuildResult, error) {
+	result := RebuildResult{
+		Rebuilt: make([]string, 0),
+		Stale:   make([]string, 0),
+	}
+
+	type fix struct {
+		limits   *domain.AccountLimits
+		balances map[string]float64
+	}
+
+	fixes := make(map[string]*fix)
+	order := make([]string, 0)
+	for _, d := range discrepancies {
+		if !d.Rebuildable() {
+			continue
+		}
+
+		f, ok := fixes[d.AccountID]
+		if !ok {
+			f = &fix{balances: make(map[string]float64)}
+			fixes[d.AccountID] = f
+			order = append(order, d.AccountID)
+		}
+
+		account := p.Accounts[d.AccountID]
+		if d.Kind == KindBalance {
+			f.balances[d.TranscationID] = account.Balances[d.TranscationID]
+		} else {
+			f.limits = account.Limits
+		}
+	}
+
+	for _, accountID := range order {
+		f := fixes[accountID]
+
+		err := writer.RebuildAccount(ctx, accountID, p.Accounts[accountID].LastSeq, f.limits, f.balances)
+		if errors.Is(err, domain.ErrProjectionStale) {
+			result.Stale = append(result.Stale, accountID)
+			continue
+		}
+
+		if err != nil {
+			return result, fmt.Errorf("failed to rebuild account %s: %w", accountID, err)
+		}
+
+		result.Rebuilt = append(result.Rebuilt, accountID)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,190 @@
+package projection
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+type events []domain.Event
+
+func (e events) ListEvents(_ context.Context, afterSeq int64, limit int) ([]domain.Event, error) {
+	list := make([]domain.Event, 0, limit)
+	for _, event := range e {
+		if event.Seq > afterSeq && len(list) < limit {
+			list = append(list, event)
+		}
+	}
+
+	return list, nil
+}
+
+// tables is the current state, rebuilding writes into it
+type tables struct {
+	limits   []domain.AccountLimits
+	balances []domain.TranscationBalance
+	// stale accounts got events after the projection
+	stale map[string]bool
+}
+
+func (t *tables) ListAccountLimits(_ context.Context, afterID string, limit int) ([]domain.AccountLimits, error) {
+	list := make([]domain.AccountLimits, 0, limit)
+	for _, limits := range t.limits {
+		if limits.AccountID > afterID && len(list) < limit {
+			list = append(list, limits)
+		}
+	}
+
+	return list, nil
+}
+
+func (t *tables) ListTranscationBalances(_ context.Context, afterID string, limit int) ([]domain.TranscationBalance, error) {
+	list := make([]domain.TranscationBalance, 0, limit)
+	for _, balance := range t.balances {
+		if balance.ID > afterID && len(list) < limit {
+			list = append(list, balance)
+		}
+	}
+
+	return list, nil
+}
+
+func (t *tables) RebuildAccount(_ context.Context, accountID string, _ int64, limits *domain.AccountLimits, balances map[string]float64) error {
+	if t.stale[accountID] {
+		return domain.ErrProjectionStale
+	}
+
+	for i := range t.limits {
+		if limits != nil && t.limits[i].AccountID == accountID {
+			t.limits[i] = *limits
+		}
+	}
+
+	for i := range t.balances {
+		if balance, ok := balances[t.balances[i].ID]; ok {
+			t.balances[i].Balance = balance
+		}
+	}
+
+	return nil
+}
+
+func event(t *testing.T, seq int64, eventType, accountID string, payload interface{}) domain.Event {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	return domain.Event{Seq: seq, AccountID: accountID, Type: eventType, Payload: data}
+}
+
+// history of account a: created, a purchase of 50 and a payment of 30
+// discharging it, the limits change with both
+func history(t *testing.T) events {
+	return events{
+		event(t, 1, domain.EventAccountCreated, "a", domain.AccountLimits{AccountID: "a", WithdrawalLimit: 100, CreditLimit: 100}),
+		event(t, 2, domain.EventAccountCreated, "b", domain.AccountLimits{AccountID: "b", WithdrawalLimit: 200, CreditLimit: 200}),
+		event(t, 3, domain.EventTranscationCreated, "a", domain.Transcation{ID: "t1", AccountID: "a", OperationTypeID: 1, Amount: -50, Balance: -50}),
+		event(t, 4, domain.EventAccountLimitsChanged, "a", domain.AccountLimits{AccountID: "a", WithdrawalLimit: 50, CreditLimit: 100}),
+		event(t, 6, domain.EventTranscationCreated, "a", domain.Transcation{ID: "t2", AccountID: "a", OperationTypeID: 4, Amount: 30, Balance: 0}),
+		event(t, 7, domain.EventTranscationDischarged, "a", domain.Discharge{TranscationID: "t1", AccountID: "a", Balance: -20, DischargedBy: "t2"}),
+		event(t, 8, domain.EventAccountLimitsChanged, "a", domain.AccountLimits{AccountID: "a", WithdrawalLimit: 50, CreditLimit: 70}),
+	}
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	p, err := Build(context.Background(), history(t), 2)
+	require.NoError(t, err)
+	require.Equal(t, 7, p.Events)
+	require.Equal(t, int64(8), p.LastSeq)
+
+	a := p.Accounts["a"]
+	require.Equal(t, &domain.AccountLimits{AccountID: "a", WithdrawalLimit: 50, CreditLimit: 70}, a.Limits)
+	require.Equal(t, map[string]float64{"t1": -20, "t2": 0}, a.Balances)
+	require.Equal(t, int64(8), a.LastSeq)
+	require.Equal(t, int64(2), p.Accounts["b"].LastSeq)
+
+	_, err = Build(context.Background(), events{{Seq: 1, Type: "account.renamed"}}, 2)
+	require.Error(t, err)
+}
+
+func TestCompareAndRebuild(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	p, err := Build(ctx, history(t), 0)
+	require.NoError(t, err)
+
+	state := &tables{
+		limits: []domain.AccountLimits{
+			// the credit limit was overwritten
+			{AccountID: "a", WithdrawalLimit: 50, CreditLimit: 1000},
+			{AccountID: "b", WithdrawalLimit: 200, CreditLimit: 200},
+			{AccountID: "c", WithdrawalLimit: 10, CreditLimit: 10},
+		},
+		balances: []domain.TranscationBalance{
+			// the discharge of t1 was lost
+			{ID: "t1", AccountID: "a", Balance: -50},
+			{ID: "t2", AccountID: "a", Balance: 0},
+		},
+	}
+
+	discrepancies, err := Compare(ctx, p, state, 1)
+	require.NoError(t, err)
+	require.Equal(t, []Discrepancy{
+		{Kind: KindCreditLimit, AccountID: "a", Projected: float(70), Current: float(1000)},
+		{Kind: KindBalance, AccountID: "a", TranscationID: "t1", Projected: float(-20), Current: float(-50)},
+		{Kind: KindMissingHistory, AccountID: "c"},
+	}, discrepancies)
+
+	result, err := Rebuild(ctx, p, discrepancies, state)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, result.Rebuilt)
+	require.Empty(t, result.Stale)
+
+	discrepancies, err = Compare(ctx, p, state, 0)
+	require.NoError(t, err)
+	require.Equal(t, []Discrepancy{{Kind: KindMissingHistory, AccountID: "c"}}, discrepancies)
+}
+
+func TestRebuildStale(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	p, err := Build(ctx, history(t), 0)
+	require.NoError(t, err)
+
+	state := &tables{
+		limits: []domain.AccountLimits{
+			{AccountID: "a", WithdrawalLimit: 0, CreditLimit: 70},
+			{AccountID: "b", WithdrawalLimit: 200, CreditLimit: 200},
+		},
+		balances: []domain.TranscationBalance{
+			{ID: "t1", AccountID: "a", Balance: -20},
+		},
+		stale: map[string]bool{"a": true},
+	}
+
+	discrepancies, err := Compare(ctx, p, state, 0)
+	require.NoError(t, err)
+	require.Equal(t, []Discrepancy{
+		{Kind: KindWithdrawalLimit, AccountID: "a", Projected: float(50), Current: float(0)},
+		{Kind: KindMissingRow, AccountID: "a", TranscationID: "t2"},
+	}, discrepancies)
+
+	result, err := Rebuild(ctx, p, discrepancies, state)
+	require.NoError(t, err)
+	require.Empty(t, result.Rebuilt)
+	require.Equal(t, []string{"a"}, result.Stale)
+	require.Equal(t, 0.0, state.limits[0].WithdrawalLimit)
+}
+
+func float(v float64) *float64 {
+	return &v
+}
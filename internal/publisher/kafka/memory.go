@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+var ErrBrokerClosed = errors.New("kafka broker closed")
+
+// MemoryBroker is an in-memory stand-in for a Kafka cluster. It implements
+// Writer, assigns partitions with the same balancer as NewWriter and keeps
+// every message so tests can assert on what was published.
+type MemoryBroker struct {
+	mu         sync.Mutex
+	partitions int
+	balancer   kafkago.Balancer
+	topics     map[string][][]kafkago.Message
+	closed     bool
+}
+
+func NewMemoryBroker(partitions int) *MemoryBroker {
+	if partitions <= 0 {
+		partitions = 1
+	}
+
+	return &MemoryBroker{
+		partitions: partitions,
+		balancer:   &kafkago.Murmur2Balancer{},
+		topics:     make(map[string][][]kafkago.Message),
+	}
+}
+
+// WriteMessages appends the messages to their topic partition, either all
+// messages are written or none
+func (b *MemoryBroker) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+
+	for _, msg := range msgs {
+		if msg.Topic == "" {
+			return kafkago.InvalidTopic
+		}
+	}
+
+	ids := make([]int, b.partitions)
+	for i := range ids {
+		ids[i] = i
+	}
+
+	now := time.Now().UTC()
+	for _, msg := range msgs {
+		partitions, ok := b.topics[msg.Topic]
+		if !ok {
+			partitions = make([][]kafkago.Message, b.partitions)
+			b.topics[msg.Topic] = partitions
+		}
+
+		msg.Partition = b.balancer.Balance(msg, ids...)
+		msg.Offset = int64(len(partitions[msg.Partition]))
+		msg.Time = now
+
+		partitions[msg.Partition] = append(partitions[msg.Partition], msg)
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	return nil
+}
+
+// Topics returns the names of all topics written to
+func (b *MemoryBroker) Topics() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topics := make([]string, 0, len(b.topics))
+	for topic := range b.topics {
+		topics = append(topics, topic)
+	}
+
+	return topics
+}
+
+// Messages returns every message of topic, ordered by partition and offset
+func (b *MemoryBroker) Messages(topic string) []kafkago.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msgs := make([]kafkago.Message, 0)
+	for _, partition := range b.topics[topic] {
+		msgs = append(msgs, partition...)
+	}
+
+	return msgs
+}
+
+// Partition returns the messages of a single topic partition in offset order
+func (b *MemoryBroker) Partition(topic string, partition int) []kafkago.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	partitions, ok := b.topics[topic]
+	if !ok || partition < 0 || partition >= len(partitions) {
+		return nil
+	}
+
+	msgs := make([]kafkago.Message, len(partitions[partition]))
+	copy(msgs, partitions[partition])
+
+	return msgs
+}
@@ -0,0 +1,124 @@
+/*
+package kafka, publish outbox events to Kafka topics.
+
+Every event type is written to its own topic, messages are keyed by account
+id so that all events of an account land on the same partition in order.
+*/
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+const (
+	HeaderEventID       = "event_id"
+	HeaderEventType     = "event_type"
+	HeaderSchemaVersion = "schema_version"
+)
+
+type (
+	// Writer is implemented by kafka-go's Writer and by MemoryBroker
+	Writer interface {
+		WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+		Close() error
+	}
+
+	Publisher struct {
+		writer      Writer
+		topicPrefix string
+	}
+
+	// message is the value written to Kafka, it is described by the schema
+	// files in internal/domain/schema
+	message struct {
+		ID            string          `json:"id"`
+		Type          string          `json:"type"`
+		SchemaVersion int             `json:"schema_version"`
+		Seq           int64           `json:"seq"`
+		AccountID     string          `json:"account_id"`
+		CreatedAt     time.Time       `json:"created_at"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+)
+
+// NewWriter returns a writer producing to the given brokers, messages are
+// partitioned with the same murmur2 hash as the Java client
+func NewWriter(brokers []string) *kafkago.Writer {
+	return &kafkago.Writer{
+		Addr:         kafkago.TCP(brokers...),
+		Balancer:     &kafkago.Murmur2Balancer{},
+		RequiredAcks: kafkago.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}
+}
+
+func NewPublisher(writer Writer, topicPrefix string) Publisher {
+	return Publisher{
+		writer:      writer,
+		topicPrefix: topicPrefix,
+	}
+}
+
+// Topic returns the topic events of eventType are written to
+func (p *Publisher) Topic(eventType string) string {
+	if p.topicPrefix == "" {
+		return eventType
+	}
+
+	return p.topicPrefix + "." + eventType
+}
+
+// Publish writes the events to Kafka, it is the Kafka Publisher of the
+// outbox relay
+func (p *Publisher) Publish(ctx context.Context, events []domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	msgs := make([]kafkago.Message, 0, len(events))
+	for _, event := range events {
+		version, ok := domain.EventSchemaVersions[event.Type]
+		if !ok {
+			return fmt.Errorf("no schema version for event type %s", event.Type)
+		}
+
+		value, err := json.Marshal(message{
+			ID:            event.ID,
+			Type:          event.Type,
+			SchemaVersion: version,
+			Seq:           event.Seq,
+			AccountID:     event.AccountID,
+			CreatedAt:     event.CreatedAt,
+			Payload:       event.Payload,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+
+		msgs = append(msgs, kafkago.Message{
+			Topic: p.Topic(event.Type),
+			Key:   []byte(event.AccountID),
+			Value: value,
+			Headers: []kafkago.Header{
+				{Key: HeaderEventID, Value: []byte(event.ID)},
+				{Key: HeaderEventType, Value: []byte(event.Type)},
+				{Key: HeaderSchemaVersion, Value: []byte(strconv.Itoa(version))},
+			},
+		})
+	}
+
+	if err := p.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to write messages to kafka: %w", err)
+	}
+
+	return nil
+}
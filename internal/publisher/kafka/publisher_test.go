@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+func TestPublish(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	broker := NewMemoryBroker(8)
+	publisher := NewPublisher(broker, "app-transcation")
+
+	accounts := []string{uuid.NewString(), uuid.NewString(), uuid.NewString()}
+	events := make([]domain.Event, 0)
+	for i := 0; i < 30; i++ {
+		events = append(events, domain.Event{
+			ID:        uuid.NewString(),
+			Seq:       int64(i + 1),
+			AccountID: accounts[i%len(accounts)],
+			Type:      domain.EventTranscationCreated,
+			Payload:   json.RawMessage(`{"amount":-10}`),
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+
+	require.NoError(t, publisher.Publish(ctx, events[:10]))
+	require.NoError(t, publisher.Publish(ctx, events[10:]))
+
+	topic := "app-transcation." + domain.EventTranscationCreated
+	require.Equal(t, []string{topic}, broker.Topics())
+
+	msgs := broker.Messages(topic)
+	require.Len(t, msgs, len(events))
+
+	partitionOf := make(map[string]int)
+	lastSeq := make(map[string]int64)
+	for _, msg := range msgs {
+		accountID := string(msg.Key)
+
+		// all events of an account share a partition and keep their order
+		if partition, ok := partitionOf[accountID]; ok {
+			require.Equal(t, partition, msg.Partition)
+		}
+		partitionOf[accountID] = msg.Partition
+
+		var value message
+		require.NoError(t, json.Unmarshal(msg.Value, &value))
+		require.Equal(t, accountID, value.AccountID)
+		require.Equal(t, domain.EventSchemaVersions[domain.EventTranscationCreated], value.SchemaVersion)
+		require.JSONEq(t, `{"amount":-10}`, string(value.Payload))
+		require.Greater(t, value.Seq, lastSeq[accountID])
+		lastSeq[accountID] = value.Seq
+
+		headers := make(map[string]string)
+		for _, header := range msg.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		require.Equal(t, value.ID, headers[HeaderEventID])
+		require.Equal(t, domain.EventTranscationCreated, headers[HeaderEventType])
+		require.Equal(t, strconv.Itoa(domain.EventSchemaVersions[domain.EventTranscationCreated]), headers[HeaderSchemaVersion])
+	}
+
+	require.Len(t, partitionOf, len(accounts))
+}
+
+func TestPublishUnknownEventType(t *testing.T) {
+	t.Parallel()
+
+	broker := NewMemoryBroker(1)
+	publisher := NewPublisher(broker, "")
+
+	err := publisher.Publish(context.Background(), []domain.Event{{ID: uuid.NewString(), Type: "unknown"}})
+	require.Error(t, err)
+	require.Empty(t, broker.Topics())
+}
+
+func TestPublishClosedBroker(t *testing.T) {
+	t.Parallel()
+
+	broker := NewMemoryBroker(1)
+	publisher := NewPublisher(broker, "")
+	require.NoError(t, broker.Close())
+
+	err := publisher.Publish(context.Background(), []domain.Event{{
+		ID:        uuid.NewString(),
+		AccountID: uuid.NewString(),
+		Type:      domain.EventTranscationCreated,
+		Payload:   json.RawMessage(`{}`),
+	}})
+	require.ErrorIs(t, err, ErrBrokerClosed)
+}
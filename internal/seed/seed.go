@@ -0,0 +1,283 @@
+// Package seed generates realistic account histories for load and demo
+// environments. Every transcation goes through the service, on an in memory
+// repo, so the histories hold the invariants of the service: limits are
+// checked and consumed, credits discharge the debts oldest first and the
+// events match the tables. The same config gives the same histories.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/madhurikadam/app-transcation/internal/database/memory"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/service"
+)
+
+// Config sizes the generated histories
+type Config struct {
+	Accounts int
+	// Months is how far back the histories go from End
+	Months int
+	// TranscationsPerMonth is the average number of transcations an account
+	// makes in a month
+	TranscationsPerMonth int
+	Seed                 int64
+	End                  time.Time
+}
+
+// Stats counts what was generated, Declined are the transcations the service
+// refused for exceeding a limit
+type Stats struct {
+	Accounts     int         `json:"accounts"`
+	Transcations int         `json:"transcations"`
+	Declined     int         `json:"declined"`
+	Events       int         `json:"events"`
+	ByOperation  map[int]int `json:"by_operation_type"`
+}
+
+// operation is an operation type along with how often it is made and the
+// range of its amounts
+type operation struct {
+	typeID   int
+	weight   int
+	min, max float64
+}
+
+// operations are purchases, installments, withdrawals and credit vouchers
+var operations = []operation{
+	{typeID: 1, weight: 55, min: 5, max: 150},
+	{typeID: 2, weight: 12, min: 100, max: 800},
+	{typeID: 3, weight: 13, min: 20, max: 300},
+	{typeID: 4, weight: 20, min: 50, max: 600},
+}
+
+// generator holds the randomness and the clock of a run
+type generator struct {
+	cfg Config
+	rnd *rand.Rand
+	// ids is the source of the ids of the accounts, transcations and events
+	ids *rand.Rand
+	now time.Time
+}
+
+// Run generates the histories one account after the other and hands each one
+// to fn. The ids are drawn from the seed too.
+func Run(ctx context.Context, cfg Config, fn func(memory.Snapshot) error) (Stats, error) {
+	if cfg.Accounts <= 0 || cfg.Months <= 0 || cfg.TranscationsPerMonth <= 0 {
+		return Stats{}, fmt.Errorf("invalid seed config: %d accounts, %d months, %d transcations per month",
+			cfg.Accounts, cfg.Months, cfg.TranscationsPerMonth)
+	}
+
+	if cfg.End.IsZero() {
+		cfg.End = time.Now().UTC()
+	}
+
+	g := &generator{
+		cfg: cfg,
+		rnd: rand.New(rand.NewSource(cfg.Seed + 1)),
+		ids: rand.New(rand.NewSource(cfg.Seed)),
+	}
+	stats := Stats{ByOperation: make(map[int]int)}
+	for i := 0; i < cfg.Accounts; i++ {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		// a repo per account keeps every write cheap, histories don't span
+		// accounts
+		repo := memory.NewRepo(memory.WithIDs(g.newID))
+		if err := g.history(ctx, repo, &stats); err != nil {
+			return stats, err
+		}
+
+		snapshot := repo.Snapshot()
+		stats.Accounts++
+		stats.Events += len(snapshot.Events)
+
+		if err := fn(snapshot); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// history opens an account before the start of the history and makes its
+// transcations month after month. The limits of the account are set back at
+// the start of every month, the way an operator renews them.
+func (g *generator) history(ctx context.Context, repo *memory.Repo, stats *Stats) error {
+	svc := service.New(repo, service.WithIDs(g.newID), service.WithClock(func() time.Time {
+		return g.now
+	}))
+
+	start := g.cfg.End.AddDate(0, -g.cfg.Months, 0)
+	g.now = start.Add(-time.Duration(g.rnd.Int63n(int64(30 * 24 * time.Hour)))).Truncate(time.Microsecond)
+
+	account, err := svc.CreateAccount(ctx, DocumentNumber(g.rnd))
+	if err != nil {
+		return err
+	}
+
+	limits := domain.AccountLimitsReq{
+		WithdrawalLimit: floatPtr(g.amount(2000, 20000)),
+		CreditLimit:     floatPtr(g.amount(1000, 10000)),
+	}
+
+	for month := 0; month < g.cfg.Months; month++ {
+		from := start.AddDate(0, month, 0)
+
+		g.now = from
+		if _, err := svc.UpdateAccountLimits(ctx, account.ID, limits); err != nil {
+			return err
+		}
+
+		for _, at := range g.times(from, from.AddDate(0, 1, 0)) {
+			g.now = at
+
+			op := g.operation()
+			_, err := svc.CreateTranscation(ctx, domain.Transcation{
+				AccountID:       account.ID,
+				OperationTypeID: op.typeID,
+				Amount:          g.amount(op.min, op.max),
+			})
+			switch {
+			case errors.Is(err, domain.ErrExceedWithdrawalLimit), errors.Is(err, domain.ErrExceedCreditLimit):
+				stats.Declined++
+			case err != nil:
+				return err
+			default:
+				stats.Transcations++
+				stats.ByOperation[op.typeID]++
+			}
+		}
+	}
+
+	return nil
+}
+
+// newID returns a uuid drawn from the ids of the run, the service and the repo
+// of a history share them
+func (g *generator) newID() string {
+	return uuid.Must(uuid.NewRandomFromReader(g.ids)).String()
+}
+
+// times returns when the transcations of the month between from and to are
+// made, in order. Some months are busier than others.
+func (g *generator) times(from, to time.Time) []time.Time {
+	n := g.cfg.TranscationsPerMonth/2 + g.rnd.Intn(g.cfg.TranscationsPerMonth+1)
+	span := to.Sub(from)
+
+	times := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		// transcations never share the time the limits are renewed at
+		times = append(times, from.Add(time.Duration(1+g.rnd.Int63n(int64(span)-1))).Truncate(time.Microsecond))
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	return times
+}
+
+func (g *generator) operation() operation {
+	total := 0
+	for _, op := range operations {
+		total += op.weight
+	}
+
+	n := g.rnd.Intn(total)
+	for _, op := range operations {
+		if n < op.weight {
+			return op
+		}
+		n -= op.weight
+	}
+
+	return operations[len(operations)-1]
+}
+
+// amount returns an amount in cents between min and max, small amounts are
+// more likely
+func (g *generator) amount(min, max float64) float64 {
+	f := g.rnd.Float64()
+	return math.Round((min+(max-min)*f*f)*100) / 100
+}
+
+// DocumentNumber returns a random valid CPF, nine digits followed by their two
+// check digits
+func DocumentNumber(rnd *rand.Rand) string {
+	digits := make([]int, 11)
+	for {
+		for i := 0; i < 9; i++ {
+			digits[i] = rnd.Intn(10)
+		}
+
+		// repeated digits pass the check but aren't valid
+		if !repeated(digits[:9]) {
+			break
+		}
+	}
+
+	digits[9] = checkDigit(digits[:9])
+	digits[10] = checkDigit(digits[:10])
+
+	number := make([]byte, 11)
+	for i, d := range digits {
+		number[i] = byte('0' + d)
+	}
+
+	return string(number)
+}
+
+// ValidDocumentNumber reports whether number is a valid CPF
+func ValidDocumentNumber(number string) bool {
+	if len(number) != 11 {
+		return false
+	}
+
+	digits := make([]int, 11)
+	for i, c := range number {
+		if c < '0' || c > '9' {
+			return false
+		}
+		digits[i] = int(c - '0')
+	}
+
+	return !repeated(digits) && digits[9] == checkDigit(digits[:9]) && digits[10] == checkDigit(digits[:10])
+}
+
+func checkDigit(digits []int) int {
+	sum := 0
+	for i, d := range digits {
+		sum += d * (len(digits) + 1 - i)
+	}
+
+	if r := sum * 10 % 11; r < 10 {
+		return r
+	}
+
+	return 0
+}
+
+func repeated(digits []int) bool {
+	for _, d := range digits[1:] {
+		if d != digits[0] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
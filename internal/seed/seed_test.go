@@ -0,0 +1,158 @@
+package seed_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/internal/database/memory"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/seed"
+)
+
+func generate(t *testing.T, cfg seed.Config) (seed.Stats, []memory.Snapshot) {
+	t.Helper()
+
+	snapshots := make([]memory.Snapshot, 0, cfg.Accounts)
+	stats, err := seed.Run(context.Background(), cfg, func(s memory.Snapshot) error {
+		snapshots = append(snapshots, s)
+		return nil
+	})
+	require.NoError(t, err)
+
+	return stats, snapshots
+}
+
+func TestRun(t *testing.T) {
+	cfg := seed.Config{
+		Accounts:             5,
+		Months:               6,
+		TranscationsPerMonth: 20,
+		Seed:                 42,
+		End:                  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	stats, snapshots := generate(t, cfg)
+	require.Equal(t, cfg.Accounts, stats.Accounts)
+	require.Len(t, snapshots, cfg.Accounts)
+	require.Positive(t, stats.Transcations)
+	for _, op := range []int{1, 2, 3, 4} {
+		require.Positive(t, stats.ByOperation[op], "operation type %d is generated", op)
+	}
+
+	start := cfg.End.AddDate(0, -cfg.Months, 0)
+	for _, s := range snapshots {
+		require.Len(t, s.Accounts, 1)
+		account := s.Accounts[0]
+		require.True(t, seed.ValidDocumentNumber(account.DocumentNumber), account.DocumentNumber)
+		require.GreaterOrEqual(t, account.WithdrawalLimit, 0.0)
+		require.GreaterOrEqual(t, account.CreaditLimit, 0.0)
+
+		for _, tx := range s.Transcations {
+			require.Equal(t, account.ID, tx.AccountID)
+			require.False(t, tx.EventAt.Before(start))
+			require.True(t, tx.EventAt.Before(cfg.End))
+
+			if tx.IsDebit() {
+				require.Negative(t, tx.Amount)
+				require.LessOrEqual(t, tx.Amount, tx.Balance)
+				require.LessOrEqual(t, tx.Balance, 0.0)
+			} else {
+				require.Positive(t, tx.Amount)
+				require.GreaterOrEqual(t, tx.Balance, 0.0)
+			}
+		}
+
+		for i, event := range s.Events {
+			require.Equal(t, int64(i+1), event.Seq)
+		}
+		require.Equal(t, domain.EventAccountCreated, s.Events[0].Type)
+	}
+
+	// the same config gives the same histories
+	again, snapshotsAgain := generate(t, cfg)
+	require.Equal(t, stats, again)
+	require.Equal(t, snapshots, snapshotsAgain)
+
+	cfg.Seed++
+	_, other := generate(t, cfg)
+	require.NotEqual(t, snapshots[0].Accounts[0].ID, other[0].Accounts[0].ID)
+}
+
+func TestRunAlongsideOtherIDs(t *testing.T) {
+	cfg := seed.Config{
+		Accounts:             2,
+		Months:               2,
+		TranscationsPerMonth: 10,
+		Seed:                 7,
+		End:                  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	_, snapshots := generate(t, cfg)
+
+	// uuids drawn elsewhere neither change the histories nor come from the
+	// seed
+	done := make(chan struct{})
+	drawn := make(chan []string)
+	go func() {
+		ids := make([]string, 0)
+		for {
+			select {
+			case <-done:
+				drawn <- ids
+				return
+			default:
+				ids = append(ids, uuid.NewString())
+			}
+		}
+	}()
+
+	_, again := generate(t, cfg)
+	close(done)
+	require.Equal(t, snapshots, again)
+
+	seeded := make(map[string]bool)
+	for _, s := range again {
+		seeded[s.Accounts[0].ID] = true
+		for _, tx := range s.Transcations {
+			seeded[tx.ID] = true
+		}
+	}
+
+	for _, id := range <-drawn {
+		require.False(t, seeded[id], id)
+	}
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	_, err := seed.Run(context.Background(), seed.Config{Months: 1, TranscationsPerMonth: 1}, func(memory.Snapshot) error {
+		return nil
+	})
+	require.Error(t, err)
+}
+
+func TestDocumentNumber(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		require.True(t, seed.ValidDocumentNumber(seed.DocumentNumber(rnd)))
+	}
+
+	tests := []struct {
+		number string
+		valid  bool
+	}{
+		{number: "52998224725", valid: true},
+		{number: "52998224724", valid: false},
+		{number: "11111111111", valid: false},
+		{number: "5299822472", valid: false},
+		{number: "5299822472a", valid: false},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.valid, seed.ValidDocumentNumber(tt.number), tt.number)
+	}
+}
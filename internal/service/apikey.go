@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+type (
+	APIKeyRepo interface {
+		CreateAPIKey(ctx context.Context, key domain.APIKey) error
+		RotateAPIKey(ctx context.Context, key domain.APIKey, oldExpiresAt time.Time) error
+		GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error)
+		ListAPIKeys(ctx context.Context) ([]domain.APIKey, error)
+		RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+		TouchAPIKey(ctx context.Context, id string, at time.Time, interval time.Duration) error
+	}
+
+	// APIKeyService issues API keys and authenticates the callers using them,
+	// it is the authenticator of the ApiKey authorization scheme
+	APIKeyService struct {
+		repo       APIKeyRepo
+		authorizer Authorizer
+		limiters   *keyLimiters
+		now        func() time.Time
+	}
+
+	// keyLimiters holds the token bucket of every rate limited key
+	keyLimiters struct {
+		mu       sync.Mutex
+		limiters map[string]*rate.Limiter
+	}
+)
+
+var (
+	ErrInvalidAPIKeyID   = fmt.Errorf("invalid api key id")
+	ErrInvalidAPIKeyName = fmt.Errorf("invalid api key name")
+	ErrInvalidSubject    = fmt.Errorf("invalid subject")
+	ErrInvalidRole       = fmt.Errorf("invalid role")
+	ErrInvalidScope      = fmt.Errorf("invalid scope")
+	ErrInvalidRateLimit  = fmt.Errorf("invalid rate limit")
+	ErrInvalidExpiry     = fmt.Errorf("invalid expiry")
+	ErrInvalidOverlap    = fmt.Errorf("invalid rotation overlap")
+)
+
+const (
+	// apiKeyTouchInterval is how often last_used_at of a busy key is written
+	apiKeyTouchInterval = time.Minute
+
+	defaultRotationOverlap = 24 * time.Hour
+	maxRotationOverlap     = 30 * 24 * time.Hour
+)
+
+func NewAPIKeyService(repo APIKeyRepo, opts ...Option) APIKeyService {
+	o := newOptions(opts)
+
+	return APIKeyService{
+		repo:       repo,
+		authorizer: o.authorizer,
+		limiters: &keyLimiters{
+			limiters: make(map[string]*rate.Limiter),
+		},
+		now: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+// Issue creates an API key, the key itself is only part of the response
+func (a *APIKeyService) Issue(ctx context.Context, req domain.APIKeyReq) (*domain.IssuedAPIKey, error) {
+	if err := a.validate(req); err != nil {
+		return nil, err
+	}
+
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	issued, err := a.newKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.repo.CreateAPIKey(ctx, issued.APIKey); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("failed to create api key")
+		return nil, err
+	}
+
+	return issued, nil
+}
+
+// Rotate issues a key replacing the given one with the same grants and expiry,
+// unless req sets another, the replaced key expires once the overlap has passed
+func (a *APIKeyService) Rotate(ctx context.Context, id string, req domain.APIKeyRotationReq) (*domain.IssuedAPIKey, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidAPIKeyID
+	}
+
+	overlap := defaultRotationOverlap
+	if req.Overlap != "" {
+		var err error
+		if overlap, err = time.ParseDuration(req.Overlap); err != nil || overlap < 0 || overlap > maxRotationOverlap {
+			return nil, ErrInvalidOverlap
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(a.now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	old, err := a.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.now()
+	if !old.Valid(now) {
+		return nil, domain.ErrNotFound
+	}
+
+	expiresAt := old.ExpiresAt
+	if req.ExpiresAt != nil {
+		expiresAt = req.ExpiresAt
+	}
+
+	issued, err := a.newKey(domain.APIKeyReq{
+		Name:      old.Name,
+		Subject:   old.Subject,
+		Roles:     old.Roles,
+		Scopes:    old.Scopes,
+		RateLimit: old.RateLimit,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	issued.RotatedFrom = &old.ID
+
+	if err := a.repo.RotateAPIKey(ctx, issued.APIKey, now.Add(overlap)); err != nil {
+		logging.FromContext(ctx).WithField("api_key_id", id).WithError(err).Error("failed to rotate api key")
+		return nil, err
+	}
+
+	return issued, nil
+}
+
+// List returns every key, keys are never part of the response
+func (a *APIKeyService) List(ctx context.Context) ([]domain.APIKey, error) {
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	return a.repo.ListAPIKeys(ctx)
+}
+
+func (a *APIKeyService) Get(ctx context.Context, id string) (*domain.APIKey, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidAPIKeyID
+	}
+
+	if err := a.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	return a.repo.GetAPIKey(ctx, id)
+}
+
+// Revoke invalidates the key right away
+func (a *APIKeyService) Revoke(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidAPIKeyID
+	}
+
+	if err := a.authorize(ctx); err != nil {
+		return err
+	}
+
+	return a.repo.RevokeAPIKey(ctx, id, a.now())
+}
+
+func (a *APIKeyService) Scheme() string {
+	return auth.SchemeAPIKey
+}
+
+// Authenticate returns the principal of a valid key, keys over their rate
+// limit are refused with an auth.RateLimitError
+func (a *APIKeyService) Authenticate(ctx context.Context, credentials string) (*auth.Principal, error) {
+	id, err := auth.ParseAPIKey(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	key, err := a.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, auth.ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	now := a.now()
+	if !auth.VerifyAPIKey(credentials, key.Hash) || !key.Valid(now) {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	if delay := a.limiters.reserve(key.ID, key.RateLimit, now); delay > 0 {
+		return nil, &auth.RateLimitError{RetryAfter: delay}
+	}
+
+	if err := a.repo.TouchAPIKey(ctx, key.ID, now, apiKeyTouchInterval); err != nil {
+		logging.FromContext(ctx).WithField("api_key_id", key.ID).WithError(err).Warn("failed to record api key use")
+	}
+
+	return &auth.Principal{
+		Subject: key.Subject,
+		Roles:   key.Roles,
+		Scopes:  key.Scopes,
+		Method:  auth.SchemeAPIKey,
+	}, nil
+}
+
+func (a *APIKeyService) validate(req domain.APIKeyReq) error {
+	if req.Name == "" {
+		return ErrInvalidAPIKeyName
+	}
+
+	if req.Subject == "" {
+		return ErrInvalidSubject
+	}
+
+	for _, role := range req.Roles {
+		if !validRole(role) {
+			return fmt.Errorf("%w: %s", ErrInvalidRole, role)
+		}
+	}
+
+	if len(req.Scopes) == 0 {
+		return ErrInvalidScope
+	}
+
+	for _, scope := range req.Scopes {
+		if !validScope(scope) {
+			return fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	if req.RateLimit < 0 {
+		return ErrInvalidRateLimit
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(a.now()) {
+		return ErrInvalidExpiry
+	}
+
+	return nil
+}
+
+func (a *APIKeyService) newKey(req domain.APIKeyReq) (*domain.IssuedAPIKey, error) {
+	id := uuid.NewString()
+
+	key, hash, err := auth.NewAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := req.Roles
+	if len(roles) == 0 {
+		roles = []string{policy.RoleMerchant}
+	}
+
+	return &domain.IssuedAPIKey{
+		APIKey: domain.APIKey{
+			ID:        id,
+			Name:      req.Name,
+			Subject:   req.Subject,
+			Roles:     roles,
+			Scopes:    req.Scopes,
+			RateLimit: req.RateLimit,
+			Hash:      hash,
+			ExpiresAt: req.ExpiresAt,
+			CreatedAt: a.now(),
+		},
+		Key: key,
+	}, nil
+}
+
+func (a *APIKeyService) authorize(ctx context.Context) error {
+	if a.authorizer == nil {
+		return nil
+	}
+
+	return a.authorizer.Authorize(ctx, policy.ActionManageAPIKeys, policy.Resource{})
+}
+
+// reserve takes a token from the bucket of the key and returns how long the
+// caller has to wait when the bucket is empty, limit is per minute
+func (k *keyLimiters) reserve(id string, limit int, now time.Time) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+
+	k.mu.Lock()
+	limiter, ok := k.limiters[id]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(float64(limit)/60), limit)
+		k.limiters[id] = limiter
+	}
+	k.mu.Unlock()
+
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay
+	}
+
+	return 0
+}
+
+func validRole(role string) bool {
+	for _, r := range policy.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validScope(scope string) bool {
+	for _, action := range policy.Actions {
+		if string(action) == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/internal/service/mocks"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type APIKeyTestSuite struct {
+	suite.Suite
+
+	repo *mocks.MockAPIKeyRepo
+	now  time.Time
+
+	svc APIKeyService
+}
+
+func TestAPIKey(t *testing.T) {
+	t.Parallel()
+
+	suite.Run(t, new(APIKeyTestSuite))
+}
+
+func (s *APIKeyTestSuite) SetupTest() {
+	s.repo = mocks.NewMockAPIKeyRepo(gomock.NewController(s.T()))
+	s.now = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	s.svc = NewAPIKeyService(s.repo)
+	s.svc.now = func() time.Time {
+		return s.now
+	}
+}
+
+func (s *APIKeyTestSuite) TestIssue() {
+	ctx := context.Background()
+	past := s.now.Add(-time.Hour)
+
+	valid := domain.APIKeyReq{
+		Name:      "checkout",
+		Subject:   "merchant-1",
+		Scopes:    []string{string(policy.ActionCreateTranscation)},
+		RateLimit: 60,
+	}
+
+	tests := []struct {
+		name     string
+		mocks    func()
+		input    func(req domain.APIKeyReq) domain.APIKeyReq
+		expError error
+	}{
+		{
+			name:     "missing name",
+			mocks:    func() {},
+			input:    func(req domain.APIKeyReq) domain.APIKeyReq { req.Name = ""; return req },
+			expError: ErrInvalidAPIKeyName,
+		},
+		{
+			name:     "missing subject",
+			mocks:    func() {},
+			input:    func(req domain.APIKeyReq) domain.APIKeyReq { req.Subject = ""; return req },
+			expError: ErrInvalidSubject,
+		},
+		{
+			name:     "unknown role",
+			mocks:    func() {},
+			input:    func(req domain.APIKeyReq) domain.APIKeyReq { req.Roles = []string{"admin"}; return req },
+			expError: ErrInvalidRole,
+		},
+		{
+			name:     "unknown scope",
+			mocks:    func() {},
+			input:    func(req domain.APIKeyReq) domain.APIKeyReq { req.Scopes = []string{"everything"}; return req },
+			expError: ErrInvalidScope,
+		},
+		{
+			name:     "negative rate limit",
+			mocks:    func() {},
+			input:    func(req domain.APIKeyReq) domain.APIKeyReq { req.RateLimit = -1; return req },
+			expError: ErrInvalidRateLimit,
+		},
+		{
+			name:     "expired",
+			mocks:    func() {},
+			input:    func(req domain.APIKeyReq) domain.APIKeyReq { req.ExpiresAt = &past; return req },
+			expError: ErrInvalidExpiry,
+		},
+		{
+			name: "failed to create key",
+			mocks: func() {
+				s.repo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Return(errTestFoo)
+			},
+			input:    func(req domain.APIKeyReq) domain.APIKeyReq { return req },
+			expError: errTestFoo,
+		},
+		{
+			name: "issue key with success",
+			mocks: func() {
+				s.repo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			input: func(req domain.APIKeyReq) domain.APIKeyReq { return req },
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.mocks()
+
+			issued, err := s.svc.Issue(ctx, tt.input(valid))
+			if tt.expError != nil {
+				s.Require().ErrorIs(err, tt.expError)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal([]string{policy.RoleMerchant}, issued.Roles)
+			s.True(auth.VerifyAPIKey(issued.Key, issued.Hash))
+
+			id, err := auth.ParseAPIKey(issued.Key)
+			s.Require().NoError(err)
+			s.Equal(issued.ID, id)
+		})
+	}
+}
+
+func (s *APIKeyTestSuite) TestAuthenticate() {
+	ctx := context.Background()
+	id := uuid.NewString()
+	key, hash, err := auth.NewAPIKey(id)
+	s.Require().NoError(err)
+
+	expired := s.now.Add(-time.Minute)
+	stored := domain.APIKey{
+		ID:      id,
+		Subject: "merchant-1",
+		Roles:   []string{policy.RoleMerchant},
+		Scopes:  []string{string(policy.ActionCreateTranscation)},
+		Hash:    hash,
+	}
+
+	tests := []struct {
+		name     string
+		mocks    func()
+		key      string
+		expError error
+	}{
+		{
+			name:     "malformed key",
+			mocks:    func() {},
+			key:      "not-a-key",
+			expError: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "unknown key",
+			mocks: func() {
+				s.repo.EXPECT().GetAPIKey(gomock.Any(), id).Return(nil, domain.ErrNotFound)
+			},
+			key:      key,
+			expError: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "wrong secret",
+			mocks: func() {
+				s.repo.EXPECT().GetAPIKey(gomock.Any(), id).Return(&stored, nil)
+			},
+			key:      key + "x",
+			expError: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "expired key",
+			mocks: func() {
+				expiredKey := stored
+				expiredKey.ExpiresAt = &expired
+				s.repo.EXPECT().GetAPIKey(gomock.Any(), id).Return(&expiredKey, nil)
+			},
+			key:      key,
+			expError: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "revoked key",
+			mocks: func() {
+				revokedKey := stored
+				revokedKey.RevokedAt = &expired
+				s.repo.EXPECT().GetAPIKey(gomock.Any(), id).Return(&revokedKey, nil)
+			},
+			key:      key,
+			expError: auth.ErrInvalidCredentials,
+		},
+		{
+			name: "valid key",
+			mocks: func() {
+				s.repo.EXPECT().GetAPIKey(gomock.Any(), id).Return(&stored, nil)
+				s.repo.EXPECT().TouchAPIKey(gomock.Any(), id, s.now, apiKeyTouchInterval).Return(nil)
+			},
+			key: key,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.mocks()
+
+			principal, err := s.svc.Authenticate(ctx, tt.key)
+			if tt.expError != nil {
+				s.Require().ErrorIs(err, tt.expError)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(&auth.Principal{
+				Subject: "merchant-1",
+				Roles:   []string{policy.RoleMerchant},
+				Scopes:  []string{string(policy.ActionCreateTranscation)},
+				Method:  auth.SchemeAPIKey,
+			}, principal)
+		})
+	}
+}
+
+func (s *APIKeyTestSuite) TestAuthenticateRateLimit() {
+	ctx := context.Background()
+	id := uuid.NewString()
+	key, hash, err := auth.NewAPIKey(id)
+	s.Require().NoError(err)
+
+	stored := domain.APIKey{ID: id, Subject: "merchant-1", Hash: hash, RateLimit: 2}
+	s.repo.EXPECT().GetAPIKey(gomock.Any(), id).Return(&stored, nil).Times(4)
+	s.repo.EXPECT().TouchAPIKey(gomock.Any(), id, gomock.Any(), apiKeyTouchInterval).Return(nil).Times(3)
+
+	for i := 0; i < 2; i++ {
+		_, err := s.svc.Authenticate(ctx, key)
+		s.Require().NoError(err)
+	}
+
+	_, err = s.svc.Authenticate(ctx, key)
+	var rateLimitErr *auth.RateLimitError
+	s.Require().ErrorAs(err, &rateLimitErr)
+	s.Equal(30*time.Second, rateLimitErr.RetryAfter)
+
+	// the bucket refills at the rate of the limit
+	s.now = s.now.Add(30 * time.Second)
+	_, err = s.svc.Authenticate(ctx, key)
+	s.Require().NoError(err)
+}
+
+func (s *APIKeyTestSuite) TestRotate() {
+	ctx := context.Background()
+	expiresAt := s.now.Add(90 * 24 * time.Hour)
+	old := domain.APIKey{
+		ID:        uuid.NewString(),
+		Name:      "checkout",
+		Subject:   "merchant-1",
+		Roles:     []string{policy.RoleMerchant},
+		Scopes:    []string{string(policy.ActionCreateTranscation)},
+		RateLimit: 60,
+		ExpiresAt: &expiresAt,
+	}
+
+	_, err := s.svc.Rotate(ctx, old.ID, domain.APIKeyRotationReq{Overlap: "forever"})
+	s.Require().ErrorIs(err, ErrInvalidOverlap)
+
+	past := s.now.Add(-time.Minute)
+	_, err = s.svc.Rotate(ctx, old.ID, domain.APIKeyRotationReq{ExpiresAt: &past})
+	s.Require().ErrorIs(err, ErrInvalidExpiry)
+
+	s.repo.EXPECT().GetAPIKey(gomock.Any(), old.ID).Return(&old, nil)
+	s.repo.EXPECT().RotateAPIKey(gomock.Any(), gomock.Any(), s.now.Add(time.Hour)).
+		DoAndReturn(func(_ context.Context, key domain.APIKey, _ time.Time) error {
+			s.Equal(old.ID, *key.RotatedFrom)
+			s.Equal(old.Scopes, key.Scopes)
+			s.Equal(old.RateLimit, key.RateLimit)
+			s.Equal(old.ExpiresAt, key.ExpiresAt)
+			s.NotEqual(old.ID, key.ID)
+
+			return nil
+		})
+
+	issued, err := s.svc.Rotate(ctx, old.ID, domain.APIKeyRotationReq{Overlap: "1h"})
+	s.Require().NoError(err)
+	s.True(auth.VerifyAPIKey(issued.Key, issued.Hash))
+
+	// the new key may be given an expiry of its own
+	renewed := s.now.Add(180 * 24 * time.Hour)
+	s.repo.EXPECT().GetAPIKey(gomock.Any(), old.ID).Return(&old, nil)
+	s.repo.EXPECT().RotateAPIKey(gomock.Any(), gomock.Any(), s.now.Add(defaultRotationOverlap)).
+		DoAndReturn(func(_ context.Context, key domain.APIKey, _ time.Time) error {
+			s.Equal(&renewed, key.ExpiresAt)
+
+			return nil
+		})
+
+	_, err = s.svc.Rotate(ctx, old.ID, domain.APIKeyRotationReq{ExpiresAt: &renewed})
+	s.Require().NoError(err)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+// eventPageSize is how many events StreamAccountEvents reads at once
+const eventPageSize = 100
+
+// EventHub wakes up the streams following an account whenever new events are
+// written for it. A wake up carries no data, streams read the events from the
+// repo, so a missed or duplicated wake up is harmless.
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel signalled on every Notify for the account and a
+// func that must be called once the caller stops listening
+func (h *EventHub) Subscribe(accountID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[accountID]; !ok {
+		h.subscribers[accountID] = make(map[chan struct{}]struct{})
+	}
+	h.subscribers[accountID][ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.subscribers[accountID], ch)
+		if len(h.subscribers[accountID]) == 0 {
+			delete(h.subscribers, accountID)
+		}
+	}
+}
+
+// Notify wakes up the subscribers of the account without blocking
+func (h *EventHub) Notify(accountID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[accountID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// NotifyAccountEvent tells the streams of the account that new events were
+// written, it is fed by the postgres listener
+func (t *TranscationService) NotifyAccountEvent(accountID string) {
+	t.hub.Notify(accountID)
+}
+
+// StreamAccountEvents calls fn with every event of the account with a sequence
+// number greater than afterSeq, or written after the call when afterSeq is
+// negative, until ctx is cancelled or fn fails
+func (t *TranscationService) StreamAccountEvents(ctx context.Context, accountID string, afterSeq int64, fn func(domain.Event) error) (err error) {
+	ctx, span := startSpan(ctx, "TranscationService.StreamAccountEvents")
+	defer endSpan(span, &err)
+
+	if accountID == "" {
+		return ErrInvalidAccountID
+	}
+
+	if _, err := t.authorizedAccount(ctx, policy.ActionReadEvents, accountID, policy.Resource{}); err != nil {
+		return err
+	}
+
+	wakeup, unsubscribe := t.hub.Subscribe(accountID)
+	defer unsubscribe()
+
+	if afterSeq < 0 {
+		seq, err := t.repo.GetLastEventSeq(ctx, accountID)
+		if err != nil {
+			return err
+		}
+
+		afterSeq = seq
+	}
+
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		events, err := t.repo.ListAccountEvents(ctx, accountID, afterSeq, eventPageSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			logging.FromContext(ctx).WithField("account_id", accountID).WithError(err).Error("failed to list account events")
+			return err
+		}
+
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+
+			afterSeq = event.Seq
+		}
+
+		if len(events) == eventPageSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wakeup:
+		case <-ticker.C:
+		}
+	}
+}
@@ -7,11 +7,135 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	domain "github.com/madhurikadam/app-transcation/internal/domain"
+	policy "github.com/madhurikadam/app-transcation/internal/policy"
 )
 
+// MockFraudChecker is a mock of FraudChecker interface.
+type MockFraudChecker struct {
+	ctrl     *gomock.Controller
+	recorder *MockFraudCheckerMockRecorder
+}
+
+// MockFraudCheckerMockRecorder is the mock recorder for MockFraudChecker.
+type MockFraudCheckerMockRecorder struct {
+	mock *MockFraudChecker
+}
+
+// NewMockFraudChecker creates a new mock instance.
+func NewMockFraudChecker(ctrl *gomock.Controller) *MockFraudChecker {
+	mock := &MockFraudChecker{ctrl: ctrl}
+	mock.recorder = &MockFraudCheckerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFraudChecker) EXPECT() *MockFraudCheckerMockRecorder {
+	return m.recorder
+}
+
+// Evaluate mocks base method.
+func (m *MockFraudChecker) Evaluate(ctx context.Context, account domain.Account, transcation domain.Transcation) (*domain.FraudDecision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Evaluate", ctx, account, transcation)
+	ret0, _ := ret[0].(*domain.FraudDecision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Evaluate indicates an expected call of Evaluate.
+func (mr *MockFraudCheckerMockRecorder) Evaluate(ctx, account, transcation interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evaluate", reflect.TypeOf((*MockFraudChecker)(nil).Evaluate), ctx, account, transcation)
+}
+
+// MockAuthorizer is a mock of Authorizer interface.
+type MockAuthorizer struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthorizerMockRecorder
+}
+
+// MockAuthorizerMockRecorder is the mock recorder for MockAuthorizer.
+type MockAuthorizerMockRecorder struct {
+	mock *MockAuthorizer
+}
+
+// NewMockAuthorizer creates a new mock instance.
+func NewMockAuthorizer(ctrl *gomock.Controller) *MockAuthorizer {
+	mock := &MockAuthorizer{ctrl: ctrl}
+	mock.recorder = &MockAuthorizerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthorizer) EXPECT() *MockAuthorizerMockRecorder {
+	return m.recorder
+}
+
+// Authorize mocks base method.
+func (m *MockAuthorizer) Authorize(ctx context.Context, action policy.Action, resource policy.Resource) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authorize", ctx, action, resource)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Authorize indicates an expected call of Authorize.
+func (mr *MockAuthorizerMockRecorder) Authorize(ctx, action, resource interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockAuthorizer)(nil).Authorize), ctx, action, resource)
+}
+
+// MockMetrics is a mock of Metrics interface.
+type MockMetrics struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricsMockRecorder
+}
+
+// MockMetricsMockRecorder is the mock recorder for MockMetrics.
+type MockMetricsMockRecorder struct {
+	mock *MockMetrics
+}
+
+// NewMockMetrics creates a new mock instance.
+func NewMockMetrics(ctrl *gomock.Controller) *MockMetrics {
+	mock := &MockMetrics{ctrl: ctrl}
+	mock.recorder = &MockMetricsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetrics) EXPECT() *MockMetricsMockRecorder {
+	return m.recorder
+}
+
+// TranscationCreated mocks base method.
+func (m *MockMetrics) TranscationCreated(transcation domain.Transcation) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "TranscationCreated", transcation)
+}
+
+// TranscationCreated indicates an expected call of TranscationCreated.
+func (mr *MockMetricsMockRecorder) TranscationCreated(transcation interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TranscationCreated", reflect.TypeOf((*MockMetrics)(nil).TranscationCreated), transcation)
+}
+
+// TranscationDeclined mocks base method.
+func (m *MockMetrics) TranscationDeclined(operationTypeID int, reason string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "TranscationDeclined", operationTypeID, reason)
+}
+
+// TranscationDeclined indicates an expected call of TranscationDeclined.
+func (mr *MockMetricsMockRecorder) TranscationDeclined(operationTypeID, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TranscationDeclined", reflect.TypeOf((*MockMetrics)(nil).TranscationDeclined), operationTypeID, reason)
+}
+
 // MockRepo is a mock of Repo interface.
 type MockRepo struct {
 	ctrl     *gomock.Controller
@@ -35,6 +159,20 @@ func (m *MockRepo) EXPECT() *MockRepoMockRecorder {
 	return m.recorder
 }
 
+// ApproveReview mocks base method.
+func (m *MockRepo) ApproveReview(ctx context.Context, review domain.Review, dbTxList []domain.DebitTx) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveReview", ctx, review, dbTxList)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApproveReview indicates an expected call of ApproveReview.
+func (mr *MockRepoMockRecorder) ApproveReview(ctx, review, dbTxList interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveReview", reflect.TypeOf((*MockRepo)(nil).ApproveReview), ctx, review, dbTxList)
+}
+
 // CreateAccount mocks base method.
 func (m *MockRepo) CreateAccount(ctx context.Context, account domain.Account) error {
 	m.ctrl.T.Helper()
@@ -64,17 +202,17 @@ func (mr *MockRepoMockRecorder) CreateCreditTranscation(ctx, transcation, dbTxLi
 }
 
 // CreateDebitTranscation mocks base method.
-func (m *MockRepo) CreateDebitTranscation(ctx context.Context, transcation domain.Transcation) error {
+func (m *MockRepo) CreateDebitTranscation(ctx context.Context, transcation domain.Transcation, velocity domain.VelocityLimits) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateDebitTranscation", ctx, transcation)
+	ret := m.ctrl.Call(m, "CreateDebitTranscation", ctx, transcation, velocity)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateDebitTranscation indicates an expected call of CreateDebitTranscation.
-func (mr *MockRepoMockRecorder) CreateDebitTranscation(ctx, transcation interface{}) *gomock.Call {
+func (mr *MockRepoMockRecorder) CreateDebitTranscation(ctx, transcation, velocity interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDebitTranscation", reflect.TypeOf((*MockRepo)(nil).CreateDebitTranscation), ctx, transcation)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDebitTranscation", reflect.TypeOf((*MockRepo)(nil).CreateDebitTranscation), ctx, transcation, velocity)
 }
 
 // GetAccount mocks base method.
@@ -92,17 +230,164 @@ func (mr *MockRepoMockRecorder) GetAccount(ctx, id interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockRepo)(nil).GetAccount), ctx, id)
 }
 
+// GetLastEventSeq mocks base method.
+func (m *MockRepo) GetLastEventSeq(ctx context.Context, accountID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastEventSeq", ctx, accountID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastEventSeq indicates an expected call of GetLastEventSeq.
+func (mr *MockRepoMockRecorder) GetLastEventSeq(ctx, accountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastEventSeq", reflect.TypeOf((*MockRepo)(nil).GetLastEventSeq), ctx, accountID)
+}
+
+// GetReview mocks base method.
+func (m *MockRepo) GetReview(ctx context.Context, id string) (*domain.Review, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReview", ctx, id)
+	ret0, _ := ret[0].(*domain.Review)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReview indicates an expected call of GetReview.
+func (mr *MockRepoMockRecorder) GetReview(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReview", reflect.TypeOf((*MockRepo)(nil).GetReview), ctx, id)
+}
+
+// HoldTranscation mocks base method.
+func (m *MockRepo) HoldTranscation(ctx context.Context, review domain.Review, velocity domain.VelocityLimits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HoldTranscation", ctx, review, velocity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HoldTranscation indicates an expected call of HoldTranscation.
+func (mr *MockRepoMockRecorder) HoldTranscation(ctx, review, velocity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HoldTranscation", reflect.TypeOf((*MockRepo)(nil).HoldTranscation), ctx, review, velocity)
+}
+
+// ListAccountEvents mocks base method.
+func (m *MockRepo) ListAccountEvents(ctx context.Context, accountID string, afterSeq int64, limit int) ([]domain.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountEvents", ctx, accountID, afterSeq, limit)
+	ret0, _ := ret[0].([]domain.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountEvents indicates an expected call of ListAccountEvents.
+func (mr *MockRepoMockRecorder) ListAccountEvents(ctx, accountID, afterSeq, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountEvents", reflect.TypeOf((*MockRepo)(nil).ListAccountEvents), ctx, accountID, afterSeq, limit)
+}
+
 // ListDebitTx mocks base method.
-func (m *MockRepo) ListDebitTx(ctx context.Context) ([]domain.Transcation, error) {
+func (m *MockRepo) ListDebitTx(ctx context.Context, accountID string) ([]domain.Transcation, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListDebitTx", ctx)
+	ret := m.ctrl.Call(m, "ListDebitTx", ctx, accountID)
 	ret0, _ := ret[0].([]domain.Transcation)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListDebitTx indicates an expected call of ListDebitTx.
-func (mr *MockRepoMockRecorder) ListDebitTx(ctx interface{}) *gomock.Call {
+func (mr *MockRepoMockRecorder) ListDebitTx(ctx, accountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDebitTx", reflect.TypeOf((*MockRepo)(nil).ListDebitTx), ctx, accountID)
+}
+
+// ListDueReviews mocks base method.
+func (m *MockRepo) ListDueReviews(ctx context.Context, at time.Time, limit int) ([]domain.Review, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDueReviews", ctx, at, limit)
+	ret0, _ := ret[0].([]domain.Review)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDueReviews indicates an expected call of ListDueReviews.
+func (mr *MockRepoMockRecorder) ListDueReviews(ctx, at, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDueReviews", reflect.TypeOf((*MockRepo)(nil).ListDueReviews), ctx, at, limit)
+}
+
+// ListReviews mocks base method.
+func (m *MockRepo) ListReviews(ctx context.Context, status string, limit int) ([]domain.Review, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReviews", ctx, status, limit)
+	ret0, _ := ret[0].([]domain.Review)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReviews indicates an expected call of ListReviews.
+func (mr *MockRepoMockRecorder) ListReviews(ctx, status, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReviews", reflect.TypeOf((*MockRepo)(nil).ListReviews), ctx, status, limit)
+}
+
+// ListTranscations mocks base method.
+func (m *MockRepo) ListTranscations(ctx context.Context, accountID string, after *domain.TranscationCursor, limit int) ([]domain.Transcation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTranscations", ctx, accountID, after, limit)
+	ret0, _ := ret[0].([]domain.Transcation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTranscations indicates an expected call of ListTranscations.
+func (mr *MockRepoMockRecorder) ListTranscations(ctx, accountID, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTranscations", reflect.TypeOf((*MockRepo)(nil).ListTranscations), ctx, accountID, after, limit)
+}
+
+// RejectReview mocks base method.
+func (m *MockRepo) RejectReview(ctx context.Context, review domain.Review) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectReview", ctx, review)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RejectReview indicates an expected call of RejectReview.
+func (mr *MockRepoMockRecorder) RejectReview(ctx, review interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectReview", reflect.TypeOf((*MockRepo)(nil).RejectReview), ctx, review)
+}
+
+// UpdateAccountLimits mocks base method.
+func (m *MockRepo) UpdateAccountLimits(ctx context.Context, accountID string, req domain.AccountLimitsReq, at time.Time) (*domain.AccountLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountLimits", ctx, accountID, req, at)
+	ret0, _ := ret[0].(*domain.AccountLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountLimits indicates an expected call of UpdateAccountLimits.
+func (mr *MockRepoMockRecorder) UpdateAccountLimits(ctx, accountID, req, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountLimits", reflect.TypeOf((*MockRepo)(nil).UpdateAccountLimits), ctx, accountID, req, at)
+}
+
+// WithinTx mocks base method.
+func (m *MockRepo) WithinTx(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithinTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithinTx indicates an expected call of WithinTx.
+func (mr *MockRepoMockRecorder) WithinTx(ctx, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDebitTx", reflect.TypeOf((*MockRepo)(nil).ListDebitTx), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithinTx", reflect.TypeOf((*MockRepo)(nil).WithinTx), ctx, fn)
 }
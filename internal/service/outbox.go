@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+)
+
+type (
+	// Publisher delivers events read from the outbox to an external system
+	Publisher interface {
+		Publish(ctx context.Context, events []domain.Event) error
+	}
+
+	OutboxRepo interface {
+		ProcessOutbox(ctx context.Context, limit int, fn func([]domain.Event) error) (int, error)
+		// GetOldestUnpublishedEventTime returns when the oldest event not
+		// published yet was written, the zero time when there is none
+		GetOldestUnpublishedEventTime(ctx context.Context) (time.Time, error)
+	}
+
+	// Relay moves events from the outbox to every publisher, events are only
+	// marked as published once all publishers accepted them. A failed batch is
+	// retried as a whole, so publishers see events at least once.
+	Relay struct {
+		repo       OutboxRepo
+		publishers []Publisher
+		batchSize  int
+		interval   time.Duration
+	}
+)
+
+func NewRelay(repo OutboxRepo, interval time.Duration, batchSize int, publishers ...Publisher) Relay {
+	return Relay{
+		repo:       repo,
+		publishers: publishers,
+		batchSize:  batchSize,
+		interval:   interval,
+	}
+}
+
+// RelayOnce publishes a single batch of events and returns its size
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	return r.repo.ProcessOutbox(ctx, r.batchSize, func(events []domain.Event) error {
+		for _, publisher := range r.publishers {
+			if err := publisher.Publish(ctx, events); err != nil {
+				return fmt.Errorf("failed to publish events: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Run relays events until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) error {
+	log.Info("starting outbox relay")
+
+	return poll(ctx, r.interval, r.batchSize, "outbox relay", r.RelayOnce)
+}
+
+// OutboxLag returns how long the oldest unpublished event has waited for the
+// relay, zero when every event is published
+func OutboxLag(ctx context.Context, repo OutboxRepo) (time.Duration, error) {
+	oldest, err := repo.GetOldestUnpublishedEventTime(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the outbox: %w", err)
+	}
+
+	if oldest.IsZero() {
+		return 0, nil
+	}
+
+	return time.Since(oldest), nil
+}
+
+// CheckOutboxLag returns a check failing when the oldest unpublished event
+// has waited longer than max, the relay is stuck or falling behind
+func CheckOutboxLag(repo OutboxRepo, max time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		lag, err := OutboxLag(ctx, repo)
+		if err != nil {
+			return err
+		}
+
+		if lag > max {
+			return fmt.Errorf("the oldest unpublished event is %s old, more than %s", lag.Round(time.Second), max)
+		}
+
+		return nil
+	}
+}
+
+// poll calls fn every interval until ctx is cancelled, a full batch is
+// followed immediately by the next call so that backlogs drain quickly
+func poll(ctx context.Context, interval time.Duration, batchSize int, name string, fn func(context.Context) (int, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := fn(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithError(err).Warnf("%s failed", name)
+		}
+
+		if err == nil && n >= batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+type outboxStub struct {
+	oldest time.Time
+	err    error
+}
+
+func (o outboxStub) ProcessOutbox(ctx context.Context, limit int, fn func([]domain.Event) error) (int, error) {
+	return 0, nil
+}
+
+func (o outboxStub) GetOldestUnpublishedEventTime(ctx context.Context) (time.Time, error) {
+	return o.oldest, o.err
+}
+
+func TestOutboxLag(t *testing.T) {
+	t.Parallel()
+
+	lag, err := OutboxLag(context.Background(), outboxStub{})
+	require.NoError(t, err)
+	require.Zero(t, lag)
+
+	lag, err = OutboxLag(context.Background(), outboxStub{oldest: time.Now().Add(-2 * time.Minute)})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, lag, 2*time.Minute)
+	require.Less(t, lag, 3*time.Minute)
+
+	_, err = OutboxLag(context.Background(), outboxStub{err: errors.New("connection refused")})
+	require.EqualError(t, err, "failed to read the outbox: connection refused")
+}
+
+func TestCheckOutboxLag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		repo    outboxStub
+		wantErr string
+	}{
+		{name: "empty outbox", repo: outboxStub{}},
+		{name: "recent event", repo: outboxStub{oldest: time.Now().Add(-time.Second)}},
+		{name: "late event", repo: outboxStub{oldest: time.Now().Add(-2 * time.Minute)}, wantErr: "the oldest unpublished event is 2m0s old, more than 1m0s"},
+		{name: "unreachable outbox", repo: outboxStub{err: errors.New("connection refused")}, wantErr: "failed to read the outbox: connection refused"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckOutboxLag(tt.repo, time.Minute)(context.Background())
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+// ReviewConfig tells how long transcations flagged by the fraud rules wait for
+// an operator and how they are decided when nobody did in time
+type ReviewConfig struct {
+	SLA time.Duration
+	// TimeoutDecision is domain.ReviewApproved or domain.ReviewRejected
+	TimeoutDecision string
+	Interval        time.Duration
+	BatchSize       int
+}
+
+var (
+	ErrInvalidReviewID     = fmt.Errorf("invalid review id")
+	ErrInvalidReviewStatus = fmt.Errorf("invalid review status")
+	ErrInvalidReviewReason = fmt.Errorf("a reason is required to reject a transcation")
+)
+
+const (
+	defaultReviewSLA       = 24 * time.Hour
+	defaultReviewInterval  = time.Minute
+	defaultReviewBatchSize = 50
+
+	// reviewerSLA is the reviewer of the reviews decided on timeout
+	reviewerSLA    = "sla"
+	reasonSLA      = "review sla expired"
+	maxReviewsPage = 500
+)
+
+// WithReviewConfig sets how transcations held for review are decided
+func WithReviewConfig(cfg ReviewConfig) Option {
+	return func(o *options) {
+		o.review = cfg
+	}
+}
+
+func (c ReviewConfig) withDefaults() ReviewConfig {
+	if c.SLA <= 0 {
+		c.SLA = defaultReviewSLA
+	}
+
+	if c.TimeoutDecision != domain.ReviewApproved {
+		c.TimeoutDecision = domain.ReviewRejected
+	}
+
+	if c.Interval <= 0 {
+		c.Interval = defaultReviewInterval
+	}
+
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultReviewBatchSize
+	}
+
+	return c
+}
+
+// holdTranscation keeps a transcation flagged for review pending until it is
+// decided, the limit it uses is reserved meanwhile
+func (t *TranscationService) holdTranscation(ctx context.Context, transcation domain.Transcation, decision domain.FraudDecision) (*domain.Transcation, error) {
+	transcation.Status = domain.TranscationPendingReview
+	if transcation.IsDebit() {
+		transcation.Amount = -transcation.Amount
+		transcation.Balance = transcation.Amount
+	}
+
+	now := t.now()
+	review := domain.Review{
+		ID:          t.newID(),
+		Transcation: transcation,
+		DecisionID:  decision.ID,
+		Reasons:     decision.Reasons,
+		Status:      domain.ReviewPending,
+		DueAt:       now.Add(t.review.SLA),
+		CreatedAt:   now,
+	}
+
+	if err := t.repo.HoldTranscation(ctx, review, t.velocity); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).WithField("account_id", transcation.AccountID).
+		WithField("transcation_id", transcation.ID).
+		WithField("review_id", review.ID).
+		Warn("transcation held for review")
+
+	return &transcation, nil
+}
+
+// ListReviews lists the reviews with the status, pending by default, ordered
+// by due time
+func (t *TranscationService) ListReviews(ctx context.Context, status string, pageSize int) (_ []domain.Review, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.ListReviews")
+	defer endSpan(span, &err)
+
+	if err := t.authorize(ctx, policy.ActionReadReviews, policy.Resource{}); err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case "":
+		status = domain.ReviewPending
+	case domain.ReviewPending, domain.ReviewApproved, domain.ReviewRejected:
+	default:
+		return nil, ErrInvalidReviewStatus
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	} else if pageSize > maxReviewsPage {
+		pageSize = maxReviewsPage
+	}
+
+	return t.repo.ListReviews(ctx, status, pageSize)
+}
+
+func (t *TranscationService) GetReview(ctx context.Context, id string) (_ *domain.Review, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.GetReview")
+	defer endSpan(span, &err)
+
+	if id == "" {
+		return nil, ErrInvalidReviewID
+	}
+
+	if err := t.authorize(ctx, policy.ActionReadReviews, policy.Resource{}); err != nil {
+		return nil, err
+	}
+
+	return t.repo.GetReview(ctx, id)
+}
+
+// ApproveReview posts the transcation held by the review
+func (t *TranscationService) ApproveReview(ctx context.Context, id string, req domain.ReviewDecisionReq) (_ *domain.Review, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.ApproveReview")
+	defer endSpan(span, &err)
+
+	return t.decideByOperator(ctx, id, domain.ReviewApproved, req.Reason)
+}
+
+// RejectReview rejects the transcation held by the review and releases the
+// limit it reserved
+func (t *TranscationService) RejectReview(ctx context.Context, id string, req domain.ReviewDecisionReq) (_ *domain.Review, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.RejectReview")
+	defer endSpan(span, &err)
+
+	if req.Reason == "" {
+		return nil, ErrInvalidReviewReason
+	}
+
+	return t.decideByOperator(ctx, id, domain.ReviewRejected, req.Reason)
+}
+
+func (t *TranscationService) decideByOperator(ctx context.Context, id, status, reason string) (*domain.Review, error) {
+	if id == "" {
+		return nil, ErrInvalidReviewID
+	}
+
+	if err := t.authorize(ctx, policy.ActionDecideReviews, policy.Resource{}); err != nil {
+		return nil, err
+	}
+
+	var reviewer string
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		reviewer = principal.Subject
+	}
+
+	return t.decideReview(ctx, id, status, reviewer, reason)
+}
+
+// DecideDueReviews decides the reviews whose SLA expired as configured and
+// returns how many were decided
+func (t *TranscationService) DecideDueReviews(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.DecideDueReviews")
+	defer endSpan(span, &err)
+
+	reviews, err := t.repo.ListDueReviews(ctx, t.now(), t.review.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, review := range reviews {
+		if _, err := t.decideReview(ctx, review.ID, t.review.TimeoutDecision, reviewerSLA, reasonSLA); err != nil && !errors.Is(err, domain.ErrReviewDecided) {
+			return 0, err
+		}
+	}
+
+	return len(reviews), nil
+}
+
+// RunReviewTimeouts decides the reviews whose SLA expired until ctx is
+// cancelled, replicas may run it side by side
+func (t *TranscationService) RunReviewTimeouts(ctx context.Context) error {
+	log.WithField("sla", t.review.SLA).WithField("decision", t.review.TimeoutDecision).Info("starting review timeouts")
+
+	return poll(ctx, t.review.Interval, t.review.BatchSize, "review timeouts", t.DecideDueReviews)
+}
+
+// decideReview decides a pending review, the review is read, the debts a
+// credit discharges listed and the decision written in one unit of work so
+// that concurrent decisions and transcations see each other's writes
+func (t *TranscationService) decideReview(ctx context.Context, id, status, reviewer, reason string) (*domain.Review, error) {
+	var decided *domain.Review
+	err := t.repo.WithinTx(ctx, func(ctx context.Context) error {
+		review, err := t.repo.GetReview(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if review.Status != domain.ReviewPending {
+			return domain.ErrReviewDecided
+		}
+
+		now := t.now()
+		review.Status = status
+		review.Reviewer = reviewer
+		review.Reason = reason
+		review.DecidedAt = &now
+
+		if status == domain.ReviewRejected {
+			review.Transcation.Status = domain.TranscationRejected
+			review.Transcation.Balance = 0
+
+			if err := t.repo.RejectReview(ctx, *review); err != nil {
+				return err
+			}
+
+			decided = review
+			return nil
+		}
+
+		review.Transcation.Status = domain.TranscationPosted
+
+		var dTxList []domain.DebitTx
+		if !review.Transcation.IsDebit() {
+			// the account is locked like when posting, so that concurrent
+			// credits don't discharge the same debts
+			if _, err := t.repo.GetAccount(ctx, review.Transcation.AccountID); err != nil {
+				return err
+			}
+
+			balance, list, err := t.dispatchTx(ctx, review.Transcation)
+			if err != nil {
+				return err
+			}
+
+			review.Transcation.Balance = balance
+			dTxList = list
+		}
+
+		if err := t.repo.ApproveReview(ctx, *review, dTxList); err != nil {
+			return err
+		}
+
+		decided = review
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decided, nil
+}
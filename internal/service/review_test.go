@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/service/mocks"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+)
+
+type ReviewTestSuite struct {
+	suite.Suite
+
+	repo *mocks.MockRepo
+	svc  TranscationService
+	now  time.Time
+}
+
+func TestReview(t *testing.T) {
+	t.Parallel()
+
+	suite.Run(t, new(ReviewTestSuite))
+}
+
+func (s *ReviewTestSuite) SetupTest() {
+	s.repo = mocks.NewMockRepo(gomock.NewController(s.T()))
+	s.now = time.Date(2022, 10, 3, 12, 0, 0, 0, time.UTC)
+
+	s.svc = New(s.repo, WithReviewConfig(ReviewConfig{
+		SLA:             time.Hour,
+		TimeoutDecision: domain.ReviewApproved,
+	}))
+	s.svc.now = func() time.Time {
+		return s.now
+	}
+
+	s.repo.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(context.WithValue(ctx, reviewTxKey{}, true))
+		}).AnyTimes()
+}
+
+// reviewTxKey marks the context of the unit of work deciding a review
+type reviewTxKey struct{}
+
+// txMatcher matches the contexts of the unit of work deciding a review
+type txMatcher struct{}
+
+func inTx() gomock.Matcher {
+	return txMatcher{}
+}
+
+func (txMatcher) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	return ok && ctx.Value(reviewTxKey{}) == true
+}
+
+func (txMatcher) String() string {
+	return "is the context of the unit of work"
+}
+
+func (s *ReviewTestSuite) review(opTypeID int, amount float64) *domain.Review {
+	return &domain.Review{
+		ID: "review-1",
+		Transcation: domain.Transcation{
+			ID:              "transcation-1",
+			AccountID:       "account-1",
+			OperationTypeID: opTypeID,
+			Amount:          amount,
+			Balance:         amount,
+			Status:          domain.TranscationPendingReview,
+		},
+		DecisionID: "decision-1",
+		Status:     domain.ReviewPending,
+		DueAt:      s.now.Add(time.Hour),
+		CreatedAt:  s.now,
+	}
+}
+
+func (s *ReviewTestSuite) TestApproveDebit() {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "ops"})
+
+	s.repo.EXPECT().GetReview(inTx(), "review-1").Return(s.review(1, -20), nil)
+	s.repo.EXPECT().ApproveReview(inTx(), gomock.Any(), gomock.Nil()).DoAndReturn(
+		func(_ context.Context, review domain.Review, _ []domain.DebitTx) error {
+			s.Equal(domain.ReviewApproved, review.Status)
+			s.Equal(domain.TranscationPosted, review.Transcation.Status)
+			s.Equal(-20.0, review.Transcation.Balance)
+			s.Equal("ops", review.Reviewer)
+			s.Equal(s.now, *review.DecidedAt)
+
+			return nil
+		})
+
+	review, err := s.svc.ApproveReview(ctx, "review-1", domain.ReviewDecisionReq{})
+	s.Require().NoError(err)
+	s.Equal(domain.ReviewApproved, review.Status)
+}
+
+func (s *ReviewTestSuite) TestApproveCredit() {
+	ctx := context.Background()
+
+	s.repo.EXPECT().GetReview(inTx(), "review-1").Return(s.review(4, 50), nil)
+	s.repo.EXPECT().GetAccount(inTx(), "account-1").Return(&domain.Account{ID: "account-1"}, nil)
+	s.repo.EXPECT().ListDebitTx(inTx(), "account-1").Return([]domain.Transcation{
+		{ID: "debit-1", Balance: -30},
+	}, nil)
+	s.repo.EXPECT().ApproveReview(inTx(), gomock.Any(), []domain.DebitTx{{ID: "debit-1", Amount: 0}}).DoAndReturn(
+		func(_ context.Context, review domain.Review, _ []domain.DebitTx) error {
+			s.Equal(20.0, review.Transcation.Balance)
+
+			return nil
+		})
+
+	_, err := s.svc.ApproveReview(ctx, "review-1", domain.ReviewDecisionReq{Reason: "customer confirmed"})
+	s.Require().NoError(err)
+}
+
+func (s *ReviewTestSuite) TestReject() {
+	ctx := context.Background()
+
+	_, err := s.svc.RejectReview(ctx, "review-1", domain.ReviewDecisionReq{})
+	s.Require().ErrorIs(err, ErrInvalidReviewReason)
+
+	s.repo.EXPECT().GetReview(inTx(), "review-1").Return(s.review(3, -20), nil)
+	s.repo.EXPECT().RejectReview(inTx(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, review domain.Review) error {
+			s.Equal(domain.ReviewRejected, review.Status)
+			s.Equal(domain.TranscationRejected, review.Transcation.Status)
+			s.Equal(-20.0, review.Transcation.Amount)
+			s.Equal("card reported stolen", review.Reason)
+
+			return nil
+		})
+
+	review, err := s.svc.RejectReview(ctx, "review-1", domain.ReviewDecisionReq{Reason: "card reported stolen"})
+	s.Require().NoError(err)
+	s.Equal(domain.ReviewRejected, review.Status)
+}
+
+func (s *ReviewTestSuite) TestAlreadyDecided() {
+	decided := s.review(1, -20)
+	decided.Status = domain.ReviewRejected
+
+	s.repo.EXPECT().GetReview(inTx(), "review-1").Return(decided, nil)
+
+	_, err := s.svc.ApproveReview(context.Background(), "review-1", domain.ReviewDecisionReq{})
+	s.Require().ErrorIs(err, domain.ErrReviewDecided)
+}
+
+func (s *ReviewTestSuite) TestListReviews() {
+	_, err := s.svc.ListReviews(context.Background(), "lost", 0)
+	s.Require().ErrorIs(err, ErrInvalidReviewStatus)
+
+	s.repo.EXPECT().ListReviews(gomock.Any(), domain.ReviewPending, defaultPageSize).Return([]domain.Review{*s.review(1, -20)}, nil)
+
+	reviews, err := s.svc.ListReviews(context.Background(), "", 0)
+	s.Require().NoError(err)
+	s.Len(reviews, 1)
+}
+
+func (s *ReviewTestSuite) TestDecideDueReviews() {
+	// the second review got decided by an operator in the meantime
+	second := s.review(2, -30)
+	second.ID = "review-2"
+	s.repo.EXPECT().ListDueReviews(gomock.Any(), s.now, defaultReviewBatchSize).Return([]domain.Review{
+		*s.review(1, -20),
+		*second,
+	}, nil)
+	s.repo.EXPECT().GetReview(inTx(), "review-1").Return(s.review(1, -20), nil)
+	s.repo.EXPECT().GetReview(inTx(), "review-2").Return(second, nil)
+	s.repo.EXPECT().ApproveReview(inTx(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, review domain.Review, _ []domain.DebitTx) error {
+			s.Equal(reviewerSLA, review.Reviewer)
+			s.Equal(reasonSLA, review.Reason)
+
+			return nil
+		})
+	s.repo.EXPECT().ApproveReview(inTx(), gomock.Any(), gomock.Any()).Return(domain.ErrReviewDecided)
+
+	n, err := s.svc.DecideDueReviews(context.Background())
+	s.Require().NoError(err)
+	s.Equal(2, n)
+}
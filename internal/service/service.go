@@ -2,28 +2,99 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	log "github.com/sirupsen/logrus"
 
 	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
 )
 
 type (
 	TranscationService struct {
-		repo Repo
+		repo       Repo
+		hub        *EventHub
+		authorizer Authorizer
+		velocity   domain.VelocityLimits
+		fraud      FraudChecker
+		review     ReviewConfig
+		now        func() time.Time
+		newID      func() string
+		metrics    Metrics
+	}
+
+	// FraudChecker decides whether a transcation may be posted, services
+	// without one approve every transcation
+	FraudChecker interface {
+		Evaluate(ctx context.Context, account domain.Account, transcation domain.Transcation) (*domain.FraudDecision, error)
+	}
+
+	// DeclinedError is returned for transcations declined by the fraud rules
+	DeclinedError struct {
+		Decision domain.FraudDecision
+	}
+
+	// Authorizer decides whether the caller of ctx may perform an action,
+	// services without one allow everything
+	Authorizer interface {
+		Authorize(ctx context.Context, action policy.Action, resource policy.Resource) error
+	}
+
+	// Metrics records the outcome of the transcations created, services
+	// without one record nothing
+	Metrics interface {
+		// TranscationCreated records a posted transcation or one held for
+		// review
+		TranscationCreated(transcation domain.Transcation)
+		// TranscationDeclined records a transcation refused for reason, one
+		// of the Decline constants
+		TranscationDeclined(operationTypeID int, reason string)
+	}
+
+	Option func(*options)
+
+	options struct {
+		authorizer Authorizer
+		velocity   domain.VelocityLimits
+		fraud      FraudChecker
+		review     ReviewConfig
+		now        func() time.Time
+		newID      func() string
+		metrics    Metrics
 	}
 
 	Repo interface {
+		// WithinTx runs fn with a context whose repo calls share one
+		// transaction, committed when fn succeeds. fn may be run again when
+		// the transaction conflicts with another one.
+		WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+
 		CreateAccount(ctx context.Context, account domain.Account) error
 		GetAccount(ctx context.Context, id string) (*domain.Account, error)
+		UpdateAccountLimits(ctx context.Context, accountID string, req domain.AccountLimitsReq, at time.Time) (*domain.AccountLimits, error)
 
 		CreateCreditTranscation(ctx context.Context, transcation domain.Transcation, dbTxList []domain.DebitTx) error
-		CreateDebitTranscation(ctx context.Context, transcation domain.Transcation) error
+		CreateDebitTranscation(ctx context.Context, transcation domain.Transcation, velocity domain.VelocityLimits) error
 		// GetCreditBalance(ctx context.Context) (float64, error)
-		ListDebitTx(ctx context.Context) ([]domain.Transcation, error)
+		ListDebitTx(ctx context.Context, accountID string) ([]domain.Transcation, error)
+		ListTranscations(ctx context.Context, accountID string, after *domain.TranscationCursor, limit int) ([]domain.Transcation, error)
+
+		ListAccountEvents(ctx context.Context, accountID string, afterSeq int64, limit int) ([]domain.Event, error)
+		GetLastEventSeq(ctx context.Context, accountID string) (int64, error)
+
+		HoldTranscation(ctx context.Context, review domain.Review, velocity domain.VelocityLimits) error
+		ApproveReview(ctx context.Context, review domain.Review, dbTxList []domain.DebitTx) error
+		RejectReview(ctx context.Context, review domain.Review) error
+		GetReview(ctx context.Context, id string) (*domain.Review, error)
+		ListReviews(ctx context.Context, status string, limit int) ([]domain.Review, error)
+		ListDueReviews(ctx context.Context, at time.Time, limit int) ([]domain.Review, error)
 	}
 )
 
@@ -31,27 +102,169 @@ var (
 	ErrInvalidDocumentNumber  = fmt.Errorf("invalid document id")
 	ErrInvalidAccountID       = fmt.Errorf("invalid account id")
 	ErrInvalidOperationTypeID = fmt.Errorf("invalid operation type id")
+	ErrInvalidPageToken       = fmt.Errorf("invalid page token")
+	ErrInvalidLimit           = fmt.Errorf("invalid limit")
+	ErrExceedWithdrawalLimit  = domain.ErrExceedWithdrawalLimit
+	ErrExceedCreditLimit      = domain.ErrExceedCreditLimit
+	ErrTranscationDeclined    = fmt.Errorf("transcation declined")
 
 	defaultCreditLimit    = 1000.00
 	defaultWithdrwalLimit = 1000.00
+
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	// streamPollInterval is how often streams look for new transcations or
+	// events when they are not woken up by the event hub
+	streamPollInterval = time.Second
 )
 
-func New(repo Repo) TranscationService {
+// WithAuthorizer checks every call against the authorizer
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(o *options) {
+		o.authorizer = authorizer
+	}
+}
+
+// WithVelocityLimits bounds how fast every account may spend, the limits are
+// enforced by the repo so that they hold across replicas
+func WithVelocityLimits(velocity domain.VelocityLimits) Option {
+	return func(o *options) {
+		o.velocity = velocity
+	}
+}
+
+// WithFraudChecker evaluates every transcation before it is posted
+func WithFraudChecker(fraud FraudChecker) Option {
+	return func(o *options) {
+		o.fraud = fraud
+	}
+}
+
+// WithMetrics records the outcome of every transcation created
+func WithMetrics(metrics Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
+
+// WithClock sets the time accounts and transcations are created at, it is
+// meant for generating histories
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithIDs sets how the ids of accounts, transcations and reviews are drawn,
+// it is meant for generating reproducible histories
+func WithIDs(newID func() string) Option {
+	return func(o *options) {
+		o.newID = newID
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func New(repo Repo, opts ...Option) TranscationService {
+	o := newOptions(opts)
+
+	now := o.now
+	if now == nil {
+		now = func() time.Time {
+			return time.Now().UTC()
+		}
+	}
+
+	newID := o.newID
+	if newID == nil {
+		newID = uuid.NewString
+	}
+
 	return TranscationService{
-		repo: repo,
+		repo:       repo,
+		hub:        NewEventHub(),
+		authorizer: o.authorizer,
+		velocity:   o.velocity,
+		fraud:      o.fraud,
+		review:     o.review.withDefaults(),
+		now:        now,
+		newID:      newID,
+		metrics:    o.metrics,
 	}
 }
 
+// authorize checks that the caller of ctx may perform the action
+func (t *TranscationService) authorize(ctx context.Context, action policy.Action, resource policy.Resource) error {
+	if t.authorizer == nil {
+		return nil
+	}
+
+	return t.authorizer.Authorize(ctx, action, resource)
+}
+
+// authorizeAccount checks that the caller of ctx may perform the action on
+// the account, the account is only loaded when authorization needs its owner
+func (t *TranscationService) authorizeAccount(ctx context.Context, action policy.Action, accountID string) error {
+	if t.authorizer == nil {
+		return nil
+	}
+
+	_, err := t.authorizedAccount(ctx, action, accountID, policy.Resource{})
+	return err
+}
+
+// authorizedAccount loads the account and checks that the caller of ctx may
+// perform the action on it, resource holds the fields the account doesn't. A
+// caller not allowed to act on any account is forbidden missing accounts too,
+// so that it can't tell which ids exist.
+func (t *TranscationService) authorizedAccount(ctx context.Context, action policy.Action, accountID string, resource policy.Resource) (*domain.Account, error) {
+	resource.AccountID = accountID
+
+	account, err := t.repo.GetAccount(ctx, accountID)
+	if errors.Is(err, domain.ErrNotFound) {
+		if err := t.authorize(ctx, action, resource); err != nil {
+			return nil, err
+		}
+
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resource.OwnerID = account.OwnerID
+	if err := t.authorize(ctx, action, resource); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
 // CreateAccount create account with document number
-func (t *TranscationService) CreateAccount(ctx context.Context, documentNumber string) (*domain.Account, error) {
+func (t *TranscationService) CreateAccount(ctx context.Context, documentNumber string) (_ *domain.Account, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.CreateAccount")
+	defer endSpan(span, &err)
+
 	if documentNumber == "" {
 		return nil, ErrInvalidDocumentNumber
 	}
 
-	now := time.Now().UTC()
+	if err := t.authorize(ctx, policy.ActionCreateAccount, policy.Resource{}); err != nil {
+		return nil, err
+	}
+
+	now := t.now()
 
 	account := domain.Account{
-		ID:              uuid.NewString(),
+		ID:              t.newID(),
 		DocumentNumber:  documentNumber,
 		CreatedAt:       now,
 		UpdatedAt:       &now,
@@ -59,9 +272,13 @@ func (t *TranscationService) CreateAccount(ctx context.Context, documentNumber s
 		WithdrawalLimit: defaultWithdrwalLimit,
 	}
 
-	err := t.repo.CreateAccount(ctx, account)
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		account.OwnerID = principal.Subject
+	}
+
+	err = t.repo.CreateAccount(ctx, account)
 	if err != nil {
-		log.Error("failed to create account", err)
+		logging.FromContext(ctx).WithError(err).Error("failed to create account")
 		return nil, err
 	}
 
@@ -69,22 +286,63 @@ func (t *TranscationService) CreateAccount(ctx context.Context, documentNumber s
 }
 
 // GetAccount get account details via account id
-func (t *TranscationService) GetAccount(ctx context.Context, accountID string) (*domain.Account, error) {
+func (t *TranscationService) GetAccount(ctx context.Context, accountID string) (_ *domain.Account, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.GetAccount")
+	defer endSpan(span, &err)
+
 	if accountID == "" {
 		return nil, ErrInvalidAccountID
 	}
 
-	account, err := t.repo.GetAccount(ctx, accountID)
+	account, err := t.authorizedAccount(ctx, policy.ActionReadAccount, accountID, policy.Resource{})
 	if err != nil {
-		log.WithField("account_id", accountID).Error("failed to get account", err)
+		if !errors.Is(err, policy.ErrForbidden) {
+			logging.FromContext(ctx).WithField("account_id", accountID).WithError(err).Error("failed to get account")
+		}
 		return nil, err
 	}
 
 	return account, nil
 }
 
+// UpdateAccountLimits sets the limits of the account, limits missing from req
+// are left unchanged
+func (t *TranscationService) UpdateAccountLimits(ctx context.Context, accountID string, req domain.AccountLimitsReq) (_ *domain.AccountLimits, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.UpdateAccountLimits")
+	defer endSpan(span, &err)
+
+	if accountID == "" {
+		return nil, ErrInvalidAccountID
+	}
+
+	if req.WithdrawalLimit == nil && req.CreditLimit == nil {
+		return nil, ErrInvalidLimit
+	}
+
+	for _, limit := range []*float64{req.WithdrawalLimit, req.CreditLimit} {
+		if limit != nil && *limit < 0 {
+			return nil, ErrInvalidLimit
+		}
+	}
+
+	if err := t.authorizeAccount(ctx, policy.ActionUpdateAccountLimits, accountID); err != nil {
+		return nil, err
+	}
+
+	limits, err := t.repo.UpdateAccountLimits(ctx, accountID, req, t.now())
+	if err != nil {
+		logging.FromContext(ctx).WithField("account_id", accountID).WithError(err).Error("failed to update account limits")
+		return nil, err
+	}
+
+	return limits, nil
+}
+
 // CreateTranscation add new transcation for given account id
-func (t *TranscationService) CreateTranscation(ctx context.Context, transcation domain.Transcation) (*domain.Transcation, error) {
+func (t *TranscationService) CreateTranscation(ctx context.Context, transcation domain.Transcation) (_ *domain.Transcation, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.CreateTranscation")
+	defer endSpan(span, &err)
+
 	if transcation.AccountID == "" {
 		return nil, ErrInvalidAccountID
 	}
@@ -93,24 +351,102 @@ func (t *TranscationService) CreateTranscation(ctx context.Context, transcation
 		return nil, err
 	}
 
-	transcation.ID = uuid.NewString()
-	transcation.EventAt = time.Now().UTC()
+	transcation.ID = t.newID()
+	transcation.EventAt = t.now()
+	transcation.Status = domain.TranscationPosted
+
+	// the account is read, the debts listed and the transcation posted in one
+	// unit of work so that concurrent transcations see each other's writes
+	var (
+		posted   *domain.Transcation
+		declined error
+	)
+	err = t.repo.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		posted, err = t.postTranscation(ctx, transcation)
+
+		// the decision declining the transcation is kept
+		declined = nil
+		if errors.As(err, new(*DeclinedError)) {
+			declined = err
+			return nil
+		}
+
+		return err
+	})
+	if err == nil && declined != nil {
+		err = declined
+	}
+
+	t.recordTranscation(transcation.OperationTypeID, posted, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return posted, nil
+}
+
+// reasons transcations are declined for
+const (
+	DeclineWithdrawalLimit = "withdrawal_limit"
+	DeclineCreditLimit     = "credit_limit"
+	DeclineVelocity        = "velocity"
+	DeclineFraud           = "fraud"
+)
+
+// recordTranscation records the outcome of a transcation, failures other than
+// declines aren't
+func (t *TranscationService) recordTranscation(operationTypeID int, created *domain.Transcation, err error) {
+	if t.metrics == nil {
+		return
+	}
+
+	switch {
+	case err == nil:
+		t.metrics.TranscationCreated(*created)
+	case errors.Is(err, ErrExceedWithdrawalLimit):
+		t.metrics.TranscationDeclined(operationTypeID, DeclineWithdrawalLimit)
+	case errors.Is(err, ErrExceedCreditLimit):
+		t.metrics.TranscationDeclined(operationTypeID, DeclineCreditLimit)
+	case errors.Is(err, domain.ErrVelocityLimitExceeded):
+		t.metrics.TranscationDeclined(operationTypeID, DeclineVelocity)
+	case errors.Is(err, ErrTranscationDeclined):
+		t.metrics.TranscationDeclined(operationTypeID, DeclineFraud)
+	}
+}
 
-	acc, err := t.repo.GetAccount(ctx, transcation.AccountID)
+// postTranscation checks the transcation against the account and posts it,
+// the repo calls run in the unit of work of ctx
+func (t *TranscationService) postTranscation(ctx context.Context, transcation domain.Transcation) (*domain.Transcation, error) {
+	acc, err := t.authorizedAccount(ctx, policy.ActionCreateTranscation, transcation.AccountID, policy.Resource{
+		OperationTypeID: transcation.OperationTypeID,
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	if (transcation.OperationTypeID == 1 || transcation.OperationTypeID == 2 || transcation.OperationTypeID == 3) && acc.WithdrawalLimit < transcation.Amount {
-		return nil, fmt.Errorf("exceed withdrwal limit")
+		return nil, ErrExceedWithdrawalLimit
 	} else if transcation.OperationTypeID == 4 && transcation.Amount > acc.CreaditLimit {
-		return nil, fmt.Errorf("exceed credit limit")
+		return nil, ErrExceedCreditLimit
+	}
+
+	decision, err := t.checkFraud(ctx, *acc, transcation)
+	if err != nil {
+		return nil, err
 	}
 
+	if decision != nil && decision.Outcome == domain.FraudReview {
+		return t.holdTranscation(ctx, transcation, *decision)
+	}
+
+	// debits are stored negative and owe their whole amount, credits
+	// discharge them oldest first
 	if transcation.OperationTypeID == 1 || transcation.OperationTypeID == 2 || transcation.OperationTypeID == 3 {
-		transcation.Balance = transcation.Amount
 		transcation.Amount = -transcation.Amount
-		if err := t.repo.CreateDebitTranscation(ctx, transcation); err != nil {
+		transcation.Balance = transcation.Amount
+		if err := t.repo.CreateDebitTranscation(ctx, transcation, t.velocity); err != nil {
 			return nil, err
 		}
 
@@ -129,10 +465,153 @@ func (t *TranscationService) CreateTranscation(ctx context.Context, transcation
 	return &transcation, nil
 }
 
+// ListTranscations list transcations of an account oldest first, a page
+// starts after the one the page token was returned with
+func (t *TranscationService) ListTranscations(ctx context.Context, accountID string, pageSize int, pageToken string) (_ *domain.TranscationPage, err error) {
+	ctx, span := startSpan(ctx, "TranscationService.ListTranscations")
+	defer endSpan(span, &err)
+
+	if accountID == "" {
+		return nil, ErrInvalidAccountID
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	} else if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	if err := t.authorizeAccount(ctx, policy.ActionReadTranscations, accountID); err != nil {
+		return nil, err
+	}
+
+	var after *domain.TranscationCursor
+	if pageToken != "" {
+		cursor, err := decodePageToken(pageToken)
+		if err != nil {
+			return nil, err
+		}
+
+		after = &cursor
+	}
+
+	transcations, err := t.repo.ListTranscations(ctx, accountID, after, pageSize)
+	if err != nil {
+		logging.FromContext(ctx).WithField("account_id", accountID).WithError(err).Error("failed to list transcations")
+		return nil, err
+	}
+
+	page := domain.TranscationPage{
+		Transcations: transcations,
+	}
+
+	if len(transcations) == pageSize {
+		last := transcations[len(transcations)-1]
+		page.NextPageToken = encodePageToken(domain.TranscationCursor{
+			EventAt: last.EventAt,
+			ID:      last.ID,
+		})
+	}
+
+	return &page, nil
+}
+
+// StreamTranscations calls fn with every transcation posted to the account
+// after since, or after the call when since is zero, until ctx is cancelled or
+// fn fails
+func (t *TranscationService) StreamTranscations(ctx context.Context, accountID string, since time.Time, fn func(domain.Transcation) error) (err error) {
+	ctx, span := startSpan(ctx, "TranscationService.StreamTranscations")
+	defer endSpan(span, &err)
+
+	if accountID == "" {
+		return ErrInvalidAccountID
+	}
+
+	if _, err := t.authorizedAccount(ctx, policy.ActionReadTranscations, accountID, policy.Resource{}); err != nil {
+		return err
+	}
+
+	if since.IsZero() {
+		since = t.now()
+	}
+
+	wakeup, unsubscribe := t.hub.Subscribe(accountID)
+	defer unsubscribe()
+
+	cursor := domain.TranscationCursor{EventAt: since}
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		transcations, err := t.repo.ListTranscations(ctx, accountID, &cursor, maxPageSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		for _, transcation := range transcations {
+			if err := fn(transcation); err != nil {
+				return err
+			}
+
+			cursor = domain.TranscationCursor{
+				EventAt: transcation.EventAt,
+				ID:      transcation.ID,
+			}
+		}
+
+		if len(transcations) == maxPageSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+func encodePageToken(cursor domain.TranscationCursor) string {
+	raw := strconv.FormatInt(cursor.EventAt.UnixNano(), 10) + "/" + cursor.ID
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePageToken(token string) (domain.TranscationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return domain.TranscationCursor{}, ErrInvalidPageToken
+	}
+
+	parts := strings.SplitN(string(raw), "/", 2)
+	if len(parts) != 2 {
+		return domain.TranscationCursor{}, ErrInvalidPageToken
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return domain.TranscationCursor{}, ErrInvalidPageToken
+	}
+
+	if _, err := uuid.Parse(parts[1]); err != nil {
+		return domain.TranscationCursor{}, ErrInvalidPageToken
+	}
+
+	return domain.TranscationCursor{
+		EventAt: time.Unix(0, nanos).UTC(),
+		ID:      parts[1],
+	}, nil
+}
+
 func (t *TranscationService) dispatchTx(ctx context.Context, transcation domain.Transcation) (float64, []domain.DebitTx, error) {
 	var balance float64
 	dTxList := make([]domain.DebitTx, 0)
-	dList, err := t.repo.ListDebitTx(ctx)
+	dList, err := t.repo.ListDebitTx(ctx, transcation.AccountID)
 	if err != nil {
 		return balance, dTxList, err
 	}
@@ -163,6 +642,41 @@ func (t *TranscationService) dispatchTx(ctx context.Context, transcation domain.
 	return txAmount, dTxList, nil
 }
 
+// checkFraud returns the decision of the fraud rules on the transcation, nil
+// without rules, and fails with a DeclinedError when they decline it
+func (t *TranscationService) checkFraud(ctx context.Context, account domain.Account, transcation domain.Transcation) (*domain.FraudDecision, error) {
+	if t.fraud == nil {
+		return nil, nil
+	}
+
+	decision, err := t.fraud.Evaluate(ctx, account, transcation)
+	if err != nil {
+		logging.FromContext(ctx).WithField("account_id", account.ID).WithError(err).Error("failed to evaluate fraud rules")
+		return nil, err
+	}
+
+	if decision.Outcome == domain.FraudDecline {
+		return nil, &DeclinedError{Decision: *decision}
+	}
+
+	return decision, nil
+}
+
+func (e *DeclinedError) Error() string {
+	reasons := make([]string, 0, len(e.Decision.Reasons))
+	for _, reason := range e.Decision.Reasons {
+		if reason.Outcome == domain.FraudDecline {
+			reasons = append(reasons, reason.Reason)
+		}
+	}
+
+	return fmt.Sprintf("%s: %s", ErrTranscationDeclined, strings.Join(reasons, ", "))
+}
+
+func (e *DeclinedError) Unwrap() error {
+	return ErrTranscationDeclined
+}
+
 func validateOpTypeID(opTypeID int) error {
 	switch opTypeID {
 	case 1, 2, 3, 4:
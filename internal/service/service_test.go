@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
 	"github.com/madhurikadam/app-transcation/internal/service/mocks"
+	"github.com/madhurikadam/app-transcation/pkg/auth"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -33,10 +36,16 @@ func TestService(t *testing.T) {
 
 func (s *ServiceTestSuite) SetupTest() {
 	s.repo = mocks.NewMockRepo(gomock.NewController(s.T()))
+	s.repo.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(runWithinTx).AnyTimes()
 
 	s.svc = New(s.repo)
 }
 
+// runWithinTx runs a unit of work like a repo without transactions
+func runWithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
 func (s *ServiceTestSuite) TestCreateAccount() {
 	ctx := context.Background()
 	documentNumber := "12345678"
@@ -196,7 +205,7 @@ func (s *ServiceTestSuite) TestCreateTranscation() {
 			name: "failed to debit create transcation in database",
 			mocks: func() {
 				s.repo.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Return(&domain.Account{WithdrawalLimit: 400}, nil)
-				s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any()).Return(errTestFoo)
+				s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any(), gomock.Any()).Return(errTestFoo)
 			},
 			input: domain.Transcation{
 				AccountID:       accountID,
@@ -236,7 +245,7 @@ func (s *ServiceTestSuite) TestCreateTranscation() {
 			name: "create debit transcation with success",
 			mocks: func() {
 				s.repo.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Return(&domain.Account{WithdrawalLimit: 400}, nil)
-				s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any()).Return(nil)
+				s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 			},
 			input: domain.Transcation{
 				AccountID:       accountID,
@@ -247,6 +256,8 @@ func (s *ServiceTestSuite) TestCreateTranscation() {
 				AccountID:       accountID,
 				OperationTypeID: 2,
 				Amount:          -20,
+				// the debit owes its whole amount until credits discharge it
+				Balance: -20,
 			},
 		},
 		{
@@ -254,7 +265,7 @@ func (s *ServiceTestSuite) TestCreateTranscation() {
 			mocks: func() {
 				s.repo.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Return(&domain.Account{CreaditLimit: 400}, nil)
 				s.repo.EXPECT().CreateCreditTranscation(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
-				s.repo.EXPECT().ListDebitTx(gomock.Any()).Return([]domain.Transcation{
+				s.repo.EXPECT().ListDebitTx(gomock.Any(), gomock.Any()).Return([]domain.Transcation{
 					{
 						ID:      uuid.NewString(),
 						Amount:  50,
@@ -282,6 +293,8 @@ func (s *ServiceTestSuite) TestCreateTranscation() {
 				AccountID:       accountID,
 				OperationTypeID: 4,
 				Amount:          20,
+				// the credit is spent discharging the oldest debit
+				Balance: 0,
 			},
 		},
 	}
@@ -306,9 +319,271 @@ func (s *ServiceTestSuite) TestCreateTranscation() {
 			s.Equal(tt.expectedOp.OperationTypeID, tx.OperationTypeID)
 			s.Equal(tt.expectedOp.AccountID, tx.AccountID)
 			s.Equal(tt.expectedOp.Amount, tx.Amount)
+			s.Equal(tt.expectedOp.Balance, tx.Balance)
 		})
 	}
 }
+func (s *ServiceTestSuite) TestCreateTranscationVelocity() {
+	ctx := context.Background()
+	velocity := domain.VelocityLimits{
+		MaxDebitsPerMinute:   5,
+		MaxDebitAmountPerDay: 1000,
+	}
+	s.svc = New(s.repo, WithVelocityLimits(velocity))
+
+	s.repo.EXPECT().GetAccount(gomock.Any(), "12345678").Return(&domain.Account{ID: "12345678", WithdrawalLimit: 400}, nil)
+	s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any(), velocity).Return(&domain.VelocityError{
+		Limit:      domain.VelocityDebitsPerMinute,
+		RetryAfter: 20 * time.Second,
+	})
+
+	_, err := s.svc.CreateTranscation(ctx, domain.Transcation{
+		AccountID:       "12345678",
+		OperationTypeID: 1,
+		Amount:          20,
+	})
+	s.Require().ErrorIs(err, domain.ErrVelocityLimitExceeded)
+
+	var velocityErr *domain.VelocityError
+	s.Require().ErrorAs(err, &velocityErr)
+	s.Equal(20*time.Second, velocityErr.RetryAfter)
+}
+
+func (s *ServiceTestSuite) TestCreateTranscationFraud() {
+	ctx := context.Background()
+	account := &domain.Account{ID: "12345678", WithdrawalLimit: 400}
+	input := domain.Transcation{
+		AccountID:       "12345678",
+		OperationTypeID: 1,
+		Amount:          20,
+	}
+
+	s.Run("declined", func() {
+		s.SetupTest()
+		checker := mocks.NewMockFraudChecker(gomock.NewController(s.T()))
+		s.svc = New(s.repo, WithFraudChecker(checker))
+
+		s.repo.EXPECT().GetAccount(gomock.Any(), "12345678").Return(account, nil)
+		checker.EXPECT().Evaluate(gomock.Any(), *account, gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ domain.Account, transcation domain.Transcation) (*domain.FraudDecision, error) {
+				s.Equal(20.0, transcation.Amount)
+
+				return &domain.FraudDecision{
+					ID:      "decision-1",
+					Outcome: domain.FraudDecline,
+					Reasons: []domain.FraudReason{
+						{Rule: "night", Outcome: domain.FraudReview, Reason: "unusual hour"},
+						{Rule: "velocity", Outcome: domain.FraudDecline, Reason: "too many purchases"},
+					},
+				}, nil
+			})
+
+		_, err := s.svc.CreateTranscation(ctx, input)
+		s.Require().ErrorIs(err, ErrTranscationDeclined)
+		s.Equal("transcation declined: too many purchases", err.Error())
+
+		var declinedErr *DeclinedError
+		s.Require().ErrorAs(err, &declinedErr)
+		s.Equal("decision-1", declinedErr.Decision.ID)
+	})
+
+	s.Run("flagged for review", func() {
+		s.SetupTest()
+		checker := mocks.NewMockFraudChecker(gomock.NewController(s.T()))
+		s.svc = New(s.repo, WithFraudChecker(checker))
+
+		s.repo.EXPECT().GetAccount(gomock.Any(), "12345678").Return(account, nil)
+		checker.EXPECT().Evaluate(gomock.Any(), *account, gomock.Any()).Return(&domain.FraudDecision{
+			ID:      "decision-1",
+			Outcome: domain.FraudReview,
+		}, nil)
+		s.repo.EXPECT().HoldTranscation(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, review domain.Review, _ domain.VelocityLimits) error {
+				s.Equal("decision-1", review.DecisionID)
+				s.Equal(domain.ReviewPending, review.Status)
+				s.Equal(domain.TranscationPendingReview, review.Transcation.Status)
+				s.Equal(-20.0, review.Transcation.Amount)
+				s.Equal(review.CreatedAt.Add(24*time.Hour), review.DueAt)
+
+				return nil
+			})
+
+		tx, err := s.svc.CreateTranscation(ctx, input)
+		s.Require().NoError(err)
+		s.Equal(domain.TranscationPendingReview, tx.Status)
+	})
+
+	s.Run("evaluation fails", func() {
+		s.SetupTest()
+		checker := mocks.NewMockFraudChecker(gomock.NewController(s.T()))
+		s.svc = New(s.repo, WithFraudChecker(checker))
+
+		s.repo.EXPECT().GetAccount(gomock.Any(), "12345678").Return(account, nil)
+		checker.EXPECT().Evaluate(gomock.Any(), *account, gomock.Any()).Return(nil, errTestFoo)
+
+		_, err := s.svc.CreateTranscation(ctx, input)
+		s.Require().ErrorIs(err, errTestFoo)
+	})
+}
+
+func (s *ServiceTestSuite) TestCreateTranscationMetrics() {
+	ctx := context.Background()
+	account := &domain.Account{ID: "12345678", WithdrawalLimit: 400, CreaditLimit: 100}
+
+	tests := []struct {
+		name   string
+		input  domain.Transcation
+		mocks  func(metrics *mocks.MockMetrics)
+		expErr bool
+	}{
+		{
+			name:  "posted",
+			input: domain.Transcation{AccountID: "12345678", OperationTypeID: 1, Amount: 20},
+			mocks: func(metrics *mocks.MockMetrics) {
+				s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				metrics.EXPECT().TranscationCreated(gomock.Any()).Do(func(transcation domain.Transcation) {
+					s.Equal(-20.0, transcation.Amount)
+					s.Equal(domain.TranscationPosted, transcation.Status)
+				})
+			},
+		},
+		{
+			name:  "withdrawal limit exceeded",
+			input: domain.Transcation{AccountID: "12345678", OperationTypeID: 3, Amount: 500},
+			mocks: func(metrics *mocks.MockMetrics) {
+				metrics.EXPECT().TranscationDeclined(3, DeclineWithdrawalLimit)
+			},
+			expErr: true,
+		},
+		{
+			name:  "credit limit exceeded",
+			input: domain.Transcation{AccountID: "12345678", OperationTypeID: 4, Amount: 500},
+			mocks: func(metrics *mocks.MockMetrics) {
+				metrics.EXPECT().TranscationDeclined(4, DeclineCreditLimit)
+			},
+			expErr: true,
+		},
+		{
+			name:  "velocity limit exceeded",
+			input: domain.Transcation{AccountID: "12345678", OperationTypeID: 1, Amount: 20},
+			mocks: func(metrics *mocks.MockMetrics) {
+				s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any(), gomock.Any()).Return(&domain.VelocityError{
+					Limit: domain.VelocityDebitsPerMinute,
+				})
+				metrics.EXPECT().TranscationDeclined(1, DeclineVelocity)
+			},
+			expErr: true,
+		},
+		{
+			name:  "failures aren't recorded",
+			input: domain.Transcation{AccountID: "12345678", OperationTypeID: 1, Amount: 20},
+			mocks: func(metrics *mocks.MockMetrics) {
+				s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any(), gomock.Any()).Return(errTestFoo)
+			},
+			expErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			metrics := mocks.NewMockMetrics(gomock.NewController(s.T()))
+			s.svc = New(s.repo, WithMetrics(metrics))
+
+			s.repo.EXPECT().GetAccount(gomock.Any(), "12345678").Return(account, nil)
+			tt.mocks(metrics)
+
+			_, err := s.svc.CreateTranscation(ctx, tt.input)
+			if tt.expErr {
+				s.Require().Error(err)
+				return
+			}
+
+			s.Require().NoError(err)
+		})
+	}
+}
+
+func (s *ServiceTestSuite) TestCreateTranscationUnitOfWork() {
+	type txKey struct{}
+
+	account := &domain.Account{ID: "12345678", WithdrawalLimit: 400, CreaditLimit: 400}
+	input := domain.Transcation{
+		AccountID:       "12345678",
+		OperationTypeID: 4,
+		Amount:          20,
+	}
+
+	withinTx := func(repo *mocks.MockRepo, commitErr error) {
+		repo.EXPECT().WithinTx(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, fn func(ctx context.Context) error) error {
+				if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+					return err
+				}
+
+				return commitErr
+			})
+	}
+	// the repo calls of the flow get the context of the unit of work
+	expectTx := func(ctx context.Context) {
+		s.Equal(true, ctx.Value(txKey{}))
+	}
+
+	s.Run("commits", func() {
+		repo := mocks.NewMockRepo(gomock.NewController(s.T()))
+		svc := New(repo)
+
+		withinTx(repo, nil)
+		repo.EXPECT().GetAccount(gomock.Any(), "12345678").DoAndReturn(func(ctx context.Context, _ string) (*domain.Account, error) {
+			expectTx(ctx)
+			return account, nil
+		})
+		repo.EXPECT().ListDebitTx(gomock.Any(), "12345678").DoAndReturn(func(ctx context.Context, _ string) ([]domain.Transcation, error) {
+			expectTx(ctx)
+			return []domain.Transcation{{ID: "debit-1", Balance: -50}}, nil
+		})
+		repo.EXPECT().CreateCreditTranscation(gomock.Any(), gomock.Any(), []domain.DebitTx{{ID: "debit-1", Amount: -30}}).DoAndReturn(
+			func(ctx context.Context, _ domain.Transcation, _ []domain.DebitTx) error {
+				expectTx(ctx)
+				return nil
+			})
+
+		tx, err := svc.CreateTranscation(context.Background(), input)
+		s.Require().NoError(err)
+		s.Equal(0.0, tx.Balance)
+	})
+
+	s.Run("failed commit fails the transcation", func() {
+		repo := mocks.NewMockRepo(gomock.NewController(s.T()))
+		svc := New(repo)
+
+		withinTx(repo, errTestFoo)
+		repo.EXPECT().GetAccount(gomock.Any(), "12345678").Return(account, nil)
+		repo.EXPECT().ListDebitTx(gomock.Any(), "12345678").Return(nil, nil)
+		repo.EXPECT().CreateCreditTranscation(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := svc.CreateTranscation(context.Background(), input)
+		s.Require().ErrorIs(err, errTestFoo)
+	})
+
+	s.Run("declines are committed", func() {
+		repo := mocks.NewMockRepo(gomock.NewController(s.T()))
+		checker := mocks.NewMockFraudChecker(gomock.NewController(s.T()))
+		svc := New(repo, WithFraudChecker(checker))
+
+		withinTx(repo, nil)
+		repo.EXPECT().GetAccount(gomock.Any(), "12345678").Return(account, nil)
+		checker.EXPECT().Evaluate(gomock.Any(), *account, gomock.Any()).Return(&domain.FraudDecision{
+			ID:      "decision-1",
+			Outcome: domain.FraudDecline,
+		}, nil)
+
+		_, err := svc.CreateTranscation(context.Background(), input)
+		s.Require().ErrorIs(err, ErrTranscationDeclined)
+	})
+}
+
 func (s *ServiceTestSuite) TestDispatchTx() {
 	ctx := context.Background()
 	accountID := "12345678"
@@ -327,7 +602,7 @@ func (s *ServiceTestSuite) TestDispatchTx() {
 		{
 			name: "create credit transcation with success where debit balance is greater than credit balance",
 			mocks: func() {
-				s.repo.EXPECT().ListDebitTx(gomock.Any()).Return([]domain.Transcation{
+				s.repo.EXPECT().ListDebitTx(gomock.Any(), gomock.Any()).Return([]domain.Transcation{
 					{
 						ID:      id1,
 						Amount:  50,
@@ -369,7 +644,7 @@ func (s *ServiceTestSuite) TestDispatchTx() {
 		{
 			name: "create credit transcation with success where debit balance is greater than credit balance",
 			mocks: func() {
-				s.repo.EXPECT().ListDebitTx(gomock.Any()).Return([]domain.Transcation{
+				s.repo.EXPECT().ListDebitTx(gomock.Any(), gomock.Any()).Return([]domain.Transcation{
 					{
 						ID:      id1,
 						Amount:  50,
@@ -438,3 +713,328 @@ func (s *ServiceTestSuite) TestDispatchTx() {
 		})
 	}
 }
+
+func (s *ServiceTestSuite) TestListTranscations() {
+	ctx := context.Background()
+	accountID := uuid.NewString()
+	lastID := uuid.NewString()
+	eventAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		mocks        func()
+		accountID    string
+		pageSize     int
+		pageToken    string
+		expErr       bool
+		expError     error
+		expNextToken bool
+	}{
+		{
+			name:      "invalid account id",
+			mocks:     func() {},
+			accountID: "",
+			expErr:    true,
+			expError:  ErrInvalidAccountID,
+		},
+		{
+			name:      "invalid page token",
+			mocks:     func() {},
+			accountID: accountID,
+			pageToken: "not-a-token",
+			expErr:    true,
+			expError:  ErrInvalidPageToken,
+		},
+		{
+			name: "failed to list transcations from database",
+			mocks: func() {
+				s.repo.EXPECT().ListTranscations(gomock.Any(), accountID, nil, defaultPageSize).Return(nil, errTestFoo)
+			},
+			accountID: accountID,
+			expErr:    true,
+			expError:  errTestFoo,
+		},
+		{
+			name: "full page returns next page token",
+			mocks: func() {
+				s.repo.EXPECT().ListTranscations(gomock.Any(), accountID, nil, 2).Return([]domain.Transcation{
+					{ID: uuid.NewString(), EventAt: eventAt},
+					{ID: lastID, EventAt: eventAt},
+				}, nil)
+			},
+			accountID:    accountID,
+			pageSize:     2,
+			expNextToken: true,
+		},
+		{
+			name: "page token is resolved to cursor",
+			mocks: func() {
+				cursor := &domain.TranscationCursor{EventAt: eventAt, ID: lastID}
+				s.repo.EXPECT().ListTranscations(gomock.Any(), accountID, cursor, maxPageSize).Return([]domain.Transcation{
+					{ID: uuid.NewString(), EventAt: eventAt},
+				}, nil)
+			},
+			accountID: accountID,
+			pageSize:  maxPageSize + 1,
+			pageToken: encodePageToken(domain.TranscationCursor{EventAt: eventAt, ID: lastID}),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.mocks()
+
+			page, err := s.svc.ListTranscations(ctx, tt.accountID, tt.pageSize, tt.pageToken)
+			if tt.expErr {
+				s.Require().Error(err)
+				s.Require().Equal(tt.expError, err)
+
+				return
+			}
+
+			s.Require().NoError(err)
+			if !tt.expNextToken {
+				s.Empty(page.NextPageToken)
+
+				return
+			}
+
+			cursor, err := decodePageToken(page.NextPageToken)
+			s.Require().NoError(err)
+			s.Equal(lastID, cursor.ID)
+			s.True(eventAt.Equal(cursor.EventAt))
+		})
+	}
+}
+
+func (s *ServiceTestSuite) TestStreamTranscations() {
+	streamPollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	accountID := uuid.NewString()
+	since := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	first := domain.Transcation{ID: uuid.NewString(), AccountID: accountID, EventAt: since.Add(time.Second)}
+	second := domain.Transcation{ID: uuid.NewString(), AccountID: accountID, EventAt: since.Add(2 * time.Second)}
+
+	s.repo.EXPECT().GetAccount(gomock.Any(), accountID).Return(&domain.Account{ID: accountID}, nil)
+	gomock.InOrder(
+		s.repo.EXPECT().ListTranscations(gomock.Any(), accountID, &domain.TranscationCursor{EventAt: since}, maxPageSize).
+			Return([]domain.Transcation{first}, nil),
+		s.repo.EXPECT().ListTranscations(gomock.Any(), accountID, &domain.TranscationCursor{EventAt: first.EventAt, ID: first.ID}, maxPageSize).
+			Return([]domain.Transcation{}, nil),
+		s.repo.EXPECT().ListTranscations(gomock.Any(), accountID, &domain.TranscationCursor{EventAt: first.EventAt, ID: first.ID}, maxPageSize).
+			Return([]domain.Transcation{second}, nil),
+	)
+
+	received := make([]domain.Transcation, 0)
+	err := s.svc.StreamTranscations(ctx, accountID, since, func(tx domain.Transcation) error {
+		received = append(received, tx)
+		if len(received) == 2 {
+			cancel()
+		}
+
+		return nil
+	})
+
+	s.Require().NoError(err)
+	s.Equal([]domain.Transcation{first, second}, received)
+}
+
+func (s *ServiceTestSuite) TestStreamTranscationsFromNow() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.svc = New(s.repo, WithClock(func() time.Time {
+		return now
+	}))
+
+	// without since the stream starts at the time of the call
+	accountID := uuid.NewString()
+	s.repo.EXPECT().GetAccount(gomock.Any(), accountID).Return(&domain.Account{ID: accountID}, nil)
+	s.repo.EXPECT().ListTranscations(gomock.Any(), accountID, &domain.TranscationCursor{EventAt: now}, maxPageSize).
+		DoAndReturn(func(context.Context, string, *domain.TranscationCursor, int) ([]domain.Transcation, error) {
+			cancel()
+			return nil, ctx.Err()
+		})
+
+	err := s.svc.StreamTranscations(ctx, accountID, time.Time{}, func(domain.Transcation) error {
+		return nil
+	})
+	s.Require().NoError(err)
+}
+
+func (s *ServiceTestSuite) TestStreamAccountEvents() {
+	streamPollInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	accountID := uuid.NewString()
+	first := domain.Event{ID: uuid.NewString(), Seq: 8, AccountID: accountID, Type: domain.EventTranscationCreated}
+	second := domain.Event{ID: uuid.NewString(), Seq: 9, AccountID: accountID, Type: domain.EventAccountLimitsChanged}
+
+	s.repo.EXPECT().GetAccount(gomock.Any(), accountID).Return(&domain.Account{ID: accountID}, nil)
+	s.repo.EXPECT().GetLastEventSeq(gomock.Any(), accountID).Return(int64(7), nil)
+	gomock.InOrder(
+		s.repo.EXPECT().ListAccountEvents(gomock.Any(), accountID, int64(7), eventPageSize).
+			DoAndReturn(func(context.Context, string, int64, int) ([]domain.Event, error) {
+				// the stream only polls again once it is woken up
+				go s.svc.NotifyAccountEvent(accountID)
+				return []domain.Event{}, nil
+			}),
+		s.repo.EXPECT().ListAccountEvents(gomock.Any(), accountID, int64(7), eventPageSize).
+			Return([]domain.Event{first, second}, nil),
+	)
+
+	received := make([]domain.Event, 0)
+	err := s.svc.StreamAccountEvents(ctx, accountID, -1, func(event domain.Event) error {
+		received = append(received, event)
+		if len(received) == 2 {
+			cancel()
+		}
+
+		return nil
+	})
+
+	s.Require().NoError(err)
+	s.Equal([]domain.Event{first, second}, received)
+
+	s.repo.EXPECT().GetAccount(gomock.Any(), accountID).Return(nil, domain.ErrNotFound)
+	err = s.svc.StreamAccountEvents(context.Background(), accountID, 0, func(domain.Event) error { return nil })
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+}
+
+func (s *ServiceTestSuite) TestUpdateAccountLimits() {
+	ctx := context.Background()
+	accountID := uuid.NewString()
+	limit := 500.0
+	negative := -1.0
+
+	tests := []struct {
+		name      string
+		mocks     func()
+		accountID string
+		req       domain.AccountLimitsReq
+		expError  error
+		expLimits *domain.AccountLimits
+	}{
+		{
+			name:     "invalid account id",
+			mocks:    func() {},
+			req:      domain.AccountLimitsReq{CreditLimit: &limit},
+			expError: ErrInvalidAccountID,
+		},
+		{
+			name:      "no limit given",
+			mocks:     func() {},
+			accountID: accountID,
+			expError:  ErrInvalidLimit,
+		},
+		{
+			name:      "negative limit",
+			mocks:     func() {},
+			accountID: accountID,
+			req:       domain.AccountLimitsReq{WithdrawalLimit: &negative},
+			expError:  ErrInvalidLimit,
+		},
+		{
+			name: "update limits with success",
+			mocks: func() {
+				s.repo.EXPECT().UpdateAccountLimits(gomock.Any(), accountID, domain.AccountLimitsReq{CreditLimit: &limit}, gomock.Any()).
+					Return(&domain.AccountLimits{AccountID: accountID, WithdrawalLimit: 1000, CreditLimit: limit}, nil)
+			},
+			accountID: accountID,
+			req:       domain.AccountLimitsReq{CreditLimit: &limit},
+			expLimits: &domain.AccountLimits{AccountID: accountID, WithdrawalLimit: 1000, CreditLimit: limit},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.mocks()
+
+			limits, err := s.svc.UpdateAccountLimits(ctx, tt.accountID, tt.req)
+			if tt.expError != nil {
+				s.Require().ErrorIs(err, tt.expError)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Require().Equal(tt.expLimits, limits)
+		})
+	}
+}
+
+func (s *ServiceTestSuite) TestAuthorization() {
+	svc := New(s.repo, WithAuthorizer(policy.New(policy.LogRecorder{})))
+
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice", Roles: []string{policy.RoleCustomer}})
+	stranger := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob", Roles: []string{policy.RoleCustomer}})
+	merchant := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "shop", Roles: []string{policy.RoleMerchant}})
+	account := &domain.Account{ID: uuid.NewString(), OwnerID: "alice", WithdrawalLimit: 1000, CreaditLimit: 1000}
+
+	s.Run("accounts are owned by their creator", func() {
+		s.repo.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(nil)
+
+		created, err := svc.CreateAccount(owner, "12345678")
+		s.Require().NoError(err)
+		s.Equal("alice", created.OwnerID)
+	})
+
+	s.Run("customers only read their own accounts", func() {
+		s.repo.EXPECT().GetAccount(gomock.Any(), account.ID).Return(account, nil).Times(2)
+
+		_, err := svc.GetAccount(owner, account.ID)
+		s.Require().NoError(err)
+
+		_, err = svc.GetAccount(stranger, account.ID)
+		s.Require().ErrorIs(err, policy.ErrForbidden)
+	})
+
+	s.Run("missing accounts are forbidden like the accounts of others", func() {
+		missing := uuid.NewString()
+		operator := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "ops", Roles: []string{policy.RoleOperator}})
+		s.repo.EXPECT().GetAccount(gomock.Any(), missing).Return(nil, domain.ErrNotFound).Times(4)
+
+		_, err := svc.GetAccount(stranger, missing)
+		s.Require().ErrorIs(err, policy.ErrForbidden)
+
+		_, err = svc.ListTranscations(stranger, missing, 0, "")
+		s.Require().ErrorIs(err, policy.ErrForbidden)
+
+		err = svc.StreamAccountEvents(stranger, missing, 0, func(domain.Event) error { return nil })
+		s.Require().ErrorIs(err, policy.ErrForbidden)
+
+		// callers allowed to read every account learn it doesn't exist
+		_, err = svc.GetAccount(operator, missing)
+		s.Require().ErrorIs(err, domain.ErrNotFound)
+	})
+
+	s.Run("merchants only charge purchases", func() {
+		s.repo.EXPECT().GetAccount(gomock.Any(), account.ID).Return(account, nil).Times(2)
+		s.repo.EXPECT().CreateDebitTranscation(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := svc.CreateTranscation(merchant, domain.Transcation{AccountID: account.ID, OperationTypeID: 1, Amount: 10})
+		s.Require().NoError(err)
+
+		_, err = svc.CreateTranscation(merchant, domain.Transcation{AccountID: account.ID, OperationTypeID: 3, Amount: 10})
+		s.Require().ErrorIs(err, policy.ErrForbidden)
+	})
+
+	s.Run("customers do not change limits", func() {
+		limit := 5000.0
+		s.repo.EXPECT().GetAccount(gomock.Any(), account.ID).Return(account, nil)
+
+		_, err := svc.UpdateAccountLimits(owner, account.ID, domain.AccountLimitsReq{CreditLimit: &limit})
+		s.Require().ErrorIs(err, policy.ErrForbidden)
+	})
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces the service methods with the global tracer provider, spans
+// are dropped until one is set
+var tracer = otel.Tracer("github.com/madhurikadam/app-transcation/internal/service")
+
+// startSpan starts the span of a service method as a child of the span of ctx
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name)
+}
+
+// endSpan ends the span of a method, recording the error it returns
+func endSpan(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+
+	span.End()
+}
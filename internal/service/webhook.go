@@ -0,0 +1,508 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/policy"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+	"github.com/madhurikadam/app-transcation/pkg/webhook"
+)
+
+type (
+	WebhookRepo interface {
+		CreateWebhookSubscription(ctx context.Context, sub domain.WebhookSubscription) error
+		GetWebhookSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+		ListWebhookSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+		DeleteWebhookSubscription(ctx context.Context, id string) error
+
+		CreateWebhookDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error
+		GetWebhookDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error)
+		ListWebhookDeliveries(ctx context.Context, subscriptionID string) ([]domain.WebhookDelivery, error)
+		ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error)
+		UpdateWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error
+	}
+
+	WebhookConfig struct {
+		// MaxAttempts is the number of failed attempts after which a delivery
+		// is moved to the dead letter state
+		MaxAttempts int
+		// Backoff is the delay before the first retry, it doubles with every
+		// further attempt up to MaxBackoff
+		Backoff    time.Duration
+		MaxBackoff time.Duration
+		Timeout    time.Duration
+		Interval   time.Duration
+		BatchSize  int
+		// AllowPrivateAddresses lets endpoints be on loopback, private and
+		// link-local addresses, meant for local development only
+		AllowPrivateAddresses bool
+	}
+
+	// Resolver looks up the addresses of the hosts of webhook endpoints,
+	// *net.Resolver is one
+	Resolver interface {
+		LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	}
+
+	WebhookService struct {
+		repo       WebhookRepo
+		client     *http.Client
+		resolver   Resolver
+		cfg        WebhookConfig
+		authorizer Authorizer
+		now        func() time.Time
+	}
+)
+
+var (
+	ErrInvalidWebhookURL     = fmt.Errorf("invalid webhook url")
+	ErrInvalidEventType      = fmt.Errorf("invalid event type")
+	ErrInvalidSubscriptionID = fmt.Errorf("invalid subscription id")
+	ErrInvalidDeliveryID     = fmt.Errorf("invalid delivery id")
+	ErrUnexpectedStatusCode  = fmt.Errorf("unexpected status code")
+)
+
+const maxWebhookResponseBodyLen = 64 << 10
+
+func NewWebhookService(repo WebhookRepo, client *http.Client, cfg WebhookConfig, opts ...Option) WebhookService {
+	// endpoints are dialed on public addresses only, their names may
+	// resolve elsewhere than when they were registered
+	if client == nil {
+		client = webhook.NewClient(cfg.Timeout)
+		if cfg.AllowPrivateAddresses {
+			client = &http.Client{Timeout: cfg.Timeout}
+		}
+	}
+
+	o := newOptions(opts)
+
+	return WebhookService{
+		repo:       repo,
+		client:     client,
+		resolver:   net.DefaultResolver,
+		cfg:        cfg,
+		authorizer: o.authorizer,
+		now: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+// CreateSubscription registers a webhook endpoint, a signing secret is
+// generated when none is given
+func (w *WebhookService) CreateSubscription(ctx context.Context, req domain.WebhookSubscriptionReq) (*domain.WebhookSubscription, error) {
+	if err := w.validateWebhookURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	if err := w.authorize(ctx, policy.ActionManageWebhooks); err != nil {
+		return nil, err
+	}
+
+	eventTypes := req.EventTypes
+	if len(eventTypes) == 0 {
+		eventTypes = []string{domain.EventTypeAll}
+	}
+
+	for _, eventType := range eventTypes {
+		if err := validateEventType(eventType); err != nil {
+			return nil, err
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	now := w.now()
+	sub := domain.WebhookSubscription{
+		ID:         uuid.NewString(),
+		URL:        req.URL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := w.repo.CreateWebhookSubscription(ctx, sub); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("failed to create webhook subscription")
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+// GetSubscription returns the subscription without its secret
+func (w *WebhookService) GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	if id == "" {
+		return nil, ErrInvalidSubscriptionID
+	}
+
+	if err := w.authorize(ctx, policy.ActionReadWebhooks); err != nil {
+		return nil, err
+	}
+
+	sub, err := w.repo.GetWebhookSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.Secret = ""
+
+	return sub, nil
+}
+
+// ListSubscriptions returns all subscriptions without their secrets
+func (w *WebhookService) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	if err := w.authorize(ctx, policy.ActionReadWebhooks); err != nil {
+		return nil, err
+	}
+
+	subs, err := w.repo.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+
+	return subs, nil
+}
+
+// DeleteSubscription removes the subscription together with its deliveries
+func (w *WebhookService) DeleteSubscription(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrInvalidSubscriptionID
+	}
+
+	if err := w.authorize(ctx, policy.ActionManageWebhooks); err != nil {
+		return err
+	}
+
+	return w.repo.DeleteWebhookSubscription(ctx, id)
+}
+
+// ListDeliveries returns the delivery log of a subscription, newest first
+func (w *WebhookService) ListDeliveries(ctx context.Context, subscriptionID string) ([]domain.WebhookDelivery, error) {
+	if subscriptionID == "" {
+		return nil, ErrInvalidSubscriptionID
+	}
+
+	if err := w.authorize(ctx, policy.ActionReadWebhooks); err != nil {
+		return nil, err
+	}
+
+	if _, err := w.repo.GetWebhookSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	return w.repo.ListWebhookDeliveries(ctx, subscriptionID)
+}
+
+// Redeliver schedules a delivery to be sent again right away, it also revives
+// deliveries in the dead letter state
+func (w *WebhookService) Redeliver(ctx context.Context, subscriptionID, deliveryID string) (*domain.WebhookDelivery, error) {
+	if subscriptionID == "" {
+		return nil, ErrInvalidSubscriptionID
+	}
+
+	if deliveryID == "" {
+		return nil, ErrInvalidDeliveryID
+	}
+
+	if err := w.authorize(ctx, policy.ActionManageWebhooks); err != nil {
+		return nil, err
+	}
+
+	delivery, err := w.repo.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if delivery.SubscriptionID != subscriptionID {
+		return nil, domain.ErrNotFound
+	}
+
+	now := w.now()
+	delivery.Status = domain.DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+
+	if err := w.repo.UpdateWebhookDelivery(ctx, *delivery); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// authorize checks that the caller of ctx may perform the action
+func (w *WebhookService) authorize(ctx context.Context, action policy.Action) error {
+	if w.authorizer == nil {
+		return nil
+	}
+
+	return w.authorizer.Authorize(ctx, action, policy.Resource{})
+}
+
+// Publish enqueues a delivery of every event for each matching subscription,
+// it is the webhook Publisher of the outbox relay
+func (w *WebhookService) Publish(ctx context.Context, events []domain.Event) error {
+	subs, err := w.repo.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := w.now()
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for _, event := range events {
+		var payload []byte
+		for _, sub := range subs {
+			if !sub.Matches(event.Type) {
+				continue
+			}
+
+			if payload == nil {
+				if payload, err = json.Marshal(event); err != nil {
+					return fmt.Errorf("failed to marshal event: %w", err)
+				}
+			}
+
+			deliveries = append(deliveries, domain.WebhookDelivery{
+				ID:             uuid.NewString(),
+				SubscriptionID: sub.ID,
+				EventID:        event.ID,
+				EventType:      event.Type,
+				Payload:        payload,
+				Status:         domain.DeliveryStatusPending,
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			})
+		}
+	}
+
+	return w.repo.CreateWebhookDeliveries(ctx, deliveries)
+}
+
+// DispatchDue sends every delivery that is due and records the outcome
+func (w *WebhookService) DispatchDue(ctx context.Context) (int, error) {
+	deliveries, err := w.repo.ClaimWebhookDeliveries(ctx, w.now(), 2*w.cfg.Timeout, w.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// deliveries are independent, a failed update doesn't cancel the others
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failed   int
+		firstErr error
+	)
+	for _, delivery := range deliveries {
+		delivery := delivery
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx := logging.WithFields(ctx, log.Fields{
+				"delivery_id":     delivery.ID,
+				"subscription_id": delivery.SubscriptionID,
+			})
+			delivery = w.deliver(ctx, delivery)
+
+			if err := w.repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+				logging.FromContext(ctx).WithError(err).Error("failed to record webhook delivery")
+
+				mu.Lock()
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return len(deliveries), fmt.Errorf("failed to record %d of %d webhook deliveries: %w", failed, len(deliveries), firstErr)
+	}
+
+	return len(deliveries), nil
+}
+
+// Run dispatches deliveries until ctx is cancelled
+func (w *WebhookService) Run(ctx context.Context) error {
+	log.Info("starting webhook dispatcher")
+
+	return poll(ctx, w.cfg.Interval, w.cfg.BatchSize, "webhook dispatcher", w.DispatchDue)
+}
+
+func (w *WebhookService) deliver(ctx context.Context, delivery domain.WebhookDelivery) domain.WebhookDelivery {
+	now := w.now()
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	delivery.LastStatusCode = nil
+
+	statusCode, err := w.send(ctx, delivery, now)
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	if err == nil {
+		delivery.Status = domain.DeliveryStatusDelivered
+		delivery.LastError = nil
+
+		return delivery
+	}
+
+	msg := err.Error()
+	delivery.LastError = &msg
+
+	logger := logging.FromContext(ctx).WithField("attempts", delivery.Attempts).WithError(err)
+
+	if delivery.Attempts >= w.cfg.MaxAttempts {
+		logger.Warn("webhook delivery moved to dead letter")
+		delivery.Status = domain.DeliveryStatusDeadLetter
+
+		return delivery
+	}
+
+	logger.Info("webhook delivery failed, retrying")
+	delivery.NextAttemptAt = now.Add(w.backoff(delivery.Attempts))
+
+	return delivery
+}
+
+// send posts the delivery to its subscription, the trace context of the
+// delivery span goes along so that receivers may join the trace
+func (w *WebhookService) send(ctx context.Context, delivery domain.WebhookDelivery, now time.Time) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "webhook.deliver", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("webhook.delivery_id", delivery.ID),
+		attribute.String("webhook.event_type", delivery.EventType),
+		attribute.Int("webhook.attempt", delivery.Attempts),
+	))
+	defer endSpan(span, &err)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.DeliveryHeader, delivery.ID)
+	req.Header.Set(webhook.EventHeader, delivery.EventType)
+	req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(delivery.Secret, timestamp, delivery.Payload))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookResponseBodyLen))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("%w: %d", ErrUnexpectedStatusCode, resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (w *WebhookService) backoff(attempts int) time.Duration {
+	delay := w.cfg.Backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= w.cfg.MaxBackoff {
+			return w.cfg.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// validateWebhookURL checks that the endpoint is an http url whose host
+// resolves to public addresses only
+func (w *WebhookService) validateWebhookURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ErrInvalidWebhookURL
+	}
+
+	if w.cfg.AllowPrivateAddresses {
+		return nil
+	}
+
+	addrs := []net.IPAddr{{IP: net.ParseIP(u.Hostname())}}
+	if addrs[0].IP == nil {
+		if addrs, err = w.resolver.LookupIPAddr(ctx, u.Hostname()); err != nil {
+			return fmt.Errorf("%w: failed to resolve %s", ErrInvalidWebhookURL, u.Hostname())
+		}
+	}
+
+	for _, addr := range addrs {
+		if !webhook.PublicIP(addr.IP) {
+			return fmt.Errorf("%w: %s isn't a public address", ErrInvalidWebhookURL, u.Hostname())
+		}
+	}
+
+	return nil
+}
+
+func validateEventType(eventType string) error {
+	if eventType == domain.EventTypeAll {
+		return nil
+	}
+
+	for _, val := range domain.EventTypes {
+		if val == eventType {
+			return nil
+		}
+	}
+
+	return ErrInvalidEventType
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
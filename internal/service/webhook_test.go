@@ -0,0 +1,501 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/madhurikadam/app-transcation/internal/domain"
+	"github.com/madhurikadam/app-transcation/internal/service/mocks"
+	"github.com/madhurikadam/app-transcation/pkg/webhook"
+
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const testWebhookSecret = "whsec_test"
+
+type WebhookTestSuite struct {
+	suite.Suite
+
+	repo     *mocks.MockWebhookRepo
+	resolver *mocks.MockResolver
+	now      time.Time
+
+	svc WebhookService
+}
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+
+	suite.Run(t, new(WebhookTestSuite))
+}
+
+func (s *WebhookTestSuite) SetupTest() {
+	ctrl := gomock.NewController(s.T())
+	s.repo = mocks.NewMockWebhookRepo(ctrl)
+	s.resolver = mocks.NewMockResolver(ctrl)
+	s.now = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// the receivers of the tests listen on loopback
+	s.svc = NewWebhookService(s.repo, &http.Client{Timeout: time.Second}, s.config())
+	s.svc.resolver = s.resolver
+	s.svc.now = func() time.Time {
+		return s.now
+	}
+}
+
+func (s *WebhookTestSuite) config() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts: 3,
+		Backoff:     10 * time.Second,
+		MaxBackoff:  time.Minute,
+		Timeout:     time.Second,
+		Interval:    time.Second,
+		BatchSize:   10,
+	}
+}
+
+// resolves makes host resolve to the addresses
+func (s *WebhookTestSuite) resolves(host string, addrs ...string) {
+	ips := make([]net.IPAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, net.IPAddr{IP: net.ParseIP(addr)})
+	}
+
+	s.resolver.EXPECT().LookupIPAddr(gomock.Any(), host).Return(ips, nil)
+}
+
+func (s *WebhookTestSuite) TestCreateSubscription() {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		mocks    func()
+		input    domain.WebhookSubscriptionReq
+		expErr   bool
+		expError error
+	}{
+		{
+			name:     "invalid url",
+			mocks:    func() {},
+			input:    domain.WebhookSubscriptionReq{URL: "ftp://example.com"},
+			expErr:   true,
+			expError: ErrInvalidWebhookURL,
+		},
+		{
+			name:     "loopback address",
+			mocks:    func() {},
+			input:    domain.WebhookSubscriptionReq{URL: "http://127.0.0.1:8080/hook"},
+			expErr:   true,
+			expError: ErrInvalidWebhookURL,
+		},
+		{
+			name:     "cloud metadata endpoint",
+			mocks:    func() {},
+			input:    domain.WebhookSubscriptionReq{URL: "http://169.254.169.254/latest/meta-data"},
+			expErr:   true,
+			expError: ErrInvalidWebhookURL,
+		},
+		{
+			name: "name resolving to a private address",
+			mocks: func() {
+				s.resolves("internal.example.com", "93.184.216.34", "10.0.0.7")
+			},
+			input:    domain.WebhookSubscriptionReq{URL: "https://internal.example.com/hook"},
+			expErr:   true,
+			expError: ErrInvalidWebhookURL,
+		},
+		{
+			name: "name not resolving",
+			mocks: func() {
+				s.resolver.EXPECT().LookupIPAddr(gomock.Any(), "missing.example.com").Return(nil, errors.New("no such host"))
+			},
+			input:    domain.WebhookSubscriptionReq{URL: "https://missing.example.com/hook"},
+			expErr:   true,
+			expError: ErrInvalidWebhookURL,
+		},
+		{
+			name: "invalid event type",
+			mocks: func() {
+				s.resolves("example.com", "93.184.216.34")
+			},
+			input: domain.WebhookSubscriptionReq{
+				URL:        "https://example.com/hook",
+				EventTypes: []string{"account.deleted"},
+			},
+			expErr:   true,
+			expError: ErrInvalidEventType,
+		},
+		{
+			name: "failed to create subscription in database",
+			mocks: func() {
+				s.resolves("example.com", "93.184.216.34")
+				s.repo.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Return(errTestFoo)
+			},
+			input:    domain.WebhookSubscriptionReq{URL: "https://example.com/hook"},
+			expErr:   true,
+			expError: errTestFoo,
+		},
+		{
+			name: "create subscription with success",
+			mocks: func() {
+				s.resolves("example.com", "93.184.216.34")
+				s.repo.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			input: domain.WebhookSubscriptionReq{URL: "https://example.com/hook"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.mocks()
+
+			sub, err := s.svc.CreateSubscription(ctx, tt.input)
+			if tt.expErr {
+				s.Require().Error(err)
+				s.Require().ErrorIs(err, tt.expError)
+
+				return
+			}
+
+			s.Require().NoError(err)
+			s.NotEmpty(sub.ID)
+			s.NotEmpty(sub.Secret)
+			s.True(sub.Active)
+			s.Equal([]string{domain.EventTypeAll}, sub.EventTypes)
+		})
+	}
+}
+
+func (s *WebhookTestSuite) TestPublish() {
+	ctx := context.Background()
+	event := domain.Event{
+		ID:        uuid.NewString(),
+		AccountID: uuid.NewString(),
+		Type:      domain.EventTranscationCreated,
+		Payload:   []byte(`{"amount":10}`),
+	}
+
+	s.repo.EXPECT().ListWebhookSubscriptions(gomock.Any()).Return([]domain.WebhookSubscription{
+		{ID: "all", EventTypes: []string{domain.EventTypeAll}, Active: true},
+		{ID: "created", EventTypes: []string{domain.EventTranscationCreated}, Active: true},
+		{ID: "inactive", EventTypes: []string{domain.EventTypeAll}, Active: false},
+	}, nil)
+	s.repo.EXPECT().CreateWebhookDeliveries(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, deliveries []domain.WebhookDelivery) error {
+			s.Require().Len(deliveries, 2)
+			s.Equal("all", deliveries[0].SubscriptionID)
+			s.Equal("created", deliveries[1].SubscriptionID)
+
+			for _, d := range deliveries {
+				s.Equal(event.ID, d.EventID)
+				s.Equal(domain.DeliveryStatusPending, d.Status)
+				s.Equal(s.now, d.NextAttemptAt)
+			}
+
+			return nil
+		})
+
+	s.Require().NoError(s.svc.Publish(ctx, []domain.Event{event}))
+}
+
+func (s *WebhookTestSuite) TestDispatchDue() {
+	ctx := context.Background()
+	payload := []byte(`{"id":"event"}`)
+
+	tests := []struct {
+		name        string
+		status      int
+		attempts    int
+		expStatus   string
+		expNextTry  time.Time
+		expAttempts int
+	}{
+		{
+			name:        "delivered with success",
+			status:      http.StatusNoContent,
+			expStatus:   domain.DeliveryStatusDelivered,
+			expAttempts: 1,
+		},
+		{
+			name:        "failed delivery is retried with backoff",
+			status:      http.StatusInternalServerError,
+			attempts:    1,
+			expStatus:   domain.DeliveryStatusPending,
+			expNextTry:  s.now.Add(20 * time.Second),
+			expAttempts: 2,
+		},
+		{
+			name:        "failed delivery is dead lettered after max attempts",
+			status:      http.StatusBadGateway,
+			attempts:    2,
+			expStatus:   domain.DeliveryStatusDeadLetter,
+			expAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			received := 0
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received++
+				body, err := io.ReadAll(r.Body)
+				s.Require().NoError(err)
+				s.Equal(payload, body)
+				s.Equal(domain.EventTranscationCreated, r.Header.Get(webhook.EventHeader))
+				s.NoError(webhook.Verify(
+					testWebhookSecret,
+					r.Header.Get(webhook.TimestampHeader),
+					r.Header.Get(webhook.SignatureHeader),
+					body,
+					s.now,
+					time.Minute,
+				))
+
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			delivery := domain.WebhookDelivery{
+				ID:             uuid.NewString(),
+				SubscriptionID: uuid.NewString(),
+				EventType:      domain.EventTranscationCreated,
+				Payload:        payload,
+				Status:         domain.DeliveryStatusPending,
+				Attempts:       tt.attempts,
+				URL:            receiver.URL,
+				Secret:         testWebhookSecret,
+			}
+
+			s.repo.EXPECT().ClaimWebhookDeliveries(gomock.Any(), s.now, gomock.Any(), 10).
+				Return([]domain.WebhookDelivery{delivery}, nil)
+			s.repo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, d domain.WebhookDelivery) error {
+					s.Equal(tt.expStatus, d.Status)
+					s.Equal(tt.expAttempts, d.Attempts)
+					s.Require().NotNil(d.LastStatusCode)
+					s.Equal(tt.status, *d.LastStatusCode)
+
+					if tt.expStatus == domain.DeliveryStatusPending {
+						s.Equal(tt.expNextTry, d.NextAttemptAt)
+						s.NotNil(d.LastError)
+					}
+
+					return nil
+				})
+
+			n, err := s.svc.DispatchDue(ctx)
+			s.Require().NoError(err)
+			s.Equal(1, n)
+			s.Equal(1, received)
+		})
+	}
+}
+
+func (s *WebhookTestSuite) TestDispatchDueFailedUpdate() {
+	var mu sync.Mutex
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received++
+		mu.Unlock()
+
+		// the other deliveries are still being sent when the first update fails
+		if r.URL.Path != "/fast" {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer receiver.Close()
+
+	deliveries := make([]domain.WebhookDelivery, 3)
+	for i := range deliveries {
+		deliveries[i] = domain.WebhookDelivery{
+			ID:        uuid.NewString(),
+			EventType: domain.EventTranscationCreated,
+			Payload:   []byte(`{}`),
+			Status:    domain.DeliveryStatusPending,
+			URL:       receiver.URL,
+			Secret:    testWebhookSecret,
+		}
+	}
+	deliveries[0].URL = receiver.URL + "/fast"
+
+	s.repo.EXPECT().ClaimWebhookDeliveries(gomock.Any(), s.now, gomock.Any(), 10).Return(deliveries, nil)
+	s.repo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, d domain.WebhookDelivery) error {
+			if d.ID == deliveries[0].ID {
+				return errors.New("connection reset")
+			}
+
+			s.Equal(domain.DeliveryStatusDelivered, d.Status)
+
+			return nil
+		}).Times(3)
+
+	n, err := s.svc.DispatchDue(context.Background())
+	s.EqualError(err, "failed to record 1 of 3 webhook deliveries: connection reset")
+	s.Equal(3, n)
+	s.Equal(3, received)
+}
+
+func (s *WebhookTestSuite) TestDispatchDuePrivateAddress() {
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer receiver.Close()
+
+	// the default client checks the address it dials, a name registered on a
+	// public address may resolve to loopback later on
+	s.svc = NewWebhookService(s.repo, nil, s.config())
+	s.svc.now = func() time.Time {
+		return s.now
+	}
+
+	s.repo.EXPECT().ClaimWebhookDeliveries(gomock.Any(), s.now, gomock.Any(), 10).Return([]domain.WebhookDelivery{{
+		ID:        uuid.NewString(),
+		EventType: domain.EventTranscationCreated,
+		Payload:   []byte(`{}`),
+		Status:    domain.DeliveryStatusPending,
+		URL:       receiver.URL,
+		Secret:    testWebhookSecret,
+	}}, nil)
+	s.repo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, d domain.WebhookDelivery) error {
+			s.Equal(domain.DeliveryStatusPending, d.Status)
+			s.Equal(1, d.Attempts)
+			s.Require().NotNil(d.LastError)
+			s.Contains(*d.LastError, webhook.ErrForbiddenAddress.Error())
+
+			return nil
+		})
+
+	_, err := s.svc.DispatchDue(context.Background())
+	s.Require().NoError(err)
+	s.Zero(received)
+}
+
+func (s *WebhookTestSuite) TestDispatchDueTraceContext() {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer receiver.Close()
+
+	s.repo.EXPECT().ClaimWebhookDeliveries(gomock.Any(), s.now, gomock.Any(), 10).Return([]domain.WebhookDelivery{{
+		ID:        uuid.NewString(),
+		EventType: domain.EventTranscationCreated,
+		Payload:   []byte(`{}`),
+		Status:    domain.DeliveryStatusPending,
+		URL:       receiver.URL,
+		Secret:    testWebhookSecret,
+	}}, nil)
+	s.repo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).Return(nil)
+
+	_, err := s.svc.DispatchDue(context.Background())
+	s.Require().NoError(err)
+
+	var deliver sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "webhook.deliver" {
+			deliver = span
+		}
+	}
+	s.Require().NotNil(deliver)
+
+	// the receiver gets the context of the delivery span
+	s.Equal(fmt.Sprintf("00-%s-%s-01", deliver.SpanContext().TraceID(), deliver.SpanContext().SpanID()), traceparent)
+}
+
+func (s *WebhookTestSuite) TestRedeliver() {
+	ctx := context.Background()
+	subID := uuid.NewString()
+	deliveryID := uuid.NewString()
+
+	tests := []struct {
+		name           string
+		mocks          func()
+		subscriptionID string
+		expErr         bool
+		expError       error
+	}{
+		{
+			name:           "invalid subscription id",
+			mocks:          func() {},
+			subscriptionID: "",
+			expErr:         true,
+			expError:       ErrInvalidSubscriptionID,
+		},
+		{
+			name: "delivery belongs to another subscription",
+			mocks: func() {
+				s.repo.EXPECT().GetWebhookDelivery(gomock.Any(), deliveryID).Return(&domain.WebhookDelivery{
+					ID:             deliveryID,
+					SubscriptionID: uuid.NewString(),
+				}, nil)
+			},
+			subscriptionID: subID,
+			expErr:         true,
+			expError:       domain.ErrNotFound,
+		},
+		{
+			name: "redeliver dead lettered delivery with success",
+			mocks: func() {
+				s.repo.EXPECT().GetWebhookDelivery(gomock.Any(), deliveryID).Return(&domain.WebhookDelivery{
+					ID:             deliveryID,
+					SubscriptionID: subID,
+					Status:         domain.DeliveryStatusDeadLetter,
+					Attempts:       3,
+				}, nil)
+				s.repo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			subscriptionID: subID,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.mocks()
+
+			delivery, err := s.svc.Redeliver(ctx, tt.subscriptionID, deliveryID)
+			if tt.expErr {
+				s.Require().Error(err)
+				s.Require().Equal(tt.expError, err)
+
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(domain.DeliveryStatusPending, delivery.Status)
+			s.Equal(0, delivery.Attempts)
+			s.Equal(s.now, delivery.NextAttemptAt)
+		})
+	}
+}
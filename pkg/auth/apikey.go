@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SchemeAPIKey is the authorization scheme of API keys
+	SchemeAPIKey = "ApiKey"
+
+	apiKeyPrefix    = "ak"
+	apiKeySecretLen = 32
+)
+
+// NewAPIKey generates the key of the API key with id, only the returned hash
+// is meant to be stored. Keys are formatted ak_<id>_<secret> so that the
+// stored key is found without scanning.
+func NewAPIKey(id string) (string, []byte, error) {
+	secret := make([]byte, apiKeySecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	key := apiKeyPrefix + "_" + id + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, HashAPIKey(key), nil
+}
+
+// ParseAPIKey returns the id of the API key
+func ParseAPIKey(key string) (string, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", ErrInvalidCredentials
+	}
+
+	return parts[1], nil
+}
+
+// HashAPIKey hashes a key for storage, keys are random enough for a plain
+// sha256 not to be brute forced
+func HashAPIKey(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+// VerifyAPIKey reports whether key matches the stored hash
+func VerifyAPIKey(key string, hash []byte) bool {
+	return subtle.ConstantTimeCompare(HashAPIKey(key), hash) == 1
+}
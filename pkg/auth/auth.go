@@ -0,0 +1,137 @@
+// Package auth authenticates the callers of the http and grpc apis and carries
+// the authenticated principal in the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/pkg/http/controller"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+var (
+	ErrMissingCredentials     = errors.New("missing credentials")
+	ErrUnsupportedScheme      = errors.New("unsupported authorization scheme")
+	ErrInvalidCredentials     = errors.New("invalid credentials")
+	ErrUnauthenticatedRequest = errors.New("unauthenticated request")
+)
+
+// RateLimitError is returned by authenticators when the caller used up its
+// request quota
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject identifies the caller, the sub claim of a token
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	Scopes  []string `json:"scopes"`
+	// Method is the authorization scheme the caller authenticated with
+	Method string `json:"method"`
+}
+
+// HasRole reports whether the principal was granted role
+func (p Principal) HasRole(role string) bool {
+	return contains(p.Roles, role)
+}
+
+// HasScope reports whether the principal was granted scope
+func (p Principal) HasScope(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal authenticated for the request
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// Authenticator validates the credentials of one authorization scheme
+type Authenticator interface {
+	// Scheme is the authorization scheme handled, e.g. Bearer
+	Scheme() string
+	Authenticate(ctx context.Context, credentials string) (*Principal, error)
+}
+
+// Authenticate finds the authenticator for the scheme of the authorization
+// value and validates its credentials
+func Authenticate(ctx context.Context, authorization string, authenticators []Authenticator) (*Principal, error) {
+	if authorization == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	for _, authenticator := range authenticators {
+		if strings.EqualFold(authenticator.Scheme(), parts[0]) {
+			return authenticator.Authenticate(ctx, strings.TrimSpace(parts[1]))
+		}
+	}
+
+	return nil, ErrUnsupportedScheme
+}
+
+// Middleware rejects requests without valid credentials with 401 and stores
+// the principal of the others in the request context
+func Middleware(authenticators ...Authenticator) func(http.Handler) http.Handler {
+	schemes := make([]string, 0, len(authenticators))
+	for _, authenticator := range authenticators {
+		schemes = append(schemes, authenticator.Scheme())
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := Authenticate(r.Context(), r.Header.Get("Authorization"), authenticators)
+
+			var rateLimitErr *RateLimitError
+			if errors.As(err, &rateLimitErr) {
+				controller.BaseController{}.SetRetryAfter(w, rateLimitErr.RetryAfter)
+				controller.BaseController{}.WriteErrorResponseMsg(w, http.StatusTooManyRequests, rateLimitErr.Error())
+				return
+			}
+
+			if err != nil {
+				logging.FromContext(r.Context()).WithField("path", r.URL.Path).WithError(err).Info("rejected unauthenticated request")
+
+				for _, scheme := range schemes {
+					w.Header().Add("WWW-Authenticate", scheme)
+				}
+				controller.BaseController{}.WriteErrorResponseMsg(w, http.StatusUnauthorized, ErrUnauthenticatedRequest.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// staticAuthenticator accepts a single credential
+type staticAuthenticator struct {
+	scheme     string
+	credential string
+}
+
+func (s staticAuthenticator) Scheme() string {
+	return s.scheme
+}
+
+func (s staticAuthenticator) Authenticate(_ context.Context, credentials string) (*Principal, error) {
+	if credentials != s.credential {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{Subject: s.scheme + "-caller", Method: s.scheme}, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	handler := Middleware(
+		staticAuthenticator{scheme: "Bearer", credential: "token"},
+		staticAuthenticator{scheme: "ApiKey", credential: "key"},
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		require.True(t, ok)
+
+		w.Write([]byte(principal.Subject))
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		expStatus     int
+		expSubject    string
+	}{
+		{name: "missing authorization", expStatus: http.StatusUnauthorized},
+		{name: "missing credentials", authorization: "Bearer ", expStatus: http.StatusUnauthorized},
+		{name: "unsupported scheme", authorization: "Basic dXNlcjpwYXNz", expStatus: http.StatusUnauthorized},
+		{name: "invalid credentials", authorization: "Bearer other", expStatus: http.StatusUnauthorized},
+		{name: "bearer token", authorization: "Bearer token", expStatus: http.StatusOK, expSubject: "Bearer-caller"},
+		{name: "scheme is case insensitive", authorization: "bearer token", expStatus: http.StatusOK, expSubject: "Bearer-caller"},
+		{name: "api key", authorization: "ApiKey key", expStatus: http.StatusOK, expSubject: "ApiKey-caller"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expStatus, rec.Code)
+			if tt.expStatus == http.StatusUnauthorized {
+				require.Equal(t, []string{"Bearer", "ApiKey"}, rec.Header().Values("WWW-Authenticate"))
+				return
+			}
+
+			require.Equal(t, tt.expSubject, rec.Body.String())
+		})
+	}
+}
+
+// limitedAuthenticator refuses every call over its rate limit
+type limitedAuthenticator struct{}
+
+func (limitedAuthenticator) Scheme() string {
+	return SchemeAPIKey
+}
+
+func (limitedAuthenticator) Authenticate(context.Context, string) (*Principal, error) {
+	return nil, &RateLimitError{RetryAfter: 1500 * time.Millisecond}
+}
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	t.Parallel()
+
+	handler := Middleware(limitedAuthenticator{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("rate limited request reached the handler")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	req.Header.Set("Authorization", "ApiKey key")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("Retry-After"))
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+// UnaryServerInterceptor is the grpc counterpart of Middleware, credentials
+// are read from the authorization metadata
+func UnaryServerInterceptor(authenticators ...Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticateGRPC(ctx, info.FullMethod, authenticators)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor
+func StreamServerInterceptor(authenticators ...Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticateGRPC(stream.Context(), info.FullMethod, authenticators)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func authenticateGRPC(ctx context.Context, method string, authenticators []Authenticator) (context.Context, error) {
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+
+	principal, err := Authenticate(ctx, authorization, authenticators)
+
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return nil, status.Error(codes.ResourceExhausted, rateLimitErr.Error())
+	}
+
+	if err != nil {
+		logging.FromContext(ctx).WithField("method", method).WithError(err).Info("rejected unauthenticated request")
+		return nil, status.Error(codes.Unauthenticated, ErrUnauthenticatedRequest.Error())
+	}
+
+	return WithPrincipal(ctx, principal), nil
+}
+
+// serverStream overrides the context of a grpc stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
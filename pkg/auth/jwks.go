@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet returns the public key a token was signed with from its kid header
+type KeySet interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// JWKS is a static set of public keys, as served by a JSON Web Key Set
+type JWKS struct {
+	keys map[string]crypto.PublicKey
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses a JSON Web Key Set, only RSA and P-256 signing keys are
+// kept, keys of other types are ignored
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	jwks := &JWKS{keys: make(map[string]crypto.PublicKey)}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", jwk.Kid, err)
+		}
+
+		if key != nil {
+			jwks.keys[jwk.Kid] = key
+		}
+	}
+
+	return jwks, nil
+}
+
+// LoadJWKSFile reads a JSON Web Key Set from a file
+func LoadJWKSFile(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks: %w", err)
+	}
+
+	return ParseJWKS(data)
+}
+
+func (j *JWKS) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid base64url value")
+	}
+
+	return new(big.Int).SetBytes(raw), nil
+}
+
+// jwksFetchTimeout bounds a fetch of the remote keys, it is shared by every
+// caller waiting for it so it doesn't run with the context of any of them
+const jwksFetchTimeout = 10 * time.Second
+
+// RemoteJWKS fetches a JSON Web Key Set over http, it refreshes the keys every
+// refresh interval and when a token names an unknown kid, at most once per
+// minRefresh so that forged kids cannot hammer the issuer. A single fetch runs
+// at a time, the callers it doesn't concern keep using the fresh keys.
+type RemoteJWKS struct {
+	url        string
+	client     *http.Client
+	refresh    time.Duration
+	minRefresh time.Duration
+
+	group singleflight.Group
+
+	// mu only guards the swap of the keys, it is never held while fetching
+	mu        sync.RWMutex
+	jwks      *JWKS
+	fetchedAt time.Time
+}
+
+func NewRemoteJWKS(url string, client *http.Client, refresh time.Duration) *RemoteJWKS {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	return &RemoteJWKS{
+		url:        url,
+		client:     client,
+		refresh:    refresh,
+		minRefresh: time.Minute,
+	}
+}
+
+func (r *RemoteJWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	jwks, fetchedAt := r.current()
+
+	stale := jwks == nil || time.Since(fetchedAt) > r.refresh
+	if !stale {
+		key, err := jwks.Key(ctx, kid)
+		if err == nil || time.Since(fetchedAt) < r.minRefresh {
+			return key, err
+		}
+	}
+
+	ch := r.group.DoChan("jwks", func() (interface{}, error) {
+		return r.refreshKeys(fetchedAt)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			if jwks == nil {
+				return nil, res.Err
+			}
+
+			// keep serving the keys we have while the issuer is unreachable
+			return jwks.Key(ctx, kid)
+		}
+
+		return res.Val.(*JWKS).Key(ctx, kid)
+	}
+}
+
+func (r *RemoteJWKS) current() (*JWKS, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.jwks, r.fetchedAt
+}
+
+// refreshKeys fetches the keys unless they were refreshed since seen, by a
+// fetch that completed while the caller was waiting to start its own
+func (r *RemoteJWKS) refreshKeys(seen time.Time) (*JWKS, error) {
+	if jwks, fetchedAt := r.current(); jwks != nil && fetchedAt.After(seen) {
+		return jwks, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	jwks, err := r.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.jwks = jwks
+	r.fetchedAt = time.Now()
+	r.mu.Unlock()
+
+	return jwks, nil
+}
+
+func (r *RemoteJWKS) fetch(ctx context.Context) (*JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks: %w", err)
+	}
+
+	return ParseJWKS(data)
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SchemeBearer is the authorization scheme of JWT access tokens
+const SchemeBearer = "Bearer"
+
+var (
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("invalid token issuer")
+	ErrInvalidAudience  = errors.New("invalid token audience")
+	ErrMissingSubject   = errors.New("token has no subject")
+
+	ErrMissingIssuer   = errors.New("the token issuer must be set")
+	ErrMissingAudience = errors.New("the token audience must be set")
+)
+
+// JWTConfig holds the claims checks of JWTAuthenticator
+type JWTConfig struct {
+	// Issuer must match the iss claim when set
+	Issuer string
+	// Audience must be one of the aud claim values when set
+	Audience string
+	// ClockSkew is the leeway allowed on exp, nbf and iat
+	ClockSkew time.Duration
+}
+
+// Validate fails when the issuer or the audience isn't set: the claim would
+// go unchecked and tokens the keys signed for any other service accepted
+func (c JWTConfig) Validate() error {
+	if c.Issuer == "" {
+		return ErrMissingIssuer
+	}
+
+	if c.Audience == "" {
+		return ErrMissingAudience
+	}
+
+	return nil
+}
+
+// Claims are the claims read from access tokens, roles are listed in the roles
+// claim and scopes in the space separated scope claim
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	Scope string   `json:"scope,omitempty"`
+}
+
+// JWTAuthenticator validates RS256 and ES256 signed bearer tokens against a
+// key set
+type JWTAuthenticator struct {
+	keys   KeySet
+	cfg    JWTConfig
+	parser *jwt.Parser
+	now    func() time.Time
+}
+
+func NewJWTAuthenticator(keys KeySet, cfg JWTConfig) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		keys: keys,
+		cfg:  cfg,
+		parser: &jwt.Parser{
+			ValidMethods: []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()},
+			// claims are validated by validateClaims which applies the clock skew
+			SkipClaimsValidation: true,
+		},
+		now: time.Now,
+	}
+}
+
+func (a *JWTAuthenticator) Scheme() string {
+	return SchemeBearer
+}
+
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	var claims Claims
+	if _, err := a.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.keys.Key(ctx, kid)
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+	}
+
+	if err := a.validateClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+	}
+
+	return &Principal{
+		Subject: claims.Subject,
+		Roles:   claims.Roles,
+		Scopes:  strings.Fields(claims.Scope),
+		Method:  SchemeBearer,
+	}, nil
+}
+
+func (a *JWTAuthenticator) validateClaims(claims Claims) error {
+	now := a.now()
+
+	if claims.ExpiresAt == nil || now.After(claims.ExpiresAt.Add(a.cfg.ClockSkew)) {
+		return ErrTokenExpired
+	}
+
+	if claims.NotBefore != nil && now.Add(a.cfg.ClockSkew).Before(claims.NotBefore.Time) {
+		return ErrTokenNotValidYet
+	}
+
+	if claims.IssuedAt != nil && now.Add(a.cfg.ClockSkew).Before(claims.IssuedAt.Time) {
+		return ErrTokenNotValidYet
+	}
+
+	if a.cfg.Issuer != "" && claims.Issuer != a.cfg.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if a.cfg.Audience != "" && !claims.VerifyAudience(a.cfg.Audience, true) {
+		return ErrInvalidAudience
+	}
+
+	if claims.Subject == "" {
+		return ErrMissingSubject
+	}
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://auth.example.com/"
+	testAudience = "app-transcation"
+)
+
+// testKeys are signing keys generated for the test run
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return testKeys{rsa: rsaKey, ec: ecKey}
+}
+
+// jwks returns the JSON Web Key Set of the public keys
+func (k testKeys) jwks(t *testing.T) []byte {
+	t.Helper()
+
+	return k.jwksWithPrefix(t, "")
+}
+
+// jwksWithPrefix prefixes the kid of every key, as issuers do when rotating
+func (k testKeys) jwksWithPrefix(t *testing.T, prefix string) []byte {
+	t.Helper()
+
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kid": prefix + "rsa", "kty": "RSA", "use": "sig", "n": encode(k.rsa.N), "e": encode(big.NewInt(int64(k.rsa.E)))},
+			{"kid": prefix + "ec", "kty": "EC", "use": "sig", "crv": "P-256", "x": encode(k.ec.X), "y": encode(k.ec.Y)},
+			{"kid": prefix + "enc", "kty": "RSA", "use": "enc", "n": encode(k.rsa.N), "e": encode(big.NewInt(int64(k.rsa.E)))},
+		},
+	})
+	require.NoError(t, err)
+
+	return data
+}
+
+func (k testKeys) sign(t *testing.T, method jwt.SigningMethod, kid string, claims Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	var key interface{} = k.rsa
+	if method == jwt.SigningMethodES256 {
+		key = k.ec
+	}
+
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	return signed
+}
+
+func validClaims(now time.Time) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "customer-1",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		Roles: []string{"customer"},
+		Scope: "accounts:read transcations:write",
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	jwks, err := ParseJWKS(keys.jwks(t))
+	require.NoError(t, err)
+
+	now := time.Now()
+	authenticator := NewJWTAuthenticator(jwks, JWTConfig{
+		Issuer:    testIssuer,
+		Audience:  testAudience,
+		ClockSkew: 30 * time.Second,
+	})
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims(now)).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		token  func() string
+		expErr error
+	}{
+		{
+			name:  "valid RS256 token",
+			token: func() string { return keys.sign(t, jwt.SigningMethodRS256, "rsa", validClaims(now)) },
+		},
+		{
+			name:  "valid ES256 token",
+			token: func() string { return keys.sign(t, jwt.SigningMethodES256, "ec", validClaims(now)) },
+		},
+		{
+			name: "expired within clock skew",
+			token: func() string {
+				claims := validClaims(now)
+				claims.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second))
+				return keys.sign(t, jwt.SigningMethodRS256, "rsa", claims)
+			},
+		},
+		{
+			name: "expired beyond clock skew",
+			token: func() string {
+				claims := validClaims(now)
+				claims.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+				return keys.sign(t, jwt.SigningMethodRS256, "rsa", claims)
+			},
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name: "not valid yet",
+			token: func() string {
+				claims := validClaims(now)
+				claims.NotBefore = jwt.NewNumericDate(now.Add(time.Minute))
+				return keys.sign(t, jwt.SigningMethodRS256, "rsa", claims)
+			},
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name: "without expiry",
+			token: func() string {
+				claims := validClaims(now)
+				claims.ExpiresAt = nil
+				return keys.sign(t, jwt.SigningMethodRS256, "rsa", claims)
+			},
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				claims := validClaims(now)
+				claims.Issuer = "https://evil.example.com/"
+				return keys.sign(t, jwt.SigningMethodRS256, "rsa", claims)
+			},
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				claims := validClaims(now)
+				claims.Audience = jwt.ClaimStrings{"another-service"}
+				return keys.sign(t, jwt.SigningMethodRS256, "rsa", claims)
+			},
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name:   "unknown kid",
+			token:  func() string { return keys.sign(t, jwt.SigningMethodRS256, "other", validClaims(now)) },
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name:   "key not meant for signatures",
+			token:  func() string { return keys.sign(t, jwt.SigningMethodRS256, "enc", validClaims(now)) },
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name:   "kid of another key type",
+			token:  func() string { return keys.sign(t, jwt.SigningMethodRS256, "ec", validClaims(now)) },
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name:   "HS256 token",
+			token:  func() string { return hmacToken },
+			expErr: ErrInvalidCredentials,
+		},
+		{
+			name:   "malformed token",
+			token:  func() string { return "not-a-token" },
+			expErr: ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(context.Background(), tt.token())
+			if tt.expErr != nil {
+				require.ErrorIs(t, err, tt.expErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, &Principal{
+				Subject: "customer-1",
+				Roles:   []string{"customer"},
+				Scopes:  []string{"accounts:read", "transcations:write"},
+				Method:  SchemeBearer,
+			}, principal)
+		})
+	}
+}
+
+func TestJWTConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, JWTConfig{Issuer: testIssuer, Audience: "transcations"}.Validate())
+	require.ErrorIs(t, JWTConfig{Audience: "transcations"}.Validate(), ErrMissingIssuer)
+	require.ErrorIs(t, JWTConfig{Issuer: testIssuer}.Validate(), ErrMissingAudience)
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, keys.jwks(t), 0o600))
+
+	jwks, err := LoadJWKSFile(path)
+	require.NoError(t, err)
+
+	authenticator := NewJWTAuthenticator(jwks, JWTConfig{Issuer: testIssuer})
+	_, err = authenticator.Authenticate(context.Background(), keys.sign(t, jwt.SigningMethodES256, "ec", validClaims(time.Now())))
+	require.NoError(t, err)
+
+	_, err = LoadJWKSFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+func TestRemoteJWKS(t *testing.T) {
+	t.Parallel()
+
+	oldKeys, newKeys := newTestKeys(t), newTestKeys(t)
+	served := oldKeys.jwks(t)
+	fetches := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(served)
+	}))
+	t.Cleanup(server.Close)
+
+	remote := NewRemoteJWKS(server.URL, server.Client(), time.Hour)
+	remote.minRefresh = 0
+
+	_, err := remote.Key(context.Background(), "rsa")
+	require.NoError(t, err)
+
+	_, err = remote.Key(context.Background(), "rsa")
+	require.NoError(t, err)
+	require.Equal(t, 1, fetches)
+
+	// keys rotated by the issuer are picked up on the first token naming them
+	served = newKeys.jwksWithPrefix(t, "2022-10-")
+	authenticator := NewJWTAuthenticator(remote, JWTConfig{})
+	_, err = authenticator.Authenticate(context.Background(), newKeys.sign(t, jwt.SigningMethodRS256, "2022-10-rsa", validClaims(time.Now())))
+	require.NoError(t, err)
+	require.Equal(t, 2, fetches)
+
+	_, err = remote.Key(context.Background(), "unknown")
+	require.True(t, errors.Is(err, ErrUnknownKey))
+	require.Equal(t, 3, fetches)
+
+	// unknown kids do not trigger a fetch more than once per minRefresh
+	remote.minRefresh = time.Hour
+	_, err = remote.Key(context.Background(), "unknown")
+	require.True(t, errors.Is(err, ErrUnknownKey))
+	require.Equal(t, 3, fetches)
+}
+
+func TestRemoteJWKSFetchDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	var fetches int32
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first fetch answers, the next ones hang until released
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+
+		w.Write(keys.jwks(t))
+	}))
+	t.Cleanup(server.Close)
+
+	remote := NewRemoteJWKS(server.URL, server.Client(), time.Hour)
+	remote.minRefresh = 0
+
+	_, err := remote.Key(context.Background(), "rsa")
+	require.NoError(t, err)
+
+	// tokens naming an unknown kid wait for a single fetch
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := remote.Key(context.Background(), "unknown")
+			errs <- err
+		}()
+	}
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 2 }, time.Second, time.Millisecond)
+
+	// while the issuer hangs, known keys are served
+	_, err = remote.Key(context.Background(), "ec")
+	require.NoError(t, err)
+
+	// and callers waiting for the fetch give up with their context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = remote.Key(ctx, "unknown")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.True(t, errors.Is(err, ErrUnknownKey))
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
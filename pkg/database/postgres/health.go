@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/golang-migrate/migrate/v4/source/httpfs"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// MigrationVersion reads the version golang-migrate keeps, NilVersion when no
+// migration was applied. Unlike Migrator.Version it goes through the pool.
+func MigrationVersion(ctx context.Context, pool *pgxpool.Pool) (int64, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+	err := pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NilVersion, false, nil
+	}
+
+	return version, dirty, err
+}
+
+// LatestVersion returns the version of the last migration of the directory,
+// NilVersion when it has none
+func LatestVersion(migrations embed.FS, migrationsPath string) (int64, error) {
+	src, err := httpfs.New(http.FS(migrations), migrationsPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	defer src.Close()
+
+	m := Migrator{source: src}
+	versions, err := m.versions()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(versions) == 0 {
+		return NilVersion, nil
+	}
+
+	return int64(versions[len(versions)-1]), nil
+}
+
+// CheckMigrationVersion returns a check failing unless the database is at the
+// expected version and clean. A database ahead of the binary fails it too, its
+// schema may not be one the binary can work with.
+func CheckMigrationVersion(pool *pgxpool.Pool, expected int64) func(context.Context) error {
+	return func(ctx context.Context) error {
+		version, dirty, err := MigrationVersion(ctx, pool)
+		if err != nil {
+			return fmt.Errorf("failed to read the migration version: %w", err)
+		}
+
+		if dirty {
+			return fmt.Errorf("migration %d failed half way, the database needs fixing", version)
+		}
+
+		if version != expected {
+			return fmt.Errorf("database at migration %d, expected %d", version, expected)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// listenRetryInterval is how long Listen waits before reconnecting after the
+// listening connection failed
+var listenRetryInterval = time.Second
+
+// Listen holds a connection of the pool and calls fn with the payload of every
+// notification sent on channel until ctx is cancelled. The connection is
+// re-acquired when it fails, notifications sent in between are lost so callers
+// should treat fn as a wake up rather than as a reliable feed.
+func Listen(ctx context.Context, pool *pgxpool.Pool, channel string, fn func(payload string)) error {
+	for {
+		err := listen(ctx, pool, channel, fn)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.WithField("channel", channel).WithError(err).Warn("postgres listener failed, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(listenRetryInterval):
+		}
+	}
+}
+
+func listen(ctx context.Context, pool *pgxpool.Pool, channel string, fn func(payload string)) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	// the connection is in LISTEN mode, it must not go back to the pool
+	defer conn.Conn().Close(context.Background())
+	defer conn.Hijack()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		fn(notification.Payload)
+	}
+}
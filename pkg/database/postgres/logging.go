@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+// queryLogger writes the queries pgx logs to the debug log of the context
+// logger, so that they carry the request they were run for
+type queryLogger struct{}
+
+func (queryLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if !queryMessages[msg] {
+		return
+	}
+
+	logger := logging.FromContext(ctx)
+	if !logger.Logger.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+
+	fields := log.Fields{"operation": msg}
+	if sql, ok := data["sql"].(string); ok {
+		fields["sql"] = sql
+	}
+
+	if table, ok := data["tableName"].(pgx.Identifier); ok {
+		fields["table"] = table.Sanitize()
+	}
+
+	if rows, ok := data["rowCount"]; ok {
+		fields["rows"] = rows
+	}
+
+	if took, ok := data["time"].(time.Duration); ok {
+		fields["duration_ms"] = float64(took.Microseconds()) / 1000
+	}
+
+	if err, ok := data["err"].(error); ok && level == pgx.LogLevelError {
+		logger = logger.WithError(err)
+	}
+
+	logger.WithFields(fields).Debug("postgres query")
+}
+
+// loggers hands what pgx logs to every logger
+type loggers []pgx.Logger
+
+func (l loggers) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	for _, logger := range l {
+		logger.Log(ctx, level, msg, data)
+	}
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/pkg/requestid"
+)
+
+func TestQueryLogger(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	level := log.GetLevel()
+	defer log.SetLevel(level)
+
+	ctx := requestid.WithID(context.Background(), "req-1")
+	query := map[string]interface{}{
+		"sql":      "SELECT * FROM accounts WHERE document_number = $1",
+		"args":     []interface{}{"12345678900"},
+		"time":     1500 * time.Microsecond,
+		"rowCount": 1,
+	}
+
+	// queries are only logged at the debug level
+	log.SetLevel(log.InfoLevel)
+	queryLogger{}.Log(ctx, pgx.LogLevelInfo, "Query", query)
+	require.Empty(t, hook.AllEntries())
+
+	log.SetLevel(log.DebugLevel)
+	queryLogger{}.Log(ctx, pgx.LogLevelInfo, "Query", query)
+	queryLogger{}.Log(ctx, pgx.LogLevelInfo, "Dialing PostgreSQL server", map[string]interface{}{"host": "localhost"})
+	require.Len(t, hook.AllEntries(), 1)
+
+	entry := hook.LastEntry()
+	require.Equal(t, "postgres query", entry.Message)
+	require.Equal(t, "req-1", entry.Data["request_id"])
+	require.Equal(t, query["sql"], entry.Data["sql"])
+	require.Equal(t, 1.5, entry.Data["duration_ms"])
+	require.NotContains(t, entry.Data, "args")
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// versionTimeout bounds the query of the migration version on every scrape
+const versionTimeout = time.Second
+
+var (
+	acquiredConnsDesc = prometheus.NewDesc("pgxpool_acquired_conns",
+		"Connections of the pool in use.", nil, nil)
+	idleConnsDesc = prometheus.NewDesc("pgxpool_idle_conns",
+		"Idle connections of the pool.", nil, nil)
+	totalConnsDesc = prometheus.NewDesc("pgxpool_total_conns",
+		"Connections of the pool, in use, idle or being opened.", nil, nil)
+	maxConnsDesc = prometheus.NewDesc("pgxpool_max_conns",
+		"Maximum number of connections of the pool.", nil, nil)
+	acquiresDesc = prometheus.NewDesc("pgxpool_acquires_total",
+		"Connections acquired from the pool.", nil, nil)
+	emptyAcquiresDesc = prometheus.NewDesc("pgxpool_empty_acquires_total",
+		"Acquires that waited for a connection because none was idle.", nil, nil)
+	canceledAcquiresDesc = prometheus.NewDesc("pgxpool_canceled_acquires_total",
+		"Acquires canceled by their context while waiting for a connection.", nil, nil)
+	acquireDurationDesc = prometheus.NewDesc("pgxpool_acquire_duration_seconds_total",
+		"Time spent acquiring connections from the pool.", nil, nil)
+	migrationVersionDesc = prometheus.NewDesc("schema_migration_version",
+		"Version of the last migration applied to the database, -1 when none was.", nil, nil)
+	migrationDirtyDesc = prometheus.NewDesc("schema_migration_dirty",
+		"1 when the last migration failed half way and the database needs fixing.", nil, nil)
+)
+
+// Collector exposes the stats of a pool and the migration version of its
+// database to prometheus
+type Collector struct {
+	pool *pgxpool.Pool
+}
+
+func NewCollector(pool *pgxpool.Pool) *Collector {
+	return &Collector{pool: pool}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		acquiredConnsDesc, idleConnsDesc, totalConnsDesc, maxConnsDesc,
+		acquiresDesc, emptyAcquiresDesc, canceledAcquiresDesc, acquireDurationDesc,
+		migrationVersionDesc, migrationDirtyDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(acquiredConnsDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(idleConnsDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(totalConnsDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(maxConnsDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(acquiresDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(emptyAcquiresDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(canceledAcquiresDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(acquireDurationDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+
+	version, dirty, err := MigrationVersion(ctx, c.pool)
+	if err != nil {
+		log.WithError(err).Warn("failed to read the migration version")
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(migrationVersionDesc, prometheus.GaugeValue, float64(version))
+	ch <- prometheus.MustNewConstMetric(migrationDirtyDesc, prometheus.GaugeValue, boolValue(dirty))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -5,27 +5,298 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/httpfs"
 )
 
+// NilVersion is the version of a database no migration was applied to
+const NilVersion = -1
+
+// directions of a migration step
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// Migrator applies the migrations of an embedded directory to a database
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+}
+
+// MigrationStatus is the version of the database and the migrations known
+type MigrationStatus struct {
+	Version    int         `json:"version"`
+	Dirty      bool        `json:"dirty"`
+	Migrations []Migration `json:"migrations"`
+}
+
+// Migration is a migration of the source and whether it was applied
+type Migration struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+// MigrationStep is the sql a migration runs in one direction
+type MigrationStep struct {
+	Version   uint
+	Name      string
+	Direction string
+	SQL       string
+}
+
+// Migrate applies every migration that wasn't applied yet
 func Migrate(ctx context.Context, migrations embed.FS, migrationsPath string, dsn string) error {
-	source, err := httpfs.New(http.FS(migrations), migrationsPath)
+	m, err := NewMigrator(migrations, migrationsPath, dsn)
 	if err != nil {
-		return fmt.Errorf("failed to load migrations: %w", err)
+		return err
 	}
+	defer m.Close()
+
+	return m.Up()
+}
 
-	m, err := migrate.NewWithSourceInstance("httpfs", source, dsn)
+func NewMigrator(migrations embed.FS, migrationsPath string, dsn string) (*Migrator, error) {
+	src, err := httpfs.New(http.FS(migrations), migrationsPath)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
 	}
 
-	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return err
+	m, err := migrate.NewWithSourceInstance("httpfs", src, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+
+	return &Migrator{m: m, source: src}, nil
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+
+	return dbErr
+}
+
+// Up applies every migration that wasn't applied yet
+func (m *Migrator) Up() error {
+	return ignoreNoChange(m.m.Up())
+}
+
+// Down reverts the last n migrations applied
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid number of migrations to revert: %d", n)
+	}
+
+	return ignoreNoChange(m.m.Steps(-n))
+}
+
+// Goto migrates up or down to the version
+func (m *Migrator) Goto(version uint) error {
+	return ignoreNoChange(m.m.Migrate(version))
+}
+
+// Force sets the version of the database without running any migration and
+// clears the dirty flag, it is how a failed migration is recovered from once
+// the database was repaired by hand. NilVersion forgets every migration.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// Version returns the version of the database, NilVersion when no migration
+// was applied
+func (m *Migrator) Version() (int, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return NilVersion, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	return int(version), dirty, nil
+}
+
+// Status returns the version of the database along with every migration of
+// the source
+func (m *Migrator) Status() (MigrationStatus, error) {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	versions, err := m.versions()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	status := MigrationStatus{Version: version, Dirty: dirty, Migrations: make([]Migration, 0, len(versions))}
+	for _, v := range versions {
+		step, err := m.step(v, DirectionUp)
+		if err != nil {
+			return MigrationStatus{}, err
+		}
+
+		status.Migrations = append(status.Migrations, Migration{
+			Version: v,
+			Name:    step.Name,
+			Applied: int(v) <= version,
+		})
+	}
+
+	return status, nil
+}
+
+// PlanUp returns the steps Up would run
+func (m *Migrator) PlanUp() ([]MigrationStep, error) {
+	versions, err := m.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	return m.plan(int(versions[len(versions)-1]))
+}
+
+// PlanDown returns the steps Down would run
+func (m *Migrator) PlanDown(n int) ([]MigrationStep, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid number of migrations to revert: %d", n)
+	}
+
+	version, _, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := m.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	// the target is the version n migrations below the current one
+	target := NilVersion
+	for i := len(versions) - 1; i >= 0; i-- {
+		if int(versions[i]) > version {
+			continue
+		}
+
+		if n == 0 {
+			target = int(versions[i])
+			break
+		}
+		n--
+	}
+
+	return m.plan(target)
+}
+
+// PlanGoto returns the steps Goto would run
+func (m *Migrator) PlanGoto(version uint) ([]MigrationStep, error) {
+	return m.plan(int(version))
+}
+
+// plan returns the steps migrating the database from its version to target
+func (m *Migrator) plan(target int) ([]MigrationStep, error) {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	if dirty {
+		return nil, migrate.ErrDirty{Version: version}
+	}
+
+	versions, err := m.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]MigrationStep, 0)
+	if target >= version {
+		for _, v := range versions {
+			if int(v) > version && int(v) <= target {
+				step, err := m.step(v, DirectionUp)
+				if err != nil {
+					return nil, err
+				}
+
+				steps = append(steps, step)
+			}
+		}
+
+		return steps, nil
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if int(v) <= version && int(v) > target {
+			step, err := m.step(v, DirectionDown)
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, step)
+		}
+	}
+
+	return steps, nil
+}
+
+// versions returns the versions of the source in order
+func (m *Migrator) versions() ([]uint, error) {
+	versions := make([]uint, 0)
+
+	v, err := m.source.First()
+	for err == nil {
+		versions = append(versions, v)
+		v, err = m.source.Next(v)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	return versions, nil
+}
+
+func (m *Migrator) step(version uint, direction string) (MigrationStep, error) {
+	read := m.source.ReadUp
+	if direction == DirectionDown {
+		read = m.source.ReadDown
+	}
+
+	r, name, err := read(version)
+	if err != nil {
+		return MigrationStep{}, fmt.Errorf("failed to read migration %d %s: %w", version, direction, err)
+	}
+	defer r.Close()
+
+	sql, err := io.ReadAll(r)
+	if err != nil {
+		return MigrationStep{}, fmt.Errorf("failed to read migration %d %s: %w", version, direction, err)
+	}
+
+	return MigrationStep{Version: version, Name: name, Direction: direction, SQL: string(sql)}, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
 	}
 
-	return nil
+	return err
 }
@@ -30,6 +30,11 @@ func Open(ctx context.Context, cfg Config, afterConn func(context.Context, *pgx.
 	pgxCfg.AfterConnect = afterConn
 	pgxCfg.MaxConns = cfg.MaxConnection
 
+	// pgx only reports queries to a logger, it is how they are traced and
+	// logged
+	pgxCfg.ConnConfig.Logger = loggers{queryTracer{}, queryLogger{}}
+	pgxCfg.ConnConfig.LogLevel = pgx.LogLevelInfo
+
 	pool, err := pgxpool.ConnectConfig(ctx, pgxCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create pgx conn pool: %w", err)
@@ -49,16 +54,16 @@ func Wait(ctx context.Context, cfg Config, afterConn func(context.Context, *pgx.
 		pool, err = Open(ctx, cfg, afterConn)
 		if err != nil {
 			if isErrInvalidConfig(err) {
-				log.Error("failed to open connection to postgres", err)
+				log.WithError(err).Error("failed to open connection to postgres")
 				return err
 			}
 
-			log.Warn("failed to open connection to postgres, retrying", err)
+			log.WithError(err).Warn("failed to open connection to postgres, retrying")
 			return retry.RetryableError(err)
 		}
 
 		if err := pool.Ping(ctx); err != nil {
-			log.Warn("failed to ping postgres, retrying", err)
+			log.WithError(err).Warn("failed to ping postgres, retrying")
 
 			// This marks the error as retryable
 			return retry.RetryableError(err)
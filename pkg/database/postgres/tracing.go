@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/madhurikadam/app-transcation/pkg/database/postgres")
+
+// queryMessages are the messages pgx logs queries with, the arguments of the
+// queries are left out of the spans and of the log
+var queryMessages = map[string]bool{
+	"Query":     true,
+	"Exec":      true,
+	"CopyFrom":  true,
+	"SendBatch": true,
+}
+
+// queryTracer turns the queries pgx logs into spans. pgx logs a query once it
+// is done along with how long it took, the span is backdated to its start.
+// Queries outside of a trace, like the polls of the background jobs, aren't
+// traced.
+type queryTracer struct{}
+
+func (queryTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return
+	}
+
+	if !queryMessages[msg] {
+		return
+	}
+
+	end := time.Now()
+	start := end
+	if took, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-took)
+	}
+
+	_, span := tracer.Start(ctx, "pgx "+msg,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", msg),
+		),
+	)
+
+	if sql, ok := data["sql"].(string); ok {
+		span.SetAttributes(attribute.String("db.statement", sql))
+	}
+
+	if table, ok := data["tableName"].(pgx.Identifier); ok {
+		span.SetAttributes(attribute.String("db.sql.table", table.Sanitize()))
+	}
+
+	switch rows := data["rowCount"].(type) {
+	case int:
+		span.SetAttributes(attribute.Int("db.rows", rows))
+	case int64:
+		span.SetAttributes(attribute.Int64("db.rows", rows))
+	}
+
+	if level == pgx.LogLevelError {
+		err, ok := data["err"].(error)
+		if !ok {
+			err = fmt.Errorf("%s failed", msg)
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End(trace.WithTimestamp(end))
+}
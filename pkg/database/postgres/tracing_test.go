@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+
+	queryTracer{}.Log(ctx, pgx.LogLevelInfo, "Query", map[string]interface{}{
+		"sql":      "SELECT 1",
+		"args":     []interface{}{"12345678900"},
+		"time":     20 * time.Millisecond,
+		"rowCount": 1,
+	})
+	queryTracer{}.Log(ctx, pgx.LogLevelError, "Exec", map[string]interface{}{
+		"sql": "UPDATE accounts SET withdrawal_limit = $1",
+		"err": errors.New("deadlock detected"),
+	})
+	queryTracer{}.Log(ctx, pgx.LogLevelInfo, "CopyFrom", map[string]interface{}{
+		"tableName": pgx.Identifier{"transcations"},
+		"rowCount":  int64(3),
+	})
+	// connections and queries outside of a trace aren't traced
+	queryTracer{}.Log(ctx, pgx.LogLevelInfo, "Dialing PostgreSQL server", map[string]interface{}{"host": "localhost"})
+	queryTracer{}.Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{"sql": "SELECT 2"})
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+
+	query := spans[0]
+	require.Equal(t, "pgx Query", query.Name())
+	require.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	require.Equal(t, 20*time.Millisecond, query.EndTime().Sub(query.StartTime()))
+	require.Contains(t, query.Attributes(), attribute.String("db.statement", "SELECT 1"))
+	require.Contains(t, query.Attributes(), attribute.Int("db.rows", 1))
+	for _, attr := range query.Attributes() {
+		require.NotEqual(t, "12345678900", attr.Value.Emit(), "the arguments are left out")
+	}
+
+	exec := spans[1]
+	require.Equal(t, "pgx Exec", exec.Name())
+	require.Equal(t, codes.Error, exec.Status().Code)
+	require.Equal(t, "deadlock detected", exec.Status().Description)
+
+	copyFrom := spans[2]
+	require.Contains(t, copyFrom.Attributes(), attribute.String("db.sql.table", `"transcations"`))
+	require.Contains(t, copyFrom.Attributes(), attribute.Int64("db.rows", 3))
+}
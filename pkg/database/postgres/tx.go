@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/sethvargo/go-retry"
+
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+const (
+	// DefaultTxRetries is how many times WithTx runs a transaction again after
+	// a serialization failure or a deadlock
+	DefaultTxRetries = 3
+	// DefaultTxBackoff is the base of the exponential backoff between retries
+	DefaultTxBackoff = 10 * time.Millisecond
+
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
+// TxBeginner starts transactions, *pgxpool.Pool is one
+type TxBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// TxOptions configures the transactions of WithTx
+type TxOptions struct {
+	// IsoLevel, AccessMode and DeferrableMode of the transaction
+	pgx.TxOptions
+	// MaxRetries defaults to DefaultTxRetries, a negative value disables
+	// retries
+	MaxRetries int
+	// Backoff defaults to DefaultTxBackoff
+	Backoff time.Duration
+}
+
+// WithTx runs fn in a transaction and commits it when fn succeeds. The
+// transaction is rolled back when fn fails or panics. Transactions failing
+// with a serialization failure or a deadlock are run again from the start,
+// fn must leave nothing behind but its writes in tx.
+func WithTx(ctx context.Context, db TxBeginner, opts TxOptions, fn func(tx pgx.Tx) error) error {
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultTxRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	base := opts.Backoff
+	if base <= 0 {
+		base = DefaultTxBackoff
+	}
+
+	backoff := retry.WithMaxRetries(uint64(maxRetries), retry.WithJitterPercent(50, retry.NewExponential(base)))
+
+	attempt := 0
+	return retry.Do(ctx, backoff, func(ctx context.Context) error {
+		attempt++
+
+		err := runTx(ctx, db, opts.TxOptions, fn)
+		if IsRetryable(err) {
+			logging.FromContext(ctx).WithField("attempt", attempt).WithError(err).Warn("transaction failed, retrying")
+			return retry.RetryableError(err)
+		}
+
+		return err
+	})
+}
+
+func runTx(ctx context.Context, db TxBeginner, txOptions pgx.TxOptions, fn func(tx pgx.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, txOptions)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+
+		if err != nil {
+			if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+				logging.FromContext(ctx).WithError(rbErr).Warn("failed to roll back transaction")
+			}
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// IsRetryable reports whether err is a serialization failure or a deadlock,
+// the transaction it ended may succeed when run again
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == codeSerializationFailure || pgErr.Code == codeDeadlockDetected
+}
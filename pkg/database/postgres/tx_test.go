@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTx records how it ended, the methods it doesn't override panic
+type fakeTx struct {
+	pgx.Tx
+
+	commitErr  error
+	committed  bool
+	rolledBack bool
+}
+
+func (t *fakeTx) Commit(context.Context) error {
+	if t.commitErr != nil {
+		return t.commitErr
+	}
+
+	t.committed = true
+	return nil
+}
+
+func (t *fakeTx) Rollback(context.Context) error {
+	if t.committed {
+		return pgx.ErrTxClosed
+	}
+
+	t.rolledBack = true
+	return nil
+}
+
+type fakeDB struct {
+	txs       []*fakeTx
+	commitErr []error
+	options   pgx.TxOptions
+}
+
+func (d *fakeDB) BeginTx(_ context.Context, options pgx.TxOptions) (pgx.Tx, error) {
+	tx := &fakeTx{}
+	if len(d.commitErr) > 0 {
+		tx.commitErr, d.commitErr = d.commitErr[0], d.commitErr[1:]
+	}
+
+	d.options = options
+	d.txs = append(d.txs, tx)
+
+	return tx, nil
+}
+
+func TestWithTx(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	serialization := &pgconn.PgError{Code: codeSerializationFailure}
+	deadlock := &pgconn.PgError{Code: codeDeadlockDetected}
+	failed := errors.New("failed")
+	opts := TxOptions{Backoff: 1}
+
+	t.Run("commits", func(t *testing.T) {
+		db := &fakeDB{}
+		err := WithTx(ctx, db, TxOptions{TxOptions: pgx.TxOptions{IsoLevel: pgx.Serializable}}, func(tx pgx.Tx) error {
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, db.txs, 1)
+		require.True(t, db.txs[0].committed)
+		require.Equal(t, pgx.Serializable, db.options.IsoLevel)
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		db := &fakeDB{}
+		err := WithTx(ctx, db, opts, func(tx pgx.Tx) error {
+			return failed
+		})
+		require.ErrorIs(t, err, failed)
+		require.Len(t, db.txs, 1)
+		require.True(t, db.txs[0].rolledBack)
+	})
+
+	t.Run("rolls back on panic", func(t *testing.T) {
+		db := &fakeDB{}
+		require.PanicsWithValue(t, "boom", func() {
+			_ = WithTx(ctx, db, opts, func(tx pgx.Tx) error {
+				panic("boom")
+			})
+		})
+		require.True(t, db.txs[0].rolledBack)
+	})
+
+	t.Run("retries serialization failures and deadlocks", func(t *testing.T) {
+		db := &fakeDB{commitErr: []error{serialization}}
+		calls := 0
+		err := WithTx(ctx, db, opts, func(tx pgx.Tx) error {
+			calls++
+			if calls == 2 {
+				return deadlock
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+		require.Len(t, db.txs, 3)
+		require.True(t, db.txs[0].rolledBack)
+		require.True(t, db.txs[1].rolledBack)
+		require.True(t, db.txs[2].committed)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		db := &fakeDB{}
+		err := WithTx(ctx, db, TxOptions{MaxRetries: 2, Backoff: 1}, func(tx pgx.Tx) error {
+			return serialization
+		})
+		require.ErrorIs(t, err, serialization)
+		require.Len(t, db.txs, 3)
+	})
+
+	t.Run("retries disabled", func(t *testing.T) {
+		db := &fakeDB{}
+		err := WithTx(ctx, db, TxOptions{MaxRetries: -1}, func(tx pgx.Tx) error {
+			return deadlock
+		})
+		require.ErrorIs(t, err, deadlock)
+		require.Len(t, db.txs, 1)
+	})
+
+	t.Run("doesn't retry other errors", func(t *testing.T) {
+		db := &fakeDB{}
+		err := WithTx(ctx, db, opts, func(tx pgx.Tx) error {
+			return &pgconn.PgError{Code: "23505"}
+		})
+		require.Error(t, err)
+		require.Len(t, db.txs, 1)
+	})
+}
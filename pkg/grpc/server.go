@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+type Server struct {
+	*grpc.Server
+	Addr string
+}
+
+func New(addr string, opts ...grpc.ServerOption) *Server {
+	srv := grpc.NewServer(opts...)
+	reflection.Register(srv)
+
+	return &Server{
+		Server: srv,
+		Addr:   addr,
+	}
+}
+
+// ListenAndServe listens on the server address and serves grpc requests until
+// the server is stopped
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("addr", s.Addr).Info("starting grpc server")
+
+	return s.Serve(lis)
+}
+
+// Shutdown waits for ctx to be done, then stops the server gracefully. Calls
+// still running after the grace period, like open streams, are cancelled.
+func (s *Server) Shutdown(ctx context.Context) func() error {
+	return func() error {
+		<-ctx.Done()
+
+		log.Info("attempting grpc server shutdown")
+
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			s.Stop()
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,149 @@
+// Package health serves the probes of the orchestrator: liveness, whether the
+// process is up, and readiness, whether it should be sent traffic
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/madhurikadam/app-transcation/pkg/http/controller"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+// statuses of the probes
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// Check returns an error when a dependency of the service isn't usable
+type Check func(ctx context.Context) error
+
+// Response is the body of the probes, checks holds the outcome of every
+// readiness check
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   Check
+	// degrades is set for the checks whose failure leaves the service ready
+	degrades bool
+}
+
+// Checker runs the readiness checks, it is ready when they all pass, the
+// degrading ones aside, and it isn't draining
+type Checker struct {
+	controller.BaseController
+
+	checks  []check
+	timeout time.Duration
+	// draining is set once the service is shutting down
+	draining int32
+}
+
+// New returns a checker giving its checks timeout to complete
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a readiness check, checks must be added before the probes are
+// served
+func (c *Checker) Add(name string, fn Check) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// AddDegrading registers a check whose failure reports the service as
+// degraded but still ready. It is meant for what every replica shares, failing
+// readiness would take them all out at once.
+func (c *Checker) AddDegrading(name string, fn Check) {
+	c.checks = append(c.checks, check{name: name, fn: fn, degrades: true})
+}
+
+// Drain makes readiness fail from now on so that the load balancer stops
+// sending requests before the servers shut down
+func (c *Checker) Drain() {
+	atomic.StoreInt32(&c.draining, 1)
+}
+
+func (c *Checker) isDraining() bool {
+	return atomic.LoadInt32(&c.draining) == 1
+}
+
+// Live always succeeds, answering is proof enough the process is up
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	c.WriteJSONResponse(w, http.StatusOK, Response{Status: StatusOK})
+}
+
+// Ready succeeds when every check passes, or only degrading ones fail, the
+// checks run concurrently
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	if c.isDraining() {
+		c.WriteJSONResponse(w, http.StatusServiceUnavailable, Response{Status: StatusDraining})
+		return
+	}
+
+	results := c.run(r.Context())
+
+	resp := Response{Status: StatusOK, Checks: make(map[string]string, len(results))}
+	for _, ch := range c.checks {
+		err := results[ch.name]
+
+		resp.Checks[ch.name] = StatusOK
+		if err == nil {
+			continue
+		}
+
+		resp.Checks[ch.name] = err.Error()
+		switch {
+		case !ch.degrades:
+			resp.Status = StatusUnavailable
+		case resp.Status == StatusOK:
+			resp.Status = StatusDegraded
+		}
+
+		logging.FromContext(r.Context()).WithError(err).WithField("check", ch.name).Warn("readiness check failed")
+	}
+
+	status := http.StatusOK
+	if resp.Status == StatusUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.WriteJSONResponse(w, status, resp)
+}
+
+func (c *Checker) run(ctx context.Context) map[string]error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]error, len(c.checks))
+	)
+	for _, ch := range c.checks {
+		ch := ch
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := ch.fn(ctx)
+
+			mu.Lock()
+			results[ch.name] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func probe(t *testing.T, h http.HandlerFunc) (int, Response) {
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var resp Response
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	return rec.Code, resp
+}
+
+func TestChecker(t *testing.T) {
+	var outboxErr error
+
+	checker := New(50 * time.Millisecond)
+	checker.Add("postgres", func(ctx context.Context) error { return nil })
+	checker.Add("outbox", func(ctx context.Context) error { return outboxErr })
+	checker.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		if outboxErr == nil {
+			return nil
+		}
+
+		return ctx.Err()
+	})
+
+	status, resp := probe(t, checker.Ready)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, Response{Status: StatusOK, Checks: map[string]string{
+		"postgres": StatusOK,
+		"outbox":   StatusOK,
+		"slow":     StatusOK,
+	}}, resp)
+
+	outboxErr = errors.New("the oldest unpublished event is 2m0s old")
+
+	status, resp = probe(t, checker.Ready)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, Response{Status: StatusUnavailable, Checks: map[string]string{
+		"postgres": StatusOK,
+		"outbox":   "the oldest unpublished event is 2m0s old",
+		"slow":     context.DeadlineExceeded.Error(),
+	}}, resp)
+
+	status, resp = probe(t, checker.Live)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, StatusOK, resp.Status)
+}
+
+func TestCheckerDegrading(t *testing.T) {
+	var outboxErr, postgresErr error
+
+	checker := New(time.Second)
+	checker.Add("postgres", func(ctx context.Context) error { return postgresErr })
+	checker.AddDegrading("outbox", func(ctx context.Context) error { return outboxErr })
+
+	outboxErr = errors.New("the oldest unpublished event is 2m0s old")
+
+	// the service is still ready
+	status, resp := probe(t, checker.Ready)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, Response{Status: StatusDegraded, Checks: map[string]string{
+		"postgres": StatusOK,
+		"outbox":   "the oldest unpublished event is 2m0s old",
+	}}, resp)
+
+	postgresErr = errors.New("connection refused")
+
+	status, resp = probe(t, checker.Ready)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, StatusUnavailable, resp.Status)
+}
+
+func TestCheckerDrain(t *testing.T) {
+	checker := New(time.Second)
+	checker.Add("postgres", func(ctx context.Context) error { return nil })
+
+	status, _ := probe(t, checker.Ready)
+	require.Equal(t, http.StatusOK, status)
+
+	checker.Drain()
+
+	status, resp := probe(t, checker.Ready)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, Response{Status: StatusDraining}, resp)
+
+	// the process is still up while draining
+	status, _ = probe(t, checker.Live)
+	require.Equal(t, http.StatusOK, status)
+}
@@ -2,7 +2,10 @@ package controller
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -21,7 +24,7 @@ func (b BaseController) WriteJSONResponse(w http.ResponseWriter, status int, bod
 	w.WriteHeader(status)
 
 	if err := json.NewEncoder(w).Encode(body); err != nil {
-		log.Error("failed to write response", err)
+		log.WithError(err).Error("failed to write response")
 	}
 }
 
@@ -31,3 +34,9 @@ func (b BaseController) WriteErrorResponseMsg(w http.ResponseWriter, status int,
 		Error: msg,
 	})
 }
+
+// SetRetryAfter tells the client how long to wait before retrying, rounded up
+// to whole seconds
+func (b BaseController) SetRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds())))))
+}
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+// AccessLog is a middleware writing a line for every request of a mux router
+// once it was served, with its status and latency. It goes after the request
+// id middleware so that the line carries the id.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		logging.FromContext(r.Context()).WithFields(log.Fields{
+			"method":      r.Method,
+			"route":       routeTemplate(r),
+			"path":        r.URL.Path,
+			"status":      rec.status(),
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"remote_addr": r.RemoteAddr,
+			"user_agent":  r.UserAgent(),
+		}).Info("http request")
+	})
+}
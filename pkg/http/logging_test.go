@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/pkg/requestid"
+)
+
+func TestAccessLog(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	router := mux.NewRouter()
+	router.Use(requestid.Middleware, AccessLog)
+	router.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodGet)
+
+	r := httptest.NewRequest(http.MethodGet, "/accounts/1?document_number=12345678900", nil)
+	r.Header.Set(requestid.Header, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	var entry *log.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Message == "http request" {
+			entry = e
+		}
+	}
+	require.NotNil(t, entry)
+
+	require.Equal(t, log.InfoLevel, entry.Level)
+	require.Equal(t, "req-1", entry.Data["request_id"])
+	require.Equal(t, http.MethodGet, entry.Data["method"])
+	require.Equal(t, "/accounts/{id}", entry.Data["route"])
+	require.Equal(t, http.StatusNotFound, entry.Data["status"])
+	require.Contains(t, entry.Data, "duration_ms")
+
+	// the query string isn't logged
+	require.Equal(t, "/accounts/1", entry.Data["path"])
+}
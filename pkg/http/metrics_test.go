@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetrics(registry)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(metrics.Middleware)
+	router.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{}`))
+	}).Methods(http.MethodGet)
+	router.HandleFunc("/transcations", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/accounts/1"},
+		{http.MethodGet, "/accounts/2"},
+		{http.MethodGet, "/accounts/missing"},
+		{http.MethodPost, "/transcations"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	requests := func(method, route, status string) float64 {
+		return testutil.ToFloat64(metrics.requests.WithLabelValues(method, route, status))
+	}
+
+	require.Equal(t, 2.0, requests(http.MethodGet, "/accounts/{id}", "200"))
+	require.Equal(t, 1.0, requests(http.MethodGet, "/accounts/{id}", "404"))
+	// handlers writing nothing answer 200
+	require.Equal(t, 1.0, requests(http.MethodPost, "/transcations", "200"))
+	require.Equal(t, 3, testutil.CollectAndCount(metrics.requests))
+	require.Equal(t, 3, testutil.CollectAndCount(metrics.duration))
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.inFlight))
+}
+
+func TestRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+
+	var got string
+	router.HandleFunc("/webhooks/{id:[-0-9a-zA-Z]+}/deliveries/{delivery_id:[-0-9a-zA-Z]+}/redeliver", func(w http.ResponseWriter, r *http.Request) {
+		got = routeTemplate(r)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhooks/a/deliveries/b/redeliver", nil))
+
+	require.Equal(t, "/webhooks/{id}/deliveries/{delivery_id}/redeliver", got)
+	require.Equal(t, unmatchedRoute, routeTemplate(httptest.NewRequest(http.MethodGet, "/", nil)))
+}
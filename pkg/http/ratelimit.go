@@ -0,0 +1,123 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+	"github.com/madhurikadam/app-transcation/pkg/http/controller"
+	"github.com/madhurikadam/app-transcation/pkg/logging"
+)
+
+// RateLimiter holds a token bucket per client, buckets of clients that were
+// not seen for a while are full again and get dropped
+type RateLimiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter allows every client perSecond requests on average and bursts
+// of up to burst requests
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	// an empty bucket is full again after burst / perSecond
+	idle := time.Minute
+	if refill := time.Duration(float64(burst) / perSecond * float64(time.Second)); refill > idle {
+		idle = refill
+	}
+
+	return &RateLimiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		idle:    idle,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Reserve takes a token from the bucket of the client and returns how long
+// the client has to wait when the bucket is empty
+func (l *RateLimiter) Reserve(client string) time.Duration {
+	now := l.now()
+
+	l.mu.Lock()
+	if now.Sub(l.lastSweep) > l.idle {
+		for key, b := range l.buckets {
+			if now.Sub(b.lastSeen) > l.idle {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[client] = b
+	}
+	b.lastSeen = now
+	l.mu.Unlock()
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay
+	}
+
+	return 0
+}
+
+// RateLimit is a middleware answering 429 with a Retry-After header to the
+// clients going over the limiter, client tells them apart. Ahead of the auth
+// middleware only ClientAddress is known, after it ClientIdentity tells
+// authenticated clients apart by subject rather than address.
+func RateLimit(limiter *RateLimiter, client func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := client(r)
+			if delay := limiter.Reserve(client); delay > 0 {
+				logging.FromContext(r.Context()).WithField("client", client).WithField("path", r.URL.Path).Info("rate limited request")
+
+				controller.BaseController{}.SetRetryAfter(w, delay)
+				controller.BaseController{}.WriteErrorResponseMsg(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIdentity is the subject of the authenticated caller of the request,
+// or its remote address when the caller is anonymous
+func ClientIdentity(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return "subject:" + principal.Subject
+	}
+
+	return ClientAddress(r)
+}
+
+// ClientAddress is the remote address of the request, whoever the caller is
+func ClientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "addr:" + host
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/madhurikadam/app-transcation/pkg/auth"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 2)
+	limiter.now = func() time.Time {
+		return now
+	}
+
+	handler := RateLimit(limiter, ClientIdentity)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(remoteAddr string, principal *auth.Principal) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/transcations", nil)
+		r.RemoteAddr = remoteAddr
+		if principal != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// the burst goes through, the next request has to wait for a token
+	require.Equal(t, http.StatusNoContent, serve("10.0.0.1:1234", nil).Code)
+	require.Equal(t, http.StatusNoContent, serve("10.0.0.1:4321", nil).Code)
+
+	w := serve("10.0.0.1:1234", nil)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// other clients have their own bucket
+	require.Equal(t, http.StatusNoContent, serve("10.0.0.2:1234", nil).Code)
+	require.Equal(t, http.StatusNoContent, serve("10.0.0.1:1234", &auth.Principal{Subject: "merchant-1"}).Code)
+
+	now = now.Add(time.Second)
+	require.Equal(t, http.StatusNoContent, serve("10.0.0.1:1234", nil).Code)
+}
+
+func TestRateLimitByAddress(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 1)
+	limiter.now = func() time.Time {
+		return now
+	}
+
+	handler := RateLimit(limiter, ClientAddress)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(remoteAddr string, principal *auth.Principal) int {
+		r := httptest.NewRequest(http.MethodPost, "/transcations", nil)
+		r.RemoteAddr = remoteAddr
+		if principal != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	// the subject of the caller doesn't get it a bucket of its own
+	require.Equal(t, http.StatusNoContent, serve("10.0.0.1:1234", &auth.Principal{Subject: "merchant-1"}))
+	require.Equal(t, http.StatusTooManyRequests, serve("10.0.0.1:1234", &auth.Principal{Subject: "merchant-2"}))
+	require.Equal(t, http.StatusNoContent, serve("10.0.0.2:1234", &auth.Principal{Subject: "merchant-1"}))
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(10, 20)
+	limiter.now = func() time.Time {
+		return now
+	}
+
+	require.Zero(t, limiter.Reserve("addr:10.0.0.1"))
+	require.Len(t, limiter.buckets, 1)
+
+	now = now.Add(2 * time.Minute)
+	require.Zero(t, limiter.Reserve("addr:10.0.0.2"))
+	require.Len(t, limiter.buckets, 1)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -14,9 +15,26 @@ import (
 
 type Server struct {
 	*http.Server
+
+	// shutdown is closed once the server starts shutting down, it ends the
+	// requests of streaming routes which would otherwise never go idle
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// Option configures the underlying http.Server
+type Option func(*http.Server)
+
+// WithWriteTimeout overrides the default write timeout of the server, zero
+// disables it. Servers with streaming routes disable it and bound the other
+// routes with Timeout instead.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(srv *http.Server) {
+		srv.WriteTimeout = d
+	}
 }
 
-func New(addr string, h http.Handler) *Server {
+func New(addr string, h http.Handler, opts ...Option) *Server {
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      h2c.NewHandler(h, &http2.Server{}),
@@ -25,7 +43,49 @@ func New(addr string, h http.Handler) *Server {
 		WriteTimeout: time.Duration(5 * time.Second),
 	}
 
-	return &Server{srv}
+	for _, opt := range opts {
+		opt(srv)
+	}
+
+	s := &Server{
+		Server:   srv,
+		shutdown: make(chan struct{}),
+	}
+
+	srv.RegisterOnShutdown(func() {
+		s.shutdownOnce.Do(func() {
+			close(s.shutdown)
+		})
+	})
+
+	return s
+}
+
+// Streaming wraps the handler of a long lived route, its request context is
+// cancelled as soon as the server starts shutting down
+func (s *Server) Streaming(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		go func() {
+			select {
+			case <-s.shutdown:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Timeout is a middleware bounding the time handlers have to write their
+// response, it replaces the server write timeout on non streaming routes
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.TimeoutHandler(h, d, `{"error":"request timed out"}`)
+	}
 }
 
 func (s *Server) Start(ctx context.Context) func() error {
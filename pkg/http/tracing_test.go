@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var handlerSpan trace.SpanContext
+	router := mux.NewRouter()
+	router.Use(Tracing)
+	router.HandleFunc("/accounts/{id:[-0-9a-zA-Z]+}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		if mux.Vars(r)["id"] == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}).Methods(http.MethodGet)
+
+	r := httptest.NewRequest(http.MethodGet, "/accounts/1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/accounts/broken", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	joined := spans[0]
+	require.Equal(t, "GET /accounts/{id}", joined.Name())
+	require.Equal(t, trace.SpanKindServer, joined.SpanKind())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", joined.SpanContext().TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", joined.Parent().SpanID().String())
+	require.True(t, joined.Parent().IsRemote())
+	require.Contains(t, joined.Attributes(), attribute.Int("http.status_code", http.StatusOK))
+	require.Equal(t, codes.Unset, joined.Status().Code)
+
+	// the handler runs in the span of the request
+	require.Equal(t, spans[1].SpanContext().SpanID(), handlerSpan.SpanID())
+
+	failed := spans[1]
+	require.False(t, failed.Parent().IsValid())
+	require.Contains(t, failed.Attributes(), attribute.Int("http.status_code", http.StatusInternalServerError))
+	require.Equal(t, codes.Error, failed.Status().Code)
+}
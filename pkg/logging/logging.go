@@ -0,0 +1,179 @@
+// Package logging configures the service log and carries the logger of the
+// request being served in its context, so that every line it causes can be
+// correlated
+package logging
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/madhurikadam/app-transcation/pkg/requestid"
+)
+
+// formats of the log
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the values never written to the log
+const Redacted = "[REDACTED]"
+
+// redactedFields identify customers, their values are left out of every line
+var redactedFields = map[string]bool{
+	"document_number": true,
+}
+
+// redactedPattern finds the redacted fields written in messages and errors,
+// as json, query strings, key=value pairs or the keys of postgres errors
+var redactedPattern = regexp.MustCompile(`("?document_number"?\)?\s*[:=]\s*\(?"?)[^"&,\s})]+`)
+
+type loggerKey struct{}
+
+// Setup configures the standard logger every package logs with
+func Setup(format, level string) error {
+	formatter, err := NewFormatter(format)
+	if err != nil {
+		return err
+	}
+
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	log.SetFormatter(formatter)
+	log.SetLevel(lvl)
+
+	return nil
+}
+
+// NewFormatter returns the formatter of format, the redacted fields are left
+// out of what it writes
+func NewFormatter(format string) (log.Formatter, error) {
+	switch format {
+	case FormatJSON:
+		return redactingFormatter{&log.JSONFormatter{}}, nil
+	case FormatText:
+		return redactingFormatter{&log.TextFormatter{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, the formats are %s and %s", format, FormatJSON, FormatText)
+	}
+}
+
+// WithFields returns a copy of ctx whose logger carries the fields
+func WithFields(ctx context.Context, fields log.Fields) context.Context {
+	return context.WithValue(ctx, loggerKey{}, entry(ctx).WithFields(fields))
+}
+
+// FromContext returns the logger of ctx, the standard one when there is none.
+// Its lines carry the request id and the trace of ctx.
+func FromContext(ctx context.Context) *log.Entry {
+	fields := log.Fields{}
+	if id := requestid.FromContext(ctx); id != "" {
+		fields["request_id"] = id
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+		fields["span_id"] = sc.SpanID().String()
+	}
+
+	return entry(ctx).WithContext(ctx).WithFields(fields)
+}
+
+func entry(ctx context.Context) *log.Entry {
+	if e, ok := ctx.Value(loggerKey{}).(*log.Entry); ok {
+		return e
+	}
+
+	return log.NewEntry(log.StandardLogger())
+}
+
+// redactingFormatter redacts the fields, message and errors of the entries
+// before formatting them
+type redactingFormatter struct {
+	log.Formatter
+}
+
+func (f redactingFormatter) Format(e *log.Entry) ([]byte, error) {
+	data := make(log.Fields, len(e.Data))
+	for key, value := range e.Data {
+		if redactedFields[key] {
+			data[key] = Redacted
+			continue
+		}
+
+		data[key] = redactValue(value)
+	}
+
+	redacted := *e
+	redacted.Data = data
+	redacted.Message = redactedPattern.ReplaceAllString(e.Message, "${1}"+Redacted)
+
+	return f.Formatter.Format(&redacted)
+}
+
+func redactValue(value interface{}) interface{} {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		return value
+	}
+
+	if !redactedPattern.MatchString(s) {
+		return value
+	}
+
+	return redactedPattern.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// UnaryServerInterceptor writes the access log of the grpc calls, with their
+// status code and latency
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		logCall(ctx, info.FullMethod, err, start)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor writes the access log of the grpc streams once they
+// end, like UnaryServerInterceptor
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+
+		logCall(stream.Context(), info.FullMethod, err, start)
+
+		return err
+	}
+}
+
+func logCall(ctx context.Context, method string, err error, start time.Time) {
+	FromContext(ctx).WithFields(log.Fields{
+		"method":      method,
+		"code":        status.Code(err).String(),
+		"duration_ms": durationMillis(time.Since(start)),
+	}).Info("grpc call")
+}
+
+// durationMillis returns d in milliseconds, with a microsecond precision
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/madhurikadam/app-transcation/pkg/requestid"
+)
+
+// newContext returns a context logging to buf as json
+func newContext(t *testing.T, buf *bytes.Buffer) context.Context {
+	formatter, err := NewFormatter(FormatJSON)
+	require.NoError(t, err)
+
+	logger := log.New()
+	logger.SetOutput(buf)
+	logger.SetFormatter(formatter)
+
+	return context.WithValue(context.Background(), loggerKey{}, log.NewEntry(logger))
+}
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	buf.Reset()
+
+	return line
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := newContext(t, &buf)
+
+	FromContext(ctx).Info("no request")
+	line := decode(t, &buf)
+	require.NotContains(t, line, "request_id")
+	require.NotContains(t, line, "trace_id")
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = requestid.WithID(ctx, "req-1")
+	ctx = WithFields(ctx, log.Fields{"delivery_id": "d-1"})
+
+	FromContext(ctx).WithField("attempts", 2).Warn("webhook delivery failed")
+	line = decode(t, &buf)
+	require.Equal(t, "req-1", line["request_id"])
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["trace_id"])
+	require.Equal(t, "00f067aa0ba902b7", line["span_id"])
+	require.Equal(t, "d-1", line["delivery_id"])
+	require.Equal(t, float64(2), line["attempts"])
+	require.Equal(t, "warning", line["level"])
+	require.Equal(t, "webhook delivery failed", line["msg"])
+}
+
+func TestRedaction(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := newContext(t, &buf)
+
+	FromContext(ctx).
+		WithField("document_number", "12345678900").
+		WithField("body", `{"document_number":"12345678900","owner":"o-1"}`).
+		WithError(errors.New(`duplicate key value violates unique constraint, Key (document_number)=(12345678900) already exists`)).
+		Info("failed to create account with document_number=12345678900, owner=o-1")
+
+	out := buf.String()
+	require.NotContains(t, out, "12345678900")
+
+	line := decode(t, &buf)
+	require.Equal(t, Redacted, line["document_number"])
+	require.Equal(t, `{"document_number":"[REDACTED]","owner":"o-1"}`, line["body"])
+	require.Equal(t, "duplicate key value violates unique constraint, Key (document_number)=([REDACTED]) already exists", line["error"])
+	require.Equal(t, "failed to create account with document_number=[REDACTED], owner=o-1", line["msg"])
+
+	// other fields are left as they are
+	FromContext(ctx).WithField("account_id", "a-1").WithField("amount", 10.5).Info("debit")
+	line = decode(t, &buf)
+	require.Equal(t, "a-1", line["account_id"])
+	require.Equal(t, 10.5, line["amount"])
+}
+
+func TestSetup(t *testing.T) {
+	require.EqualError(t, Setup("xml", "info"), `unknown log format "xml", the formats are json and text`)
+	require.Error(t, Setup(FormatJSON, "loud"))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := requestid.WithID(newContext(t, &buf), "req-1")
+
+	_, err := UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/transcation.v1.TranscationService/GetAccount"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "account not found")
+		})
+	require.Error(t, err)
+
+	line := decode(t, &buf)
+	require.Equal(t, "grpc call", line["msg"])
+	require.Equal(t, "req-1", line["request_id"])
+	require.Equal(t, "/transcation.v1.TranscationService/GetAccount", line["method"])
+	require.Equal(t, codes.NotFound.String(), line["code"])
+	require.Contains(t, line, "duration_ms")
+}
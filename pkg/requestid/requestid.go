@@ -0,0 +1,105 @@
+// Package requestid carries the id of the request being served so that what
+// it caused can be correlated
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Header is the http header of the request id, echoed in the response
+	Header = "X-Request-ID"
+	// MetadataKey is the grpc metadata key of the request id
+	MetadataKey = "x-request-id"
+
+	maxLength = 128
+)
+
+type requestIDKey struct{}
+
+// WithID returns a copy of ctx carrying the request id
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request id of ctx, empty when there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware keeps the request id sent by the client, or assigns one, and
+// echoes it in the response
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := sanitize(r.Header.Get(Header))
+		w.Header().Set(Header, id)
+
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
+
+// UnaryServerInterceptor is the grpc counterpart of Middleware
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := incomingID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+
+		return handler(WithID(ctx, id), req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id := incomingID(stream.Context())
+		_ = stream.SetHeader(metadata.Pairs(MetadataKey, id))
+
+		return handler(srv, &serverStream{ServerStream: stream, ctx: WithID(stream.Context(), id)})
+	}
+}
+
+// incomingID returns the request id sent in the grpc metadata of ctx, or a new
+// one
+func incomingID(ctx context.Context) string {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(MetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+
+	return sanitize(id)
+}
+
+// serverStream overrides the context of a grpc stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// sanitize returns the id sent by the client when it is printable and not too
+// long, a new one otherwise
+func sanitize(id string) string {
+	if id == "" || len(id) > maxLength {
+		return uuid.NewString()
+	}
+
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return uuid.NewString()
+		}
+	}
+
+	return id
+}
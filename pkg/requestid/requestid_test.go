@@ -0,0 +1,44 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+		if id != "" {
+			r.Header.Set(Header, id)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// the id of the client is kept
+	w := serve("req-1")
+	require.Equal(t, "req-1", got)
+	require.Equal(t, "req-1", w.Header().Get(Header))
+
+	// a missing or unusable id is replaced
+	for _, id := range []string{"", "with space", strings.Repeat("a", maxLength+1)} {
+		w := serve(id)
+		require.NotEmpty(t, got)
+		require.NotEqual(t, id, got)
+		require.Equal(t, got, w.Header().Get(Header))
+	}
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{ip: "93.184.216.34", public: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{ip: "127.0.0.1"},
+		{ip: "::1"},
+		{ip: "0.0.0.0"},
+		{ip: "::"},
+		{ip: "10.1.2.3"},
+		{ip: "172.16.0.1"},
+		{ip: "192.168.1.1"},
+		{ip: "fd00:ec2::254"},
+		{ip: "169.254.169.254"},
+		{ip: "fe80::1"},
+		{ip: "100.100.100.200"},
+		{ip: "224.0.0.1"},
+		{ip: "255.255.255.255"},
+		{ip: "::ffff:127.0.0.1"},
+		{ip: "::ffff:169.254.169.254"},
+		{ip: "64:ff9b::a9fe:a9fe"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.public, PublicIP(net.ParseIP(tt.ip)), tt.ip)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// the address is checked when dialing, whatever the url says
+	_, err := NewClient(time.Second).Get(server.URL)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrForbiddenAddress), err)
+}
@@ -0,0 +1,79 @@
+// Package webhook signs and verifies webhook payloads with HMAC-SHA256. The
+// signature is computed over "<unix timestamp>.<body>" so that a captured
+// request cannot be replayed with a different timestamp.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	signatureVersion = "v1"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+)
+
+// Sign returns the signature header value for the body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	return fmt.Sprintf("%s=%s", signatureVersion, hex.EncodeToString(mac(secret, timestamp, body)))
+}
+
+// Verify checks the signature and timestamp headers of a received webhook,
+// timestamps further than tolerance away from now are rejected
+func Verify(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	if tolerance > 0 {
+		diff := now.Sub(time.Unix(ts, 0))
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if diff > tolerance {
+			return ErrInvalidTimestamp
+		}
+	}
+
+	prefix := signatureVersion + "="
+	if !strings.HasPrefix(signature, prefix) {
+		return ErrInvalidSignature
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal(got, mac(secret, ts, body)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret string, timestamp int64, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write(body)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+
+	// computed with: printf '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac whsec_test
+	require.Equal(t, "v1=11bf4466ea17c3df3fd743af0b435368e16b7a05eb8eced85e8c4670767bdec5", Sign("whsec_test", 1700000000, []byte(`{"id":"1"}`)))
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	const secret = "whsec_test"
+	body := []byte(`{"id":"1","amount":10}`)
+	now := time.Unix(1700000000, 0)
+	sent := now.Add(-30 * time.Second)
+	timestamp := strconv.FormatInt(sent.Unix(), 10)
+	signature := Sign(secret, sent.Unix(), body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		tolerance time.Duration
+		wantErr   error
+	}{
+		{name: "valid", secret: secret, timestamp: timestamp, signature: signature, body: body, tolerance: time.Minute},
+		{name: "no tolerance accepts any timestamp", secret: secret, timestamp: timestamp, signature: signature, body: body},
+		{name: "timestamp outside the tolerance", secret: secret, timestamp: timestamp, signature: signature, body: body, tolerance: 10 * time.Second, wantErr: ErrInvalidTimestamp},
+		{name: "timestamp in the future", secret: secret, timestamp: strconv.FormatInt(now.Add(time.Hour).Unix(), 10), signature: Sign(secret, now.Add(time.Hour).Unix(), body), body: body, tolerance: time.Minute, wantErr: ErrInvalidTimestamp},
+		{name: "unparsable timestamp", secret: secret, timestamp: "yesterday", signature: signature, body: body, tolerance: time.Minute, wantErr: ErrInvalidTimestamp},
+		{name: "tampered body", secret: secret, timestamp: timestamp, signature: signature, body: []byte(`{"id":"1","amount":1000}`), tolerance: time.Minute, wantErr: ErrInvalidSignature},
+		{name: "replayed with another timestamp", secret: secret, timestamp: strconv.FormatInt(now.Unix(), 10), signature: signature, body: body, tolerance: time.Minute, wantErr: ErrInvalidSignature},
+		{name: "other secret", secret: "whsec_other", timestamp: timestamp, signature: signature, body: body, tolerance: time.Minute, wantErr: ErrInvalidSignature},
+		{name: "unknown version", secret: secret, timestamp: timestamp, signature: "v2" + signature[2:], body: body, tolerance: time.Minute, wantErr: ErrInvalidSignature},
+		{name: "not hex", secret: secret, timestamp: timestamp, signature: "v1=zz", body: body, tolerance: time.Minute, wantErr: ErrInvalidSignature},
+		{name: "truncated", secret: secret, timestamp: timestamp, signature: signature[:len(signature)-2], body: body, tolerance: time.Minute, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, now, tt.tolerance)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}